@@ -0,0 +1,156 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auth Authentication and role based authorization of the admin API
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/core/config"
+	logic "github.com/ztalab/ZACA/logic/auth"
+)
+
+// ContextKeyIdentity Identity of the caller in the gin context
+const ContextKeyIdentity = "identity"
+
+// Identity Authenticated caller
+type Identity struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Method string `json:"method"`
+}
+
+// Operator Name recorded in the operation logs
+func (i *Identity) Operator() string {
+	return i.Method + ":" + i.Name
+}
+
+// Authenticator Returns a nil identity without error if the request does not carry its credentials
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Auth Authenticators of the admin API, all handlers are no-ops when disabled
+type Auth struct {
+	enabled        bool
+	authenticators []Authenticator
+	logger         *zap.SugaredLogger
+}
+
+// New Build the authenticators enabled in the configuration. Disabling authentication needs insecure
+func New(conf config.APIAuth) (*Auth, error) {
+	a := &Auth{
+		enabled: conf.Enabled,
+		logger:  logger.Named("auth").SugaredLogger,
+	}
+	if !conf.Enabled {
+		if !conf.Insecure {
+			return nil, errors.New("http.auth is disabled, enable it or set http.auth.insecure to serve the admin API unauthenticated")
+		}
+		a.logger.Warn("Admin API authentication is disabled, any caller can revoke certificates and review change requests")
+		return a, nil
+	}
+	if conf.BootstrapTokenHash == "" && !conf.MTLS.Enabled && !conf.OIDC.Enabled {
+		a.logger.Warn("No http.auth.bootstrap-token-hash, mtls or oidc configured, only the API tokens already created are accepted")
+	}
+	a.authenticators = append(a.authenticators, NewTokenAuthenticator(conf.BootstrapTokenHash))
+	if conf.MTLS.Enabled {
+		m, err := NewMTLSAuthenticator(conf.MTLS.Roles)
+		if err != nil {
+			return nil, errors.Wrap(err, "mtls")
+		}
+		a.authenticators = append(a.authenticators, m)
+	}
+	if conf.OIDC.Enabled {
+		o, err := NewOIDCAuthenticator(conf.OIDC)
+		if err != nil {
+			return nil, errors.Wrap(err, "oidc")
+		}
+		a.authenticators = append(a.authenticators, o)
+	}
+	return a, nil
+}
+
+// Authenticate Middleware identifying the caller, the first authenticator recognising the credentials wins
+func (a *Auth) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled {
+			c.Next()
+			return
+		}
+		for _, authenticator := range a.authenticators {
+			id, err := authenticator.Authenticate(c.Request)
+			if err != nil {
+				a.logger.With("path", c.Request.URL.Path, "remote", c.ClientIP()).Warnf("Authentication failed: %s", err)
+				abort(c, http.StatusUnauthorized, "authentication failed")
+				return
+			}
+			if id != nil {
+				c.Set(ContextKeyIdentity, id)
+				c.Set(helper.ContextKeyOperator, id.Operator())
+				c.Next()
+				return
+			}
+		}
+		abort(c, http.StatusUnauthorized, "authentication required")
+	}
+}
+
+// Require Middleware refusing callers without at least the given role
+func (a *Auth) Require(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled {
+			c.Next()
+			return
+		}
+		id, ok := IdentityFromContext(c)
+		if !ok || !logic.RoleAllows(id.Role, role) {
+			abort(c, http.StatusForbidden, "role "+role+" required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// IdentityFromContext ...
+func IdentityFromContext(c *gin.Context) (*Identity, bool) {
+	v, ok := c.Get(ContextKeyIdentity)
+	if !ok {
+		return nil, false
+	}
+	id, ok := v.(*Identity)
+	return id, ok
+}
+
+func abort(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, helper.MSPNormalizeHTTPResponseBody{
+		Code:    int64(status),
+		Message: msg,
+	})
+}
+
+func checkRoles(bindings []config.RoleBinding) error {
+	for _, b := range bindings {
+		if b.Match == "" || !logic.ValidRole(b.Role) {
+			return errors.Errorf("invalid role binding %q: %q", b.Match, b.Role)
+		}
+	}
+	return nil
+}
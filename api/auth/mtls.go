@@ -0,0 +1,56 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+)
+
+// MTLSAuthenticator Client certificates verified by the API listener against the trust bundle of this CA
+type MTLSAuthenticator struct {
+	roles map[string]string
+}
+
+// NewMTLSAuthenticator Bindings match the SPIFFE ID or the unique ID of the certificate
+func NewMTLSAuthenticator(bindings []config.RoleBinding) (*MTLSAuthenticator, error) {
+	if err := checkRoles(bindings); err != nil {
+		return nil, err
+	}
+	m := &MTLSAuthenticator{roles: make(map[string]string, len(bindings))}
+	for _, b := range bindings {
+		m.roles[b.Match] = b.Role
+	}
+	return m, nil
+}
+
+// Authenticate ...
+func (m *MTLSAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	peer, ok := spiffe.PeerIdentityFromContext(r.Context())
+	if !ok {
+		return nil, nil
+	}
+	name := peer.String()
+	role, ok := m.roles[name]
+	if !ok {
+		role, ok = m.roles[peer.UniqueID]
+	}
+	if !ok {
+		return nil, fmt.Errorf("no role granted to %s", name)
+	}
+	return &Identity{Name: name, Role: role, Method: "mtls"}, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ztalab/ZACA/core/config"
+	logic "github.com/ztalab/ZACA/logic/auth"
+	"github.com/ztalab/ZACA/pkg/oidc"
+)
+
+// OIDCAuthenticator Bearer tokens of an OpenID Connect provider, the role comes from a claim
+type OIDCAuthenticator struct {
+	verifier  *oidc.Verifier
+	roleClaim string
+	roles     map[string]string
+}
+
+// NewOIDCAuthenticator ...
+func NewOIDCAuthenticator(conf config.OIDCAuth) (*OIDCAuthenticator, error) {
+	if conf.Issuer == "" || conf.Audience == "" || conf.JWKSURL == "" {
+		return nil, errors.New("issuer, audience and jwks-url are required")
+	}
+	if err := checkRoles(conf.Roles); err != nil {
+		return nil, err
+	}
+	o := &OIDCAuthenticator{
+		verifier: oidc.NewVerifier(oidc.Config{
+			Issuer:   conf.Issuer,
+			Audience: conf.Audience,
+			JWKSURL:  conf.JWKSURL,
+		}),
+		roleClaim: conf.RoleClaim,
+		roles:     make(map[string]string, len(conf.Roles)),
+	}
+	if o.roleClaim == "" {
+		o.roleClaim = "groups"
+	}
+	for _, b := range conf.Roles {
+		o.roles[b.Match] = b.Role
+	}
+	return o, nil
+}
+
+// Authenticate The highest role granted by the values of the role claim is used
+func (o *OIDCAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.HasPrefix(token, logic.TokenPrefix) {
+		return nil, nil
+	}
+	claims, err := o.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	var role string
+	for _, v := range oidc.StringsClaim(claims, o.roleClaim) {
+		if granted, ok := o.roles[v]; ok && logic.RoleAllows(granted, role) {
+			role = granted
+		}
+	}
+	if role == "" {
+		return nil, fmt.Errorf("no role granted to %s", sub)
+	}
+	return &Identity{Name: sub, Role: role, Method: "oidc"}, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	logic "github.com/ztalab/ZACA/logic/auth"
+)
+
+// TokenAuthenticator API tokens stored hashed in the database
+type TokenAuthenticator struct {
+	bootstrapHash string
+	logic         *logic.Logic
+}
+
+// NewTokenAuthenticator bootstrapHash is the hex SHA-256 of an admin token that is not stored in the database
+func NewTokenAuthenticator(bootstrapHash string) *TokenAuthenticator {
+	return &TokenAuthenticator{
+		bootstrapHash: strings.ToLower(bootstrapHash),
+		logic:         logic.NewLogic(),
+	}
+}
+
+// Authenticate ...
+func (t *TokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, ok := bearerToken(r)
+	if !ok || !strings.HasPrefix(token, logic.TokenPrefix) {
+		return nil, nil
+	}
+	if t.bootstrapHash != "" &&
+		subtle.ConstantTimeCompare([]byte(logic.HashToken(token)), []byte(t.bootstrapHash)) == 1 {
+		return &Identity{Name: "bootstrap", Role: logic.RoleAdmin, Method: "token"}, nil
+	}
+	record, err := t.logic.LookupToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.New("unknown or expired API token")
+	}
+	return &Identity{Name: record.Name, Role: record.Role, Method: "token"}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(h[7:]), true
+}
@@ -20,12 +20,16 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ztalab/ZACA/logic/events"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/requestid"
 )
 
 const (
 	// MSPNormalStateHTTPStatusCode ...
 	MSPNormalStateHTTPStatusCode int64 = 1001
+	// ContextKeyOperator Name of the authenticated caller in the gin context
+	ContextKeyOperator = "operator"
 )
 
 // HTTPWrapContext ...
@@ -107,6 +111,28 @@ func (c *HTTPWrapContext) BindG(params interface{}) {
 	}
 }
 
+// Operator Name of the authenticated caller, empty when the API is not authenticated
+func (c *HTTPWrapContext) Operator() string {
+	return c.G.GetString(ContextKeyOperator)
+}
+
+// Origin Caller recorded in the audit log
+func (c *HTTPWrapContext) Origin() events.Origin {
+	return events.Origin{
+		Operator:  c.Operator(),
+		SourceIP:  c.G.ClientIP(),
+		RequestID: requestid.FromRequest(c.G.Request),
+	}
+}
+
+// RequestID Middleware assigning the request ID
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestid.Ensure(c.Writer, c.Request)
+		c.Next()
+	}
+}
+
 // WrapH ...
 func WrapH(h HTTPWrapHandler) func(*gin.Context) {
 	return func(c *gin.Context) {
@@ -120,7 +146,7 @@ func WrapH(h HTTPWrapHandler) func(*gin.Context) {
 		var wrapCtx HTTPWrapContext
 		wrapCtx.G = c
 		var cancel context.CancelFunc
-		wrapCtx.Ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		wrapCtx.Ctx, cancel = context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
 		res, err := h(&wrapCtx)
 		if err != nil {
@@ -19,13 +19,25 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/ztalab/ZACA/api/auth"
 	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/api/v1/approval"
+	"github.com/ztalab/ZACA/api/v1/audit"
+	authAPI "github.com/ztalab/ZACA/api/v1/auth"
 	"github.com/ztalab/ZACA/api/v1/ca"
+	"github.com/ztalab/ZACA/api/v1/cascade"
 	"github.com/ztalab/ZACA/api/v1/certleaf"
+	"github.com/ztalab/ZACA/api/v1/forbid"
 	"github.com/ztalab/ZACA/api/v1/health"
+	"github.com/ztalab/ZACA/api/v1/jobs"
+	"github.com/ztalab/ZACA/api/v1/registry"
 	"github.com/ztalab/ZACA/api/v1/workload"
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/docs"
+	authLogic "github.com/ztalab/ZACA/logic/auth"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func Serve() *gin.Engine {
@@ -33,6 +45,8 @@ func Serve() *gin.Engine {
 	if !core.Is.Config.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	router.Use(helper.RequestID())
+	router.Use(otelgin.Middleware(tracing.ServiceAPI))
 	pprof.Register(router)
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
@@ -45,12 +59,27 @@ func Serve() *gin.Engine {
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 	}
 
+	authn, err := auth.New(core.Is.Config.HTTP.Auth)
+	if err != nil {
+		logger.Fatalf("API authentication configuration error: %v", err)
+	}
+
 	// API V1
 	v1 := router.Group("/api/v1")
 	v1.GET("/health", helper.WrapH(health.Health))
+	if !core.Is.Config.Keymanager.SelfSign {
+		// Cascade revocation from the upper CA, requests are signed with its key
+		prefix := v1.Group("/cascade/upstream")
+		handler := cascade.NewAPI()
+		prefix.POST("/notify", helper.WrapH(handler.UpstreamNotify))
+		prefix.GET("/status", helper.WrapH(handler.UpstreamStatus))
+		prefix.GET("/preview", helper.WrapH(handler.UpstreamPreview))
+		prefix.GET("/topology", helper.WrapH(handler.UpstreamTopology))
+	}
+	v1 = v1.Group("", authn.Authenticate())
 	{
 		// Workload API
-		prefix := v1.Group("/workload")
+		prefix := v1.Group("/workload", authn.Require(authLogic.RoleViewer))
 		handler := workload.NewAPI()
 		prefix.GET("/certs", helper.WrapH(handler.CertList))
 		prefix.GET("/cert", helper.WrapH(handler.CertDetail))
@@ -58,7 +87,7 @@ func Serve() *gin.Engine {
 		prefix.GET("/units_certs_list", helper.WrapH(handler.UnitsCertsList))
 		// Root CA Prohibit operation
 		if !core.Is.Config.Keymanager.SelfSign {
-			lifeCyclePrefix := prefix.Group("/lifecycle")
+			lifeCyclePrefix := prefix.Group("/lifecycle", authn.Require(authLogic.RoleOperator))
 			{
 				lifeCyclePrefix.POST("/revoke", helper.WrapH(handler.RevokeCerts))
 				lifeCyclePrefix.POST("/recover", helper.WrapH(handler.RecoverCerts))
@@ -73,7 +102,7 @@ func Serve() *gin.Engine {
 	}
 	{
 		// CA API
-		prefix := v1.Group("/ca")
+		prefix := v1.Group("/ca", authn.Require(authLogic.RoleViewer))
 		handler := ca.NewAPI()
 		prefix.GET("/role_profiles", helper.WrapH(handler.RoleProfiles))
 		prefix.GET("/workload_units", helper.WrapH(handler.WorkloadUnits))
@@ -82,13 +111,76 @@ func Serve() *gin.Engine {
 		prefix.GET("/overall_certs_count", helper.WrapH(handler.OverallCertsCount))
 		prefix.GET("/overall_expiry_certs", helper.WrapH(handler.OverallExpiryCerts))
 		prefix.GET("/overall_units_enable_status", helper.WrapH(handler.OverallUnitsEnableStatus))
+		if core.Is.Config.Keymanager.ManualEnroll.Enabled {
+			prefix.GET("/enrollment", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.Enrollment))
+			prefix.POST("/enrollment", authn.Require(authLogic.RoleAdmin), helper.WrapH(handler.EnrollmentComplete))
+		}
 	}
 	{
 		// Cert Leaf
-		prefix := v1.Group("/certleaf")
+		prefix := v1.Group("/certleaf", authn.Require(authLogic.RoleViewer))
 		handler := certleaf.NewAPI()
 		prefix.GET("/cert_chain", helper.WrapH(handler.CertChain))
 		prefix.GET("/cert_chain_from_root", helper.WrapH(handler.CertChainFromRoot))
 	}
+	if !core.Is.Config.Keymanager.SelfSign {
+		// Bulk lifecycle jobs
+		prefix := v1.Group("/jobs", authn.Require(authLogic.RoleViewer))
+		handler := jobs.NewAPI()
+		prefix.GET("", helper.WrapH(handler.JobList))
+		prefix.GET("/detail", helper.WrapH(handler.JobDetail))
+		prefix.POST("", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.JobCreate))
+		prefix.POST("/cancel", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.JobCancel))
+	}
+	{
+		// Pattern-based forbid rules
+		prefix := v1.Group("/forbid_rules", authn.Require(authLogic.RoleViewer))
+		handler := forbid.NewAPI()
+		prefix.GET("", helper.WrapH(handler.RuleList))
+		prefix.POST("", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.RuleCreate))
+		prefix.POST("/delete", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.RuleDelete))
+	}
+	{
+		// Cascade revocation of subordinate CAs
+		prefix := v1.Group("/cascade", authn.Require(authLogic.RoleViewer))
+		handler := cascade.NewAPI()
+		prefix.GET("/revocations", helper.WrapH(handler.RevocationList))
+		prefix.GET("/revocation", helper.WrapH(handler.RevocationDetail))
+		prefix.GET("/subordinates", helper.WrapH(handler.Subordinates))
+		prefix.GET("/preview", helper.WrapH(handler.Preview))
+		prefix.GET("/topology", helper.WrapH(handler.Topology))
+		prefix.POST("/revoke", authn.Require(authLogic.RoleAdmin), helper.WrapH(handler.Revoke))
+	}
+	{
+		// Subordinate CAs known from their heartbeats
+		prefix := v1.Group("/registry", authn.Require(authLogic.RoleViewer))
+		handler := registry.NewAPI()
+		prefix.GET("/subordinates", helper.WrapH(handler.SubordinateList))
+		prefix.POST("/subordinates/delete", authn.Require(authLogic.RoleAdmin), helper.WrapH(handler.SubordinateDelete))
+	}
+	{
+		// Four-eyes approval of protected operations
+		prefix := v1.Group("/change_requests", authn.Require(authLogic.RoleViewer))
+		handler := approval.NewAPI()
+		prefix.GET("", helper.WrapH(handler.ChangeRequestList))
+		prefix.GET("/detail", helper.WrapH(handler.ChangeRequestDetail))
+		prefix.POST("/approve", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.ChangeRequestApprove))
+		prefix.POST("/reject", authn.Require(authLogic.RoleOperator), helper.WrapH(handler.ChangeRequestReject))
+	}
+	{
+		// Audit log
+		prefix := v1.Group("/audit", authn.Require(authLogic.RoleOperator))
+		handler := audit.NewAPI()
+		prefix.GET("/events", helper.WrapH(handler.EventList))
+		prefix.GET("/verify", helper.WrapH(handler.Verify))
+	}
+	{
+		// API tokens
+		prefix := v1.Group("/auth", authn.Require(authLogic.RoleAdmin))
+		handler := authAPI.NewAPI()
+		prefix.GET("/tokens", helper.WrapH(handler.TokenList))
+		prefix.POST("/token", helper.WrapH(handler.TokenCreate))
+		prefix.POST("/token/delete", helper.WrapH(handler.TokenDelete))
+	}
 	return router
 }
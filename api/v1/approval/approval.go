@@ -0,0 +1,161 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/auth"
+	"github.com/ztalab/ZACA/api/helper"
+	logic "github.com/ztalab/ZACA/logic/approval"
+	authLogic "github.com/ztalab/ZACA/logic/auth"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// ChangeRequestList Change requests
+// @Tags Approval
+// @Summary Change requests
+// @Description Protected operations and their review, newest first
+// @Produce json
+// @Param status query string false "pending / approved / executing / rejected / expired / executed / failed"
+// @Param operation query string false "unit-revoke / unit-forbid / cascade-revoke / intermediate-sign / policy-change / bulk-job / unit-recover / unit-unforbid"
+// @Param requested_by query string false "Requesting operator"
+// @Param subject query string false "What the operation applies to"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /change_requests [get]
+func (a *API) ChangeRequestList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Status      string `form:"status"`
+		Operation   string `form:"operation"`
+		RequestedBy string `form:"requested_by"`
+		Subject     string `form:"subject"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	list, total, err := a.logic.List(&logic.ListParams{
+		Page:        req.Page,
+		PageSize:    req.LimitNum,
+		Status:      req.Status,
+		Operation:   req.Operation,
+		RequestedBy: req.RequestedBy,
+		Subject:     req.Subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: list,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// ChangeRequestDetail Change request
+// @Tags Approval
+// @Summary Change request
+// @Description Parameters, review and result of a change request
+// @Produce json
+// @Param id query int true "Change request ID"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /change_requests/detail [get]
+func (a *API) ChangeRequestDetail(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		ID uint64 `form:"id" binding:"required"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Get(req.ID)
+}
+
+// ChangeRequestApprove Approve a change request
+// @Tags Approval
+// @Summary Approve
+// @Description Approve a pending request of another operator, the operation executes at once
+// @Produce json
+// @Param body body logic.ReviewParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /change_requests/approve [post]
+func (a *API) ChangeRequestApprove(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.ReviewParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if err := a.checkRole(c, req.ID); err != nil {
+		return http.StatusForbidden, err
+	}
+	return a.logic.Approve(&req)
+}
+
+// ChangeRequestReject Reject a change request
+// @Tags Approval
+// @Summary Reject
+// @Description Reject a pending request of another operator
+// @Produce json
+// @Param body body logic.ReviewParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /change_requests/reject [post]
+func (a *API) ChangeRequestReject(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.ReviewParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if err := a.checkRole(c, req.ID); err != nil {
+		return http.StatusForbidden, err
+	}
+	return a.logic.Reject(&req)
+}
+
+// checkRole Cascade revocations are reviewed by admins, as they are requested
+func (a *API) checkRole(c *helper.HTTPWrapContext, id uint64) error {
+	row, err := a.logic.Get(id)
+	if err != nil || row.Operation != logic.OpCascadeRevoke {
+		return nil
+	}
+	if identity, ok := auth.IdentityFromContext(c.G); ok && !authLogic.RoleAllows(identity.Role, authLogic.RoleAdmin) {
+		return errors.New("role admin required")
+	}
+	return nil
+}
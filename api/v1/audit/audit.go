@@ -0,0 +1,111 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	logic "github.com/ztalab/ZACA/logic/audit"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// EventList Audit events
+// @Tags Audit
+// @Summary Audit events
+// @Description Persisted CA operations, newest first, seq and hash are empty until the event is chained, within seconds
+// @Produce json
+// @Param category query string false "workload_lifecycle / ca"
+// @Param type query string false "sign / revoke / self-revoke / recover / forbid / oscp-sign / keypair-change ..."
+// @Param operator query string false "Operator"
+// @Param unique_id query string false "Unique ID"
+// @Param sn query string false "Certificate serial number"
+// @Param request_id query string false "Request ID"
+// @Param source_ip query string false "Caller address"
+// @Param start_time query string false "Start time"
+// @Param end_time query string false "End time"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /audit/events [get]
+func (a *API) EventList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Category  string `form:"category"`
+		Type      string `form:"type"`
+		Operator  string `form:"operator"`
+		UniqueID  string `form:"unique_id"`
+		SN        string `form:"sn"`
+		RequestID string `form:"request_id"`
+		SourceIP  string `form:"source_ip"`
+		StartTime string `form:"start_time"`
+		EndTime   string `form:"end_time"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	data, err := a.logic.EventList(&logic.EventListParams{
+		Category:  req.Category,
+		Type:      req.Type,
+		Operator:  req.Operator,
+		UniqueID:  req.UniqueID,
+		SN:        req.SN,
+		RequestID: req.RequestID,
+		SourceIP:  req.SourceIP,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Page:      req.Page,
+		PageSize:  req.LimitNum,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: data.List,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    data.Total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// Verify Verify the hash chain of the audit log
+// @Tags Audit
+// @Summary Verify audit log
+// @Description Detects missing or edited audit events
+// @Produce json
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=logic.VerifyResult} " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /audit/verify [get]
+func (a *API) Verify(c *helper.HTTPWrapContext) (interface{}, error) {
+	return a.logic.Verify()
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	logic "github.com/ztalab/ZACA/logic/auth"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// TokenList API tokens
+// @Tags Auth
+// @Summary API tokens
+// @Description API tokens, the token itself is never returned
+// @Produce json
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /auth/tokens [get]
+func (a *API) TokenList(c *helper.HTTPWrapContext) (interface{}, error) {
+	return a.logic.ListTokens()
+}
+
+// TokenCreate Create an API token
+// @Tags Auth
+// @Summary Create token
+// @Description Create an API token, the token is only returned in this response
+// @Produce json
+// @Param body body logic.CreateTokenParams true "role: viewer/operator/admin, ttl in hours"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=logic.CreateTokenResult} " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /auth/token [post]
+func (a *API) TokenCreate(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.CreateTokenParams
+	c.BindG(&req)
+	req.Operator = c.Operator()
+
+	return a.logic.CreateToken(&req)
+}
+
+// TokenDelete Revoke an API token
+// @Tags Auth
+// @Summary Delete token
+// @Description Revoke an API token
+// @Produce json
+// @Param body body logic.DeleteTokenParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /auth/token/delete [post]
+func (a *API) TokenDelete(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.DeleteTokenParams
+	c.BindG(&req)
+	req.Operator = c.Operator()
+
+	if err := a.logic.DeleteToken(&req); err != nil {
+		return nil, err
+	}
+	return "deleted", nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"net/http"
+
+	"github.com/ztalab/ZACA/api/helper"
+	logic "github.com/ztalab/ZACA/logic/ca"
+)
+
+// Enrollment Manual enrollment status
+// @Tags CA
+// @Summary Manual enrollment
+// @Description Status of this CA, while pending the response holds the request to have signed
+// @Produce json
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /ca/enrollment [get]
+func (a *API) Enrollment(c *helper.HTTPWrapContext) (interface{}, error) {
+	return a.logic.Enrollment()
+}
+
+// EnrollmentComplete Import the signed certificate
+// @Tags CA
+// @Summary Complete manual enrollment
+// @Description The certificate must match the pending key and verify to the trusted root
+// @Produce json
+// @Param body body logic.EnrollmentParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Router /ca/enrollment [post]
+func (a *API) EnrollmentComplete(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.EnrollmentParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	enrollment, err := a.logic.CompleteEnrollment(&req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	return enrollment, nil
+}
@@ -49,8 +49,7 @@ type OverallCertsCountResponse struct {
 func (a *API) OverallCertsCount(c *helper.HTTPWrapContext) (interface{}, error) {
 	query := func() *gorm.DB {
 		return core.Is.Db.Session(&gorm.Session{}).Model(&model.Certificates{}).
-			Where("expiry > ?", time.Now()).
-			Where("reason IN ?", []int{0, 2})
+			Where("expiry > ?", time.Now())
 	}
 
 	var total int64
@@ -181,7 +180,7 @@ func getExpiryCountByDuration(period time.Duration, before time.Time) (int64, er
 	query := core.Is.Db.Session(&gorm.Session{}).Model(&model.Certificates{}).
 		Where("expiry > ?", before).
 		Where("expiry < ?", expiryDate).
-		Where("reason = 0").
+		Where("status = ?", "good").
 		Where(`common_name != ""`)
 
 	var count int64
@@ -221,22 +220,22 @@ func (a *API) OverallUnitsEnableStatus(c *helper.HTTPWrapContext) (interface{},
 	res := &OverallUnitsEnableStatus{}
 
 	{
-		if err := query().Where("reason = ?", 0).Count(&res.Enable.CertsCount).Error; err != nil {
+		if err := query().Where("status = ?", "good").Count(&res.Enable.CertsCount).Error; err != nil {
 			a.logger.Errorf("mysql query err: %s", err)
 			return nil, err
 		}
-		if err := query().Where("reason = ?", 2).Count(&res.Disable.CertsCount).Error; err != nil {
+		if err := query().Where("status = ?", "revoked").Count(&res.Disable.CertsCount).Error; err != nil {
 			a.logger.Errorf("mysql query err: %s", err)
 			return nil, err
 		}
 	}
 
 	{
-		if err := query().Where("reason = ?", 0).Group("common_name").Count(&res.Enable.UnitsCount).Error; err != nil {
+		if err := query().Where("status = ?", "good").Group("common_name").Count(&res.Enable.UnitsCount).Error; err != nil {
 			a.logger.Errorf("mysql query err: %s", err)
 			return nil, err
 		}
-		if err := query().Where("reason = ?", 2).Group("common_name").Count(&res.Disable.UnitsCount).Error; err != nil {
+		if err := query().Where("status = ?", "revoked").Group("common_name").Count(&res.Disable.UnitsCount).Error; err != nil {
 			a.logger.Errorf("mysql query err: %s", err)
 			return nil, err
 		}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cascade
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/logic/approval"
+	logic "github.com/ztalab/ZACA/logic/cascade"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// RevocationList Cascade revocations
+// @Tags Cascade
+// @Summary Cascade revocations
+// @Description Sent to subordinate CAs and received from the upper CA, newest first
+// @Produce json
+// @Param direction query string false "downstream / upstream"
+// @Param name query string false "Subordinate CA"
+// @Param status query string false "pending / failed / unreachable / delivered / received / completed"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/revocations [get]
+func (a *API) RevocationList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Direction string `form:"direction"`
+		Name      string `form:"name"`
+		Status    string `form:"status"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	list, total, err := a.logic.List(&logic.ListParams{
+		Page:      req.Page,
+		PageSize:  req.LimitNum,
+		Direction: req.Direction,
+		Name:      req.Name,
+		Status:    req.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: list,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// RevocationDetail Cascade revocation with its progress
+// @Tags Cascade
+// @Summary Cascade revocation
+// @Description Delivery status and the progress reported by the subordinate CA
+// @Produce json
+// @Param id query int true "Cascade revocation ID"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/revocation [get]
+func (a *API) RevocationDetail(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		ID uint64 `form:"id" binding:"required"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Get(req.ID)
+}
+
+// Subordinates Subordinate CAs with their latest cascade revocation
+// @Tags Cascade
+// @Summary Subordinate CAs
+// @Description Configured subordinate CAs and those revoked before
+// @Produce json
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/subordinates [get]
+func (a *API) Subordinates(c *helper.HTTPWrapContext) (interface{}, error) {
+	return a.logic.Subordinates()
+}
+
+// Preview Impact of a cascade revocation
+// @Tags Cascade
+// @Summary Preview cascade revocation
+// @Description Intermediate certificates, units and subordinate CAs that would be revoked
+// @Produce json
+// @Param name query string true "Subordinate CA, OU of its intermediate certificates"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/preview [get]
+func (a *API) Preview(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		Name string `form:"name" binding:"required"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Preview(c.G.Request.Context(), req.Name), nil
+}
+
+// Revoke Revoke a subordinate CA and everything it issued
+// @Tags Cascade
+// @Summary Cascade revoke
+// @Description Revoke the intermediate certificates of a subordinate CA, the subordinate is told to revoke what it issued and stop signing
+// @Produce json
+// @Param body body logic.RevokeParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/revoke [post]
+func (a *API) Revoke(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.RevokeParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpCascadeRevoke) {
+		return approval.NewLogic().Submit(approval.OpCascadeRevoke, req.Name,
+			"Revoke subordinate CA "+req.Name+" and everything it issued", &req, req.Origin)
+	}
+	return a.logic.Revoke(&req)
+}
+
+// UpstreamNotify Cascade revocation sent by the upper CA, signed with its key
+// @Tags Cascade
+// @Summary Upper CA notification
+// @Produce json
+// @Param body body logic.Notification true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 401 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/upstream/notify [post]
+func (a *API) UpstreamNotify(c *helper.HTTPWrapContext) (interface{}, error) {
+	body, err := ioutil.ReadAll(c.G.Request.Body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := logic.Authenticate(c.G.Request, body); err != nil {
+		a.logger.With("ip", c.G.ClientIP()).Warnf("Cascade notification refused: %s", err)
+		return http.StatusUnauthorized, err
+	}
+	var n logic.Notification
+	if err := jsoniter.Unmarshal(body, &n); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return a.logic.Receive(&n, c.Origin())
+}
+
+// UpstreamStatus Progress of a cascade revocation, polled by the upper CA
+// @Tags Cascade
+// @Summary Upper CA status query
+// @Produce json
+// @Param id query int true "Cascade revocation ID at the upper CA"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 401 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/upstream/status [get]
+func (a *API) UpstreamStatus(c *helper.HTTPWrapContext) (interface{}, error) {
+	if err := logic.Authenticate(c.G.Request, nil); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	var req struct {
+		ID uint64 `form:"id" binding:"required"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Status(req.ID)
+}
+
+// UpstreamPreview Impact of revoking keys of this CA, queried by the upper CA
+// @Tags Cascade
+// @Summary Upper CA preview query
+// @Produce json
+// @Param subject_key_ids query []string true "Subject key IDs of the intermediate certificates"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 401 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/upstream/preview [get]
+func (a *API) UpstreamPreview(c *helper.HTTPWrapContext) (interface{}, error) {
+	if err := logic.Authenticate(c.G.Request, nil); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	var req logic.PreviewRequest
+	c.BindG(&req)
+
+	return a.logic.IssuedImpact(c.G.Request.Context(), req.SubjectKeyIDs)
+}
+
+// Topology Tree of the CAs
+// @Tags Cascade
+// @Summary CA topology
+// @Description From the root CA down to every subordinate, with certificate expiry, last heartbeat, issuance rate and status
+// @Produce json
+// @Param refresh query bool false "Ask the subordinates again instead of using the cached tree"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/topology [get]
+func (a *API) Topology(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		Refresh bool `form:"refresh"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Topology(c.G.Request.Context(), req.Refresh)
+}
+
+// UpstreamTopology Subtree of this CA, queried by the upper CA
+// @Tags Cascade
+// @Summary Upper CA topology query
+// @Produce json
+// @Param depth query int false "Levels of subordinates to include"
+// @Param refresh query bool false "Bypass the cache"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 401 {object} helper.HTTPWrapErrorResponse
+// @Router /cascade/upstream/topology [get]
+func (a *API) UpstreamTopology(c *helper.HTTPWrapContext) (interface{}, error) {
+	if err := logic.Authenticate(c.G.Request, nil); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	var req struct {
+		Depth   int  `form:"depth"`
+		Refresh bool `form:"refresh"`
+	}
+	c.BindG(&req)
+	if max := core.Is.Config.Topology.MaxDepth; req.Depth > max || req.Depth < 0 {
+		req.Depth = max
+	}
+
+	return a.logic.Subtree(c.G.Request.Context(), req.Depth, req.Refresh)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forbid
+
+import (
+	"strconv"
+
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/logic/approval"
+	logic "github.com/ztalab/ZACA/logic/forbid"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// RuleList Forbid rules
+// @Tags Forbid
+// @Summary Forbid rules
+// @Description Active pattern-based forbid rules, newest first
+// @Produce json
+// @Param kind query string false "site / cluster / unique_id / unique_id_prefix / unique_id_glob / dns"
+// @Param all query bool false "Include deleted and expired rules"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /forbid_rules [get]
+func (a *API) RuleList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Kind string `form:"kind"`
+		All  bool   `form:"all"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	list, total, err := a.logic.List(&logic.ListParams{
+		Page:     req.Page,
+		PageSize: req.LimitNum,
+		Kind:     req.Kind,
+		All:      req.All,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: list,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// RuleCreate Add a forbid rule
+// @Tags Forbid
+// @Summary Create forbid rule
+// @Description Refuse or shorten certificates of a SPIFFE site, cluster, unique_id prefix or glob, or DNS SAN pattern
+// @Produce json
+// @Param body body logic.CreateParams true "effect: deny / short_ttl"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /forbid_rules [post]
+func (a *API) RuleCreate(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.CreateParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpPolicyChange) {
+		return approval.NewLogic().Submit(approval.OpPolicyChange, req.Kind+":"+req.Pattern,
+			"Add "+req.Kind+" forbid rule "+req.Pattern,
+			&approval.PolicyChange{Action: approval.PolicyCreateRule, Rule: &req}, req.Origin)
+	}
+	return a.logic.Create(&req)
+}
+
+// RuleDelete Remove a forbid rule
+// @Tags Forbid
+// @Summary Delete forbid rule
+// @Produce json
+// @Param body body object true "{\"id\": 1}"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /forbid_rules/delete [post]
+func (a *API) RuleDelete(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		ID uint64 `json:"id" binding:"required"`
+	}
+	c.BindG(&req)
+
+	if approval.Protected(approval.OpPolicyChange) {
+		id := strconv.FormatUint(req.ID, 10)
+		return approval.NewLogic().Submit(approval.OpPolicyChange, "forbid_rule:"+id, "Delete forbid rule "+id,
+			&approval.PolicyChange{Action: approval.PolicyDeleteRule, ID: req.ID}, c.Origin())
+	}
+	if err := a.logic.Delete(req.ID, c.Origin()); err != nil {
+		return nil, err
+	}
+	return "deleted", nil
+}
@@ -14,8 +14,6 @@ limitations under the License.
 package health
 
 import (
-	"crypto/tls"
-	"net/http"
 	"time"
 
 	"github.com/ztalab/ZACA/api/helper"
@@ -36,16 +34,6 @@ type HealthModule struct {
 	State       int    `json:"state"`
 }
 
-var httpClient = &http.Client{
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		IdleConnTimeout: 5 * time.Second,
-	},
-	Timeout: 3 * time.Second,
-}
-
 // Health ...
 func Health(c *helper.HTTPWrapContext) (interface{}, error) {
 	var hm []*HealthModule
@@ -71,7 +59,10 @@ func Health(c *helper.HTTPWrapContext) (interface{}, error) {
 			DisplayName: "RootCA",
 			State:       200,
 		}
-		keymanager.GetKeeper().RootClient.DoWithRetry(func(remote *cfClient.AuthRemote) error {
+		upperClients := keymanager.GetKeeper().RootClient
+		httpClient := upperClients.HTTPClient()
+		httpClient.Timeout = 3 * time.Second
+		upperClients.DoWithRetry(c.Ctx, func(remote *cfClient.AuthRemote) error {
 			caURL := remote.Hosts()[0]
 
 			resp, err := httpClient.Get(caURL + CfsslHealthAPI)
@@ -0,0 +1,145 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobs
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/logic/approval"
+	logic "github.com/ztalab/ZACA/logic/jobs"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// JobList Bulk lifecycle jobs
+// @Tags Jobs
+// @Summary Jobs
+// @Description Bulk lifecycle jobs, newest first
+// @Produce json
+// @Param status query string false "pending / running / succeeded / failed / cancelled"
+// @Param kind query string false "revoke / recover / forbid / reissue"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /jobs [get]
+func (a *API) JobList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Status string `form:"status"`
+		Kind   string `form:"kind"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	list, total, err := a.logic.List(&logic.ListParams{
+		Page:     req.Page,
+		PageSize: req.LimitNum,
+		Status:   req.Status,
+		Kind:     req.Kind,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: list,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// JobDetail Status and progress of a job
+// @Tags Jobs
+// @Summary Job
+// @Description Status and progress, processed out of total
+// @Produce json
+// @Param id query int true "Job ID"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /jobs/detail [get]
+func (a *API) JobDetail(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		ID uint64 `form:"id" binding:"required"`
+	}
+	c.BindG(&req)
+
+	return a.logic.Get(req.ID)
+}
+
+// JobCreate Queue a bulk lifecycle job
+// @Tags Jobs
+// @Summary Create job
+// @Description Revoke, recover, forbid or reissue the certificates of a unit list, SPIFFE cluster, AKI or issuance time range.
+// @Description reissue only revokes the certificates as superseded, the workloads enroll again on their own.
+// @Description revoke, reissue and recover need approval when unit-revoke is protected, forbid when unit-forbid is
+// @Produce json
+// @Param body body logic.CreateParams true "kind: revoke / recover / forbid / reissue"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /jobs [post]
+func (a *API) JobCreate(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.CreateParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if op := approval.JobOperation(req.Kind); op != "" && approval.Protected(op) {
+		if err := req.Selector.Validate(); err != nil {
+			return nil, err
+		}
+		selector, _ := jsoniter.MarshalToString(req.Selector)
+		return approval.NewLogic().Submit(approval.OpBulkJob, req.Kind+" "+selector,
+			"Bulk "+req.Kind+" of "+selector, &req, req.Origin)
+	}
+
+	return a.logic.Create(&req)
+}
+
+// JobCancel Cancel a job
+// @Tags Jobs
+// @Summary Cancel job
+// @Description Pending jobs are cancelled at once, running jobs after the current batch
+// @Produce json
+// @Param body body logic.CancelParams true " "
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /jobs/cancel [post]
+func (a *API) JobCancel(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req logic.CancelParams
+	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	return a.logic.Cancel(&req)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/api/helper"
+	logic "github.com/ztalab/ZACA/logic/registry"
+)
+
+type API struct {
+	logic  *logic.Logic
+	logger *zap.SugaredLogger
+}
+
+func NewAPI() *API {
+	return &API{
+		logic:  logic.NewLogic(),
+		logger: logger.Named("api").SugaredLogger,
+	}
+}
+
+// SubordinateList Registered subordinate CAs
+// @Tags Registry
+// @Summary Subordinate CAs
+// @Description Latest heartbeat of each subordinate CA: SPIFFE site/cluster, version, CA certificate, issuance and revocation counters
+// @Produce json
+// @Param name query string false "OU of the subordinate, partial match"
+// @Param stale query bool false "Only subordinates without heartbeat within the stale period, or only the others"
+// @Param limit_num query int false "Paging parameters, default 20"
+// @Param page query int false "Number of pages, default 1"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody{data=helper.MSPNormalizeList} " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Failure 500 {object} helper.HTTPWrapErrorResponse
+// @Router /registry/subordinates [get]
+func (a *API) SubordinateList(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req = struct {
+		Name  string `form:"name"`
+		Stale string `form:"stale"`
+		helper.MSPNormalizeListPaginateParams
+	}{
+		MSPNormalizeListPaginateParams: helper.DefaultMSPNormalizeListPaginateParams,
+	}
+	c.BindG(&req)
+
+	params := &logic.ListParams{
+		Page:     req.Page,
+		PageSize: req.LimitNum,
+		Name:     req.Name,
+	}
+	if req.Stale != "" {
+		stale, err := strconv.ParseBool(req.Stale)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		params.Stale = &stale
+	}
+	list, total, err := a.logic.List(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := helper.MSPNormalizeList{
+		List: list,
+		Paginate: helper.MSPNormalizePaginate{
+			Total:    total,
+			Current:  req.Page,
+			PageSize: req.LimitNum,
+		},
+	}
+	return result, nil
+}
+
+// SubordinateDelete Forget a subordinate CA
+// @Tags Registry
+// @Summary Delete subordinate
+// @Description Remove a decommissioned subordinate from the registry, it is registered again by its next heartbeat
+// @Produce json
+// @Param body body object true "{\"name\": \"OU of the subordinate\"}"
+// @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
+// @Failure 400 {object} helper.HTTPWrapErrorResponse
+// @Router /registry/subordinates/delete [post]
+func (a *API) SubordinateDelete(c *helper.HTTPWrapContext) (interface{}, error) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	c.BindG(&req)
+
+	if err := a.logic.Delete(req.Name, c.Origin()); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return "deleted", nil
+}
@@ -14,14 +14,17 @@ limitations under the License.
 package workload
 
 import (
+	"strings"
+
 	"github.com/ztalab/ZACA/api/helper"
+	"github.com/ztalab/ZACA/logic/approval"
 	logic "github.com/ztalab/ZACA/logic/workload"
 )
 
 // RevokeCerts revoked certificate
 // @Tags Workload
 // @Summary (p3)Revoke
-// @Description revoked certificate
+// @Description revoked certificate with an RFC 5280 reason, certificateHold can be given a hold_until release time
 // @Produce json
 // @Param body body logic.RevokeCertsParams true "sn+aki / unique_id pick one of two"
 // @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
@@ -31,6 +34,12 @@ import (
 func (a *API) RevokeCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req logic.RevokeCertsParams
 	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpUnitRevoke) {
+		subject, summary := certsSubject(req.UniqueId, req.SN, req.AKI)
+		return approval.NewLogic().Submit(approval.OpUnitRevoke, subject, "Revoke "+summary, &req, req.Origin)
+	}
 
 	err := a.logic.RevokeCerts(&req)
 	if err != nil {
@@ -43,7 +52,7 @@ func (a *API) RevokeCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 // RecoverCerts Restore certificate
 // @Tags Workload
 // @Summary (p3)Recover
-// @Description Restore certificate
+// @Description Restore certificate on certificateHold, other revocations are final
 // @Produce json
 // @Param body body logic.RecoverCertsParams true "sn+aki / unique_id either-or"
 // @Success 200 {object} helper.MSPNormalizeHTTPResponseBody " "
@@ -53,6 +62,12 @@ func (a *API) RevokeCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 func (a *API) RecoverCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req logic.RecoverCertsParams
 	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpUnitRecover) {
+		subject, summary := certsSubject(req.UniqueId, req.SN, req.AKI)
+		return approval.NewLogic().Submit(approval.OpUnitRecover, subject, "Recover "+summary, &req, req.Origin)
+	}
 
 	err := a.logic.RecoverCerts(&req)
 	if err != nil {
@@ -75,6 +90,11 @@ func (a *API) RecoverCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 func (a *API) ForbidNewCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req logic.ForbidNewCertsParams
 	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpUnitForbid) {
+		return submitForbid(&req)
+	}
 
 	err := a.logic.ForbidNewCerts(&req)
 	if err != nil {
@@ -97,6 +117,11 @@ func (a *API) ForbidNewCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 func (a *API) RecoverForbidNewCerts(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req logic.ForbidNewCertsParams
 	c.BindG(&req)
+	req.Origin = c.Origin()
+
+	if approval.Protected(approval.OpUnitUnforbid) {
+		return submitUnforbid(&req)
+	}
 
 	err := a.logic.RecoverForbidNewCerts(&req)
 	if err != nil {
@@ -124,9 +149,15 @@ func (a *API) ForbidUnit(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req ForbidUnitParams
 	c.BindG(&req)
 
-	err := a.logic.ForbidNewCerts(&logic.ForbidNewCertsParams{
+	params := &logic.ForbidNewCertsParams{
 		UniqueIds: []string{req.UniqueID},
-	})
+		Origin:    c.Origin(),
+	}
+	if approval.Protected(approval.OpUnitForbid) {
+		return submitForbid(params)
+	}
+
+	err := a.logic.ForbidNewCerts(params)
 	if err != nil {
 		a.logger.With("req", req).Errorf("Failed to prohibit certificate application: %s", err)
 		return nil, err
@@ -158,9 +189,15 @@ func (a *API) RecoverUnit(c *helper.HTTPWrapContext) (interface{}, error) {
 	var req ForbidUnitParams
 	c.BindG(&req)
 
-	err := a.logic.RecoverForbidNewCerts(&logic.ForbidNewCertsParams{
+	params := &logic.ForbidNewCertsParams{
 		UniqueIds: []string{req.UniqueID},
-	})
+		Origin:    c.Origin(),
+	}
+	if approval.Protected(approval.OpUnitUnforbid) {
+		return submitUnforbid(params)
+	}
+
+	err := a.logic.RecoverForbidNewCerts(params)
 	if err != nil {
 		a.logger.With("req", req).Errorf("Failed to restore the requested certificate: %s", err)
 		return nil, err
@@ -176,3 +213,23 @@ func (a *API) RecoverUnit(c *helper.HTTPWrapContext) (interface{}, error) {
 
 	return "success", nil
 }
+
+// submitForbid Store a unit forbid for approval
+func submitForbid(params *logic.ForbidNewCertsParams) (interface{}, error) {
+	units := strings.Join(params.UniqueIds, ", ")
+	return approval.NewLogic().Submit(approval.OpUnitForbid, units, "Forbid new certificates of "+units, params, params.Origin)
+}
+
+// submitUnforbid Store the lifting of a unit forbid for approval
+func submitUnforbid(params *logic.ForbidNewCertsParams) (interface{}, error) {
+	units := strings.Join(params.UniqueIds, ", ")
+	return approval.NewLogic().Submit(approval.OpUnitUnforbid, units, "Allow new certificates of "+units, params, params.Origin)
+}
+
+// certsSubject Subject and summary of a change request on the certificates of a unit, or on one certificate
+func certsSubject(uniqueID, sn, aki string) (string, string) {
+	if uniqueID != "" {
+		return uniqueID, "the certificates of unit " + uniqueID
+	}
+	return sn + "/" + aki, "certificate " + sn + " (AKI " + aki + ")"
+}
@@ -324,11 +324,9 @@ func (a *API) UnitsCertsList(c *helper.HTTPWrapContext) (interface{}, error) {
 
 	switch {
 	case req.IsForbid == 1:
-		query = query.Where("reason = ?", 2)
+		query = query.Where("status = ?", "revoked")
 	case req.IsForbid == 2:
-		query = query.Where("reason = ?", 0)
-	default:
-		query = query.Where("reason IN ?", []int{0, 2})
+		query = query.Where("status = ?", "good")
 	}
 
 	if len(uniqueIds) == 0 {
@@ -360,11 +358,9 @@ func (a *API) UnitsCertsList(c *helper.HTTPWrapContext) (interface{}, error) {
 
 	switch {
 	case req.IsForbid == 1:
-		query = query.Where("reason = ?", 2)
+		query = query.Where("status = ?", "revoked")
 	case req.IsForbid == 2:
-		query = query.Where("reason = ?", 0)
-	default:
-		query = query.Where("reason IN ?", []int{0, 2})
+		query = query.Where("status = ?", "good")
 	}
 
 	if req.Role != "" {
@@ -0,0 +1,135 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crl
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ztalab/cfssl/api"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/workload"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// A Handler serves the CRL of the certificates issued by this CA,
+// DER encoded or PEM encoded with ?format=pem
+type Handler struct {
+	db       *gorm.DB
+	cache    *memorycacher.Cache
+	validity time.Duration
+	logger   *logger.Logger
+}
+
+// NewHandler returns a new http.Handler that serves the CRL
+func NewHandler() http.Handler {
+	conf := core.Is.Config.Crl
+	cache := memorycacher.New(time.Duration(conf.CacheTime)*time.Minute, memorycacher.NoExpiration, 16)
+	metrics.RegisterCache("crl", cache)
+	return &api.HTTPHandler{
+		Handler: &Handler{
+			db:       core.Is.Db,
+			cache:    cache,
+			validity: time.Duration(conf.Validity) * time.Hour,
+			logger:   logger.Named("crl"),
+		},
+		Methods: []string{"GET"},
+	}
+}
+
+// Handle Respond with the cached CRL, a new one is signed when the cache time is over
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
+	priv, cert, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return err
+	}
+	aki := hex.EncodeToString(cert.SubjectKeyId)
+
+	var der []byte
+	if cached, ok := h.cache.Get(aki); ok {
+		der, _ = cached.([]byte)
+	}
+	if der == nil {
+		entries, err := h.entries(r.Context(), aki)
+		if err != nil {
+			h.logger.Errorf("Revoked certificates acquisition error: %v", err)
+			return err
+		}
+		now := time.Now()
+		// Seconds since the epoch keep the CRL number increasing across instances
+		der, err = revocation.CreateCRL(cert, priv, big.NewInt(now.Unix()), now, now.Add(h.validity), entries)
+		if err != nil {
+			h.logger.Errorf("CRL signature failed: %v", err)
+			return err
+		}
+		h.cache.SetDefault(aki, der)
+		h.logger.With("aki", aki).Infof("CRL signed with %d entries", len(entries))
+	}
+
+	if r.URL.Query().Get("format") == "pem" {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		return pem.Encode(w, &pem.Block{Type: "X509 CRL", Bytes: der})
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Header().Set("Content-Length", strconv.Itoa(len(der)))
+	_, err = w.Write(der)
+	return err
+}
+
+// entries Unexpired revoked certificates of the CA, holds that are over are left out
+func (h *Handler) entries(ctx context.Context, aki string) ([]revocation.Entry, error) {
+	now := time.Now()
+	var certs []*model.Certificates
+	err := h.db.WithContext(ctx).
+		Select("serial_number", "status", "reason", "revoked_at", "invalidity_date", "hold_until").
+		Where("authority_key_identifier = ? AND status = ? AND expiry > ?", aki, "revoked", now).
+		Order("revoked_at").
+		Find(&certs).Error
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]revocation.Entry, 0, len(certs))
+	for _, cert := range certs {
+		if workload.HoldExpired(cert, now) {
+			continue
+		}
+		sn, ok := new(big.Int).SetString(cert.SerialNumber, 10)
+		if !ok {
+			h.logger.With("sn", cert.SerialNumber).Warn("Serial number is not a decimal integer")
+			continue
+		}
+		entry := revocation.Entry{
+			SerialNumber: sn,
+			RevokedAt:    cert.RevokedAt,
+			Reason:       int(cert.Reason.Int64),
+		}
+		if cert.InvalidityDate.Valid {
+			entry.InvalidityDate = &cert.InvalidityDate.Time
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/hook"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/vaultsecret"
+)
+
+// Enrollment status
+const (
+	EnrollmentPending  = "pending"
+	EnrollmentEnrolled = "enrolled"
+)
+
+// PendingKeyPairName db row of the key waiting for its certificate, the certificate column holds the request
+const PendingKeyPairName = "pending"
+
+// ErrNoPendingEnrollment There is no key waiting for a certificate
+var ErrNoPendingEnrollment = errors.New("no pending enrollment")
+
+// Enrollment Manual enrollment of this CA
+type Enrollment struct {
+	Status    string     `json:"status"`
+	CSR       string     `json:"csr,omitempty"`
+	Subject   string     `json:"subject"`
+	SN        string     `json:"sn,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BeginEnrollment Generate the key and the request of this CA unless one is already pending or enrolled
+func (k *Keeper) BeginEnrollment() (*Enrollment, error) {
+	if enrollment, err := k.enrolled(); err != nil || enrollment != nil {
+		return enrollment, err
+	}
+	enrollment, err := k.pendingEnrollment()
+	if err == nil {
+		return enrollment, nil
+	}
+	if !errors.Is(err, ErrNoPendingEnrollment) {
+		return nil, err
+	}
+
+	csrPEM, keyPEM, err := NewIntermediateCSR()
+	if err != nil {
+		return nil, errors.Wrap(err, "key, csr Production error")
+	}
+	pending := &model.SelfKeypair{
+		Name:        PendingKeyPairName,
+		PrivateKey:  sql.NullString{String: string(keyPEM), Valid: true},
+		Certificate: sql.NullString{String: string(csrPEM), Valid: true},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if hook.EnableVaultStorage {
+		pending.PrivateKey = sql.NullString{String: "", Valid: true}
+		if err := core.Is.VaultSecret.StoreCertPEMKey(vaultsecret.CAPendingStoreKey, string(csrPEM), string(keyPEM)); err != nil {
+			k.logger.Errorf("Vault write pending key error: %s", err)
+			return nil, err
+		}
+	}
+	if err := k.DB.Create(pending).Error; err != nil {
+		k.logger.Errorf("Database insert error: %v", err)
+		return nil, err
+	}
+	k.logger.Info("Generated the key of this CA, waiting for the certificate of its request")
+	return k.pendingEnrollment()
+}
+
+// GetEnrollment Status of the manual enrollment
+func (k *Keeper) GetEnrollment() (*Enrollment, error) {
+	if enrollment, err := k.enrolled(); err != nil || enrollment != nil {
+		return enrollment, err
+	}
+	return k.pendingEnrollment()
+}
+
+// CompleteEnrollment Install the certificate of the pending request, certPEM may be followed by the chain.
+// The certificate must match the pending key and verify to trustPEM, or to the root at the end of the chain
+func (k *Keeper) CompleteEnrollment(certPEM, trustPEM []byte) (*Enrollment, error) {
+	keyPEM, _, _, err := k.getPendingPEM()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := k.installKeyPair(keyPEM, certPEM, trustPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.DB.Where("name = ?", PendingKeyPairName).Delete(&model.SelfKeypair{}).Error; err != nil {
+		k.logger.Errorf("Pending key delete error: %v", err)
+	}
+	if hook.EnableVaultStorage {
+		if err := core.Is.VaultSecret.DeleteCertPEM(vaultsecret.CAPendingStoreKey); err != nil {
+			k.logger.Errorf("Vault delete pending key error: %s", err)
+		}
+	}
+	k.logger.With("sn", cert.SerialNumber.String()).Infof("Manual enrollment completed")
+	return k.GetEnrollment()
+}
+
+func (k *Keeper) enrolled() (*Enrollment, error) {
+	keyPair := &model.SelfKeypair{}
+	err := k.DB.Where("name = ?", SelfKeyPairName).Order("id desc").First(keyPair).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cert, err := helpers.ParseCertificatePEM([]byte(keyPair.Certificate.String))
+	if err != nil {
+		return nil, errors.Wrap(err, "certificate of this CA")
+	}
+	return &Enrollment{
+		Status:    EnrollmentEnrolled,
+		Subject:   cert.Subject.String(),
+		SN:        cert.SerialNumber.String(),
+		NotAfter:  &cert.NotAfter,
+		CreatedAt: keyPair.CreatedAt,
+	}, nil
+}
+
+func (k *Keeper) pendingEnrollment() (*Enrollment, error) {
+	_, csrPEM, createdAt, err := k.getPendingPEM()
+	if err != nil {
+		return nil, err
+	}
+	req, err := helpers.ParseCSRPEM(csrPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "pending request")
+	}
+	return &Enrollment{
+		Status:    EnrollmentPending,
+		CSR:       string(csrPEM),
+		Subject:   req.Subject.String(),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (k *Keeper) getPendingPEM() (key, csr []byte, createdAt time.Time, err error) {
+	pending := &model.SelfKeypair{}
+	err = k.DB.Where("name = ?", PendingKeyPairName).Order("id desc").First(pending).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, createdAt, ErrNoPendingEnrollment
+	}
+	if err != nil {
+		return nil, nil, createdAt, err
+	}
+	key, csr = []byte(pending.PrivateKey.String), []byte(pending.Certificate.String)
+	if hook.EnableVaultStorage {
+		csrStr, keyStr, err := core.Is.VaultSecret.GetCertPEMKey(vaultsecret.CAPendingStoreKey)
+		if err != nil {
+			k.logger.Errorf("vault pending key read error: %s", err)
+			return nil, nil, createdAt, err
+		}
+		key, csr = []byte(*keyStr), []byte(*csrStr)
+	}
+	return key, csr, pending.CreatedAt, nil
+}
@@ -14,10 +14,12 @@ limitations under the License.
 package keymanager
 
 import (
+	"context"
 	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
+	"encoding/hex"
 	"math"
 	"time"
 
@@ -25,10 +27,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
 	"github.com/ztalab/ZACA/logic/schema"
 	"github.com/ztalab/ZACA/pkg/influxdb"
 	"github.com/ztalab/ZACA/pkg/logger"
 	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/metrics"
 	"github.com/ztalab/ZACA/pkg/vaultsecret"
 	cfssl_client "github.com/ztalab/cfssl/api/client"
 	"github.com/ztalab/cfssl/helpers"
@@ -68,18 +72,26 @@ func InitKeeper() error {
 	db := core.Is.Db
 	var rootClients UpperClients
 	var err error
-	if !core.Is.Config.Keymanager.SelfSign {
+	switch {
+	case core.Is.Config.Keymanager.SelfSign:
+	case core.Is.Config.Keymanager.Disconnected():
+		// No upper CA to call, calls fail with "No clients available"
+		rootClients = &upperClients{logger: logger.Named("upperca").SugaredLogger}
+	default:
 		rootClients, err = NewUpperClients(core.Is.Config.Keymanager.UpperCa)
 	}
 	if err != nil {
 		return errors.Wrap(err, "upper client Create error")
 	}
+	cache := memorycacher.New(time.Hour, memorycacher.NoExpiration, math.MaxInt64)
+	metrics.RegisterCache("keeper", cache)
 	Std = &Keeper{
 		DB:         db,
 		logger:     logger.Named("keeper"),
-		cache:      memorycacher.New(time.Hour, memorycacher.NoExpiration, math.MaxInt64),
+		cache:      cache,
 		RootClient: rootClients,
 	}
+	registerExpiryCollector()
 	return nil
 }
 
@@ -227,6 +239,16 @@ func (k *Keeper) SetKeyPairPEM(key, cert []byte) error {
 		return err
 	}
 	k.cache.Flush()
+
+	if x509Cert, err := helpers.ParseCertificatePEM(cert); err == nil {
+		events.NewCAKeyPair("keypair-change", events.OperatorSystem, events.KeyPairOp{
+			CommonName: x509Cert.Subject.CommonName,
+			SN:         x509Cert.SerialNumber.String(),
+			AKI:        hex.EncodeToString(x509Cert.AuthorityKeyId),
+			SKI:        hex.EncodeToString(x509Cert.SubjectKeyId),
+			NotAfter:   x509Cert.NotAfter.Format(time.RFC3339),
+		}).Log()
+	}
 	return nil
 }
 
@@ -282,9 +304,27 @@ func (k *Keeper) GetL3CachedTrustCerts() (certs []*x509.Certificate, err error)
 	return certs, nil
 }
 
+// GetTrustCertPool Trust bundle used to verify peers: trust certificates of the upper CA and our own certificate
+func (k *Keeper) GetTrustCertPool() (*x509.CertPool, error) {
+	certs, err := k.GetL3CachedTrustCerts()
+	if err != nil {
+		return nil, err
+	}
+	_, self, err := k.GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	pool.AddCert(self)
+	return pool, nil
+}
+
 // GetRemoteTrustCerts Obtain remote trust certificate (including root certificate and intermediate CA certificate)
 func (k *Keeper) GetRemoteTrustCerts() (certs []*x509.Certificate, err error) {
-	if core.Is.Config.Keymanager.SelfSign {
+	if core.Is.Config.Keymanager.SelfSign || core.Is.Config.Keymanager.Disconnected() {
 		return
 	}
 	reqBytes, _ := jsoniter.Marshal(&info.Req{
@@ -292,7 +332,7 @@ func (k *Keeper) GetRemoteTrustCerts() (certs []*x509.Certificate, err error) {
 	})
 
 	var resp *info.Resp
-	err = k.RootClient.DoWithRetry(func(remote *cfssl_client.AuthRemote) error {
+	err = k.RootClient.DoWithRetry(context.Background(), func(remote *cfssl_client.AuthRemote) error {
 		infoResp, err := remote.Info(reqBytes)
 		if err != nil {
 			return err
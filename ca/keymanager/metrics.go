@@ -0,0 +1,70 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"crypto/x509"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+var caNotAfterDesc = prometheus.NewDesc(
+	"zaca_ca_cert_not_after_timestamp_seconds",
+	"Expiry of this CA certificate (kind self) and of the trusted upper CA certificates (kind trust)",
+	[]string{"kind", "common_name", "sn"}, nil,
+)
+
+// caExpiryCollector Read the cached certificates of the keeper on every scrape
+type caExpiryCollector struct{}
+
+func registerExpiryCollector() {
+	if err := prometheus.Register(caExpiryCollector{}); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			logger.Errorf("CA expiry collector registration error: %v", err)
+		}
+	}
+}
+
+// Describe ...
+func (caExpiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- caNotAfterDesc
+}
+
+// Collect ...
+func (caExpiryCollector) Collect(ch chan<- prometheus.Metric) {
+	k := Std
+	if k == nil {
+		return
+	}
+	if _, cert, err := k.GetCachedSelfKeyPair(); err == nil && cert != nil {
+		ch <- notAfterMetric("self", cert)
+	}
+	if certs, err := k.GetL3CachedTrustCerts(); err == nil {
+		seen := make(map[string]bool, len(certs))
+		for _, cert := range certs {
+			// Duplicate label values fail the whole scrape
+			if sn := cert.SerialNumber.String(); !seen[sn] {
+				seen[sn] = true
+				ch <- notAfterMetric("trust", cert)
+			}
+		}
+	}
+}
+
+func notAfterMetric(kind string, cert *x509.Certificate) prometheus.Metric {
+	return prometheus.MustNewConstMetric(caNotAfterDesc, prometheus.GaugeValue,
+		float64(cert.NotAfter.Unix()), kind, cert.Subject.CommonName, cert.SerialNumber.String())
+}
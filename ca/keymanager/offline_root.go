@@ -0,0 +1,107 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/cli/genkey"
+	"github.com/ztalab/cfssl/csr"
+	"github.com/ztalab/cfssl/helpers"
+)
+
+// NewIntermediateCSR Key and request of this CA, for an offline root to sign
+func NewIntermediateCSR() (csrPEM, keyPEM []byte, err error) {
+	g := &csr.Generator{Validator: genkey.Validator}
+	return g.ProcessRequest(getIntermediateCSRTemplate())
+}
+
+// ImportKeyPair Install the certificate signed by an offline root.
+// certPEM may be followed by the chain, as in the chain file of the ceremony, trustPEM holds the root certificate
+func (k *Keeper) ImportKeyPair(keyPEM, certPEM, trustPEM []byte) error {
+	cert, err := k.installKeyPair(keyPEM, certPEM, trustPEM)
+	if err != nil {
+		return err
+	}
+	k.logger.With("sn", cert.SerialNumber.String()).Infof("Imported the certificate signed by the offline root")
+	return nil
+}
+
+// installKeyPair Check the certificate against the key and the trusted roots before saving them.
+// Without trustPEM the self-signed certificates of the chain are the roots
+func (k *Keeper) installKeyPair(keyPEM, certPEM, trustPEM []byte) (*x509.Certificate, error) {
+	priv, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "key")
+	}
+	certs, err := helpers.ParseCertificatesPEM(certPEM)
+	if err != nil || len(certs) == 0 {
+		return nil, errors.New("certificate: no PEM certificate")
+	}
+	cert := certs[0]
+
+	var trusts []*x509.Certificate
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		if isSelfSigned(c) {
+			trusts = append(trusts, c)
+		} else {
+			intermediates.AddCert(c)
+		}
+	}
+	if len(bytes.TrimSpace(trustPEM)) > 0 {
+		trusts, err = helpers.ParseCertificatesPEM(trustPEM)
+		if err != nil || len(trusts) == 0 {
+			return nil, errors.New("trust: no PEM certificate")
+		}
+	}
+	if len(trusts) == 0 {
+		return nil, errors.New("trust: no root certificate, append it to the chain or pass it separately")
+	}
+
+	pub, ok := priv.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("the key does not belong to the certificate")
+	}
+	if !cert.IsCA {
+		return nil, errors.New("the certificate is not a CA certificate")
+	}
+	roots := x509.NewCertPool()
+	for _, trust := range trusts {
+		roots.AddCert(trust)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "the certificate is not signed by the trusted root")
+	}
+
+	if err := k.saveTrustCerts(trusts); err != nil {
+		return nil, err
+	}
+	if err := k.SetKeyPairPEM(keyPEM, helpers.EncodeCertificatePEM(cert)); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
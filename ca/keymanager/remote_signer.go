@@ -14,7 +14,12 @@ limitations under the License.
 package keymanager
 
 import (
+	"context"
+	"io/ioutil"
+	"time"
+
 	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
 	"github.com/ztalab/ZACA/pkg/logger"
 	cfssl_client "github.com/ztalab/cfssl/api/client"
 	"github.com/ztalab/cfssl/cli/genkey"
@@ -46,6 +51,12 @@ func (ss *RemoteSigner) Run() error {
 		ss.logger.Info("The certificate already exists. Skip the remote signing process")
 		return nil
 	}
+	if core.Is.Config.Keymanager.OfflineRoot {
+		return errors.New("no certificate, have the offline root sign one: zaca ca csr, zaca root sign-csr, zaca ca import")
+	}
+	if core.Is.Config.Keymanager.ManualEnroll.Enabled {
+		return ss.waitEnrollment()
+	}
 	ss.logger.Warn("There is no certificate. You will sign the certificate remotely")
 	g := &csr.Generator{Validator: genkey.Validator}
 	csrBytes, key, err := g.ProcessRequest(getIntermediateCSRTemplate())
@@ -60,7 +71,7 @@ func (ss *RemoteSigner) Run() error {
 	}
 
 	signReqBytes, _ := jsoniter.Marshal(&signReq)
-	err = GetKeeper().RootClient.DoWithRetry(func(remote *cfssl_client.AuthRemote) error {
+	err = GetKeeper().RootClient.DoWithRetry(context.Background(), func(remote *cfssl_client.AuthRemote) error {
 		certResp, err := remote.Sign(signReqBytes)
 		if err != nil {
 			return err
@@ -80,3 +91,33 @@ func (ss *RemoteSigner) Run() error {
 
 	return nil
 }
+
+// waitEnrollment Publish the request of this CA and block until its certificate is imported
+func (ss *RemoteSigner) waitEnrollment() error {
+	conf := core.Is.Config.Keymanager.ManualEnroll
+	enrollment, err := GetKeeper().BeginEnrollment()
+	if err != nil {
+		return err
+	}
+	if enrollment.Status == EnrollmentEnrolled {
+		return nil
+	}
+	if conf.CSRFile != "" {
+		if err := ioutil.WriteFile(conf.CSRFile, []byte(enrollment.CSR), 0644); err != nil {
+			return errors.Wrap(err, "csr-file")
+		}
+	}
+	ss.logger.With("subject", enrollment.Subject, "csr-file", conf.CSRFile).
+		Warnf("Pending enrollment, have the request signed and import the certificate: zaca ca enroll --cert <chain>")
+
+	ticker := time.NewTicker(time.Duration(conf.PollInterval) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		key, cert, _ := GetKeeper().GetDBSelfKeyPairPEM()
+		if key != nil && cert != nil {
+			ss.logger.Info("Certificate imported, leaving the pending enrollment state")
+			return nil
+		}
+	}
+	return nil
+}
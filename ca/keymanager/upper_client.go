@@ -14,73 +14,230 @@ limitations under the License.
 package keymanager
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/ztalab/ZACA/pkg/logger"
 	"github.com/ztalab/cfssl/api/client"
 	"github.com/ztalab/cfssl/auth"
-	"go.uber.org/multierr"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
 	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/pkg/failover"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/tlspolicy"
+	"github.com/ztalab/ZACA/pkg/tracing"
 )
 
 type UpperClients interface {
-	DoWithRetry(f func(*client.AuthRemote) error) error
+	DoWithRetry(ctx context.Context, f func(*client.AuthRemote) error) error
 	AllClients() map[string]*client.AuthRemote
+	// HTTPClient Client for other requests to the upper CAs, with the same TLS verification
+	HTTPClient() *http.Client
+	// ReportHealth Result of a health check of the upper CA at host
+	ReportHealth(host string, err error, latency time.Duration)
+	// Status Circuits and health of the upper CAs
+	Status() []failover.Status
 }
 
 type upperClients struct {
 	// ip to client
-	clients map[string]*client.AuthRemote
-	logger  *zap.SugaredLogger
+	clients   map[string]*client.AuthRemote
+	addrs     map[string]string
+	auth      auth.Provider
+	hmacKey   []byte
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	pool      *failover.Pool
+	logger    *zap.SugaredLogger
+
+	transportOnce sync.Once
+	transport     *http.Transport
 }
 
-func (uc *upperClients) DoWithRetry(f func(*client.AuthRemote) error) error {
+// DoWithRetry Call the upper CAs by health, priority and latency until one succeeds, skipping open circuits.
+// Each call is a child span of ctx and carries its trace context so the upper CA continues the trace
+func (uc *upperClients) DoWithRetry(ctx context.Context, f func(*client.AuthRemote) error) error {
 	if len(uc.clients) == 0 {
 		return errors.New("No clients available")
 	}
-	var errGroup error
-	for _, upperClient := range uc.clients {
+	defer uc.updateCircuitMetrics()
+	return uc.pool.Do(ctx, func(ctx context.Context, host string) error {
+		spanCtx, span := tracing.Start(ctx, "upperca", attribute.String("upperca.host", host))
+		upperClient := uc.newClient(spanCtx, uc.addrs[host])
+		start := time.Now()
 		err := f(upperClient)
-		if err == nil {
-			// success
-			return nil
+		metrics.UpperCARequests.WithLabelValues(host, metrics.Result(err)).Inc()
+		metrics.UpperCADuration.WithLabelValues(host).Observe(metrics.Since(start))
+		tracing.End(span, err)
+		if err != nil {
+			uc.logger.With("upper", upperClient.Hosts()).Warnf("upper ca Execution error: %s", err)
 		}
-		uc.logger.With("upper", upperClient.Hosts()).Warnf("upper ca Execution error: %s", err)
-		multierr.AppendInto(&errGroup, err)
-	}
-	return errGroup
+		return err
+	})
 }
 
 func (uc *upperClients) AllClients() map[string]*client.AuthRemote {
 	return uc.clients
 }
 
+func (uc *upperClients) HTTPClient() *http.Client {
+	uc.transportOnce.Do(func() {
+		tlsConfig := uc.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		uc.transport = &http.Transport{
+			TLSClientConfig:     tlsConfig.Clone(),
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     15 * time.Second,
+		}
+	})
+	return &http.Client{Transport: uc.transport, Timeout: uc.timeout}
+}
+
+func (uc *upperClients) ReportHealth(host string, err error, latency time.Duration) {
+	if uc.pool == nil {
+		return
+	}
+	uc.pool.ReportHealth(host, err, latency)
+	uc.updateCircuitMetrics()
+}
+
+func (uc *upperClients) Status() []failover.Status {
+	if uc.pool == nil {
+		return nil
+	}
+	return uc.pool.Status()
+}
+
+func (uc *upperClients) updateCircuitMetrics() {
+	for _, s := range uc.pool.Status() {
+		open := 0.0
+		if s.State == failover.StateOpen {
+			open = 1
+		}
+		metrics.UpperCACircuitOpen.WithLabelValues(s.Name).Set(open)
+	}
+}
+
+// newClient The request modifier is per client, so traced calls get a client of their own.
+// cfssl modifies the TLS configuration it is given, each client gets a copy
+func (uc *upperClients) newClient(ctx context.Context, addr string) *client.AuthRemote {
+	upperClient := client.NewAuthServer(addr, uc.tlsConfig.Clone(), uc.auth)
+	upperClient.SetRequestTimeout(uc.timeout)
+	// Replay-safe headers on top of the cfssl token, upper CAs without support fall back to the token
+	upperClient.SetReqModifier(func(req *http.Request, body []byte) {
+		tracing.Inject(ctx, req.Header)
+		if err := reqauth.SignHMAC(req, body, uc.hmacKey); err != nil {
+			logger.Errorf("Request signature error: %v", err)
+		}
+	})
+	return upperClient
+}
+
+// newUpperTLSConfig Verify the upper CAs against the pinned bundle and SPKI pins, optionally presenting
+// the certificate of this CA. Without either the configuration is refused, unless insecure is set explicitly
+func newUpperTLSConfig(conf config.UpperCaTLS) (*tls.Config, error) {
+	var getClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	if conf.ClientCert {
+		getClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := GetKeeper().GetCachedTLSKeyPair()
+			if err != nil {
+				// Not signed yet, the first signing request goes without client certificate
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		}
+	}
+
+	pinning := &tlspolicy.Pinning{ServerName: conf.ServerName}
+	if conf.CaFile != "" {
+		data, err := ioutil.ReadFile(conf.CaFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "upper-ca-tls.ca-file")
+		}
+		pinning.Roots = x509.NewCertPool()
+		if !pinning.Roots.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("upper-ca-tls.ca-file %s: no PEM certificate", conf.CaFile)
+		}
+	}
+	pins, err := tlspolicy.ParsePins(conf.SpkiPins)
+	if err != nil {
+		return nil, errors.Wrap(err, "upper-ca-tls.spki-pins")
+	}
+	pinning.Pins = pins
+
+	if !pinning.Enabled() {
+		if !conf.Insecure {
+			return nil, errors.New("upper CA TLS cannot be verified, set keymanager.upper-ca-tls.ca-file or spki-pins")
+		}
+		logger.Warn("Upper CA TLS is not verified, keymanager.upper-ca-tls.insecure is set")
+		return &tls.Config{
+			InsecureSkipVerify:   true, //nolint:gosec
+			GetClientCertificate: getClientCertificate,
+		}, nil
+	}
+	return pinning.ClientConfig(getClientCertificate), nil
+}
+
 func NewUpperClients(adds []string) (UpperClients, error) {
 	if len(adds) == 0 {
 		return nil, errors.New("Upper CA Address configuration error")
 	}
-	ap, err := auth.New(core.Is.Config.Singleca.CfsslConfig.AuthKeys["intermediate"].Key, nil)
+	conf := core.Is.Config.Keymanager
+	authKey := core.Is.Config.Singleca.CfsslConfig.AuthKeys["intermediate"].Key
+	ap, err := auth.New(authKey, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "Auth key Configuration error")
 	}
-	clients := make(map[string]*client.AuthRemote)
+	tlsConfig, err := newUpperTLSConfig(conf.UpperCaTLS)
+	if err != nil {
+		return nil, err
+	}
+	uc := &upperClients{
+		clients:   make(map[string]*client.AuthRemote),
+		addrs:     make(map[string]string),
+		auth:      ap,
+		hmacKey:   reqauth.DecodeAuthKey(authKey),
+		tlsConfig: tlsConfig,
+		timeout:   time.Duration(conf.UpperCaFailover.Timeout) * time.Second,
+		logger:    logger.Named("upperca").SugaredLogger,
+	}
+	var endpoints []failover.Endpoint
 	for _, addr := range adds {
 		upperAddr, err := url.Parse(addr)
 		if err != nil {
 			return nil, errors.Wrap(err, "Upper CA Address resolution error")
 		}
-		upperClient := client.NewAuthServer(addr, &tls.Config{
-			InsecureSkipVerify: true, //nolint:gosec
-		}, ap)
-		clients[upperAddr.Host] = upperClient
+		if _, ok := uc.clients[upperAddr.Host]; !ok {
+			endpoints = append(endpoints, failover.Endpoint{
+				Name:     upperAddr.Host,
+				Priority: conf.UpperCaFailover.Priorities[upperAddr.Host],
+			})
+		}
+		uc.clients[upperAddr.Host] = uc.newClient(context.Background(), addr)
+		uc.addrs[upperAddr.Host] = addr
 	}
-	logger.Infof("Upper CA Client Quantity: %v", len(clients))
-	return &upperClients{
-		clients: clients,
-		logger:  logger.Named("upperca").SugaredLogger,
-	}, nil
+	uc.pool = failover.New(failover.Config{
+		Timeout:          uc.timeout,
+		Retries:          conf.UpperCaFailover.Retries,
+		BackoffBase:      time.Duration(conf.UpperCaFailover.BackoffBase) * time.Millisecond,
+		BackoffMax:       time.Duration(conf.UpperCaFailover.BackoffMax) * time.Millisecond,
+		FailureThreshold: conf.UpperCaFailover.FailureThreshold,
+		OpenDuration:     time.Duration(conf.UpperCaFailover.OpenDuration) * time.Second,
+	}, endpoints)
+	logger.Infof("Upper CA Client Quantity: %v", len(uc.clients))
+	return uc, nil
 }
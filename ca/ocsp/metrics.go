@@ -20,6 +20,7 @@ import (
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/logic/schema"
 	"github.com/ztalab/ZACA/pkg/influxdb"
+	"github.com/ztalab/ZACA/pkg/metrics"
 )
 
 var (
@@ -66,13 +67,18 @@ func CountAll() {
 	}()
 }
 
-func AddMetricsPoint(uniqueID string, hitCache bool, certStatus string) {
+func AddMetricsPoint(uniqueID string, hitCache bool, certStatus string, start time.Time) {
+	cacheStatus := "miss"
+	if hitCache {
+		cacheStatus = "hit"
+	}
+	metrics.OCSPRequests.WithLabelValues(certStatus, cacheStatus).Inc()
+	metrics.OCSPDuration.WithLabelValues(cacheStatus).Observe(metrics.Since(start))
+
 	if !core.Is.Config.Influxdb.Enabled {
 		return
 	}
-	cacheStatus := "miss"
 	if hitCache {
-		cacheStatus = "hit"
 		atomic.AddUint64(&overallOcspCachedCounter, 1)
 	}
 
@@ -22,6 +22,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/ztalab/ZACA/pkg/logger"
 	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/metrics"
 	"github.com/ztalab/cfssl/helpers"
 	"github.com/ztalab/cfssl/hook"
 	"github.com/ztalab/cfssl/ocsp"
@@ -32,6 +33,8 @@ import (
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
 	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/workload"
+	"github.com/ztalab/ZACA/pkg/revocation"
 )
 
 const (
@@ -66,10 +69,12 @@ func NewSharedSources(signer ocsp.Signer) (*SharedSources, error) {
 		return nil, errors.New("database instance not found")
 	}
 	cacheTime := time.Duration(core.Is.Config.Ocsp.CacheTime)
+	cache := memorycacher.New(cacheTime*time.Minute, memorycacher.NoExpiration, math.MaxInt64)
+	metrics.RegisterCache("ocsp", cache)
 	return &SharedSources{
 		DB:         core.Is.Db,
 		Logger:     logger.Named("ocsp-ss").SugaredLogger,
-		Cache:      memorycacher.New(cacheTime*time.Minute, memorycacher.NoExpiration, math.MaxInt64),
+		Cache:      cache,
 		OcspSigner: signer,
 	}, nil
 }
@@ -87,11 +92,12 @@ func (ss *SharedSources) Response(req *stdocsp.Request) ([]byte, http.Header, er
 		return nil, nil, errors.New("request contains no serial")
 	}
 	strSN := sn.String()
+	start := time.Now()
 
 	if cachedResp, ok := ss.Cache.Get(strSN + aki); ok {
 		if resp, ok := cachedResp.([]byte); ok {
 			ss.Logger.With("sn", strSN, "aki", aki).Debugf("ocspResp cache")
-			AddMetricsPoint("", true, CertStatusUnknown)
+			AddMetricsPoint("", true, CertStatusUnknown, start)
 			return resp, nil, nil
 		}
 		ss.Logger.With("sn", strSN, "aki", aki).Errorf("cache Value parsing error")
@@ -102,11 +108,11 @@ func (ss *SharedSources) Response(req *stdocsp.Request) ([]byte, http.Header, er
 	if err := ss.DB.Where("serial_number = ? AND authority_key_identifier = ?", strSN, aki).First(certRecord).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			ss.Logger.With("sn", strSN, "aki", aki).Warnw("Certificate does not exist")
-			AddMetricsPoint("", false, CertStatusNotFound)
+			AddMetricsPoint("", false, CertStatusNotFound, start)
 			return nil, nil, ocsp.ErrNotFound
 		}
 		ss.Logger.With("sn", strSN, "aki", aki).Errorf("Certificate acquisition error: %v", err)
-		AddMetricsPoint("", false, CertStatusServerError)
+		AddMetricsPoint("", false, CertStatusServerError, start)
 		return nil, nil, errors.Wrap(err, "server error")
 	}
 
@@ -122,7 +128,7 @@ func (ss *SharedSources) Response(req *stdocsp.Request) ([]byte, http.Header, er
 	cert, err := helpers.ParseCertificatePEM([]byte(certRecord.Pem))
 	if err != nil {
 		ss.Logger.With("sn", strSN, "aki", aki).Errorf("Certificate PEM parsing error: %v", err)
-		AddMetricsPoint("", false, CertStatusCertParseError)
+		AddMetricsPoint("", false, CertStatusCertParseError, start)
 		return nil, nil, errors.Wrap(err, "cert err")
 	}
 
@@ -132,11 +138,23 @@ func (ss *SharedSources) Response(req *stdocsp.Request) ([]byte, http.Header, er
 		Reason:      int(certRecord.Reason.Int64),
 		RevokedAt:   certRecord.RevokedAt,
 	}
+	// The hold is over even if the release has not run yet
+	if workload.HoldExpired(certRecord, time.Now()) {
+		signReq.Status, signReq.Reason = CertStatusGood, revocation.Unspecified
+	}
+	if signReq.Status == "revoked" && certRecord.InvalidityDate.Valid {
+		ext, err := revocation.InvalidityDateExtension(certRecord.InvalidityDate.Time)
+		if err != nil {
+			ss.Logger.With("sn", strSN, "aki", aki).Errorf("Invalidity date encoding error: %v", err)
+		} else {
+			signReq.Extensions = append(signReq.Extensions, ext)
+		}
+	}
 
 	ocspResp, err := ss.OcspSigner.Sign(*signReq)
 	if err != nil {
 		ss.Logger.With("sn", strSN, "aki", aki).Errorf("OCSP Sign error: %v", err)
-		AddMetricsPoint(cert.Subject.CommonName, false, CertStatusOCSPSignError)
+		AddMetricsPoint(cert.Subject.CommonName, false, CertStatusOCSPSignError, start)
 		return nil, nil, errors.Wrap(err, "internal err")
 	}
 
@@ -150,6 +168,6 @@ func (ss *SharedSources) Response(req *stdocsp.Request) ([]byte, http.Header, er
 
 	ss.Logger.With("sn", strSN, "aki", aki).Infof("OCSP Signature Complete")
 
-	AddMetricsPoint(cert.Subject.CommonName, false, CertStatusGood)
+	AddMetricsPoint(cert.Subject.CommonName, false, CertStatusGood, start)
 	return ocspResp, nil, nil
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry Endpoints of the TLS port called by subordinate CAs, authenticated with the key of their intermediate certificate
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ztalab/cfssl/api"
+	cf_err "github.com/ztalab/cfssl/errors"
+
+	"github.com/ztalab/ZACA/logic/ca"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/registry"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// HeartbeatHandler Records the heartbeats of subordinate CAs
+type HeartbeatHandler struct {
+	logic  *registry.Logic
+	logger *logger.Logger
+}
+
+// NewHeartbeatHandler ...
+func NewHeartbeatHandler() http.Handler {
+	return &api.HTTPHandler{
+		Handler: &HeartbeatHandler{
+			logic:  registry.NewLogic(),
+			logger: logger.Named("registry"),
+		},
+		Methods: []string{"POST"},
+	}
+}
+
+// Handle Authenticate the subordinate and store its heartbeat
+func (h *HeartbeatHandler) Handle(w http.ResponseWriter, r *http.Request) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+
+	cert, err := h.logic.Authenticate(r, body)
+	if err != nil {
+		h.logger.With("sn", r.Header.Get(registry.HeaderSerial), "ip", r.RemoteAddr).Warnf("Heartbeat refused: %v", err)
+		return cf_err.NewBadRequest(err)
+	}
+	var hb registry.Heartbeat
+	if err := json.Unmarshal(body, &hb); err != nil {
+		return cf_err.NewBadRequestString("Unable to parse heartbeat")
+	}
+	row, err := h.logic.Record(r.Context(), cert, &hb, events.OriginFromRequest(r, registry.OperatorSubordinate))
+	if err != nil {
+		return err
+	}
+	return api.SendResponse(w, row)
+}
+
+// TopologyHandler Intermediate CAs signed by this CA, for the subordinates
+type TopologyHandler struct {
+	logic  *registry.Logic
+	ca     *ca.Logic
+	logger *logger.Logger
+}
+
+// NewTopologyHandler Replaces the unauthenticated proxy of the admin API on /api/v1/cap/
+func NewTopologyHandler() http.Handler {
+	return &api.HTTPHandler{
+		Handler: &TopologyHandler{
+			logic:  registry.NewLogic(),
+			ca:     ca.NewLogic(),
+			logger: logger.Named("registry"),
+		},
+		Methods: []string{"GET"},
+	}
+}
+
+// Handle Authenticate the subordinate and list the intermediate CAs
+func (h *TopologyHandler) Handle(w http.ResponseWriter, r *http.Request) error {
+	if _, err := h.logic.Authenticate(r, nil); err != nil {
+		h.logger.With("sn", r.Header.Get(registry.HeaderSerial), "ip", r.RemoteAddr).Warnf("Topology query refused: %v", err)
+		return cf_err.NewBadRequest(err)
+	}
+	list, err := h.ca.IntermediateTopology()
+	if err != nil {
+		return err
+	}
+	return api.SendResponse(w, list)
+}
@@ -18,19 +18,22 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/ztalab/ZACA/pkg/logger"
 	"github.com/ztalab/cfssl/api"
-	"github.com/ztalab/cfssl/certdb"
 	cf_err "github.com/ztalab/cfssl/errors"
 	"github.com/ztalab/cfssl/helpers"
 	"github.com/ztalab/cfssl/hook"
-	"github.com/ztalab/cfssl/ocsp"
 	"gorm.io/gorm"
 
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
 	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/workload"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/revocation"
 	"github.com/ztalab/ZACA/pkg/signature"
 	"github.com/ztalab/ZACA/util"
 )
@@ -38,16 +41,16 @@ import (
 // A Handler accepts requests with a serial number parameter
 // and revokes
 type Handler struct {
-	dbAccessor certdb.Accessor
-	logger     *logger.Logger
+	logger   *logger.Logger
+	verifier *reqauth.Verifier
 }
 
 // NewHandler returns a new http.Handler that handles a revoke request.
-func NewHandler(dbAccessor certdb.Accessor) http.Handler {
+func NewHandler() http.Handler {
 	return &api.HTTPHandler{
 		Handler: &Handler{
-			dbAccessor: dbAccessor,
-			logger:     logger.Named("revoke"),
+			logger:   logger.Named("revoke"),
+			verifier: core.RequestVerifier(),
 		},
 		Methods: []string{"POST"},
 	}
@@ -55,24 +58,39 @@ func NewHandler(dbAccessor certdb.Accessor) http.Handler {
 
 // This type is meant to be unmarshalled from JSON
 type JsonRevokeRequest struct {
-	Serial  string `json:"serial"`
-	AKI     string `json:"authority_key_id"`
-	Reason  string `json:"reason"`
-	Nonce   string `json:"nonce"`
-	Sign    string `json:"sign"`
-	AuthKey string `json:"auth_key"`
-	Profile string `json:"profile"`
+	Serial string `json:"serial"`
+	AKI    string `json:"authority_key_id"`
+	// RFC 5280 reason, default keyCompromise
+	Reason         string     `json:"reason"`
+	InvalidityDate *time.Time `json:"invalidity_date"`
+	HoldUntil      *time.Time `json:"hold_until"`
+	Comment        string     `json:"comment"`
+	Nonce          string     `json:"nonce"`
+	Sign           string     `json:"sign"`
+	Profile        string     `json:"profile"`
 }
 
 // Handle responds to revocation requests. It attempts to revoke
 // a certificate with a given serial number
-func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
+// The request is authenticated with the reqauth headers, signed by the certificate key or HMAC'd with the profile auth key
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.RevokeRequests.WithLabelValues("sdk", metrics.Result(err)).Inc()
+		metrics.RevokeDuration.WithLabelValues("sdk").Observe(metrics.Since(start))
+	}()
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return err
 	}
 	r.Body.Close()
 
+	authReq, replaySafe, err := reqauth.FromHTTP(r, body)
+	if err != nil {
+		return cf_err.NewBadRequest(err)
+	}
+
 	// Default the status to good so it matches the cli
 	var req JsonRevokeRequest
 	err = json.Unmarshal(body, &req)
@@ -85,7 +103,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	certRecord := &model.Certificates{}
-	if err := core.Is.Db.Where("serial_number = ? AND authority_key_identifier = ?", req.Serial, req.AKI).First(certRecord).Error; err != nil {
+	if err := core.Is.Db.WithContext(r.Context()).Where("serial_number = ? AND authority_key_identifier = ?", req.Serial, req.AKI).First(certRecord).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			h.logger.With("sn", req.Serial, "aki", req.AKI).Warn("Certificate does not exist")
 		} else {
@@ -96,7 +114,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 
 	// Get certificate PEM from vault
 	if hook.EnableVaultStorage {
-		pem, err := core.Is.VaultSecret.GetCertPEM(req.Serial)
+		pem, err := core.Is.VaultSecret.WithContext(r.Context()).GetCertPEM(req.Serial)
 		if err != nil {
 			h.logger.With("sn", req.Serial, "aki", req.AKI).Warnf("Vault Get error: %v", err)
 		} else {
@@ -110,24 +128,32 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 		return cf_err.NewBadRequest(err)
 	}
 
-	// TODO Compatible with standard cfssl authentication mode
 	var valid bool
-	if req.AuthKey == "" {
+	switch {
+	case replaySafe && authReq.Scheme == reqauth.SchemeCert:
+		err = h.verifier.VerifySignature(authReq, cert.PublicKey)
+		valid = err == nil
+	case replaySafe:
+		key, ok := core.Is.Config.Singleca.ProfileAuthKey(req.Profile)
+		if !ok {
+			return cf_err.NewBadRequest(errors.New("profile Unspecified"))
+		}
+		err = h.verifier.VerifyHMAC(authReq, reqauth.DecodeAuthKey(key))
+		valid = err == nil
+	case core.Is.Config.Singleca.RequestAuth.AllowLegacy:
+		// Signature over a client chosen nonce, reuse is only refused within the freshness window
 		v := signature.NewVerifier(cert.PublicKey)
 		valid, err = v.Verify([]byte(req.Nonce), req.Sign)
 		if err != nil {
 			h.logger.With("sn", req.Serial, "aki", req.AKI).Warnf("Validation error: %v", err)
 			return cf_err.NewBadRequest(err)
 		}
-	} else {
-		if req.Profile == "" {
-			return cf_err.NewBadRequest(errors.New("profile Unspecified"))
-		}
-		if authKey, ok := core.Is.Config.Singleca.CfsslConfig.AuthKeys[req.Profile]; ok {
-			if authKey.Key == req.AuthKey {
-				valid = true
-			}
+		if valid {
+			err = h.verifier.UseNonce(req.Serial + ":" + req.Nonce)
+			valid = err == nil
 		}
+	default:
+		err = errors.New("legacy authentication is disabled")
 	}
 
 	if !valid {
@@ -135,20 +161,26 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 		return cf_err.NewBadRequest(err)
 	}
 
-	var reasonCode int
-	reasonCode, err = ocsp.ReasonStringToCode("keycompromise")
+	rev, err := workload.NewRevocation(req.Reason, revocation.KeyCompromise, req.InvalidityDate, req.HoldUntil, req.Comment)
 	if err != nil {
-		return cf_err.NewBadRequestString("Invalid reason code")
+		return cf_err.NewBadRequest(err)
+	}
+	// Only a hold can be turned into another revocation
+	if certRecord.Status != "good" &&
+		(certRecord.Reason.Int64 != revocation.CertificateHold || rev.Reason == revocation.CertificateHold) {
+		return cf_err.NewBadRequestString("certificate has already been revoked")
 	}
 
-	// Delete the certificate corresponding to vault
-	if hook.EnableVaultStorage {
-		if err := core.Is.VaultSecret.DeleteCertPEM(req.Serial); err != nil {
+	// Delete the certificate corresponding to vault, held certificates may still be recovered
+	if hook.EnableVaultStorage && rev.Reason != revocation.CertificateHold {
+		if err := core.Is.VaultSecret.WithContext(r.Context()).DeleteCertPEM(req.Serial); err != nil {
 			h.logger.With("sn", req.Serial, "aki", req.AKI).Warnf("Vault Delete error: %v", err)
 		}
 	}
 
-	err = h.dbAccessor.RevokeCertificate(req.Serial, req.AKI, reasonCode)
+	err = core.Is.Db.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		return workload.RevokeBatch(tx, []*model.Certificates{certRecord}, rev)
+	})
 	if err != nil {
 		h.logger.With("sn", req.Serial, "aki", req.AKI).Warnf("Database operation error: %v", err)
 		return err
@@ -156,11 +188,9 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 
 	AddMetricsPoint(cert)
 
-	events.NewWorkloadLifeCycle("self-revoke", events.OperatorSDK, events.CertOp{
-		UniqueId: cert.Subject.CommonName,
-		SN:       req.Serial,
-		AKI:      req.AKI,
-	}).Log()
+	op := rev.CertOp(certRecord)
+	op.UniqueId = cert.Subject.CommonName
+	events.NewWorkloadLifeCycle("self-revoke", events.OperatorSDK, op).WithRequest(r).Log()
 
 	h.logger.With("sn", req.Serial, "aki", req.AKI, "uri", util.GetSanURI(cert)).Info("Workload Active revocation of certificate")
 
@@ -0,0 +1,549 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rootca Offline root CA ceremonies.
+// The root key stays in an encrypted file or on a PKCS#11 token of an offline machine, state is kept in
+// a ceremony directory instead of the database. Every operation writes a transcript signed by the root key,
+// each transcript links to the one before so the directory can be audited as a whole.
+package rootca
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/config"
+	"github.com/ztalab/cfssl/csr"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/initca"
+	"github.com/ztalab/cfssl/signer"
+	"github.com/ztalab/cfssl/signer/local"
+
+	"github.com/ztalab/ZACA/pkg/pkiutil"
+	"github.com/ztalab/ZACA/pkg/revocation"
+	"github.com/ztalab/ZACA/pkg/transcript"
+)
+
+// Files of the ceremony directory
+const (
+	CertFile      = "root.pem"
+	KeyFile       = "root-key.pem"
+	IndexFile     = "index.json"
+	CRLFile       = "root.crl"
+	TranscriptDir = "transcripts"
+	IssuedDir     = "issued"
+	CRLDir        = "crl"
+)
+
+// Operations
+const (
+	OpInit    = "init"
+	OpSignCSR = "sign-csr"
+	OpGenCRL  = "gencrl"
+)
+
+// Index Certificates signed by the root and the CRL number
+type Index struct {
+	CRLNumber int64     `json:"crl_number"`
+	Issued    []*Issued `json:"issued"`
+}
+
+// Issued ...
+type Issued struct {
+	SerialNumber   string     `json:"serial_number"`
+	Subject        string     `json:"subject"`
+	Profile        string     `json:"profile"`
+	NotBefore      time.Time  `json:"not_before"`
+	NotAfter       time.Time  `json:"not_after"`
+	SHA256         string     `json:"sha256"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	Reason         int        `json:"reason,omitempty"`
+	InvalidityDate *time.Time `json:"invalidity_date,omitempty"`
+}
+
+// Ceremony Operations on the root of a ceremony directory
+type Ceremony struct {
+	Dir string
+	Key KeyConfig
+	// Names of the people present, recorded in the transcript
+	Operators []string
+	Version   string
+}
+
+// InitParams ...
+type InitParams struct {
+	CommonName string
+	O          string
+	// cfssl duration, e.g. 175200h
+	Expiry  string
+	KeyAlgo string
+	KeySize int
+}
+
+// Init Create the root key and the self-signed root certificate
+func (c *Ceremony) Init(params *InitParams) (*x509.Certificate, error) {
+	if err := c.checkOperators(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(c.path(CertFile)); err == nil {
+		return nil, errors.Errorf("%s exists, the directory already holds a root", c.path(CertFile))
+	}
+	for _, dir := range []string{c.Dir, c.path(TranscriptDir), c.path(IssuedDir), c.path(CRLDir)} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := c.Key.Generate(params.KeyAlgo, params.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	certPEM, _, err := initca.NewFromSigner(&csr.CertificateRequest{
+		CN:         params.CommonName,
+		Names:      []csr.Name{{O: params.O}},
+		KeyRequest: &csr.KeyRequest{A: params.KeyAlgo, S: params.KeySize},
+		CA:         &csr.CAConfig{Expiry: params.Expiry},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(c.path(CertFile), certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := c.saveIndex(&Index{}); err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]string{CertFile: transcript.Digest(certPEM)}
+	details := map[string]interface{}{
+		"subject":       cert.Subject.String(),
+		"serial_number": cert.SerialNumber.String(),
+		"ski":           hex.EncodeToString(cert.SubjectKeyId),
+		"not_after":     cert.NotAfter,
+		"key_backend":   c.Key.Backend(),
+		"key_algorithm": fmt.Sprintf("%s-%d", params.KeyAlgo, params.KeySize),
+	}
+	if c.Key.Backend() == BackendFile {
+		if data, err := ioutil.ReadFile(c.Key.File); err == nil {
+			outputs[filepath.Base(c.Key.File)] = transcript.Digest(data)
+		}
+	} else {
+		details["pkcs11_token"] = c.Key.PKCS11.TokenLabel
+		details["pkcs11_key_label"] = c.Key.PKCS11.KeyLabel
+	}
+	if err := c.record(key, OpInit, nil, outputs, details); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// SignParams ...
+type SignParams struct {
+	CSR []byte
+	// cfssl configuration holding the signing profile
+	Config  *config.Config
+	Profile string
+	// CRL distribution point written into the certificate, the published location of the root CRL
+	CRLURL       string
+	PermittedDNS []string
+	PermittedURI []string
+}
+
+// SignResult ...
+type SignResult struct {
+	Cert *x509.Certificate
+	// Path of the certificate and of the certificate followed by the root, to import into the subordinate
+	CertFile, ChainFile string
+}
+
+// SignCSR Sign an intermediate CA request under a CA profile of the cfssl configuration
+func (c *Ceremony) SignCSR(params *SignParams) (*SignResult, error) {
+	if err := c.checkOperators(); err != nil {
+		return nil, err
+	}
+	if params.Config == nil || params.Config.Signing == nil {
+		return nil, errors.New("cfssl configuration has no signing section")
+	}
+	profile, ok := params.Config.Signing.Profiles[params.Profile]
+	if !ok {
+		return nil, errors.Errorf("cfssl configuration has no %q profile", params.Profile)
+	}
+	if !profile.CAConstraint.IsCA {
+		return nil, errors.Errorf("profile %q does not issue CA certificates", params.Profile)
+	}
+	req, err := helpers.ParseCSRPEM(params.CSR)
+	if err != nil {
+		return nil, errors.Wrap(err, "certificate request")
+	}
+	if err := req.CheckSignature(); err != nil {
+		return nil, errors.Wrap(err, "certificate request signature")
+	}
+	root, key, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	index, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	signReq := signer.SignRequest{
+		Request:     string(params.CSR),
+		Profile:     params.Profile,
+		CRLOverride: params.CRLURL,
+	}
+	nc := pkiutil.NameConstraints{PermittedDNSDomains: params.PermittedDNS, PermittedURIDomains: params.PermittedURI}
+	if !nc.IsEmpty() {
+		ext, err := pkiutil.BuildNameConstraintsExtension(nc, true)
+		if err != nil {
+			return nil, err
+		}
+		if !profile.ExtensionWhitelist[ext.Id.String()] {
+			return nil, errors.Errorf("profile %q must list %s in allowed_extensions for name constraints", params.Profile, ext.Id)
+		}
+		signReq.Extensions = append(signReq.Extensions, signer.Extension{
+			ID:       config.OID(ext.Id),
+			Critical: ext.Critical,
+			Value:    hex.EncodeToString(ext.Value),
+		})
+	}
+	s, err := local.NewSigner(key, root, signer.DefaultSigAlgo(key), params.Config.Signing)
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := s.Sign(signReq)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	sn := cert.SerialNumber.String()
+	res := &SignResult{
+		Cert:      cert,
+		CertFile:  c.path(IssuedDir, sn+".pem"),
+		ChainFile: c.path(IssuedDir, sn+"-chain.pem"),
+	}
+	rootPEM := helpers.EncodeCertificatePEM(root)
+	if err := writeFile(res.CertFile, certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := writeFile(res.ChainFile, append(append([]byte{}, certPEM...), rootPEM...), 0644); err != nil {
+		return nil, err
+	}
+	index.Issued = append(index.Issued, &Issued{
+		SerialNumber: sn,
+		Subject:      cert.Subject.String(),
+		Profile:      params.Profile,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		SHA256:       transcript.Digest(cert.Raw),
+	})
+	if err := c.saveIndex(index); err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{
+		"subject":       cert.Subject.String(),
+		"serial_number": sn,
+		"profile":       params.Profile,
+		"not_after":     cert.NotAfter,
+	}
+	if params.CRLURL != "" {
+		details["crl_url"] = params.CRLURL
+	}
+	if !nc.IsEmpty() {
+		details["permitted_dns"] = params.PermittedDNS
+		details["permitted_uri"] = params.PermittedURI
+	}
+	err = c.record(key, OpSignCSR,
+		map[string]string{"csr": transcript.Digest(params.CSR)},
+		map[string]string{filepath.Base(res.CertFile): transcript.Digest(certPEM)},
+		details)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Revocation Certificate signed by the root to put on the CRL
+type Revocation struct {
+	SerialNumber   string
+	Reason         int
+	InvalidityDate *time.Time
+}
+
+// CRLParams ...
+type CRLParams struct {
+	Revoke   []Revocation
+	Validity time.Duration
+}
+
+// GenCRL Record the revocations and sign a new root CRL listing every revoked certificate
+func (c *Ceremony) GenCRL(params *CRLParams) ([]byte, error) {
+	if err := c.checkOperators(); err != nil {
+		return nil, err
+	}
+	if params.Validity <= 0 {
+		return nil, errors.New("CRL validity must be positive")
+	}
+	root, key, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	index, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, r := range params.Revoke {
+		if err := revocation.CheckRevokeReason(r.Reason); err != nil {
+			return nil, err
+		}
+		issued := index.find(r.SerialNumber)
+		if issued == nil {
+			return nil, errors.Errorf("serial number %s was not signed by this root", r.SerialNumber)
+		}
+		if issued.RevokedAt != nil {
+			return nil, errors.Errorf("serial number %s is already revoked", r.SerialNumber)
+		}
+		revokedAt := now
+		issued.RevokedAt = &revokedAt
+		issued.Reason = r.Reason
+		issued.InvalidityDate = r.InvalidityDate
+	}
+
+	var entries []revocation.Entry
+	var revoked []string
+	for _, issued := range index.Issued {
+		if issued.RevokedAt == nil || issued.NotAfter.Before(now) {
+			continue
+		}
+		sn, ok := new(big.Int).SetString(issued.SerialNumber, 10)
+		if !ok {
+			return nil, errors.Errorf("invalid serial number %s in the index", issued.SerialNumber)
+		}
+		entries = append(entries, revocation.Entry{
+			SerialNumber:   sn,
+			RevokedAt:      *issued.RevokedAt,
+			Reason:         issued.Reason,
+			InvalidityDate: issued.InvalidityDate,
+		})
+		revoked = append(revoked, issued.SerialNumber)
+	}
+	index.CRLNumber++
+	der, err := revocation.CreateCRL(root, key, big.NewInt(index.CRLNumber), now, now.Add(params.Validity), entries)
+	if err != nil {
+		return nil, err
+	}
+	numbered := fmt.Sprintf("root-%d.crl", index.CRLNumber)
+	if err := writeFile(c.path(CRLDir, numbered), der, 0644); err != nil {
+		return nil, err
+	}
+	if err := writeFile(c.path(CRLFile), der, 0644); err != nil {
+		return nil, err
+	}
+	if err := c.saveIndex(index); err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{
+		"crl_number":  index.CRLNumber,
+		"next_update": now.Add(params.Validity),
+		"revoked":     revoked,
+	}
+	if len(params.Revoke) > 0 {
+		added := make([]string, 0, len(params.Revoke))
+		for _, r := range params.Revoke {
+			added = append(added, r.SerialNumber+":"+revocation.ReasonString(r.Reason))
+		}
+		details["newly_revoked"] = added
+	}
+	err = c.record(key, OpGenCRL, nil, map[string]string{numbered: transcript.Digest(der)}, details)
+	if err != nil {
+		return nil, err
+	}
+	return der, nil
+}
+
+// Verify Check the transcripts against the root certificate, and the root certificate against the init transcript
+func (c *Ceremony) Verify() ([]*transcript.Record, error) {
+	certPEM, err := ioutil.ReadFile(c.path(CertFile))
+	if err != nil {
+		return nil, err
+	}
+	root, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := c.transcripts()
+	if err != nil {
+		return nil, err
+	}
+	if len(signed) == 0 {
+		return nil, errors.New("no transcripts")
+	}
+	records, err := transcript.VerifyChain(signed, root.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if records[0].Operation != OpInit || records[0].Outputs[CertFile] != transcript.Digest(certPEM) {
+		return nil, errors.Errorf("%s is not the certificate created by the init ceremony", CertFile)
+	}
+	return records, nil
+}
+
+// open Root certificate and key, the key must belong to the certificate
+func (c *Ceremony) open() (*x509.Certificate, *Key, error) {
+	certPEM, err := ioutil.ReadFile(c.path(CertFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := c.Key.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, ok := key.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !pub.Equal(root.PublicKey) {
+		key.Close()
+		return nil, nil, errors.New("the key does not belong to the root certificate")
+	}
+	return root, key, nil
+}
+
+// record Append a signed transcript linked to the last one
+func (c *Ceremony) record(key *Key, op string, inputs, outputs map[string]string, details map[string]interface{}) error {
+	signed, err := c.transcripts()
+	if err != nil {
+		return err
+	}
+	prev := transcript.Genesis
+	if len(signed) > 0 {
+		prev = transcript.Digest([]byte(signed[len(signed)-1]))
+	}
+	host, _ := os.Hostname()
+	seq := uint64(len(signed) + 1)
+	jws, err := transcript.Sign(&transcript.Record{
+		Seq:       seq,
+		Operation: op,
+		Time:      time.Now().UTC(),
+		Operators: c.Operators,
+		Host:      host,
+		Version:   c.Version,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Details:   details,
+		Previous:  prev,
+	}, key)
+	if err != nil {
+		return err
+	}
+	return writeFile(c.path(TranscriptDir, fmt.Sprintf("%04d-%s.jws", seq, op)), []byte(jws), 0644)
+}
+
+// transcripts Signed transcripts in order
+func (c *Ceremony) transcripts() ([]string, error) {
+	names, err := filepath.Glob(c.path(TranscriptDir, "*.jws"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	signed := make([]string, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		signed = append(signed, strings.TrimSpace(string(data)))
+	}
+	return signed, nil
+}
+
+func (c *Ceremony) checkOperators() error {
+	if len(c.Operators) == 0 {
+		return errors.New("name the operators present, they are recorded in the transcript")
+	}
+	return nil
+}
+
+func (c *Ceremony) loadIndex() (*Index, error) {
+	data, err := ioutil.ReadFile(c.path(IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, errors.Wrap(err, IndexFile)
+	}
+	return &index, nil
+}
+
+func (c *Ceremony) saveIndex(index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(c.path(IndexFile), data, 0644)
+}
+
+func (c *Ceremony) path(elem ...string) string {
+	return filepath.Join(append([]string{c.Dir}, elem...)...)
+}
+
+func (index *Index) find(sn string) *Issued {
+	for _, issued := range index.Issued {
+		if issued.SerialNumber == sn {
+			return issued
+		}
+	}
+	return nil
+}
+
+// writeFile Replace the file through a rename, an interrupted write leaves the old content
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// EncodeCRLPEM ...
+func EncodeCRLPEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
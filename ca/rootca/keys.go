@@ -0,0 +1,147 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/ztalab/ZACA/pkg/keyfile"
+)
+
+// Key backends
+const (
+	BackendFile   = "file"
+	BackendPKCS11 = "pkcs11"
+)
+
+// KeyConfig Where the root key is kept, a PKCS#11 token when a module is set, an encrypted file otherwise
+type KeyConfig struct {
+	File       string
+	Passphrase []byte
+	PKCS11     PKCS11Config
+}
+
+// PKCS11Config ...
+type PKCS11Config struct {
+	// Path of the PKCS#11 library of the HSM
+	Module     string
+	TokenLabel string
+	PIN        string
+	KeyLabel   string
+}
+
+// Backend ...
+func (c *KeyConfig) Backend() string {
+	if c.PKCS11.Module != "" {
+		return BackendPKCS11
+	}
+	return BackendFile
+}
+
+// Key Root key, Close releases the token session
+type Key struct {
+	crypto.Signer
+	close func() error
+}
+
+// Close ...
+func (k *Key) Close() error {
+	if k.close == nil {
+		return nil
+	}
+	return k.close()
+}
+
+// Generate Create the root key, an existing key is never overwritten
+func (c *KeyConfig) Generate(algo string, size int) (*Key, error) {
+	if c.Backend() == BackendPKCS11 {
+		return generatePKCS11(&c.PKCS11, algo, size)
+	}
+	if _, err := os.Stat(c.File); err == nil {
+		return nil, errors.Errorf("key file %s exists", c.File)
+	}
+	priv, err := generateKey(algo, size)
+	if err != nil {
+		return nil, err
+	}
+	data, err := keyfile.Encrypt(priv, c.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &Key{Signer: priv}, nil
+}
+
+// Load Open the root key
+func (c *KeyConfig) Load() (*Key, error) {
+	if c.Backend() == BackendPKCS11 {
+		return loadPKCS11(&c.PKCS11)
+	}
+	data, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := keyfile.Decrypt(data, c.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Signer: priv}, nil
+}
+
+func generateKey(algo string, size int) (crypto.Signer, error) {
+	switch algo {
+	case "rsa":
+		if size < 2048 {
+			return nil, errors.Errorf("RSA key size %d is below 2048", size)
+		}
+		return rsa.GenerateKey(rand.Reader, size)
+	case "ecdsa":
+		curve, err := ecdsaCurve(size)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+	return nil, errors.Errorf("unsupported key algorithm %q", algo)
+}
+
+func ecdsaCurve(size int) (elliptic.Curve, error) {
+	switch size {
+	case 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, errors.Errorf("unsupported ECDSA key size %d", size)
+}
@@ -0,0 +1,97 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import (
+	"crypto/rand"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/pkg/errors"
+)
+
+func configurePKCS11(conf *PKCS11Config) (*crypto11.Context, error) {
+	if conf.KeyLabel == "" {
+		return nil, errors.New("pkcs11: key label is required")
+	}
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       conf.Module,
+		TokenLabel: conf.TokenLabel,
+		Pin:        conf.PIN,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "pkcs11")
+	}
+	return ctx, nil
+}
+
+// generatePKCS11 The key pair is created on the token and never leaves it
+func generatePKCS11(conf *PKCS11Config, algo string, size int) (*Key, error) {
+	ctx, err := configurePKCS11(conf)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ctx.FindKeyPair(nil, []byte(conf.KeyLabel))
+	if err == nil && existing != nil {
+		ctx.Close()
+		return nil, errors.Errorf("pkcs11: token holds a key labelled %q", conf.KeyLabel)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	var signer crypto11.Signer
+	switch algo {
+	case "rsa":
+		if size < 2048 {
+			ctx.Close()
+			return nil, errors.Errorf("RSA key size %d is below 2048", size)
+		}
+		signer, err = ctx.GenerateRSAKeyPairWithLabel(id, []byte(conf.KeyLabel), size)
+	case "ecdsa":
+		curve, cerr := ecdsaCurve(size)
+		if cerr != nil {
+			ctx.Close()
+			return nil, cerr
+		}
+		signer, err = ctx.GenerateECDSAKeyPairWithLabel(id, []byte(conf.KeyLabel), curve)
+	default:
+		err = errors.Errorf("unsupported key algorithm %q", algo)
+	}
+	if err != nil {
+		ctx.Close()
+		return nil, errors.Wrap(err, "pkcs11")
+	}
+	return &Key{Signer: signer, close: ctx.Close}, nil
+}
+
+func loadPKCS11(conf *PKCS11Config) (*Key, error) {
+	ctx, err := configurePKCS11(conf)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ctx.FindKeyPair(nil, []byte(conf.KeyLabel))
+	if err != nil {
+		ctx.Close()
+		return nil, errors.Wrap(err, "pkcs11")
+	}
+	if signer == nil {
+		ctx.Close()
+		return nil, errors.Errorf("pkcs11: no key labelled %q", conf.KeyLabel)
+	}
+	return &Key{Signer: signer, close: ctx.Close}, nil
+}
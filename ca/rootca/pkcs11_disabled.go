@@ -0,0 +1,30 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rootca
+
+import "github.com/pkg/errors"
+
+// errNoPKCS11 PKCS#11 needs cgo, which the default static build leaves out
+var errNoPKCS11 = errors.New("built without PKCS#11 support, rebuild with CGO_ENABLED=1 go build -tags pkcs11")
+
+func generatePKCS11(*PKCS11Config, string, int) (*Key, error) {
+	return nil, errNoPKCS11
+}
+
+func loadPKCS11(*PKCS11Config) (*Key, error) {
+	return nil, errNoPKCS11
+}
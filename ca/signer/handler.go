@@ -14,25 +14,34 @@ limitations under the License.
 package signer
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/ztalab/cfssl/api"
 	"github.com/ztalab/cfssl/auth"
 	"github.com/ztalab/cfssl/bundler"
+	"github.com/ztalab/cfssl/config"
 	"github.com/ztalab/cfssl/errors"
 	"github.com/ztalab/cfssl/helpers"
 	"github.com/ztalab/cfssl/hook"
 	"github.com/ztalab/cfssl/log"
 	"github.com/ztalab/cfssl/signer"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/ztalab/ZACA/core"
-	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/approval"
+	"github.com/ztalab/ZACA/logic/cascade"
 	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/forbid"
+	"github.com/ztalab/ZACA/pkg/reqauth"
 	"github.com/ztalab/ZACA/pkg/spiffe"
+	"github.com/ztalab/ZACA/pkg/tracing"
 )
 
 // NoBundlerMessage is used to alert the user that the server does not have a bundler initialized.
@@ -194,8 +203,9 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) error {
 
 // An AuthHandler verifies and signs incoming signature requests.
 type AuthHandler struct {
-	signer  signer.Signer
-	bundler *bundler.Bundler
+	signer   signer.Signer
+	bundler  *bundler.Bundler
+	verifier *reqauth.Verifier
 }
 
 // NewAuthHandlerFromSigner creates a new AuthHandler from the signer
@@ -228,7 +238,8 @@ func NewAuthHandlerFromSigner(signer signer.Signer) (http.Handler, error) {
 
 	return &api.HTTPHandler{
 		Handler: &AuthHandler{
-			signer: signer,
+			signer:   signer,
+			verifier: core.RequestVerifier(),
 		},
 		Methods: []string{"POST"},
 	}, nil
@@ -242,9 +253,18 @@ func (h *AuthHandler) SetBundler(caBundleFile, intBundleFile string) (err error)
 
 // Handle receives the incoming request, validates it, and processes it.
 // Process signed certificate requests for authentication
-func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
+// Requests signed with the reqauth headers carry the sign request as body,
+// legacy cfssl requests wrap it in an auth.AuthenticatedRequest.
+func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) (err error) {
 	log.Info("signature request received")
 
+	var aReq auth.AuthenticatedRequest
+	var req jsonSignRequest
+	start := time.Now()
+	defer func() {
+		observeSign(h.signer, req.Profile, start, err)
+	}()
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Errorf("failed to read response body: %v", err)
@@ -252,18 +272,37 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer r.Body.Close()
 
-	var aReq auth.AuthenticatedRequest
-	err = json.Unmarshal(body, &aReq)
+	authReq, replaySafe, err := reqauth.FromHTTP(r, body)
 	if err != nil {
-		log.Errorf("failed to unmarshal authenticated request: %v", err)
 		return errors.NewBadRequest(err)
 	}
 
-	var req jsonSignRequest
-	err = json.Unmarshal(aReq.Request, &req)
-	if err != nil {
-		log.Errorf("failed to unmarshal request from authenticated request: %v", err)
-		return errors.NewBadRequestString("Unable to parse authenticated sign request")
+	if replaySafe {
+		// Clients built on the cfssl AuthRemote still wrap the sign request
+		signBody := body
+		if json.Unmarshal(body, &aReq) == nil && len(aReq.Request) > 0 {
+			signBody = aReq.Request
+		}
+		if err := json.Unmarshal(signBody, &req); err != nil {
+			log.Errorf("failed to unmarshal sign request: %v", err)
+			return errors.NewBadRequestString("Unable to parse sign request")
+		}
+	} else {
+		if !core.Is.Config.Singleca.RequestAuth.AllowLegacy {
+			log.Warning("received legacy authenticated request")
+			return errors.NewBadRequestString("legacy authentication is disabled")
+		}
+		err = json.Unmarshal(body, &aReq)
+		if err != nil {
+			log.Errorf("failed to unmarshal authenticated request: %v", err)
+			return errors.NewBadRequest(err)
+		}
+
+		err = json.Unmarshal(aReq.Request, &req)
+		if err != nil {
+			log.Errorf("failed to unmarshal request from authenticated request: %v", err)
+			return errors.NewBadRequestString("Unable to parse authenticated sign request")
+		}
 	}
 
 	// Sanity checks to ensure that we have a valid policy. This
@@ -285,7 +324,15 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	validAuth := false
-	if profile.Provider.Verify(&aReq) {
+	if replaySafe {
+		if key, ok := core.Is.Config.Singleca.ProfileAuthKey(req.Profile); ok {
+			if err := h.verifier.VerifyHMAC(authReq, reqauth.DecodeAuthKey(key)); err != nil {
+				log.Warningf("received authenticated request failing verification: %v", err)
+			} else {
+				validAuth = true
+			}
+		}
+	} else if profile.Provider.Verify(&aReq) {
 		validAuth = true
 	} else if profile.PrevProvider != nil && profile.PrevProvider.Verify(&aReq) {
 		validAuth = true
@@ -302,30 +349,47 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Can audit apply for certificate
-	// Query whether the DB is marked with uniqueID to prohibit application
-	for _, signHost := range signReq.Hosts {
-		id, err := spiffe.ParseIDGIdentity(signHost)
-		if err != nil {
-			continue
+	if err := checkForbidden(r, &signReq, profile); err != nil {
+		return err
+	}
+	if err := checkRevokedByUpper(r); err != nil {
+		return err
+	}
+
+	// Name constraints: intermediates get permitted subtrees, leaves must stay inside our own
+	if profile.CAConstraint.IsCA {
+		if err := applyNameConstraints(&signReq, profile); err != nil {
+			log.Warningf("intermediate name constraints: %v", err)
+			return err
 		}
+	} else if err := checkSelfNameConstraints(&signReq); err != nil {
+		log.Warningf("request violates CA name constraints: %v", err)
+		return errors.NewBadRequestString(err.Error())
+	}
 
-		if id.UniqueID != "" {
-			query := core.Is.Db.Where("unique_id = ?", id.UniqueID).
-				Where("deleted_at IS NULL")
-			record, err := dao.GetForbid(query)
-			if err == nil && record != nil {
-				events.NewWorkloadLifeCycle("forbid-sign", events.OperatorSDK, events.CertOp{
-					UniqueId: id.UniqueID,
-				}).Log()
-				return errors.NewBadRequestString("unique_id forbidden for signing certs")
-			}
+	// Remember the profile, renewal reissues with the same one
+	signReq.Metadata = withProfileMetadata(signReq.Metadata, req.Profile)
+
+	// Intermediate CAs may need the approval of an operator, the subordinate retries until it is given.
+	// The approval is claimed by this signing only
+	var approved *model.ChangeRequest
+	if profile.CAConstraint.IsCA {
+		if approved, err = checkApproval(r, &signReq, req.Profile); err != nil {
+			return err
 		}
 	}
 
 	// CFSSL In the issuing logic, if the certificate storage mode is vault, the database flag bit is added, and the certificate PEM is not actually stored
+	_, span := tracing.Start(r.Context(), "cfssl.sign", attribute.String("profile", req.Profile))
 	cert, err := h.signer.Sign(signReq)
+	tracing.End(span, err)
 	if err != nil {
 		log.Errorf("signature failed: %v", err)
+		if approved != nil {
+			if err := approval.NewLogic().SignFailed(approved, err); err != nil {
+				log.Errorf("change request %d not updated: %v", approved.ID, err)
+			}
+		}
 		return err
 	}
 
@@ -333,7 +397,7 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 
 	// After the certificate is issued, it is added and stored in the vault
 	if hook.EnableVaultStorage {
-		if err := core.Is.VaultSecret.StoreCertPEM(x509Cert.SerialNumber.String(), string(cert)); err != nil {
+		if err := core.Is.VaultSecret.WithContext(r.Context()).StoreCertPEM(x509Cert.SerialNumber.String(), string(cert)); err != nil {
 			core.Is.Logger.Errorf("vault store err: %s", err)
 			return err
 		}
@@ -347,7 +411,12 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 			UniqueId: x509Cert.Subject.CommonName,
 			SN:       x509Cert.SerialNumber.String(),
 			AKI:      hex.EncodeToString(x509Cert.AuthorityKeyId),
-		}).Log()
+		}).WithRequest(r).Log()
+	}
+	if approved != nil && x509Cert != nil {
+		if err := approval.NewLogic().SignExecuted(approved, x509Cert.SerialNumber.String()); err != nil {
+			log.Errorf("change request %d not updated: %v", approved.ID, err)
+		}
 	}
 
 	result := map[string]interface{}{"certificate": string(cert)}
@@ -367,3 +436,86 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) error {
 	log.Info("wrote response")
 	return api.SendResponse(w, result)
 }
+
+// checkForbidden Match the SANs of the certificate against the cached forbid rules: deny refuses the request,
+// short_ttl caps the validity of the certificate
+func checkForbidden(r *http.Request, signReq *signer.SignRequest, profile *config.SigningProfile) error {
+	hosts, err := effectiveHosts(signReq)
+	if err != nil {
+		return errors.NewBadRequest(err)
+	}
+	decision, err := forbid.GetCache().Match(r.Context(), hosts)
+	if err != nil {
+		log.Errorf("forbid rules error: %v", err)
+		return errors.NewBadRequestString("forbid rules unavailable")
+	}
+	if decision == nil {
+		return nil
+	}
+	op := events.CertOp{Comment: decision.Rule.Reason}
+	if id, err := spiffe.ParseIDGIdentity(decision.Host); err == nil {
+		op.UniqueId = id.UniqueID
+	}
+	if decision.Deny() {
+		events.NewWorkloadLifeCycle("forbid-sign", events.OperatorSDK, op).WithRequest(r).Log()
+		if decision.Rule.Reason != "" {
+			return errors.NewBadRequestString("forbidden for signing certs: " + decision.Rule.Reason)
+		}
+		return errors.NewBadRequestString("forbidden for signing certs")
+	}
+	if profile.Expiry > decision.Rule.MaxTTL {
+		signReq.NotAfter = time.Now().Add(decision.Rule.MaxTTL)
+		log.Infof("forbid rule %d caps %s to %s", decision.Rule.ID, decision.Host, decision.Rule.MaxTTL)
+	}
+	return nil
+}
+
+// checkApproval Approved change request for signing the intermediate CA, when intermediate signing is protected
+func checkApproval(r *http.Request, signReq *signer.SignRequest, profile string) (*model.ChangeRequest, error) {
+	if !approval.Protected(approval.OpIntermediateSign) {
+		return nil, nil
+	}
+	csr, err := helpers.ParseCSRPEM([]byte(signReq.Request))
+	if err != nil {
+		return nil, errors.NewBadRequestString("Unable to parse certificate request")
+	}
+	hosts, err := effectiveHosts(signReq)
+	if err != nil {
+		return nil, errors.NewBadRequest(err)
+	}
+	sum := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+	params := &approval.IntermediateSign{
+		Profile:         profile,
+		CommonName:      csr.Subject.CommonName,
+		OU:              csr.Subject.OrganizationalUnit,
+		Hosts:           hosts,
+		PublicKeySHA256: hex.EncodeToString(sum[:]),
+	}
+	row, err := approval.NewLogic().SignApproval(params, events.OriginFromRequest(r, events.OperatorSDK))
+	if err != nil {
+		log.Warningf("intermediate CA signing not approved: %v", err)
+		return nil, errors.NewBadRequestString(err.Error())
+	}
+	return row, nil
+}
+
+// checkRevokedByUpper Refuse to sign once the upper CA revoked our certificate
+func checkRevokedByUpper(r *http.Request) error {
+	blocked, err := cascade.SigningBlocked(r.Context())
+	if err != nil {
+		log.Errorf("cascade revocation query error: %v", err)
+		return errors.NewBadRequestString("cascade revocation query error")
+	}
+	if blocked {
+		return errors.NewBadRequestString("CA certificate has been revoked by the upper CA")
+	}
+	return nil
+}
+
+func withProfileMetadata(metadata map[string]interface{}, profile string) map[string]interface{} {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata[MetadataProfile] = profile
+	return metadata
+}
@@ -21,6 +21,8 @@ import (
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/logic/schema"
 	"github.com/ztalab/ZACA/pkg/influxdb"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/cfssl/signer"
 )
 
 var overallSignCounter uint64
@@ -64,3 +66,16 @@ func AddMetricsPoint(cert *x509.Certificate) {
 		},
 	})
 }
+
+// observeSign Record a sign or renew request, profiles unknown to the policy share one label
+func observeSign(s signer.Signer, profile string, start time.Time, err error) {
+	label := "default"
+	if profile != "" {
+		label = "unknown"
+		if policy := s.Policy(); policy != nil && policy.Profiles[profile] != nil {
+			label = profile
+		}
+	}
+	metrics.SignRequests.WithLabelValues(label, metrics.Result(err)).Inc()
+	metrics.SignDuration.WithLabelValues(label).Observe(metrics.Since(start))
+}
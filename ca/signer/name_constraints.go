@@ -0,0 +1,180 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signer
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ztalab/cfssl/config"
+	"github.com/ztalab/cfssl/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/log"
+	"github.com/ztalab/cfssl/signer"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/pkg/pkiutil"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+)
+
+// applyNameConstraints Add permitted subtrees to a CA signing request
+// The constraints are derived from the SPIFFE site/cluster OU of the CSR and the allow-list registered for the subordinate
+func applyNameConstraints(req *signer.SignRequest, profile *config.SigningProfile) error {
+	ncConf := core.Is.Config.Keymanager.NameConstraints
+	if !ncConf.Enabled || !profile.CAConstraint.IsCA {
+		return nil
+	}
+
+	csr, err := helpers.ParseCSRPEM([]byte(req.Request))
+	if err != nil {
+		return errors.NewBadRequestString("Unable to parse certificate request")
+	}
+	if pkiutil.HasNameConstraintsExtension(csr.Extensions) {
+		return errors.NewBadRequestString("certificate request must not carry name constraints")
+	}
+
+	var nc pkiutil.NameConstraints
+	for _, ou := range csr.Subject.OrganizationalUnit {
+		if id, err := spiffe.ParseIDGIdentity(ou); err == nil {
+			nc.PermittedURIDomains = appendUnique(nc.PermittedURIDomains, id.SiteID)
+		}
+		for _, sub := range ncConf.Subordinates {
+			if sub.Ou != ou {
+				continue
+			}
+			for _, d := range sub.PermittedDNS {
+				nc.PermittedDNSDomains = appendUnique(nc.PermittedDNSDomains, d)
+			}
+			for _, u := range sub.PermittedURI {
+				nc.PermittedURIDomains = appendUnique(nc.PermittedURIDomains, u)
+			}
+		}
+	}
+	if nc.IsEmpty() {
+		log.Warningf("no name constraints derivable for intermediate, OU: %v", csr.Subject.OrganizationalUnit)
+		return errors.NewBadRequestString("no name constraints registered for this subordinate")
+	}
+
+	// Constraints of a subordinate may not be wider than our own
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return err
+	}
+	if err := checkConstraintsWithin(self, nc); err != nil {
+		return errors.NewBadRequest(err)
+	}
+
+	ext, err := pkiutil.BuildNameConstraintsExtension(nc, ncConf.Critical)
+	if err != nil {
+		return errors.NewBadRequest(err)
+	}
+	req.Extensions = append(req.Extensions, signer.Extension{
+		ID:       config.OID(ext.Id),
+		Critical: ext.Critical,
+		Value:    hex.EncodeToString(ext.Value),
+	})
+	return nil
+}
+
+// checkSelfNameConstraints Refuse to issue leaves outside of the constraints of the CA certificate
+// X.509 URI constraints only cover the trust domain, so the SPIFFE cluster is checked against our own OU as well,
+// a cluster CA can only issue identities of its own cluster.
+func checkSelfNameConstraints(req *signer.SignRequest) error {
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return err
+	}
+	if self == nil {
+		return nil
+	}
+	hosts, err := effectiveHosts(req)
+	if err != nil {
+		return err
+	}
+	if err := pkiutil.CheckNameConstraints(self, hosts); err != nil {
+		return err
+	}
+	// Only CA certificates constrained by the upper CA are bound to their cluster
+	if core.Is.Config.Keymanager.SelfSign || len(self.PermittedURIDomains) == 0 {
+		return nil
+	}
+	var selfID *spiffe.IDGIdentity
+	for _, ou := range self.Subject.OrganizationalUnit {
+		if id, err := spiffe.ParseIDGIdentity(ou); err == nil {
+			selfID = id
+			break
+		}
+	}
+	if selfID == nil || selfID.ClusterID == "" {
+		return nil
+	}
+	for _, host := range hosts {
+		id, err := spiffe.ParseIDGIdentity(host)
+		if err != nil {
+			continue
+		}
+		if id.SiteID != selfID.SiteID || id.ClusterID != selfID.ClusterID {
+			return fmt.Errorf("identity %s is outside of CA cluster %s", host, selfID.String())
+		}
+	}
+	return nil
+}
+
+// effectiveHosts SANs of the certificate to issue: cfssl keeps those of the CSR when the request has no hosts
+func effectiveHosts(req *signer.SignRequest) ([]string, error) {
+	if req.Hosts != nil {
+		return req.Hosts, nil
+	}
+	csr, err := helpers.ParseCSRPEM([]byte(req.Request))
+	if err != nil {
+		return nil, errors.NewBadRequestString("Unable to parse certificate request")
+	}
+	hosts := append([]string{}, csr.DNSNames...)
+	for _, ip := range csr.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	hosts = append(hosts, csr.EmailAddresses...)
+	for _, u := range csr.URIs {
+		hosts = append(hosts, u.String())
+	}
+	return hosts, nil
+}
+
+func checkConstraintsWithin(self *x509.Certificate, nc pkiutil.NameConstraints) error {
+	if self == nil {
+		return nil
+	}
+	for _, d := range nc.PermittedURIDomains {
+		if err := pkiutil.CheckNameConstraints(self, []string{"spiffe://" + d}); err != nil {
+			return err
+		}
+	}
+	for _, d := range nc.PermittedDNSDomains {
+		if err := pkiutil.CheckNameConstraints(self, []string{d}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
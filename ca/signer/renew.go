@@ -0,0 +1,330 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signer
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ztalab/cfssl/api"
+	"github.com/ztalab/cfssl/bundler"
+	"github.com/ztalab/cfssl/config"
+	"github.com/ztalab/cfssl/csr"
+	"github.com/ztalab/cfssl/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/hook"
+	"github.com/ztalab/cfssl/signer"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/tracing"
+	"github.com/ztalab/ZACA/util"
+)
+
+const (
+	// MetadataProfile Profile the certificate was issued with
+	MetadataProfile = "profile"
+	// MetadataRenewedFrom Serial number of the certificate that was renewed
+	MetadataRenewedFrom = "renewed_from"
+)
+
+// A RenewHandler reissues a certificate to a workload authenticated by its current certificate.
+// The workload proves possession of a new key with the CSR, identity and profile are taken from the current certificate.
+type RenewHandler struct {
+	signer  signer.Signer
+	bundler *bundler.Bundler
+	logger  *logger.Logger
+}
+
+// NewRenewHandlerFromSigner creates a new RenewHandler from the signer
+func NewRenewHandlerFromSigner(signer signer.Signer) (http.Handler, error) {
+	if signer.Policy() == nil {
+		return nil, errors.New(errors.PolicyError, errors.InvalidPolicy)
+	}
+	return &api.HTTPHandler{
+		Handler: &RenewHandler{
+			signer: signer,
+			logger: logger.Named("renew"),
+		},
+		Methods: []string{"POST"},
+	}, nil
+}
+
+// SetBundler allows injecting an optional Bundler into the Handler.
+func (h *RenewHandler) SetBundler(caBundleFile, intBundleFile string) (err error) {
+	h.bundler, err = bundler.NewBundler(caBundleFile, intBundleFile)
+	return err
+}
+
+type jsonRenewRequest struct {
+	Request string `json:"certificate_request"`
+	Bundle  bool   `json:"bundle"`
+}
+
+// Handle Verify the client certificate of the TLS connection and issue a new certificate for the same identity
+func (h *RenewHandler) Handle(w http.ResponseWriter, r *http.Request) (err error) {
+	var profileName string
+	start := time.Now()
+	defer func() {
+		observeSign(h.signer, profileName, start, err)
+	}()
+
+	current, err := h.verifyPeer(r)
+	if err != nil {
+		h.logger.Warnf("Renewal client authentication failed: %v", err)
+		return errors.NewBadRequestString("a valid client certificate is required")
+	}
+	sn := current.SerialNumber.String()
+	aki := hex.EncodeToString(current.AuthorityKeyId)
+	log := h.logger.With("sn", sn, "aki", aki, "uri", util.GetSanURI(current))
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	var req jsonRenewRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errors.NewBadRequestString("Unable to parse renew request")
+	}
+	if req.Request == "" {
+		return errors.NewBadRequestString("missing parameter 'certificate_request'")
+	}
+
+	certReq, err := helpers.ParseCSRPEM([]byte(req.Request))
+	if err != nil {
+		return errors.NewBadRequestString("Unable to parse certificate request")
+	}
+
+	record := &model.Certificates{}
+	if err := core.Is.Db.WithContext(r.Context()).Where("serial_number = ? AND authority_key_identifier = ?", sn, aki).First(record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn("Renewed certificate does not exist")
+			return errors.NewBadRequestString("certificate was not issued by this CA")
+		}
+		log.Errorf("Certificate acquisition error: %v", err)
+		return err
+	}
+
+	metadata, err := checkRenewal(current, certReq, record)
+	if err != nil {
+		log.Warnf("Renewal refused: %v", err)
+		return err
+	}
+	profileName, _ = metadata[MetadataProfile].(string)
+	profile, err := renewProfile(h.signer.Policy(), profileName)
+	if err != nil {
+		log.Warnf("Renewal refused: %v", err)
+		return err
+	}
+
+	signReq := signer.SignRequest{
+		Hosts:    certHosts(current),
+		Request:  req.Request,
+		Subject:  certSubject(current),
+		Profile:  profileName,
+		Metadata: metadata,
+	}
+	if err := checkForbidden(r, &signReq, profile); err != nil {
+		return err
+	}
+	if err := checkRevokedByUpper(r); err != nil {
+		return err
+	}
+	if err := checkSelfNameConstraints(&signReq); err != nil {
+		log.Warnf("request violates CA name constraints: %v", err)
+		return errors.NewBadRequestString(err.Error())
+	}
+
+	_, span := tracing.Start(r.Context(), "cfssl.sign", attribute.String("profile", profileName))
+	cert, err := h.signer.Sign(signReq)
+	tracing.End(span, err)
+	if err != nil {
+		log.Errorf("signature failed: %v", err)
+		return err
+	}
+
+	x509Cert, _ := helpers.ParseCertificatePEM(cert)
+
+	if hook.EnableVaultStorage {
+		if err := core.Is.VaultSecret.WithContext(r.Context()).StoreCertPEM(x509Cert.SerialNumber.String(), string(cert)); err != nil {
+			log.Errorf("vault store err: %s", err)
+			return err
+		}
+	}
+
+	AddMetricsPoint(x509Cert)
+
+	if x509Cert != nil {
+		events.NewWorkloadLifeCycle("renew", events.OperatorSDK, events.CertOp{
+			UniqueId:    x509Cert.Subject.CommonName,
+			SN:          x509Cert.SerialNumber.String(),
+			AKI:         hex.EncodeToString(x509Cert.AuthorityKeyId),
+			RenewedFrom: sn,
+		}).WithRequest(r).Log()
+	}
+
+	result := map[string]interface{}{"certificate": string(cert)}
+	if req.Bundle {
+		if h.bundler == nil {
+			return api.SendResponseWithMessage(w, result, NoBundlerMessage,
+				errors.New(errors.PolicyError, errors.InvalidRequest).ErrorCode)
+		}
+
+		bundle, err := h.bundler.BundleFromPEMorDER(cert, nil, bundler.Optimal, "")
+		if err != nil {
+			return err
+		}
+
+		result["bundle"] = bundle
+	}
+	log.Info("Certificate renewed")
+	return api.SendResponse(w, result)
+}
+
+// verifyPeer Depending on the TLS policy the listener may only request the client certificate,
+// the chain is always verified here against the trust bundle
+func (h *RenewHandler) verifyPeer(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, errors.NewBadRequestString("no client certificate")
+	}
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	roots, err := keymanager.GetKeeper().GetTrustCertPool()
+	if err != nil {
+		return nil, err
+	}
+	peer := r.TLS.PeerCertificates[0]
+	if err := verifyIssued(peer, r.TLS.PeerCertificates[1:], roots, self); err != nil {
+		return nil, err
+	}
+	return peer, nil
+}
+
+// verifyIssued Verify the chain of the peer certificate against the trust bundle, only certificates
+// issued by this CA can be renewed here
+func verifyIssued(peer *x509.Certificate, chain []*x509.Certificate, roots *x509.CertPool, self *x509.Certificate) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain {
+		intermediates.AddCert(cert)
+	}
+	if _, err := peer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return err
+	}
+	if !bytes.Equal(peer.AuthorityKeyId, self.SubjectKeyId) {
+		return errors.NewBadRequestString("certificate was not issued by this CA")
+	}
+	return nil
+}
+
+// checkRenewal Check the CSR proves possession of a new key and the stored certificate is still good,
+// returns the metadata of the new certificate: that of the current one and the serial number it renews
+func checkRenewal(current *x509.Certificate, certReq *x509.CertificateRequest, record *model.Certificates) (map[string]interface{}, error) {
+	if err := certReq.CheckSignature(); err != nil {
+		return nil, errors.NewBadRequestString("invalid certificate request signature")
+	}
+	if bytes.Equal(certReq.RawSubjectPublicKeyInfo, current.RawSubjectPublicKeyInfo) {
+		return nil, errors.NewBadRequestString("renewal requires a new key")
+	}
+	if record.Status != "good" {
+		return nil, errors.NewBadRequestString("certificate has been " + record.Status)
+	}
+
+	metadata := make(map[string]interface{})
+	if record.Metadata.Valid && record.Metadata.String != "" {
+		if err := json.Unmarshal([]byte(record.Metadata.String), &metadata); err != nil {
+			return nil, errors.NewBadRequestString("certificate metadata is unreadable")
+		}
+	}
+	// Certificates issued before the profile was recorded cannot be renewed, the default profile may grant more
+	if _, ok := metadata[MetadataProfile].(string); !ok {
+		return nil, errors.NewBadRequestString("certificate does not record its profile, request a new certificate")
+	}
+	metadata[MetadataRenewedFrom] = current.SerialNumber.String()
+	return metadata, nil
+}
+
+// renewProfile Profile a certificate was issued with, "" is recorded for the default profile.
+// A profile since removed from the policy is refused, cfssl would sign with the default instead
+func renewProfile(policy *config.Signing, name string) (*config.SigningProfile, error) {
+	var profile *config.SigningProfile
+	if policy != nil {
+		if name == "" {
+			profile = policy.Default
+		} else {
+			profile = policy.Profiles[name]
+		}
+	}
+	if profile == nil {
+		return nil, errors.NewBadRequestString("profile " + name + " of the certificate no longer exists")
+	}
+	if profile.CAConstraint.IsCA {
+		return nil, errors.NewBadRequestString("CA certificates cannot be renewed")
+	}
+	return profile, nil
+}
+
+func certHosts(cert *x509.Certificate) []string {
+	hosts := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+len(cert.IPAddresses))
+	for _, uri := range cert.URIs {
+		hosts = append(hosts, uri.String())
+	}
+	hosts = append(hosts, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	hosts = append(hosts, cert.EmailAddresses...)
+	return hosts
+}
+
+func certSubject(cert *x509.Certificate) *signer.Subject {
+	name := csr.Name{}
+	if len(cert.Subject.Country) > 0 {
+		name.C = cert.Subject.Country[0]
+	}
+	if len(cert.Subject.Province) > 0 {
+		name.ST = cert.Subject.Province[0]
+	}
+	if len(cert.Subject.Locality) > 0 {
+		name.L = cert.Subject.Locality[0]
+	}
+	if len(cert.Subject.Organization) > 0 {
+		name.O = cert.Subject.Organization[0]
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		name.OU = cert.Subject.OrganizationalUnit[0]
+	}
+	return &signer.Subject{
+		CN:           cert.Subject.CommonName,
+		Names:        []csr.Name{name},
+		SerialNumber: cert.Subject.SerialNumber,
+	}
+}
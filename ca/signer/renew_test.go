@@ -0,0 +1,196 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ztalab/cfssl/config"
+
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+var testSerial int64
+
+func issue(t *testing.T, parent *testCA, isCA bool, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	testSerial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("test %d", testSerial)},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          big.NewInt(testSerial).Bytes(),
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	signerCert, signerKey := tmpl, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, key.Public(), signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newTestCA(t *testing.T, parent *testCA) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return &testCA{cert: issue(t, parent, true, key), key: key}
+}
+
+func newKey() crypto.Signer {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return key
+}
+
+func TestVerifyIssued(t *testing.T) {
+	root := newTestCA(t, nil)
+	self := newTestCA(t, root)
+	sibling := newTestCA(t, root)
+	untrusted := newTestCA(t, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	cases := []struct {
+		name  string
+		peer  *x509.Certificate
+		chain []*x509.Certificate
+		ok    bool
+	}{
+		{"issued by this CA", issue(t, self, false, newKey()), []*x509.Certificate{self.cert}, true},
+		{"chain not presented", issue(t, self, false, newKey()), nil, false},
+		{"issued by another CA of the trust domain", issue(t, sibling, false, newKey()), []*x509.Certificate{sibling.cert}, false},
+		{"issued by an untrusted CA", issue(t, untrusted, false, newKey()), []*x509.Certificate{untrusted.cert}, false},
+		{"self-signed", issue(t, nil, false, newKey()), nil, false},
+	}
+	for _, c := range cases {
+		if err := verifyIssued(c.peer, c.chain, roots, self.cert); (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+func TestCheckRenewal(t *testing.T) {
+	ca := newTestCA(t, nil)
+	currentKey := newKey()
+	current := issue(t, ca, false, currentKey)
+	csr := func(key crypto.Signer) *x509.CertificateRequest {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "test"}}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	tampered := csr(newKey())
+	tampered.Signature = append([]byte{}, tampered.Signature...)
+	tampered.Signature[len(tampered.Signature)-1] ^= 0xff
+	record := func(status, metadata string) *model.Certificates {
+		return &model.Certificates{Status: status, Metadata: sql.NullString{String: metadata, Valid: metadata != ""}}
+	}
+	issued := `{"profile":"workload","unique_id":"u1"}`
+
+	cases := []struct {
+		name    string
+		req     *x509.CertificateRequest
+		record  *model.Certificates
+		ok      bool
+		profile string
+	}{
+		{"new key", csr(newKey()), record("good", issued), true, "workload"},
+		{"issued with the default profile", csr(newKey()), record("good", `{"profile":""}`), true, ""},
+		{"key reused", csr(currentKey), record("good", issued), false, ""},
+		{"CSR signature invalid", tampered, record("good", issued), false, ""},
+		{"revoked", csr(newKey()), record("revoked", issued), false, ""},
+		{"forbidden", csr(newKey()), record("forbidden", issued), false, ""},
+		{"profile not recorded", csr(newKey()), record("good", `{"unique_id":"u1"}`), false, ""},
+		{"no metadata", csr(newKey()), record("good", ""), false, ""},
+		{"metadata unreadable", csr(newKey()), record("good", "{"), false, ""},
+	}
+	for _, c := range cases {
+		metadata, err := checkRenewal(current, c.req, c.record)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if metadata[MetadataProfile] != c.profile {
+			t.Errorf("%s: profile %v not carried over", c.name, metadata[MetadataProfile])
+		}
+		if metadata[MetadataRenewedFrom] != current.SerialNumber.String() {
+			t.Errorf("%s: renewed_from is %v", c.name, metadata[MetadataRenewedFrom])
+		}
+		if c.profile == "workload" && metadata["unique_id"] != "u1" {
+			t.Errorf("%s: metadata not carried over: %v", c.name, metadata)
+		}
+	}
+}
+
+func TestRenewProfile(t *testing.T) {
+	workload := &config.SigningProfile{}
+	intermediate := &config.SigningProfile{CAConstraint: config.CAConstraint{IsCA: true}}
+	policy := &config.Signing{
+		Profiles: map[string]*config.SigningProfile{"workload": workload, "intermediate": intermediate},
+		Default:  &config.SigningProfile{},
+	}
+
+	cases := []struct {
+		name    string
+		policy  *config.Signing
+		profile string
+		want    *config.SigningProfile
+	}{
+		{"recorded profile", policy, "workload", workload},
+		{"default profile", policy, "", policy.Default},
+		{"removed profile", policy, "legacy", nil},
+		{"CA profile", policy, "intermediate", nil},
+		{"no default profile", &config.Signing{Profiles: policy.Profiles}, "", nil},
+		{"no policy", nil, "workload", nil},
+	}
+	for _, c := range cases {
+		got, err := renewProfile(c.policy, c.profile)
+		if got != c.want || (err == nil) != (c.want != nil) {
+			t.Errorf("%s: renewProfile() = %v, %v", c.name, got, err)
+		}
+	}
+}
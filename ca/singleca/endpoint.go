@@ -23,7 +23,6 @@ import (
 	"github.com/ztalab/cfssl/api"
 	"github.com/ztalab/cfssl/api/bundle"
 	"github.com/ztalab/cfssl/api/certinfo"
-	"github.com/ztalab/cfssl/api/crl"
 	"github.com/ztalab/cfssl/api/gencrl"
 	"github.com/ztalab/cfssl/api/generator"
 	"github.com/ztalab/cfssl/api/health"
@@ -34,9 +33,12 @@ import (
 	"github.com/ztalab/cfssl/api/signhandler"
 	certsql "github.com/ztalab/cfssl/certdb/sql"
 
+	"github.com/ztalab/ZACA/ca/crl"
 	"github.com/ztalab/ZACA/ca/keymanager"
+	caregistry "github.com/ztalab/ZACA/ca/registry"
 	"github.com/ztalab/ZACA/ca/revoke"
 	"github.com/ztalab/ZACA/ca/signer"
+	"github.com/ztalab/ZACA/logic/registry"
 )
 
 // V1APIPrefix is the prefix of all CFSSL V1 API Endpoints.
@@ -99,6 +101,26 @@ var endpoints = map[string]func() (http.Handler, error){
 		return h, nil
 	},
 
+	"renew": func() (http.Handler, error) {
+		if s == nil {
+			return nil, errBadSigner
+		}
+
+		h, err := signer.NewRenewHandlerFromSigner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if conf.CABundleFile != "" && conf.IntBundleFile != "" {
+			sh := h.(*api.HTTPHandler).Handler.(*signer.RenewHandler)
+			if err := sh.SetBundler(conf.CABundleFile, conf.IntBundleFile); err != nil {
+				return nil, err
+			}
+		}
+
+		return h, nil
+	},
+
 	"info": func() (http.Handler, error) {
 		if s == nil {
 			return nil, errBadSigner
@@ -125,7 +147,7 @@ var endpoints = map[string]func() (http.Handler, error){
 			return nil, errNoCertDBConfigured
 		}
 
-		return crl.NewHandler(certsql.NewAccessor(db), conf.CAFile, conf.CAKeyFile)
+		return crl.NewHandler(), nil
 	},
 
 	"gencrl": func() (http.Handler, error) {
@@ -177,6 +199,14 @@ var endpoints = map[string]func() (http.Handler, error){
 		return certinfo.NewHandler(), nil
 	},
 
+	registry.PathHeartbeat: func() (http.Handler, error) {
+		return caregistry.NewHeartbeatHandler(), nil
+	},
+
+	registry.PathIntermediateTopology: func() (http.Handler, error) {
+		return caregistry.NewTopologyHandler(), nil
+	},
+
 	"ocspsign": func() (http.Handler, error) {
 		if ocspSigner == nil {
 			return nil, errBadSigner
@@ -189,7 +219,7 @@ var endpoints = map[string]func() (http.Handler, error){
 			return nil, errNoCertDBConfigured
 		}
 		revoke.CountAll()
-		return revoke.NewHandler(certsql.NewAccessor(db)), nil
+		return revoke.NewHandler(), nil
 	},
 
 	"health": func() (http.Handler, error) {
@@ -14,15 +14,14 @@ limitations under the License.
 package singleca
 
 import (
+	"context"
 	"crypto"
 	"crypto/tls"
 	"crypto/x509"
-	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	// ...
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -40,21 +39,15 @@ import (
 	ocsp_responder "github.com/ztalab/ZACA/ca/ocsp"
 	"github.com/ztalab/ZACA/ca/upperca"
 	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/logic/registry"
 )
 
 var (
-	conf        cli.Config
-	s           signer.Signer
-	ocspSigner  ocsp.Signer
-	db          *sqlx.DB
-	router      = mux.NewRouter()
-	proxyClient = resty.NewWithClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	})
+	conf       cli.Config
+	s          signer.Signer
+	ocspSigner ocsp.Signer
+	db         *sqlx.DB
+	router     = mux.NewRouter()
 )
 
 // registerHandlers instantiates various handlers and associate them to corresponding endpoints.
@@ -87,7 +80,8 @@ func registerHandlers() {
 	logger.Info("Handler set up complete.")
 }
 
-func Server() (*mux.Router, error) {
+// Server Handlers of the TLS port, the background workers it starts stop when ctx is done
+func Server(ctx context.Context) (*mux.Router, error) {
 	var err error
 	logger := core.Is.Logger.Named("singleca")
 
@@ -99,46 +93,20 @@ func Server() (*mux.Router, error) {
 		if err := keymanager.NewSelfSigner().Run(); err != nil {
 			logger.Fatalf("Self signed certificate error: %v", err)
 		}
-		router.PathPrefix("/api/v1/cap/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			localPort := core.Is.Config.HTTP.Listen
-			splits := strings.Split(localPort, ":")
-			localPort = splits[len(splits)-1]
-			localUrl := "http://127.0.0.1:" + localPort + "/api/v1/"
-			localUrl += strings.TrimPrefix(strings.Replace(request.URL.Path, "/api/v1/cap/", "", 1), "/")
-			var resp *resty.Response
-			var err error
-			switch request.Method {
-			case http.MethodGet:
-				resp, err = proxyClient.R().
-					Get(localUrl)
-			case http.MethodPost:
-				bodyBytes, _ := ioutil.ReadAll(request.Body)
-				resp, err = proxyClient.R().
-					SetBody(bodyBytes).
-					Post(localUrl)
-			default:
-				writer.WriteHeader(404)
-				writer.Write([]byte("404 not found"))
-			}
-
-			if err != nil {
-				logger.Errorf("Request error: %s", err)
-				writer.WriteHeader(500)
-				writer.Write([]byte("server error"))
-			}
-
-			writer.WriteHeader(200)
-			writer.Write(resp.Body())
-		})
 	} else {
 		conf = cli.Config{
-			Disable: "crl,gencrl,newcert,bundle,newkey,init_ca,scan,scaninfo,certinfo,ocspsign,/",
+			Disable: "gencrl,newcert,bundle,newkey,init_ca,scan,scaninfo,certinfo,ocspsign,/",
 		}
 		if err := keymanager.NewRemoteSigner().Run(); err != nil {
 			logger.Fatalf("Remote signing certificate error: %v", err)
 		}
 		// Superior CA health check
-		go upperca.NewChecker().Run()
+		if !core.Is.Config.Keymanager.Disconnected() {
+			go upperca.NewChecker().Run(ctx)
+			if core.Is.Config.Registry.Enabled {
+				core.Singleton(ctx, "registry-reporter", registry.NewReporter(core.Is.Config.Registry).Run)
+			}
+		}
 	}
 
 	logger.Info("Initializing signer")
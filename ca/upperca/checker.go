@@ -14,7 +14,8 @@ limitations under the License.
 package upperca
 
 import (
-	"crypto/tls"
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -33,38 +34,30 @@ import (
 const CfsslHealthApi = "/api/v1/cfssl/health"
 
 type Checker interface {
-	Run()
+	Run(ctx context.Context)
 }
 
-var httpClient = resty.NewWithClient(&http.Client{
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		MaxIdleConns:        10,
-		MaxIdleConnsPerHost: 10,
-	},
-	Timeout: 5 * time.Second,
-})
-
 type checker struct {
 	keymanager.UpperClients
-	logger *zap.SugaredLogger
-	influx *influxdb.Metrics
+	httpClient *resty.Client
+	logger     *zap.SugaredLogger
+	influx     *influxdb.Metrics
 }
 
-func (hc *checker) Run() {
-	if !core.Is.Config.Influxdb.Enabled {
-		return
-	}
-	go func() {
-		for {
-			<-time.After(5 * time.Second)
-			for _, upperClient := range hc.UpperClients.AllClients() {
-				go hc.checkUpper(upperClient)
-			}
+// Run Check the upper CAs every 5 seconds until ctx is done, the results order the failover between them
+func (hc *checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, upperClient := range hc.UpperClients.AllClients() {
+			go hc.checkUpper(upperClient)
 		}
-	}()
+	}
 }
 
 func (hc *checker) checkUpper(upperClient *client.AuthRemote) {
@@ -72,7 +65,7 @@ func (hc *checker) checkUpper(upperClient *client.AuthRemote) {
 	caUrl := upperClient.Hosts()[0]
 	caHost := schema.GetHostFromUrl(caUrl)
 
-	resp, err := httpClient.R().Get(caUrl + CfsslHealthApi)
+	resp, err := hc.httpClient.R().Get(caUrl + CfsslHealthApi)
 	// Statistical trust certificate comb
 	var statusCode int
 	if err != nil {
@@ -81,10 +74,18 @@ func (hc *checker) checkUpper(upperClient *client.AuthRemote) {
 		statusCode = resp.StatusCode()
 	}
 
-	if err != nil || resp.StatusCode() != http.StatusOK {
+	if err == nil && statusCode != http.StatusOK {
+		err = fmt.Errorf("health status %d", statusCode)
+	}
+	if err != nil {
 		hc.logger.Warnf("Upper CA: %s Connection error: %s", caHost, err)
 	}
+	hc.UpperClients.ReportHealth(caHost, err, resp.Time())
 
+	// Every replica probes for its own failover, one writes the points
+	if !core.Is.Config.Influxdb.Enabled || (core.Is.Elector != nil && !core.Is.Elector.IsLeader()) {
+		return
+	}
 	hc.influx.AddPoint(&influxdb.MetricsData{
 		Measurement: schema.MetricsUpperCaInfo,
 		Fields: map[string]interface{}{
@@ -101,8 +102,10 @@ func (hc *checker) checkUpper(upperClient *client.AuthRemote) {
 
 // NewChecker Execute only in subordinate CAS
 func NewChecker() Checker {
+	upperClients := keymanager.GetKeeper().RootClient
 	return &checker{
-		UpperClients: keymanager.GetKeeper().RootClient,
+		UpperClients: upperClients,
+		httpClient:   resty.NewWithClient(upperClients.HTTPClient()).SetTimeout(5 * time.Second),
 		logger:       logger.Named("upper").SugaredLogger,
 		influx:       core.Is.Metrics,
 	}
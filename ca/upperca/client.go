@@ -14,6 +14,7 @@ limitations under the License.
 package upperca
 
 import (
+	"context"
 	"strings"
 
 	cfssl_client "github.com/ztalab/cfssl/api/client"
@@ -21,8 +22,8 @@ import (
 	"github.com/ztalab/ZACA/ca/keymanager"
 )
 
-func ProxyRequest(f func(host string) error) error {
-	return keymanager.GetKeeper().RootClient.DoWithRetry(func(remote *cfssl_client.AuthRemote) error {
+func ProxyRequest(ctx context.Context, f func(host string) error) error {
+	return keymanager.GetKeeper().RootClient.DoWithRetry(ctx, func(remote *cfssl_client.AuthRemote) error {
 		host := strings.TrimSuffix(remote.Hosts()[0], "/")
 		return f(host)
 	})
@@ -15,9 +15,20 @@ package cmd
 
 import (
 	"context"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ztalab/ZACA/api"
 	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/logic/approval"
+	"github.com/ztalab/ZACA/logic/cascade"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/expiry"
+	"github.com/ztalab/ZACA/logic/inventory"
+	"github.com/ztalab/ZACA/logic/jobs"
+	"github.com/ztalab/ZACA/logic/registry"
+	"github.com/ztalab/ZACA/logic/workload"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+	"github.com/ztalab/ZACA/pkg/tracing"
 	"net/http"
 	"os"
 	"os/signal"
@@ -35,10 +46,24 @@ func InitHTTPServer(ctx context.Context, handler http.Handler) func() {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
+	listener := core.Is.Config.HTTP.TLS.API
+	if listener.Enabled {
+		tlsCfg, err := NewServerTLSConfig(listener.ClientAuth)
+		if err != nil {
+			logger.Fatalf("TLS policy error: %v", err)
+		}
+		srv.TLSConfig = tlsCfg
+		srv.Handler = spiffe.PeerIdentityHandler(handler)
+	}
 
 	go func() {
 		logger.Infof("HTTP server is running at %s.", addr)
-		err := srv.ListenAndServe()
+		var err error
+		if listener.Enabled {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
 		if err != nil && err != http.ErrServerClosed {
 			panic(err)
 		}
@@ -60,7 +85,42 @@ func RunHttp(ctx context.Context) error {
 	state := 1
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	// Cancelled on exit, the background workers stop before the event bus is closed
+	ctx, cancel := context.WithCancel(ctx)
+	stopTracing := startTracing(tracing.ServiceAPI)
+	stopElection := startElection(tracing.ServiceAPI)
 	app := api.Serve()
+	if core.Is.Config.ExpiryMonitor.Enabled {
+		monitor, err := expiry.NewMonitor(core.Is.Config.ExpiryMonitor)
+		if err != nil {
+			logger.Fatalf("Expiry monitor configuration error: %v", err)
+		}
+		core.Singleton(ctx, "expiry-monitor", monitor.Run)
+	}
+	runnerDone := make(chan struct{})
+	if !core.Is.Config.Keymanager.SelfSign {
+		go func() {
+			defer close(runnerDone)
+			jobs.NewRunner(core.Is.Config.Jobs).Run(ctx)
+		}()
+		core.Singleton(ctx, "hold-release", workload.NewLogic().ReleaseHolds)
+	}
+	core.Singleton(ctx, "cascade-notifier", cascade.NewNotifier(core.Is.Config.Cascade).Run)
+	core.Singleton(ctx, "registry-stale", registry.NewLogic().Run)
+	core.Singleton(ctx, "audit-chain", events.RunChain)
+	if core.Is.Config.Approval.Enabled {
+		if !core.Is.Config.HTTP.Auth.Enabled {
+			logger.Warn("Approval is enabled without http.auth, change requests cannot be reviewed")
+		}
+		core.Singleton(ctx, "approval-expirer", approval.NewLogic().Run)
+	}
+	if core.Is.Config.Inventory.Enabled {
+		exporter, err := inventory.NewExporter(core.Is.Config.Inventory, prometheus.DefaultRegisterer)
+		if err != nil {
+			logger.Fatalf("Inventory exporter error: %v", err)
+		}
+		core.Singleton(ctx, "inventory-exporter", exporter.Run)
+	}
 	cleanFunc := InitHTTPServer(ctx, app)
 
 EXIT:
@@ -78,6 +138,14 @@ EXIT:
 	}
 
 	cleanFunc()
+	cancel()
+	if !core.Is.Config.Keymanager.SelfSign {
+		// The job in progress commits its batch and is released
+		<-runnerDone
+	}
+	stopElection()
+	closeEvents()
+	stopTracing()
 	logger.Infof("HTTP service exit")
 	time.Sleep(time.Second)
 	os.Exit(state)
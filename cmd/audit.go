@@ -0,0 +1,38 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ztalab/ZACA/logic/audit"
+)
+
+// RunAuditVerify Verify the audit log hash chain, expectSeq and expectHash are an optional head recorded earlier
+func RunAuditVerify(expectSeq uint64, expectHash string) error {
+	l := audit.NewLogic()
+	res, err := l.Verify()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("audit log intact: %d events, head seq %d, head hash %s\n", res.Count, res.HeadSeq, res.HeadHash)
+	if expectHash != "" {
+		// A truncated tail keeps a valid chain, only the recorded head reveals it
+		if err := l.CheckHead(expectSeq, expectHash); err != nil {
+			return err
+		}
+		fmt.Printf("recorded head seq %d found in the chain\n", expectSeq)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+)
+
+// RunCaCSR Write a key and a request of this CA for the offline root, the key file is created with mode 0600
+func RunCaCSR(keyOut, csrOut string) error {
+	csrPEM, keyPEM, err := keymanager.NewIntermediateCSR()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(keyOut, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(keyPEM); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(csrOut, csrPEM, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("key written to %s, take %s to the root ceremony\n", keyOut, csrOut)
+	return nil
+}
+
+// RunCaImport Install the certificate signed by the offline root
+func RunCaImport(keyFile, certFile, trustFile string) error {
+	var files [3][]byte
+	for i, name := range []string{keyFile, certFile, trustFile} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files[i] = data
+	}
+	if err := keymanager.GetKeeper().ImportKeyPair(files[0], files[1], files[2]); err != nil {
+		return err
+	}
+	fmt.Printf("certificate imported, %s can now be destroyed\n", keyFile)
+	return nil
+}
+
+// RunCaEnrollCSR Write the request of the pending enrollment, generating the key of this CA on first use
+func RunCaEnrollCSR(csrOut string) error {
+	enrollment, err := keymanager.GetKeeper().BeginEnrollment()
+	if err != nil {
+		return err
+	}
+	if enrollment.Status == keymanager.EnrollmentEnrolled {
+		return fmt.Errorf("already enrolled, certificate %s valid until %s", enrollment.SN, enrollment.NotAfter.Format(time.RFC3339))
+	}
+	if csrOut == "" {
+		fmt.Print(enrollment.CSR)
+		return nil
+	}
+	if err := ioutil.WriteFile(csrOut, []byte(enrollment.CSR), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("request of %s written to %s, the key stays in this CA\n", enrollment.Subject, csrOut)
+	return nil
+}
+
+// RunCaEnroll Import the certificate of the pending request, trustFile defaults to the root at the end of the chain
+func RunCaEnroll(certFile, trustFile string) error {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	var trustPEM []byte
+	if trustFile != "" {
+		if trustPEM, err = ioutil.ReadFile(trustFile); err != nil {
+			return err
+		}
+	}
+	enrollment, err := keymanager.GetKeeper().CompleteEnrollment(certPEM, trustPEM)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("enrolled, certificate %s valid until %s\n", enrollment.SN, enrollment.NotAfter.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ceremony `zaca root` commands, run on the offline machine holding the root key.
+// They need neither the configuration file nor the database.
+package ceremony
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	"github.com/ztalab/cfssl/config"
+
+	"github.com/ztalab/ZACA/ca/rootca"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// NewCommand `root` command family
+func NewCommand() cli.Command {
+	return cli.Command{
+		Name:  "root",
+		Usage: "Offline root CA ceremonies",
+		Subcommands: []cli.Command{
+			{
+				Name:  "init",
+				Usage: "Create the root key and the self-signed root certificate",
+				Flags: append(ceremonyFlags(),
+					cli.StringFlag{Name: "cn", Usage: "Common name"},
+					cli.StringFlag{Name: "o", Usage: "Organization", Value: "CI123 ROOT AUTHORITY"},
+					cli.StringFlag{Name: "expiry", Value: "175200h"},
+					cli.StringFlag{Name: "key-algo", Usage: "rsa | ecdsa", Value: "rsa"},
+					cli.IntFlag{Name: "key-size", Usage: "RSA bits or ECDSA curve size", Value: 4096},
+				),
+				Action: func(c *cli.Context) error {
+					ceremony, err := newCeremony(c)
+					if err != nil {
+						return exit(err)
+					}
+					cert, err := ceremony.Init(&rootca.InitParams{
+						CommonName: c.String("cn"),
+						O:          c.String("o"),
+						Expiry:     c.String("expiry"),
+						KeyAlgo:    c.String("key-algo"),
+						KeySize:    c.Int("key-size"),
+					})
+					if err != nil {
+						return exit(err)
+					}
+					fmt.Printf("root %s, serial %s, valid until %s\n", cert.Subject, cert.SerialNumber, cert.NotAfter.Format(time.RFC3339))
+					fmt.Printf("distribute %s as the trust anchor of subordinate deployments\n", filepath.Join(ceremony.Dir, rootca.CertFile))
+					return nil
+				},
+			},
+			{
+				Name:  "sign-csr",
+				Usage: "Sign an intermediate CA request",
+				Flags: append(ceremonyFlags(),
+					cli.StringFlag{Name: "csr", Usage: "Request file, written by zaca ca csr"},
+					cli.StringFlag{Name: "config", Usage: "cfssl configuration with the signing profiles", Value: "/etc/capitalizone/config.json"},
+					cli.StringFlag{Name: "profile", Value: "intermediate"},
+					cli.StringFlag{Name: "crl-url", Usage: "Where the root CRL is published, written into the certificate"},
+					cli.StringSliceFlag{Name: "permitted-dns", Usage: "Name constraint, repeatable"},
+					cli.StringSliceFlag{Name: "permitted-uri", Usage: "Name constraint on URI hosts such as the SPIFFE trust domain, repeatable"},
+				),
+				Action: func(c *cli.Context) error {
+					ceremony, err := newCeremony(c)
+					if err != nil {
+						return exit(err)
+					}
+					if c.String("csr") == "" {
+						return exit(errors.New("--csr is required"))
+					}
+					csrPEM, err := ioutil.ReadFile(c.String("csr"))
+					if err != nil {
+						return exit(err)
+					}
+					conf, err := config.LoadFile(c.String("config"))
+					if err != nil {
+						return exit(errors.Wrapf(err, "cfssl configuration %s", c.String("config")))
+					}
+					res, err := ceremony.SignCSR(&rootca.SignParams{
+						CSR:          csrPEM,
+						Config:       conf,
+						Profile:      c.String("profile"),
+						CRLURL:       c.String("crl-url"),
+						PermittedDNS: c.StringSlice("permitted-dns"),
+						PermittedURI: c.StringSlice("permitted-uri"),
+					})
+					if err != nil {
+						return exit(err)
+					}
+					fmt.Printf("signed %s, serial %s, valid until %s\n", res.Cert.Subject, res.Cert.SerialNumber, res.Cert.NotAfter.Format(time.RFC3339))
+					fmt.Printf("import on the subordinate: zaca ca import --cert %s --trust %s --key <key of zaca ca csr>\n",
+						res.ChainFile, filepath.Join(ceremony.Dir, rootca.CertFile))
+					return nil
+				},
+			},
+			{
+				Name:  "gencrl",
+				Usage: "Sign the root CRL, optionally revoking intermediate certificates first",
+				Flags: append(ceremonyFlags(),
+					cli.StringSliceFlag{Name: "revoke", Usage: "Serial number to revoke, repeatable"},
+					cli.StringFlag{Name: "reason", Usage: "RFC 5280 reason of the revocations", Value: "unspecified"},
+					cli.StringFlag{Name: "invalidity-date", Usage: "RFC 3339 time the key is known or suspected to be compromised"},
+					cli.DurationFlag{Name: "validity", Usage: "Until the next update, the next ceremony must be held before", Value: 90 * 24 * time.Hour},
+					cli.StringFlag{Name: "out", Usage: "Also write the CRL here, PEM encoded when the name ends in .pem"},
+				),
+				Action: func(c *cli.Context) error {
+					ceremony, err := newCeremony(c)
+					if err != nil {
+						return exit(err)
+					}
+					reason, err := revocation.ParseReason(c.String("reason"))
+					if err != nil {
+						return exit(err)
+					}
+					var invalidity *time.Time
+					if v := c.String("invalidity-date"); v != "" {
+						t, err := time.Parse(time.RFC3339, v)
+						if err != nil {
+							return exit(errors.Wrap(err, "--invalidity-date"))
+						}
+						invalidity = &t
+					}
+					params := &rootca.CRLParams{Validity: c.Duration("validity")}
+					for _, sn := range c.StringSlice("revoke") {
+						params.Revoke = append(params.Revoke, rootca.Revocation{SerialNumber: sn, Reason: reason, InvalidityDate: invalidity})
+					}
+					der, err := ceremony.GenCRL(params)
+					if err != nil {
+						return exit(err)
+					}
+					if out := c.String("out"); out != "" {
+						data := der
+						if strings.HasSuffix(out, ".pem") {
+							data = rootca.EncodeCRLPEM(der)
+						}
+						if err := ioutil.WriteFile(out, data, 0644); err != nil {
+							return exit(err)
+						}
+					}
+					fmt.Printf("CRL written to %s, publish it before %s\n", filepath.Join(ceremony.Dir, rootca.CRLFile),
+						time.Now().Add(params.Validity).Format(time.RFC3339))
+					return nil
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "Verify the signatures and the chain of the ceremony transcripts",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "dir", Usage: "Ceremony directory", Value: "."},
+				},
+				Action: func(c *cli.Context) error {
+					ceremony := &rootca.Ceremony{Dir: c.String("dir")}
+					records, err := ceremony.Verify()
+					if err != nil {
+						return exit(err)
+					}
+					for _, r := range records {
+						fmt.Printf("%4d  %s  %-8s  %s\n", r.Seq, r.Time.Format(time.RFC3339), r.Operation, strings.Join(r.Operators, ", "))
+					}
+					fmt.Printf("%d transcripts intact\n", len(records))
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func ceremonyFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "dir", Usage: "Ceremony directory", Value: "."},
+		cli.StringSliceFlag{Name: "operator", Usage: "Person present at the ceremony, repeatable"},
+		cli.StringFlag{Name: "key-file", Usage: "Encrypted root key, default <dir>/" + rootca.KeyFile},
+		cli.StringFlag{Name: "passphrase-file", Usage: "File holding the key passphrase", EnvVar: "ZACA_ROOT_PASSPHRASE_FILE"},
+		cli.StringFlag{Name: "pkcs11-module", Usage: "PKCS#11 library, keeps the key on the token instead of a file"},
+		cli.StringFlag{Name: "pkcs11-token", Usage: "Token label"},
+		cli.StringFlag{Name: "pkcs11-pin-file", Usage: "File holding the user PIN", EnvVar: "ZACA_PKCS11_PIN_FILE"},
+		cli.StringFlag{Name: "pkcs11-key-label", Value: "zaca-root"},
+	}
+}
+
+func newCeremony(c *cli.Context) (*rootca.Ceremony, error) {
+	ceremony := &rootca.Ceremony{
+		Dir:       c.String("dir"),
+		Operators: c.StringSlice("operator"),
+		Version:   c.App.Version,
+	}
+	if module := c.String("pkcs11-module"); module != "" {
+		pin, err := readSecret(c.String("pkcs11-pin-file"), "--pkcs11-pin-file")
+		if err != nil {
+			return nil, err
+		}
+		ceremony.Key.PKCS11 = rootca.PKCS11Config{
+			Module:     module,
+			TokenLabel: c.String("pkcs11-token"),
+			PIN:        string(pin),
+			KeyLabel:   c.String("pkcs11-key-label"),
+		}
+		return ceremony, nil
+	}
+	ceremony.Key.File = c.String("key-file")
+	if ceremony.Key.File == "" {
+		ceremony.Key.File = filepath.Join(ceremony.Dir, rootca.KeyFile)
+	}
+	passphrase, err := readSecret(c.String("passphrase-file"), "--passphrase-file")
+	if err != nil {
+		return nil, err
+	}
+	ceremony.Key.Passphrase = passphrase
+	return ceremony, nil
+}
+
+// readSecret Secrets are read from files, not flags, to keep them out of the shell history
+func readSecret(name, flag string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.Errorf("%s is required", flag)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+func exit(err error) error {
+	return cli.NewExitError(fmt.Sprintf("error: %s", err), 1)
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// Bulk actions
+const (
+	ActionRevoke   = "revoke"
+	ActionRecover  = "recover"
+	ActionForbid   = "forbid"
+	ActionUnforbid = "unforbid"
+)
+
+func bulkCommand() cli.Command {
+	return cli.Command{
+		Name:  "bulk",
+		Usage: "Run lifecycle operations listed in a CSV file",
+		Description: "The first line names the columns: unique_id, or sn and aki, and optionally action, reason and comment.\n" +
+			"   A row without an action uses --action. Every row is one API request, the results are printed per row.",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "file, f", Usage: "CSV file, - reads stdin"},
+			cli.StringFlag{Name: "action", Usage: "revoke | recover | forbid | unforbid"},
+			cli.StringFlag{Name: "reason", Usage: "Revocation reason of rows without one"},
+			cli.StringFlag{Name: "comment", Usage: "Comment of rows without one"},
+			cli.BoolFlag{Name: "continue-on-error", Usage: "Keep going after a failed row"},
+		},
+		Action: func(c *cli.Context) error {
+			return do(c, func(cl *Client) error {
+				rows, err := readBulk(c.String("file"))
+				if err != nil {
+					return err
+				}
+				var results []interface{}
+				failed := 0
+				for _, row := range rows {
+					result := map[string]interface{}{"line": row.line, "target": row.target(), "result": "ok"}
+					action := row.get("action", c.String("action"))
+					result["action"] = action
+					if err := row.run(cl, action, row.get("reason", c.String("reason")), row.get("comment", c.String("comment"))); err != nil {
+						failed++
+						result["result"] = "error: " + err.Error()
+					}
+					results = append(results, result)
+					if failed > 0 && !c.Bool("continue-on-error") {
+						break
+					}
+				}
+				if err := Print(os.Stdout, format(c), results, "line", "action", "target", "result"); err != nil {
+					return err
+				}
+				if failed > 0 {
+					return errors.Errorf("%d of %d rows failed", failed, len(rows))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+type bulkRow struct {
+	line   int
+	fields map[string]string
+}
+
+func (r *bulkRow) get(column, def string) string {
+	if v := r.fields[column]; v != "" {
+		return v
+	}
+	return def
+}
+
+func (r *bulkRow) target() string {
+	if uid := r.fields["unique_id"]; uid != "" {
+		return uid
+	}
+	return r.fields["sn"] + "/" + r.fields["aki"]
+}
+
+func (r *bulkRow) run(cl *Client, action, reason, comment string) error {
+	switch action {
+	case ActionRevoke, ActionRecover:
+		body, err := target(r.fields["sn"], r.fields["aki"], r.fields["unique_id"])
+		if err != nil {
+			return errors.New("give either sn and aki, or unique_id")
+		}
+		if action == ActionRecover {
+			_, err = cl.Post("/workload/lifecycle/recover", body)
+			return err
+		}
+		if reason != "" {
+			body["reason"] = reason
+		}
+		if comment != "" {
+			body["comment"] = comment
+		}
+		_, err = cl.Post("/workload/lifecycle/revoke", body)
+		return err
+	case ActionForbid, ActionUnforbid:
+		uid := r.fields["unique_id"]
+		if uid == "" {
+			return errors.New("unique_id is required")
+		}
+		_, err := forbid(cl, []string{uid}, action == ActionForbid)
+		return err
+	case "":
+		return errors.New("no action, set the action column or --action")
+	}
+	return errors.Errorf("unknown action %q", action)
+}
+
+// readBulk Rows of the CSV file keyed by the header columns
+func readBulk(file string) ([]*bulkRow, error) {
+	if file == "" {
+		return nil, errors.New("--file is required")
+	}
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("the file has no rows below the header")
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	rows := make([]*bulkRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := &bulkRow{line: i + 2, fields: make(map[string]string, len(header))}
+		for j, v := range record {
+			if j < len(header) {
+				row.fields[header[j]] = strings.TrimSpace(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// printTopology Intermediate CAs as an indented tree
+func printTopology(w io.Writer, data interface{}) error {
+	nodes, _ := data.([]interface{})
+	if len(nodes) == 0 {
+		fmt.Fprintln(w, "no intermediate CAs")
+		return nil
+	}
+	printTopologyNodes(w, nodes, 0)
+	return nil
+}
+
+func printTopologyNodes(w io.Writer, nodes []interface{}, depth int) {
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := cell(lookup(node, "metadata.ou"))
+		if o := lookup(node, "metadata.o"); o != nil && o != "" {
+			name = cell(o) + "/" + name
+		}
+		if current, _ := node["current"].(bool); current {
+			name += " (this CA)"
+		}
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", depth), name)
+		certs, _ := node["certs"].([]interface{})
+		for _, cert := range certs {
+			m, _ := cert.(map[string]interface{})
+			fmt.Fprintf(w, "%s  - sn %s, %s, expires %s\n", strings.Repeat("  ", depth),
+				cell(m["sn"]), cell(m["status"]), cell(m["expiry"]))
+		}
+		children, _ := node["children"].([]interface{})
+		printTopologyNodes(w, children, depth+1)
+	}
+}
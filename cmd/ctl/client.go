@@ -0,0 +1,151 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// Client Admin API client, authenticated with a bearer token or a client certificate
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// NewClient Client from the global flags of the ctl command
+func NewClient(c *cli.Context) (*Client, error) {
+	server := strings.TrimRight(c.GlobalString("server"), "/")
+	if server == "" {
+		return nil, errors.New("--server is required")
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.GlobalBool("insecure"),
+	}
+	if ca := c.GlobalString("cacert"); ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "read --cacert")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cert, key := c.GlobalString("cert"), c.GlobalString("key"); cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "load --cert and --key")
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	return &Client{
+		server: server,
+		token:  c.GlobalString("token"),
+		http: &http.Client{
+			Timeout:   c.GlobalDuration("timeout"),
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// response Envelope of the admin API
+type response struct {
+	Code    int64           `json:"code"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+	Msg     string          `json:"msg"`
+}
+
+// Get ...
+func (cl *Client) Get(path string, query url.Values) (interface{}, error) {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return cl.do(http.MethodGet, path, nil)
+}
+
+// Post ...
+func (cl *Client) Post(path string, body interface{}) (interface{}, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return cl.do(http.MethodPost, path, data)
+}
+
+func (cl *Client) do(method, path string, body []byte) (interface{}, error) {
+	req, err := http.NewRequest(method, cl.server+"/api/v1"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cl.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cl.token)
+	}
+	start := time.Now()
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var env response
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, errors.Errorf("%s %s: HTTP %d after %s, unexpected response: %.200s",
+				method, path, resp.StatusCode, time.Since(start).Round(time.Millisecond), raw)
+		}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg := env.Message
+		if msg == "" {
+			msg = env.Msg
+		}
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if len(env.Data) == 0 {
+		return nil, nil
+	}
+	var data interface{}
+	dec := json.NewDecoder(bytes.NewReader(env.Data))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ctl Command-line client of the admin API, for scripts and runbooks.
+// Failures exit with status 1.
+package ctl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// certColumns Table columns of certificate lists
+var certColumns = []string{"sn", "aki", "unique_id", "role", "status", "expiry"}
+
+// NewCommand `ctl` command family
+func NewCommand() cli.Command {
+	return cli.Command{
+		Name:  "ctl",
+		Usage: "Admin API client",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "server", Usage: "Admin API address", Value: "http://127.0.0.1:8080", EnvVar: "ZACA_SERVER"},
+			cli.StringFlag{Name: "token", Usage: "API token", EnvVar: "ZACA_TOKEN"},
+			cli.StringFlag{Name: "cert", Usage: "Client certificate for mTLS", EnvVar: "ZACA_CERT"},
+			cli.StringFlag{Name: "key", Usage: "Key of the client certificate", EnvVar: "ZACA_KEY"},
+			cli.StringFlag{Name: "cacert", Usage: "CA bundle verifying the server", EnvVar: "ZACA_CACERT"},
+			cli.BoolFlag{Name: "insecure", Usage: "Skip verification of the server certificate"},
+			cli.StringFlag{Name: "output, o", Usage: "table | json | yaml", Value: FormatTable},
+			cli.DurationFlag{Name: "timeout", Usage: "Request timeout", Value: 30 * time.Second},
+		},
+		Subcommands: []cli.Command{
+			certsCommand(),
+			{
+				Name:  "revoke",
+				Usage: "Revoke a certificate (--sn and --aki) or all certificates of a unit (--unique-id)",
+				Flags: append(targetFlags(),
+					cli.StringFlag{Name: "reason", Usage: "RFC 5280 reason, e.g. keyCompromise, superseded, certificateHold"},
+					cli.StringFlag{Name: "invalidity-date", Usage: "RFC 3339 time the key is known or suspected to be compromised"},
+					cli.StringFlag{Name: "hold-until", Usage: "RFC 3339 release time of a certificateHold"},
+					cli.StringFlag{Name: "comment"},
+				),
+				Action: run(revoke),
+			},
+			{
+				Name:   "recover",
+				Usage:  "Recover certificates on hold (--sn and --aki, or --unique-id)",
+				Flags:  targetFlags(),
+				Action: run(recoverCerts),
+			},
+			{
+				Name:  "forbid",
+				Usage: "Forbid units from requesting new certificates",
+				Flags: []cli.Flag{
+					cli.StringSliceFlag{Name: "unique-id", Usage: "Unit, repeatable"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					return forbid(cl, c.StringSlice("unique-id"), true)
+				}),
+			},
+			{
+				Name:  "unforbid",
+				Usage: "Allow units to request certificates again",
+				Flags: []cli.Flag{
+					cli.StringSliceFlag{Name: "unique-id", Usage: "Unit, repeatable"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					return forbid(cl, c.StringSlice("unique-id"), false)
+				}),
+			},
+			{
+				Name:  "units",
+				Usage: "List workload units",
+				Flags: append(pageFlags(),
+					cli.StringFlag{Name: "unique-id"},
+				),
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					query := pageQuery(c)
+					setIf(query, "unique_id", c.String("unique-id"))
+					return cl.Get("/ca/workload_units", query)
+				}),
+			},
+			topologyCommand(),
+			{
+				Name:  "subordinates",
+				Usage: "List the subordinate CAs registered by their heartbeats",
+				Flags: append(pageFlags(),
+					cli.StringFlag{Name: "name", Usage: "OU of the subordinate, partial match"},
+					cli.StringFlag{Name: "stale", Usage: "true lists the silent subordinates, false the others"},
+				),
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					query := pageQuery(c)
+					setIf(query, "name", c.String("name"))
+					setIf(query, "stale", c.String("stale"))
+					return cl.Get("/registry/subordinates", query)
+				}, "name", "version", "serial_number", "issued_day", "revoked_total", "last_seen_at", "stale"),
+			},
+			bulkCommand(),
+			enrollmentCommand(),
+		},
+	}
+}
+
+func certsCommand() cli.Command {
+	return cli.Command{
+		Name:  "certs",
+		Usage: "Inspect certificates",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List certificates",
+				Flags: append(pageFlags(),
+					cli.StringFlag{Name: "unique-id"},
+					cli.StringFlag{Name: "role"},
+					cli.StringFlag{Name: "status", Usage: "good | revoked"},
+					cli.StringFlag{Name: "sn"},
+					cli.StringFlag{Name: "expiry-from", Usage: "Expiring after, 2006-01-02 15:04:05"},
+					cli.StringFlag{Name: "expiry-to", Usage: "Expiring before, 2006-01-02 15:04:05"},
+					cli.StringFlag{Name: "order", Usage: "Default issued_at desc"},
+				),
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					query := pageQuery(c)
+					setIf(query, "unique_id", c.String("unique-id"))
+					setIf(query, "role", c.String("role"))
+					setIf(query, "status", c.String("status"))
+					setIf(query, "cert_sn", c.String("sn"))
+					setIf(query, "expiry_start_time", c.String("expiry-from"))
+					setIf(query, "expiry_end_time", c.String("expiry-to"))
+					setIf(query, "order", c.String("order"))
+					return cl.Get("/workload/certs", query)
+				}, certColumns...),
+			},
+			{
+				Name:  "show",
+				Usage: "Show a certificate",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "sn"},
+					cli.StringFlag{Name: "aki"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					if c.String("sn") == "" || c.String("aki") == "" {
+						return nil, errors.New("--sn and --aki are required")
+					}
+					return cl.Get("/workload/cert", url.Values{"sn": {c.String("sn")}, "aki": {c.String("aki")}})
+				}),
+			},
+			{
+				Name:  "chain",
+				Usage: "Show the chain of a certificate (--sn and --aki) or of this CA (--self)",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "sn"},
+					cli.StringFlag{Name: "aki"},
+					cli.BoolFlag{Name: "self"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					query := url.Values{}
+					if c.Bool("self") {
+						query.Set("self_cert", "true")
+					} else if c.String("sn") == "" || c.String("aki") == "" {
+						return nil, errors.New("--sn and --aki, or --self, are required")
+					}
+					setIf(query, "sn", c.String("sn"))
+					setIf(query, "aki", c.String("aki"))
+					data, err := cl.Get("/certleaf/cert_chain", query)
+					if err != nil || format(c) != FormatTable {
+						return data, err
+					}
+					return flattenChain(data), nil
+				}, certColumns...),
+			},
+		},
+	}
+}
+
+func targetFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "sn", Usage: "Certificate serial number"},
+		cli.StringFlag{Name: "aki", Usage: "Authority key identifier of the certificate"},
+		cli.StringFlag{Name: "unique-id", Usage: "All certificates of the unit"},
+	}
+}
+
+func pageFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.IntFlag{Name: "page", Value: 1},
+		cli.IntFlag{Name: "limit", Value: 20},
+	}
+}
+
+func pageQuery(c *cli.Context) url.Values {
+	return url.Values{
+		"page":      {strconv.Itoa(c.Int("page"))},
+		"limit_num": {strconv.Itoa(c.Int("limit"))},
+	}
+}
+
+func setIf(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// target SN+AKI or unique_id of a lifecycle request
+func target(sn, aki, uniqueID string) (map[string]interface{}, error) {
+	switch {
+	case uniqueID != "" && sn == "" && aki == "":
+		return map[string]interface{}{"unique_id": uniqueID}, nil
+	case uniqueID == "" && sn != "" && aki != "":
+		return map[string]interface{}{"sn": sn, "aki": aki}, nil
+	}
+	return nil, errors.New("give either --sn and --aki, or --unique-id")
+}
+
+func revoke(c *cli.Context, cl *Client) (interface{}, error) {
+	body, err := target(c.String("sn"), c.String("aki"), c.String("unique-id"))
+	if err != nil {
+		return nil, err
+	}
+	for flag, field := range map[string]string{"invalidity-date": "invalidity_date", "hold-until": "hold_until"} {
+		if v := c.String(flag); v != "" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return nil, errors.Errorf("--%s: %s", flag, err)
+			}
+			body[field] = v
+		}
+	}
+	if v := c.String("reason"); v != "" {
+		body["reason"] = v
+	}
+	if v := c.String("comment"); v != "" {
+		body["comment"] = v
+	}
+	return cl.Post("/workload/lifecycle/revoke", body)
+}
+
+func recoverCerts(c *cli.Context, cl *Client) (interface{}, error) {
+	body, err := target(c.String("sn"), c.String("aki"), c.String("unique-id"))
+	if err != nil {
+		return nil, err
+	}
+	return cl.Post("/workload/lifecycle/recover", body)
+}
+
+func forbid(cl *Client, units []string, on bool) (interface{}, error) {
+	if len(units) == 0 {
+		return nil, errors.New("--unique-id is required")
+	}
+	path := "/workload/lifecycle/forbid_new_certs"
+	if !on {
+		path = "/workload/lifecycle/recover_forbid_new_certs"
+	}
+	return cl.Post(path, map[string]interface{}{"unique_ids": units})
+}
+
+// flattenChain Certificates of a chain from the leaf up, for the table output
+func flattenChain(data interface{}) []interface{} {
+	var rows []interface{}
+	for node, ok := data.(map[string]interface{}); ok; node, ok = node["issuer_cert"].(map[string]interface{}) {
+		rows = append(rows, node)
+	}
+	return rows
+}
+
+// run Action calling the API, the result is printed in the output format
+func run(fn func(c *cli.Context, cl *Client) (interface{}, error), columns ...string) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		return do(c, func(cl *Client) error {
+			data, err := fn(c, cl)
+			if err != nil {
+				return err
+			}
+			return Print(os.Stdout, format(c), data, columns...)
+		})
+	}
+}
+
+// do Run fn with a client, failures exit with status 1
+func do(c *cli.Context, fn func(cl *Client) error) error {
+	cl, err := NewClient(c)
+	if err == nil {
+		err = fn(cl)
+	}
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error: %s", err), 1)
+	}
+	return nil
+}
+
+func format(c *cli.Context) string {
+	return c.GlobalString("output")
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var enrollmentColumns = []string{"status", "subject", "sn", "not_after", "created_at"}
+
+func enrollmentCommand() cli.Command {
+	return cli.Command{
+		Name:  "enrollment",
+		Usage: "Manual enrollment of the CA",
+		Subcommands: []cli.Command{
+			{
+				Name:  "show",
+				Usage: "Show the enrollment status, the request is generated on first use",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "csr-out", Usage: "Write the pending request here"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					data, err := cl.Get("/ca/enrollment", nil)
+					if err != nil {
+						return nil, err
+					}
+					if out := c.String("csr-out"); out != "" {
+						m, _ := data.(map[string]interface{})
+						csr, _ := m["csr"].(string)
+						if csr == "" {
+							return nil, errors.New("no pending request")
+						}
+						if err := ioutil.WriteFile(out, []byte(csr), 0644); err != nil {
+							return nil, err
+						}
+					}
+					return data, nil
+				}, enrollmentColumns...),
+			},
+			{
+				Name:  "complete",
+				Usage: "Import the signed certificate of the pending request",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "cert", Usage: "Certificate, may be followed by the chain"},
+					cli.StringFlag{Name: "trust", Usage: "Root certificate, default the root at the end of the chain"},
+				},
+				Action: run(func(c *cli.Context, cl *Client) (interface{}, error) {
+					if c.String("cert") == "" {
+						return nil, errors.New("--cert is required")
+					}
+					certPEM, err := ioutil.ReadFile(c.String("cert"))
+					if err != nil {
+						return nil, err
+					}
+					body := map[string]interface{}{"certificate": string(certPEM)}
+					if name := c.String("trust"); name != "" {
+						trustPEM, err := ioutil.ReadFile(name)
+						if err != nil {
+							return nil, err
+						}
+						body["trust"] = string(trustPEM)
+					}
+					return cl.Post("/ca/enrollment", body)
+				}, enrollmentColumns...),
+			},
+		},
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// maxCell Nested values are shown as compact JSON cut to this width in tables
+const maxCell = 60
+
+// Print Write data in the format, columns pick and order the table columns of lists
+func Print(w io.Writer, format string, data interface{}, columns ...string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(yamlValue(data))
+	case FormatTable, "":
+		return printTable(w, data, columns)
+	}
+	return errors.Errorf("unknown output format %q, use table, json or yaml", format)
+}
+
+func printTable(w io.Writer, data interface{}, columns []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch v := data.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if list, ok := v["list"].([]interface{}); ok {
+			printRows(tw, list, columns)
+			if p, ok := v["paginate"].(map[string]interface{}); ok {
+				fmt.Fprintf(tw, "\npage %v, %v per page, %v in total\n", p["current"], p["pageSize"], p["total"])
+			}
+			return nil
+		}
+		keys := sortedKeys(v)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(k), cell(v[k]))
+		}
+	case []interface{}:
+		printRows(tw, v, columns)
+	default:
+		fmt.Fprintln(tw, cell(v))
+	}
+	return nil
+}
+
+func printRows(w io.Writer, rows []interface{}, columns []string) {
+	if len(columns) == 0 && len(rows) > 0 {
+		if first, ok := rows[0].(map[string]interface{}); ok {
+			columns = sortedKeys(first)
+		}
+	}
+	if len(columns) == 0 {
+		for _, row := range rows {
+			fmt.Fprintln(w, cell(row))
+		}
+		return
+	}
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		m, _ := row.(map[string]interface{})
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			cells[i] = cell(lookup(m, c))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+// lookup Value of a column, dotted columns reach into nested objects
+func lookup(m map[string]interface{}, column string) interface{} {
+	var v interface{} = m
+	for _, part := range strings.Split(column, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[part]
+	}
+	return v
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	}
+	b, _ := json.Marshal(v)
+	if len(b) > maxCell {
+		return string(b[:maxCell-3]) + "..."
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// yamlValue Numbers decoded as json.Number are written as YAML numbers
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = yamlValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = yamlValue(e)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/ztalab/ZACA/pkg/topology"
+)
+
+func topologyCommand() cli.Command {
+	return cli.Command{
+		Name:  "topology",
+		Usage: "Show the intermediate CAs signed by this CA, or the whole tree with --tree",
+		Flags: []cli.Flag{
+			cli.BoolFlag{Name: "upper", Usage: "Topology seen from the upper CA"},
+			cli.BoolFlag{Name: "tree", Usage: "Tree from the root CA to every subordinate, with status"},
+			cli.BoolFlag{Name: "refresh", Usage: "Ask the subordinates again instead of using the cached tree"},
+			cli.StringFlag{Name: "dot", Usage: "Write the tree as Graphviz DOT to a file, - for stdout"},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Bool("tree") || c.String("dot") != "" {
+				return do(c, func(cl *Client) error { return topologyTree(c, cl) })
+			}
+			path := "/ca/intermediate_topology"
+			if c.Bool("upper") {
+				path = "/ca/upper_ca_intermediate_topology"
+			}
+			return do(c, func(cl *Client) error {
+				data, err := cl.Get(path, nil)
+				if err != nil {
+					return err
+				}
+				if format(c) != FormatTable {
+					return Print(os.Stdout, format(c), data)
+				}
+				return printTopology(os.Stdout, data)
+			})
+		},
+	}
+}
+
+func topologyTree(c *cli.Context, cl *Client) error {
+	query := url.Values{}
+	if c.Bool("refresh") {
+		query.Set("refresh", "true")
+	}
+	data, err := cl.Get("/cascade/topology", query)
+	if err != nil {
+		return err
+	}
+	if c.String("dot") == "" && format(c) != FormatTable {
+		return Print(os.Stdout, format(c), data)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var root topology.Node
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return err
+	}
+	switch path := c.String("dot"); path {
+	case "":
+		return printTree(os.Stdout, &root)
+	case "-":
+		return root.WriteDOT(os.Stdout)
+	default:
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := root.WriteDOT(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// printTree Indented tree, one CA per line
+func printTree(w io.Writer, root *topology.Node) error {
+	root.Walk(func(node *topology.Node, depth int) {
+		line := []string{strings.Repeat("  ", depth) + node.Name, node.Role, node.Status}
+		if node.NotAfter != nil {
+			line = append(line, "expires "+node.NotAfter.Format("2006-01-02"))
+		}
+		line = append(line, fmt.Sprintf("issued %d/h %d/24h", node.IssuedHour, node.IssuedDay))
+		if node.LastHeartbeat != nil {
+			line = append(line, "seen "+node.LastHeartbeat.Format(time.RFC3339))
+		}
+		if node.Current {
+			line = append(line, "(this CA)")
+		}
+		if node.Error != "" {
+			line = append(line, "error: "+node.Error)
+		}
+		fmt.Fprintln(w, strings.Join(line, "  "))
+	})
+	counts := root.Count()
+	summary := make([]string, 0, len(counts))
+	for _, status := range []string{topology.StatusHealthy, topology.StatusExpiring, topology.StatusExpired,
+		topology.StatusRevoked, topology.StatusUnreachable, topology.StatusUnknown} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", strings.Join(summary, ", "))
+	return err
+}
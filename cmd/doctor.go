@@ -0,0 +1,409 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/hook"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/ca/upperca"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/initer"
+	"github.com/ztalab/ZACA/pkg/doctor"
+	"github.com/ztalab/ZACA/pkg/vaultsecret"
+)
+
+// clockSkewWarn Skew worth syncing the clock for, the lease of the leader election and certificate validity depend on it
+const clockSkewWarn = 2 * time.Second
+
+// RunDoctor Diagnose the deployment wired by probe, an error when a check failed so that it can gate an init container
+func RunDoctor(probe *initer.Probe, jsonOut bool, timeout time.Duration) error {
+	d := &doctorState{probe: probe, upperSkews: make(map[string]time.Duration)}
+	checks := []doctor.Check{{Name: "config", Run: d.checkConfig}}
+	if probe.ConfigErr == nil {
+		checks = append(checks,
+			doctor.Check{Name: "mysql", Run: d.checkMysql},
+			doctor.Check{Name: "migrations", Run: d.checkMigrations},
+			doctor.Check{Name: "vault", Run: d.checkVault},
+			doctor.Check{Name: "ca-keypair", Run: d.checkKeyPair},
+			doctor.Check{Name: "ca-chain", Run: d.checkChain},
+			doctor.Check{Name: "ca-expiry", Run: d.checkExpiry},
+			doctor.Check{Name: "upper-ca", Run: d.checkUpperCA},
+			doctor.Check{Name: "upper-ca-auth", Run: d.checkAuthKey},
+			doctor.Check{Name: "clock", Run: d.checkClock},
+		)
+	}
+	report := doctor.Run(context.Background(), timeout, checks)
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if !report.OK {
+		return errors.Errorf("doctor: %d checks failed", report.Summary[doctor.StatusFail])
+	}
+	return nil
+}
+
+// doctorState Findings of earlier checks used by later ones
+type doctorState struct {
+	probe      *initer.Probe
+	cert       *x509.Certificate
+	trust      []*x509.Certificate
+	upperUp    []string
+	upperSkews map[string]time.Duration // Date header of each reachable upper CA against the midpoint of the request
+}
+
+func (d *doctorState) checkConfig(context.Context) doctor.Result {
+	if err := d.probe.ConfigErr; err != nil {
+		return doctor.Fail("Fix conf.yml and the cfssl configuration of singleca.config-path, see conf.yml for every key",
+			"configuration error: %s", err)
+	}
+	return doctor.Pass("conf.yml and %s loaded", core.Is.Config.Singleca.ConfigPath)
+}
+
+func (d *doctorState) checkMysql(ctx context.Context) doctor.Result {
+	if err := d.probe.MysqlErr; err != nil {
+		return doctor.Fail("Check mysql.dsn or IS_MYSQL_DSN, and that MySQL accepts connections from this host",
+			"connection failed: %s", err)
+	}
+	var version string
+	if err := core.Is.Db.WithContext(ctx).Raw("SELECT VERSION()").Scan(&version).Error; err != nil {
+		return doctor.Fail("The user of mysql.dsn needs access to its database", "query failed: %s", err)
+	}
+	return doctor.Pass("connected, server %s", version)
+}
+
+func (d *doctorState) checkMigrations(context.Context) doctor.Result {
+	if core.Is.Db == nil {
+		return doctor.Skip("no database connection")
+	}
+	applied, latest, dirty, err := mysql.MigrationStatus(core.Is.Db)
+	switch {
+	case err != nil:
+		return doctor.Fail("Run from the directory holding database/mysql/migrations", "migration state unavailable: %s", err)
+	case dirty:
+		return doctor.Fail("A migration stopped halfway: repair the schema by hand, then force the version with the migrate CLI",
+			"version %d is dirty", applied)
+	case applied < latest:
+		return doctor.Warn("Pending migrations are applied when the api, tls or ocsp service starts",
+			"version %d, %d available", applied, latest)
+	case applied > latest:
+		return doctor.Warn("The database was migrated by a newer release, upgrade this binary",
+			"version %d, this binary knows up to %d", applied, latest)
+	}
+	return doctor.Pass("version %d, up to date", applied)
+}
+
+func (d *doctorState) checkVault(context.Context) doctor.Result {
+	if !core.Is.Config.Vault.Enabled {
+		return doctor.Skip("vault storage disabled, keys are stored in MySQL")
+	}
+	if err := d.probe.VaultErr; err != nil {
+		return doctor.Fail("Check vault.addr and vault.token", "unreachable: %s", err)
+	}
+	status, err := core.Is.VaultClient.Sys().SealStatus()
+	if err != nil {
+		return doctor.Fail("Check vault.addr and vault.token", "seal status error: %s", err)
+	}
+	if status.Sealed {
+		return doctor.Fail("Unseal vault with the unseal keys", "sealed, unseal progress %d/%d", status.Progress, status.T)
+	}
+	return doctor.Pass("reachable and unsealed, version %s", status.Version)
+}
+
+// storageReady Whether the key pair storage can be read, with the reason when it cannot
+func (d *doctorState) storageReady() (string, bool) {
+	if core.Is.Db == nil {
+		return "no database connection", false
+	}
+	if hook.EnableVaultStorage && core.Is.VaultClient == nil {
+		return "vault unreachable", false
+	}
+	if d.probe.KeeperErr != nil {
+		return "keymanager not initialized", false
+	}
+	return "", true
+}
+
+func (d *doctorState) checkKeyPair(context.Context) doctor.Result {
+	if reason, ok := d.storageReady(); !ok {
+		return doctor.Skip(reason)
+	}
+	keyPEM, certPEM, err := keymanager.GetKeeper().GetDBSelfKeyPairPEM()
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && len(certPEM) == 0) {
+		return doctor.Fail("The tls service creates or enrolls the CA key at its first start, or import one with zaca ca import",
+			"no CA key pair stored")
+	}
+	if err != nil {
+		return doctor.Fail("Check the storage of the CA key pair", "read error: %s", err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return doctor.Fail("Import a valid key pair with zaca ca import", "certificate unreadable: %s", err)
+	}
+	d.cert = cert
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return doctor.Fail("The key or the certificate was replaced alone, import the matching pair with zaca ca import",
+			"stored key does not match the certificate %s: %s", cert.Subject, err)
+	}
+	return doctor.Pass("key matches the certificate %s, serial %s", cert.Subject, cert.SerialNumber)
+}
+
+// storedTrustCerts Trust certificates stored locally, the tls service fetches them from the upper CA when missing
+func storedTrustCerts() ([]*x509.Certificate, error) {
+	var certsPEM string
+	if hook.EnableVaultStorage {
+		pem, err := core.Is.VaultSecret.GetCertPEM(vaultsecret.CATructCertsKey)
+		if err != nil {
+			return nil, err
+		}
+		certsPEM = *pem
+	} else {
+		row := &model.SelfKeypair{}
+		err := core.Is.Db.Where("name = ?", keymanager.SelfKeyTrustName).Order("id desc").First(row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		certsPEM = row.Certificate.String
+	}
+	if strings.TrimSpace(certsPEM) == "" {
+		return nil, nil
+	}
+	return helpers.ParseCertificatesPEM([]byte(certsPEM))
+}
+
+func (d *doctorState) checkChain(context.Context) doctor.Result {
+	if d.cert == nil {
+		return doctor.Skip("no CA certificate")
+	}
+	conf := core.Is.Config.Keymanager
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	if conf.SelfSign {
+		roots.AddCert(d.cert)
+	} else {
+		trust, err := storedTrustCerts()
+		if err != nil {
+			return doctor.Fail("Check the storage of the trust certificates", "trust certificates unreadable: %s", err)
+		}
+		if len(trust) == 0 {
+			if conf.Disconnected() {
+				return doctor.Fail("Import the root certificate with zaca ca import --trust, or zaca ca enroll --trust",
+					"no trust certificates stored")
+			}
+			return doctor.Warn("The tls service fetches them from the upper CA at start",
+				"no trust certificates stored")
+		}
+		d.trust = trust
+		for _, c := range trust {
+			if bytes.Equal(c.RawIssuer, c.RawSubject) {
+				roots.AddCert(c)
+			} else {
+				intermediates.AddCert(c)
+			}
+		}
+	}
+	chains, err := d.cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return doctor.Fail("The trust certificates must chain the CA certificate to its root: import the chain signed with it again",
+			"chain invalid: %s", err)
+	}
+	names := make([]string, 0, len(chains[0]))
+	for _, c := range chains[0] {
+		names = append(names, c.Subject.CommonName)
+	}
+	return doctor.Pass("valid chain %s", strings.Join(names, " -> "))
+}
+
+func (d *doctorState) checkExpiry(context.Context) doctor.Result {
+	if d.cert == nil {
+		return doctor.Skip("no CA certificate")
+	}
+	horizon := 0
+	for _, days := range core.Is.Config.ExpiryMonitor.CAThresholds {
+		if days > horizon {
+			horizon = days
+		}
+	}
+	now := time.Now()
+	certs := append([]*x509.Certificate{d.cert}, d.trust...)
+	sort.Slice(certs, func(i, j int) bool { return certs[i].NotAfter.Before(certs[j].NotAfter) })
+	first := certs[0]
+	left := first.NotAfter.Sub(now)
+	switch {
+	case d.cert.NotBefore.After(now):
+		return doctor.Fail("Sync the clock of this host, or wait until the certificate is valid",
+			"certificate %s not valid before %s", d.cert.Subject.CommonName, d.cert.NotBefore.Format(time.RFC3339))
+	case left <= 0:
+		return doctor.Fail("Renew the certificate: rotate the CA key, or sign a new one with the offline root",
+			"certificate %s expired on %s", first.Subject.CommonName, first.NotAfter.Format(time.RFC3339))
+	case left < time.Duration(horizon)*24*time.Hour:
+		return doctor.Warn("Renew the certificate before it expires: rotate the CA key, or sign a new one with the offline root",
+			"certificate %s expires in %d days, on %s", first.Subject.CommonName, int(left.Hours()/24), first.NotAfter.Format(time.RFC3339))
+	}
+	return doctor.Pass("%d certificates valid for %d days at least, until %s", len(certs), int(left.Hours()/24), first.NotAfter.Format(time.RFC3339))
+}
+
+// upperSkip Reason why the upper CA is not checked
+func (d *doctorState) upperSkip() (string, bool) {
+	conf := core.Is.Config.Keymanager
+	switch {
+	case conf.SelfSign:
+		return "root CA, no upper CA", true
+	case conf.OfflineRoot:
+		return "signed by an offline root, no upper CA", true
+	case conf.ManualEnroll.Enabled:
+		return "manual enrollment, no upper CA", true
+	}
+	return "", false
+}
+
+func (d *doctorState) checkUpperCA(ctx context.Context) doctor.Result {
+	if reason, skip := d.upperSkip(); skip {
+		return doctor.Skip(reason)
+	}
+	if err := d.probe.KeeperErr; err != nil {
+		return doctor.Fail("Check keymanager.upper-ca, keymanager.upper-ca-tls and the intermediate auth key of the cfssl configuration",
+			"upper CA clients unavailable: %s", err)
+	}
+	rootClient := keymanager.GetKeeper().RootClient
+	client := rootClient.HTTPClient()
+	var failed []string
+	for host, remote := range rootClient.AllClients() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(remote.Hosts()[0], "/")+upperca.CfsslHealthApi, nil)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", host, err))
+			continue
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", host, err))
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			failed = append(failed, fmt.Sprintf("%s: health status %d", host, resp.StatusCode))
+			continue
+		}
+		d.upperUp = append(d.upperUp, host)
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			d.upperSkews[host] = date.Sub(start.Add(time.Since(start) / 2))
+		}
+	}
+	sort.Strings(d.upperUp)
+	sort.Strings(failed)
+	hint := "Check network access to keymanager.upper-ca, and the pinned roots of keymanager.upper-ca-tls"
+	switch {
+	case len(d.upperUp) == 0:
+		return doctor.Fail(hint, "no upper CA reachable: %s", strings.Join(failed, "; "))
+	case len(failed) > 0:
+		return doctor.Warn(hint, "%d of %d upper CAs reachable, %s", len(d.upperUp), len(d.upperUp)+len(failed), strings.Join(failed, "; "))
+	}
+	return doctor.Pass("%s reachable", strings.Join(d.upperUp, ", "))
+}
+
+func (d *doctorState) checkAuthKey(context.Context) doctor.Result {
+	if reason, skip := d.upperSkip(); skip {
+		return doctor.Skip(reason)
+	}
+	if len(d.upperUp) == 0 {
+		return doctor.Skip("no upper CA reachable")
+	}
+	remote := keymanager.GetKeeper().RootClient.AllClients()[d.upperUp[0]]
+	// A request without CSR is refused after the token is verified, the reason tells whether the key is accepted
+	body, _ := json.Marshal(map[string]string{"profile": "intermediate"})
+	_, err := remote.Sign(body)
+	switch {
+	case err == nil:
+		return doctor.Warn("", "the upper CA %s accepted an empty request", d.upperUp[0])
+	case strings.Contains(err.Error(), "invalid token"):
+		return doctor.Fail("The intermediate auth key of the cfssl configuration must be the one of the upper CA",
+			"auth key refused by %s", d.upperUp[0])
+	case strings.Contains(err.Error(), "certificate_request"):
+		return doctor.Pass("auth key accepted by %s", d.upperUp[0])
+	}
+	return doctor.Warn("Check the logs of the upper CA", "auth key not confirmed by %s: %s", d.upperUp[0], err)
+}
+
+func (d *doctorState) checkClock(ctx context.Context) doctor.Result {
+	if core.Is.Db == nil && len(d.upperSkews) == 0 {
+		return doctor.Skip("no database nor upper CA to compare with")
+	}
+	window := time.Duration(core.Is.Config.Singleca.RequestAuth.Window) * time.Second
+	var skews []string
+	worst := time.Duration(0)
+	add := func(name string, skew time.Duration) {
+		skews = append(skews, fmt.Sprintf("%s %s", name, skew.Round(time.Millisecond)))
+		if math.Abs(float64(skew)) > math.Abs(float64(worst)) {
+			worst = skew
+		}
+	}
+	if core.Is.Db != nil {
+		var ts float64
+		start := time.Now()
+		if err := core.Is.Db.WithContext(ctx).Raw("SELECT UNIX_TIMESTAMP(NOW(6))").Scan(&ts).Error; err != nil {
+			return doctor.Fail("", "database time unavailable: %s", err)
+		}
+		local := start.Add(time.Since(start) / 2)
+		add("mysql", time.Unix(0, int64(ts*1e9)).Sub(local))
+	}
+	for host, skew := range d.upperSkews {
+		// The Date header has a resolution of one second
+		if skew > -time.Second && skew < time.Second {
+			skew = 0
+		}
+		add(host, skew)
+	}
+	abs := time.Duration(math.Abs(float64(worst)))
+	switch {
+	case window > 0 && abs >= window:
+		return doctor.Fail("Sync the clock with NTP, signed requests are refused beyond singleca.request-auth.window",
+			"clock skew %s", strings.Join(skews, ", "))
+	case abs >= clockSkewWarn:
+		return doctor.Warn("Sync the clock with NTP, the leader lease and certificate validity depend on it",
+			"clock skew %s", strings.Join(skews, ", "))
+	}
+	return doctor.Pass("clock skew %s", strings.Join(skews, ", "))
+}
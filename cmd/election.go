@@ -0,0 +1,55 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/pkg/election"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// startElection Campaign for the lease of the service, the returned func resigns so that another replica takes over at once
+func startElection(service string) func() {
+	conf := core.Is.Config.Election
+	if !conf.Enabled {
+		return func() {}
+	}
+	hostname := core.Is.Config.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	elector := election.New(election.NewMysqlStore(core.Is.Db), election.Config{
+		Name:          service,
+		Holder:        fmt.Sprintf("%s/%d", hostname, os.Getpid()),
+		TTL:           time.Duration(conf.LeaseTTL) * time.Second,
+		RenewInterval: time.Duration(conf.RenewInterval) * time.Second,
+	}, logger.Named("election").SugaredLogger)
+	core.Is.Elector = elector
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
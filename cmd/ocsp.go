@@ -20,7 +20,10 @@ import (
 	"github.com/ztalab/ZACA/ca/singleca"
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+	"github.com/ztalab/ZACA/pkg/tracing"
 	"github.com/ztalab/cfssl/ocsp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"net/http"
 	"net/http/pprof"
 	"os"
@@ -38,7 +41,7 @@ func InitOcspServer(ctx context.Context, ocspSigner ocsp.Signer) func() {
 	}
 	ocsp_responder.CountAll()
 	mux := http.NewServeMux()
-	mux.Handle("/", ocsp.NewResponder(src, nil))
+	mux.Handle("/", otelhttp.NewHandler(ocsp.NewResponder(src, nil), "ocsp"))
 
 	addr := core.Is.Config.HTTP.OcspListen
 	srv := &http.Server{
@@ -48,9 +51,23 @@ func InitOcspServer(ctx context.Context, ocspSigner ocsp.Signer) func() {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
+	listener := core.Is.Config.HTTP.TLS.Ocsp
+	if listener.Enabled {
+		tlsCfg, err := NewServerTLSConfig(listener.ClientAuth)
+		if err != nil {
+			logger.Fatalf("TLS policy error: %v", err)
+		}
+		srv.TLSConfig = tlsCfg
+		srv.Handler = spiffe.PeerIdentityHandler(mux)
+	}
 	go func() {
 		logger.Infof("OCSP server is running at %s.", addr)
-		err := srv.ListenAndServe()
+		var err error
+		if listener.Enabled {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
 		if err != nil && err != http.ErrServerClosed {
 			panic(err)
 		}
@@ -100,6 +117,7 @@ func RunOcsp(ctx context.Context) error {
 	state := 1
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	stopTracing := startTracing(tracing.ServiceOCSP)
 	app := singleca.OcspServer()
 	cleanFunc := InitOcspServer(ctx, app)
 
@@ -118,6 +136,8 @@ EXIT:
 	}
 
 	cleanFunc()
+	closeEvents()
+	stopTracing()
 	logger.Infof("Exit OCSP service")
 	time.Sleep(time.Second)
 	os.Exit(state)
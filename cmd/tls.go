@@ -21,7 +21,13 @@ import (
 	"github.com/ztalab/ZACA/ca/keymanager"
 	"github.com/ztalab/ZACA/ca/singleca"
 	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/logic/events"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/requestid"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+	"github.com/ztalab/ZACA/pkg/tlspolicy"
+	"github.com/ztalab/ZACA/pkg/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"net/http"
 	"net/http/pprof"
 	"os"
@@ -30,20 +36,30 @@ import (
 	"time"
 )
 
+// NewServerTLSConfig Listener configuration from the TLS policy, client certificates are verified against the trust bundle
+func NewServerTLSConfig(clientAuth string) (*tls.Config, error) {
+	policy := core.Is.Config.HTTP.TLS
+	p, err := tlspolicy.New(policy.MinVersion, policy.CipherSuites, policy.Curves, policy.ALPN, clientAuth)
+	if err != nil {
+		return nil, err
+	}
+	return p.ServerConfig(func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return keymanager.GetKeeper().GetCachedTLSKeyPair()
+	}, keymanager.GetKeeper().GetTrustCertPool), nil
+}
+
 // InitTlsServer Initialize TLS service
 func InitTlsServer(ctx context.Context, handler *mux.Router) func() {
 	addr := core.Is.Config.HTTP.CaListen
-	tlsCfg := &tls.Config{
-		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			return keymanager.GetKeeper().GetCachedTLSKeyPair()
-		},
-		InsecureSkipVerify: true,
-		ClientAuth:         tls.NoClientCert,
+	tlsCfg, err := NewServerTLSConfig(core.Is.Config.HTTP.TLS.ClientAuth)
+	if err != nil {
+		logger.Fatalf("TLS policy error: %v", err)
 	}
+	handler.Use(otelmux.Middleware(tracing.ServiceTLS))
 	srv := &http.Server{
 		Addr:         addr,
 		TLSConfig:    tlsCfg,
-		Handler:      handler,
+		Handler:      requestid.Handler(spiffe.PeerIdentityHandler(handler)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
@@ -96,13 +112,19 @@ func InitTlsServer(ctx context.Context, handler *mux.Router) func() {
 
 func RunTls(ctx context.Context) error {
 	state := 1
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-
-	app, err := singleca.Server()
+	// Cancelled on exit, the background workers stop before the event bus is closed
+	ctx, cancel := context.WithCancel(ctx)
+	stopTracing := startTracing(tracing.ServiceTLS)
+	stopElection := startElection(tracing.ServiceTLS)
+	// Server may block in a pending enrollment, signals keep their default action until it returns
+	app, err := singleca.Server(ctx)
 	if err != nil {
+		cancel()
 		return err
 	}
+	core.Singleton(ctx, "audit-chain", events.RunChain)
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	cleanFunc := InitTlsServer(ctx, app)
 
 EXIT:
@@ -120,8 +142,46 @@ EXIT:
 	}
 
 	cleanFunc()
+	cancel()
+	stopElection()
+	closeEvents()
+	stopTracing()
 	logger.Infof("TLS service exit")
 	time.Sleep(time.Second)
 	os.Exit(state)
 	return nil
 }
+
+// startTracing Install the tracer provider of the service, the returned func flushes the spans
+func startTracing(service string) func() {
+	conf := core.Is.Config.Tracing
+	if !conf.Enabled {
+		return func() {}
+	}
+	shutdown, err := tracing.Init(tracing.Config{
+		Service:     service,
+		Version:     core.Is.Config.Version,
+		Exporter:    conf.Exporter,
+		Endpoint:    conf.Endpoint,
+		Insecure:    conf.Insecure,
+		Headers:     conf.Headers,
+		SampleRatio: conf.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatalf("Tracing configuration error: %v", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Errorf("Tracing shutdown error: %v", err)
+		}
+	}
+}
+
+// closeEvents Deliver the queued lifecycle events before exiting
+func closeEvents() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events.CloseBus(ctx)
+}
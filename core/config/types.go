@@ -28,6 +28,7 @@ type IConfig struct {
 	Keymanager     Keymanager            `yaml:"keymanager"`
 	Singleca       Singleca              `yaml:"singleca"`
 	OCSPHost       string                `yaml:"ocsp-host"`
+	CRLHost        string                `yaml:"crl-host"`
 	HTTP           HTTP                  `yaml:"http"`
 	Mysql          Mysql                 `yaml:"mysql"`
 	Vault          Vault                 `yaml:"vault"`
@@ -37,6 +38,140 @@ type IConfig struct {
 	Version        string                `yaml:"version"`
 	Hostname       string                `yaml:"hostname"`
 	Ocsp           Ocsp                  `yaml:"ocsp"`
+	Crl            Crl                   `yaml:"crl"`
+	Events         Events                `yaml:"events"`
+	ExpiryMonitor  ExpiryMonitor         `yaml:"expiry-monitor"`
+	Inventory      Inventory             `yaml:"inventory"`
+	Tracing        Tracing               `yaml:"tracing"`
+	Jobs           Jobs                  `yaml:"jobs"`
+	Cascade        Cascade               `yaml:"cascade"`
+	Forbid         Forbid                `yaml:"forbid"`
+	Approval       Approval              `yaml:"approval"`
+	Topology       Topology              `yaml:"topology"`
+	Registry       SubordinateRegistry   `yaml:"registry"`
+	Election       Election              `yaml:"election"`
+}
+
+// Election Leader election of the replicas of each service, only the leader runs the singleton jobs
+type Election struct {
+	Enabled       bool `yaml:"enabled"`        // Without it every replica runs them
+	LeaseTTL      int  `yaml:"lease-ttl"`      // Seconds, a dead leader is replaced within lease-ttl + renew-interval
+	RenewInterval int  `yaml:"renew-interval"` // Seconds between renewals, well below lease-ttl
+}
+
+// SubordinateRegistry Heartbeats of subordinate CAs to their upper CA
+type SubordinateRegistry struct {
+	Enabled    bool `yaml:"enabled"`     // Report this CA to the upper CA
+	Interval   int  `yaml:"interval"`    // Seconds between heartbeats, and between stale checks of the upper CA
+	StaleAfter int  `yaml:"stale-after"` // Seconds without heartbeat before a subordinate is flagged stale
+}
+
+// Topology Tree of the CAs, each subordinate is asked for its own subtree
+type Topology struct {
+	CacheTTL      int `yaml:"cache-ttl"`      // Seconds a subtree is reused
+	Timeout       int `yaml:"timeout"`        // Seconds to wait for a subordinate
+	MaxDepth      int `yaml:"max-depth"`      // Levels of subordinates below this CA
+	ExpiryWarning int `yaml:"expiry-warning"` // Days before expiry a CA is reported expiring
+}
+
+// Approval Four-eyes approval of sensitive operations
+type Approval struct {
+	Enabled bool `yaml:"enabled"`
+	// unit-revoke | unit-forbid | cascade-revoke | intermediate-sign | policy-change, empty protects all.
+	// Recovering and lifting a forbid are protected with unit-revoke and unit-forbid
+	Operations []string `yaml:"operations"`
+	TTL        int      `yaml:"ttl"` // Hours until a pending request expires
+}
+
+// Forbid Forbid rules cached in memory by the signing service
+type Forbid struct {
+	RefreshInterval int `yaml:"refresh-interval"` // Seconds between checks of the rule revision
+}
+
+// Cascade Delivery of cascade revocations to subordinate CAs
+type Cascade struct {
+	Interval     int           `yaml:"interval"` // Seconds between delivery attempts
+	Subordinates []Subordinate `yaml:"subordinates"`
+}
+
+// Subordinate API address of a subordinate CA, by the OU of its intermediate certificates
+type Subordinate struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// Jobs Runner of the bulk lifecycle jobs in the api service
+type Jobs struct {
+	BatchSize    int `yaml:"batch-size"`
+	PollInterval int `yaml:"poll-interval"` // Seconds
+	Lease        int `yaml:"lease"`         // Seconds without progress before another instance resumes a job
+}
+
+// Tracing OpenTelemetry spans, exported to an OTLP/HTTP collector or stdout
+type Tracing struct {
+	Enabled     bool              `yaml:"enabled"`
+	Exporter    string            `yaml:"exporter"` // otlp | stdout
+	Endpoint    string            `yaml:"endpoint"` // host:port of the collector
+	Insecure    bool              `yaml:"insecure"`
+	Headers     map[string]string `yaml:"headers"`
+	SampleRatio float64           `yaml:"sample-ratio"`
+}
+
+// Inventory Per-unit certificate gauges, units in the allow-list are always exported
+type Inventory struct {
+	Enabled   bool     `yaml:"enabled"`
+	Interval  int      `yaml:"interval"`   // Minutes between refreshes
+	AllowList []string `yaml:"allow-list"` // unique_id patterns, e.g. "gateway-*"
+	TopN      int      `yaml:"top-n"`      // Other units expiring first, -1 exports all
+}
+
+// ExpiryMonitor Alerts for certificates nearing expiry, thresholds are in days
+type ExpiryMonitor struct {
+	Enabled      bool             `yaml:"enabled"`
+	Interval     int              `yaml:"interval"`      // Minutes between scans
+	Thresholds   map[string][]int `yaml:"thresholds"`    // Per role, "default" for the other roles
+	CAThresholds []int            `yaml:"ca-thresholds"` // Own and upper CA certificates
+	Channels     []AlertChannel   `yaml:"channels"`
+}
+
+// AlertChannel type is webhook, smtp or prometheus
+type AlertChannel struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// webhook
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+	// smtp
+	SMTPAddr string   `yaml:"smtp-addr"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	StartTLS bool     `yaml:"starttls"`
+}
+
+// Events External sinks of the operation events
+type Events struct {
+	QueueSize int         `yaml:"queue-size"` // Per sink, events are dropped to the dead letters when full
+	Timeout   int         `yaml:"timeout"`    // Seconds for one delivery, retries included
+	Sinks     []EventSink `yaml:"sinks"`
+}
+
+// EventSink type is webhook, jsonl or syslog, empty categories and types match all events
+type EventSink struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Categories []string `yaml:"categories"`
+	Types      []string `yaml:"types"`
+	// webhook
+	URL        string `yaml:"url"`
+	Secret     string `yaml:"secret"`
+	MaxRetries int    `yaml:"max-retries"`
+	// jsonl
+	Path string `yaml:"path"`
+	// syslog, network is udp, tcp or unix
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
 }
 
 type Registry struct {
@@ -49,6 +184,12 @@ type Ocsp struct {
 	CacheTime int `yaml:"cache-time"`
 }
 
+// Crl CRL published on the ca listener
+type Crl struct {
+	Validity  int `yaml:"validity"`   // Hours until nextUpdate
+	CacheTime int `yaml:"cache-time"` // Minutes a generated CRL is served
+}
+
 type LogProxy struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
@@ -58,15 +199,93 @@ type Log struct {
 	LogProxy LogProxy `yaml:"log-proxy"`
 }
 type Singleca struct {
-	ConfigPath string `yaml:"config-path"`
+	ConfigPath  string      `yaml:"config-path"`
+	RequestAuth RequestAuth `yaml:"request-auth"`
 
 	// Raw
 	CfsslConfig *cfssl_config.Config
 }
+
+// ProfileAuthKey Auth key of a signing profile, an empty name is the default profile
+func (s Singleca) ProfileAuthKey(profile string) (string, bool) {
+	if s.CfsslConfig == nil || s.CfsslConfig.Signing == nil {
+		return "", false
+	}
+	p := s.CfsslConfig.Signing.Default
+	if profile != "" {
+		p = s.CfsslConfig.Signing.Profiles[profile]
+	}
+	if p == nil || p.AuthKeyName == "" {
+		return "", false
+	}
+	key, ok := s.CfsslConfig.AuthKeys[p.AuthKeyName]
+	return key.Key, ok && key.Key != ""
+}
+
+// RequestAuth Replay-safe authentication of authsign and revoke
+type RequestAuth struct {
+	Window         int    `yaml:"window"`           // Freshness window in seconds
+	NonceStore     string `yaml:"nonce-store"`      // mysql, shared by the replicas, or memory for a single instance
+	NonceCacheSize int    `yaml:"nonce-cache-size"` // Nonces kept by the memory store
+	AllowLegacy    bool   `yaml:"allow-legacy"`     // Accept cfssl tokens on authsign and nonce signatures on revoke
+}
+
 type HTTP struct {
-	OcspListen string `yaml:"ocsp-listen"`
-	CaListen   string `yaml:"ca-listen"`
-	Listen     string `yaml:"listen"`
+	OcspListen string    `yaml:"ocsp-listen"`
+	CaListen   string    `yaml:"ca-listen"`
+	Listen     string    `yaml:"listen"`
+	TLS        TLSPolicy `yaml:"tls"`
+	Auth       APIAuth   `yaml:"auth"`
+}
+
+// APIAuth Authentication of the admin API, roles are viewer, operator and admin
+type APIAuth struct {
+	Enabled bool `yaml:"enabled"`
+	// BootstrapTokenHash hex SHA-256 of an admin token, used to create the first API tokens
+	BootstrapTokenHash string   `yaml:"bootstrap-token-hash"`
+	MTLS               MTLSAuth `yaml:"mtls"`
+	OIDC               OIDCAuth `yaml:"oidc"`
+	// Insecure Serve the admin API without authentication when disabled, refused otherwise
+	Insecure bool `yaml:"insecure"`
+}
+
+// MTLSAuth Client certificates issued by this CA, needs http.tls.api with client-auth optional or require
+type MTLSAuth struct {
+	Enabled bool          `yaml:"enabled"`
+	Roles   []RoleBinding `yaml:"roles"`
+}
+
+// OIDCAuth Bearer tokens of an OpenID Connect provider
+type OIDCAuth struct {
+	Enabled   bool          `yaml:"enabled"`
+	Issuer    string        `yaml:"issuer"`
+	Audience  string        `yaml:"audience"`
+	JWKSURL   string        `yaml:"jwks-url"`
+	RoleClaim string        `yaml:"role-claim"`
+	Roles     []RoleBinding `yaml:"roles"`
+}
+
+// RoleBinding Grant a role to a SPIFFE ID or unique ID (mtls) or to a value of the role claim (oidc)
+type RoleBinding struct {
+	Match string `yaml:"match"`
+	Role  string `yaml:"role"`
+}
+
+// TLSPolicy Protocol settings shared by the listeners, client-auth is one of none, request, optional, require
+type TLSPolicy struct {
+	MinVersion   string      `yaml:"min-version"`
+	CipherSuites []string    `yaml:"cipher-suites"`
+	Curves       []string    `yaml:"curves"`
+	ALPN         []string    `yaml:"alpn"`
+	ClientAuth   string      `yaml:"client-auth"`
+	API          TLSListener `yaml:"api"`
+	Ocsp         TLSListener `yaml:"ocsp"`
+}
+
+// TLSListener The API and OCSP listeners serve plain HTTP unless enabled
+type TLSListener struct {
+	Enabled    bool   `yaml:"enabled"`
+	ClientAuth string `yaml:"client-auth"`
 }
 type Mysql struct {
 	Dsn string `yaml:"dsn"`
@@ -85,9 +304,60 @@ type CsrTemplates struct {
 	IntermediateCa IntermediateCa `yaml:"intermediate-ca"`
 }
 type Keymanager struct {
-	UpperCa      []string     `yaml:"upper-ca"`
-	SelfSign     bool         `yaml:"self-sign"`
-	CsrTemplates CsrTemplates `yaml:"csr-templates"`
+	UpperCa         []string        `yaml:"upper-ca"`
+	UpperCaTLS      UpperCaTLS      `yaml:"upper-ca-tls"`
+	UpperCaFailover UpperCaFailover `yaml:"upper-ca-failover"`
+	SelfSign        bool            `yaml:"self-sign"`
+	OfflineRoot     bool            `yaml:"offline-root"` // The certificate signed by an offline root is imported with `zaca ca import`
+	ManualEnroll    ManualEnroll    `yaml:"manual-enroll"`
+	CsrTemplates    CsrTemplates    `yaml:"csr-templates"`
+	NameConstraints NameConstraints `yaml:"name-constraints"`
+}
+
+// UpperCaTLS Verification of the upper CA servers, they present CA certificates without host names
+type UpperCaTLS struct {
+	CaFile     string   `yaml:"ca-file"`     // Pinned root bundle
+	SpkiPins   []string `yaml:"spki-pins"`   // base64 SHA-256 of a public key of the server chain, any match is accepted
+	ServerName bool     `yaml:"server-name"` // Also verify the host name
+	ClientCert bool     `yaml:"client-cert"` // Mutual TLS with the certificate of this CA
+	Insecure   bool     `yaml:"insecure"`    // Accept unverified servers when neither ca-file nor spki-pins is set
+}
+
+// UpperCaFailover Selection of the upper CA, retries and circuit breaking
+type UpperCaFailover struct {
+	Priorities       map[string]int `yaml:"priorities"`        // By host:port, lower first, default 0
+	Timeout          int            `yaml:"timeout"`           // Seconds per call
+	Retries          int            `yaml:"retries"`           // Rounds over the upper CAs after the first one failed
+	BackoffBase      int            `yaml:"backoff-base"`      // Milliseconds before the second round, doubled after each
+	BackoffMax       int            `yaml:"backoff-max"`       // Milliseconds
+	FailureThreshold int            `yaml:"failure-threshold"` // Consecutive failures opening the circuit of an upper CA
+	OpenDuration     int            `yaml:"open-duration"`     // Seconds before a trial call
+}
+
+// Disconnected No upper CA is called, the certificate of this CA is installed by an operator
+func (k Keymanager) Disconnected() bool {
+	return k.OfflineRoot || k.ManualEnroll.Enabled
+}
+
+// ManualEnroll The key stays here, its request is signed out of band and the certificate imported
+type ManualEnroll struct {
+	Enabled      bool   `yaml:"enabled"`
+	CSRFile      string `yaml:"csr-file"`      // Also write the pending request here
+	PollInterval int    `yaml:"poll-interval"` // Seconds between checks for the imported certificate
+}
+
+// NameConstraints Constraints added to the intermediate CA certificates signed by this CA
+type NameConstraints struct {
+	Enabled      bool                        `yaml:"enabled"`
+	Critical     bool                        `yaml:"critical"`
+	Subordinates []SubordinateNameConstraint `yaml:"subordinates"`
+}
+
+// SubordinateNameConstraint Allow-list registered for one subordinate, matched by the OU of its CSR
+type SubordinateNameConstraint struct {
+	Ou           string   `yaml:"ou"`
+	PermittedDNS []string `yaml:"permitted-dns"`
+	PermittedURI []string `yaml:"permitted-uri"`
 }
 type Vault struct {
 	Enabled bool   `yaml:"enabled"`
@@ -15,11 +15,13 @@ package core
 
 import (
 	"context"
+	"time"
 
 	vaultAPI "github.com/hashicorp/vault/api"
 	"github.com/ztalab/ZACA/core/config"
 	"github.com/ztalab/ZACA/pkg/influxdb"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/reqauth"
 	"github.com/ztalab/ZACA/pkg/vaultsecret"
 	"gorm.io/gorm"
 )
@@ -32,9 +34,43 @@ type Config struct {
 // Is ...
 var Is *I
 
-// Elector ...
+// Elector Leader election of the replicas of the service, see pkg/election
 type Elector interface {
 	IsLeader() bool
+	// Token Fencing token of the current leadership
+	Token() int64
+	// Fence Error when the lease was taken over by another replica
+	Fence(ctx context.Context) error
+	// Go Run a singleton job while this replica leads
+	Go(ctx context.Context, name string, job func(ctx context.Context))
+}
+
+// Singleton Run job on the leader only, on every replica when no elector is configured
+func Singleton(ctx context.Context, name string, job func(ctx context.Context)) {
+	if Is.Elector == nil {
+		go job(ctx)
+		return
+	}
+	Is.Elector.Go(ctx, name, job)
+}
+
+// Fence Check that this replica still leads before a side effect a second leader must not repeat,
+// nil when no elector is configured
+func Fence(ctx context.Context) error {
+	if Is.Elector == nil {
+		return nil
+	}
+	return Is.Elector.Fence(ctx)
+}
+
+// RequestVerifier Verifier of replay-safe requests, with nonces shared by the replicas unless the memory store is configured
+func RequestVerifier() *reqauth.Verifier {
+	conf := Is.Config.Singleca.RequestAuth
+	window := time.Duration(conf.Window) * time.Second
+	if conf.NonceStore == "memory" || Is.Db == nil {
+		return reqauth.NewVerifier(window, conf.NonceCacheSize)
+	}
+	return reqauth.NewVerifierWithStore(window, reqauth.NewMysqlStore(Is.Db))
 }
 
 // Logger ...
@@ -0,0 +1,77 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+)
+
+// GetAllAuditEvents is a function to get a slice of record(s) from audit_events table in the cap database
+// params - page     - page requested (defaults to 0)
+// params - pagesize - number of records in a page  (defaults to 20)
+// params - order    - db sort order column
+// error - ErrNotFound, db Find error
+func GetAllAuditEvents(db *gorm.DB, page, pagesize int, order string) (results []*model.AuditEvent, totalRows int64, err error) {
+
+	resultOrm := db.Model(&model.AuditEvent{})
+	resultOrm.Count(&totalRows)
+
+	if page > 0 {
+		offset := (page - 1) * pagesize
+		resultOrm = resultOrm.Offset(offset).Limit(pagesize)
+	} else {
+		resultOrm = resultOrm.Limit(pagesize)
+	}
+
+	if order != "" {
+		resultOrm = resultOrm.Order(order)
+	}
+
+	if err = resultOrm.Find(&results).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return results, totalRows, nil
+		}
+		return nil, -1, err
+	}
+
+	return results, totalRows, nil
+}
+
+// GetAuditEvent is a function to get a single record from the audit_events table in the cap database
+// error - ErrNotFound, db Find error
+func GetAuditEvent(db *gorm.DB) (record *model.AuditEvent, err error) {
+	record = &model.AuditEvent{}
+	if err = db.First(record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return record, err
+	}
+
+	return record, nil
+}
+
+// AddAuditEvent is a function to add a single record to audit_events table in the cap database
+// error - ErrInsertFailed, db save call failed
+func AddAuditEvent(db *gorm.DB, record *model.AuditEvent) (result *model.AuditEvent, RowsAffected int64, err error) {
+	query := db.Save(record)
+	if err = query.Error; err != nil {
+		return nil, -1, ErrInsertFailed
+	}
+
+	return record, query.RowsAffected, nil
+}
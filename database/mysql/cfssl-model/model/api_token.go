@@ -0,0 +1,63 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `api_token` (
+  `id` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `role` varchar(16) NOT NULL,
+  `created_by` varchar(128) DEFAULT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `last_used_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash_idx` (`token_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// APIToken struct is a row record of the api_token table in the cap database
+// Only the SHA-256 of the token is stored
+type APIToken struct {
+	ID         uint32         `gorm:"primary_key;AUTO_INCREMENT;column:id;type:uint;" json:"id" db:"id"`
+	Name       string         `gorm:"column:name;type:varchar;size:64;" json:"name" db:"name"`
+	TokenHash  string         `gorm:"column:token_hash;type:varchar;size:64;" json:"-" db:"token_hash"`
+	Role       string         `gorm:"column:role;type:varchar;size:16;" json:"role" db:"role"`
+	CreatedBy  sql.NullString `gorm:"column:created_by;type:varchar;size:128;" json:"created_by" db:"created_by"`
+	ExpiresAt  null.Time      `gorm:"column:expires_at;type:timestamp;" json:"expires_at" db:"expires_at"`
+	LastUsedAt null.Time      `gorm:"column:last_used_at;type:timestamp;" json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time      `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time      `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+	DeletedAt  null.Time      `gorm:"column:deleted_at;type:timestamp;" json:"deleted_at" db:"deleted_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (a *APIToken) TableName() string {
+	return "api_token"
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `audit_events` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `seq` bigint(20) unsigned NULL,
+  `category` varchar(32) NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `operator` varchar(255) NOT NULL,
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `unique_id` varchar(255) NOT NULL DEFAULT '',
+  `serial_number` varchar(128) NOT NULL DEFAULT '',
+  `object` text NOT NULL,
+  `prev_hash` char(64) NULL,
+  `hash` char(64) NULL,
+  `created_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `seq_idx` (`seq`),
+  KEY `type_idx` (`type`),
+  KEY `unique_id_idx` (`unique_id`),
+  KEY `serial_number_idx` (`serial_number`),
+  KEY `request_id_idx` (`request_id`),
+  KEY `created_at_idx` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// AuditEvent struct is a row record of the audit_events table in the cap database
+// Rows are linked by hash once chained, see HashFields, seq is 0 and the hashes empty until then
+type AuditEvent struct {
+	ID           uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Seq          uint64    `gorm:"column:seq;type:ubigint;" json:"seq" db:"seq"`
+	Category     string    `gorm:"column:category;type:varchar;size:32;" json:"category" db:"category"`
+	Type         string    `gorm:"column:type;type:varchar;size:32;" json:"type" db:"type"`
+	Operator     string    `gorm:"column:operator;type:varchar;size:255;" json:"operator" db:"operator"`
+	SourceIP     string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	RequestID    string    `gorm:"column:request_id;type:varchar;size:64;" json:"request_id" db:"request_id"`
+	UniqueID     string    `gorm:"column:unique_id;type:varchar;size:255;" json:"unique_id" db:"unique_id"`
+	SerialNumber string    `gorm:"column:serial_number;type:varchar;size:128;" json:"serial_number" db:"serial_number"`
+	Object       string    `gorm:"column:object;type:text;" json:"object" db:"object"`
+	PrevHash     string    `gorm:"column:prev_hash;type:char;size:64;" json:"prev_hash" db:"prev_hash"`
+	Hash         string    `gorm:"column:hash;type:char;size:64;" json:"hash" db:"hash"`
+	CreatedAt    time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (a *AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// HashFields Fields covered by the hash chain, created_at is truncated to microseconds as stored
+func (a *AuditEvent) HashFields() []string {
+	return []string{
+		a.Category,
+		a.Type,
+		a.Operator,
+		a.SourceIP,
+		a.RequestID,
+		a.UniqueID,
+		a.SerialNumber,
+		a.Object,
+		a.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	}
+}
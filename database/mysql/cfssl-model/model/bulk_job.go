@@ -0,0 +1,80 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `bulk_job` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `kind` varchar(16) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `selector` json NOT NULL,
+  `cursor_sn` varchar(128) NOT NULL DEFAULT '',
+  `cursor_aki` varchar(128) NOT NULL DEFAULT '',
+  `total` bigint(20) NOT NULL DEFAULT '0',
+  `processed` bigint(20) NOT NULL DEFAULT '0',
+  `error` text,
+  `cancel_requested` tinyint(1) NOT NULL DEFAULT '0',
+  `created_by` varchar(255) NOT NULL DEFAULT '',
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `owner` varchar(255) NOT NULL DEFAULT '',
+  `lease_expires_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `started_at` timestamp NULL DEFAULT NULL,
+  `finished_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `status_idx` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// BulkJob struct is a row record of the bulk_job table in the cap database
+// The cursor is the last certificate processed, or the last unique_id for forbid jobs
+type BulkJob struct {
+	ID              uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Kind            string    `gorm:"column:kind;type:varchar;size:16;" json:"kind" db:"kind"`
+	Status          string    `gorm:"column:status;type:varchar;size:16;" json:"status" db:"status"`
+	Selector        string    `gorm:"column:selector;type:json;" json:"selector" db:"selector"`
+	CursorSN        string    `gorm:"column:cursor_sn;type:varchar;size:128;" json:"-" db:"cursor_sn"`
+	CursorAKI       string    `gorm:"column:cursor_aki;type:varchar;size:128;" json:"-" db:"cursor_aki"`
+	Total           int64     `gorm:"column:total;type:bigint;" json:"total" db:"total"`
+	Processed       int64     `gorm:"column:processed;type:bigint;" json:"processed" db:"processed"`
+	Error           string    `gorm:"column:error;type:text;" json:"error" db:"error"`
+	CancelRequested bool      `gorm:"column:cancel_requested;type:tinyint;" json:"cancel_requested" db:"cancel_requested"`
+	CreatedBy       string    `gorm:"column:created_by;type:varchar;size:255;" json:"created_by" db:"created_by"`
+	SourceIP        string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	RequestID       string    `gorm:"column:request_id;type:varchar;size:64;" json:"request_id" db:"request_id"`
+	Owner           string    `gorm:"column:owner;type:varchar;size:255;" json:"owner" db:"owner"`
+	LeaseExpiresAt  null.Time `gorm:"column:lease_expires_at;type:timestamp;" json:"-" db:"lease_expires_at"`
+	CreatedAt       time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	StartedAt       null.Time `gorm:"column:started_at;type:timestamp;" json:"started_at" db:"started_at"`
+	FinishedAt      null.Time `gorm:"column:finished_at;type:timestamp;" json:"finished_at" db:"finished_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (b *BulkJob) TableName() string {
+	return "bulk_job"
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `cascade_revocation` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `direction` varchar(16) NOT NULL,
+  `upstream_id` bigint(20) unsigned DEFAULT NULL,
+  `name` varchar(128) NOT NULL,
+  `serial_numbers` json NOT NULL,
+  `subject_key_ids` json NOT NULL,
+  `reason` int(11) NOT NULL DEFAULT '0',
+  `comment` varchar(255) NOT NULL DEFAULT '',
+  `url` varchar(255) NOT NULL DEFAULT '',
+  `status` varchar(16) NOT NULL,
+  `attempts` int(11) NOT NULL DEFAULT '0',
+  `last_error` text,
+  `progress` json DEFAULT NULL,
+  `created_by` varchar(255) NOT NULL DEFAULT '',
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `created_at` timestamp NULL DEFAULT NULL,
+  `delivered_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `upstream_id_idx` (`upstream_id`),
+  KEY `direction_status_idx` (`direction`,`status`),
+  KEY `name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// CascadeRevocation struct is a row record of the cascade_revocation table in the cap database
+// Downstream rows are revocations of a subordinate CA to deliver, upstream rows were received from the upper CA
+type CascadeRevocation struct {
+	ID            uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Direction     string    `gorm:"column:direction;type:varchar;size:16;" json:"direction" db:"direction"`
+	UpstreamID    null.Int  `gorm:"column:upstream_id;type:ubigint;" json:"upstream_id" db:"upstream_id"`
+	Name          string    `gorm:"column:name;type:varchar;size:128;" json:"name" db:"name"`
+	SerialNumbers string    `gorm:"column:serial_numbers;type:json;" json:"serial_numbers" db:"serial_numbers"`
+	SubjectKeyIDs string    `gorm:"column:subject_key_ids;type:json;" json:"subject_key_ids" db:"subject_key_ids"`
+	Reason        int       `gorm:"column:reason;type:int;" json:"reason" db:"reason"`
+	Comment       string    `gorm:"column:comment;type:varchar;size:255;" json:"comment" db:"comment"`
+	URL           string    `gorm:"column:url;type:varchar;size:255;" json:"url" db:"url"`
+	Status        string    `gorm:"column:status;type:varchar;size:16;" json:"status" db:"status"`
+	Attempts      int       `gorm:"column:attempts;type:int;" json:"attempts" db:"attempts"`
+	LastError     string    `gorm:"column:last_error;type:text;" json:"last_error" db:"last_error"`
+	Progress      string    `gorm:"column:progress;type:json;" json:"progress" db:"progress"`
+	CreatedBy     string    `gorm:"column:created_by;type:varchar;size:255;" json:"created_by" db:"created_by"`
+	SourceIP      string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	RequestID     string    `gorm:"column:request_id;type:varchar;size:64;" json:"request_id" db:"request_id"`
+	CreatedAt     time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	DeliveredAt   null.Time `gorm:"column:delivered_at;type:timestamp;" json:"delivered_at" db:"delivered_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (c *CascadeRevocation) TableName() string {
+	return "cascade_revocation"
+}
@@ -47,6 +47,9 @@ CREATE TABLE `certificates` (
   `metadata` json DEFAULT NULL,
   `sans` json DEFAULT NULL,
   `common_name` text,
+  `invalidity_date` timestamp NULL DEFAULT NULL,
+  `hold_until` timestamp NULL DEFAULT NULL,
+  `revocation_comment` varchar(255) DEFAULT NULL,
   PRIMARY KEY (`serial_number`,`authority_key_identifier`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
 
@@ -86,6 +89,12 @@ type Certificates struct {
 	Sans sql.NullString `gorm:"column:sans;type:json;" json:"sans" db:"sans"`
 	//[12] common_name                                    text(65535)          null: true   primary: false  isArray: false  auto: false  col: text            len: 65535   default: []
 	CommonName sql.NullString `gorm:"column:common_name;type:text;size:65535;" json:"common_name" db:"common_name"`
+	//[13] invalidity_date                                timestamp            null: true   primary: false  isArray: false  auto: false  col: timestamp       len: -1      default: []
+	InvalidityDate null.Time `gorm:"column:invalidity_date;type:timestamp;" json:"invalidity_date" db:"invalidity_date"`
+	//[14] hold_until                                     timestamp            null: true   primary: false  isArray: false  auto: false  col: timestamp       len: -1      default: []
+	HoldUntil null.Time `gorm:"column:hold_until;type:timestamp;" json:"hold_until" db:"hold_until"`
+	//[15] revocation_comment                             varchar(255)         null: true   primary: false  isArray: false  auto: false  col: varchar         len: 255     default: []
+	RevocationComment sql.NullString `gorm:"column:revocation_comment;type:varchar;size:255;" json:"revocation_comment" db:"revocation_comment"`
 }
 
 var certificatesTableInfo = &TableInfo{
@@ -364,6 +373,69 @@ var certificatesTableInfo = &TableInfo{
 			ProtobufType:       "string",
 			ProtobufPos:        13,
 		},
+
+		&ColumnInfo{
+			Index:              13,
+			Name:               "invalidity_date",
+			Comment:            ``,
+			Notes:              ``,
+			Nullable:           true,
+			DatabaseTypeName:   "timestamp",
+			DatabaseTypePretty: "timestamp",
+			IsPrimaryKey:       false,
+			IsAutoIncrement:    false,
+			IsArray:            false,
+			ColumnType:         "timestamp",
+			ColumnLength:       -1,
+			GoFieldName:        "InvalidityDate",
+			GoFieldType:        "null.Time",
+			JSONFieldName:      "invalidity_date",
+			ProtobufFieldName:  "invalidity_date",
+			ProtobufType:       "uint64",
+			ProtobufPos:        14,
+		},
+
+		&ColumnInfo{
+			Index:              14,
+			Name:               "hold_until",
+			Comment:            ``,
+			Notes:              ``,
+			Nullable:           true,
+			DatabaseTypeName:   "timestamp",
+			DatabaseTypePretty: "timestamp",
+			IsPrimaryKey:       false,
+			IsAutoIncrement:    false,
+			IsArray:            false,
+			ColumnType:         "timestamp",
+			ColumnLength:       -1,
+			GoFieldName:        "HoldUntil",
+			GoFieldType:        "null.Time",
+			JSONFieldName:      "hold_until",
+			ProtobufFieldName:  "hold_until",
+			ProtobufType:       "uint64",
+			ProtobufPos:        15,
+		},
+
+		&ColumnInfo{
+			Index:              15,
+			Name:               "revocation_comment",
+			Comment:            ``,
+			Notes:              ``,
+			Nullable:           true,
+			DatabaseTypeName:   "varchar",
+			DatabaseTypePretty: "varchar(255)",
+			IsPrimaryKey:       false,
+			IsAutoIncrement:    false,
+			IsArray:            false,
+			ColumnType:         "varchar",
+			ColumnLength:       255,
+			GoFieldName:        "RevocationComment",
+			GoFieldType:        "sql.NullString",
+			JSONFieldName:      "revocation_comment",
+			ProtobufFieldName:  "revocation_comment",
+			ProtobufType:       "string",
+			ProtobufPos:        16,
+		},
 	},
 }
 
@@ -0,0 +1,77 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `change_request` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `operation` varchar(32) NOT NULL,
+  `subject` varchar(255) NOT NULL DEFAULT '',
+  `summary` varchar(512) NOT NULL DEFAULT '',
+  `params` json NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `requested_by` varchar(255) NOT NULL DEFAULT '',
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `reviewed_by` varchar(255) NOT NULL DEFAULT '',
+  `review_comment` varchar(255) NOT NULL DEFAULT '',
+  `reviewed_at` timestamp NULL DEFAULT NULL,
+  `result` text,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `executed_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `status_expires_idx` (`status`,`expires_at`),
+  KEY `operation_subject_idx` (`operation`,`subject`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// ChangeRequest struct is a row record of the change_request table in the cap database
+// A protected operation waiting for the approval of a second operator
+type ChangeRequest struct {
+	ID            uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Operation     string    `gorm:"column:operation;type:varchar;size:32;" json:"operation" db:"operation"`
+	Subject       string    `gorm:"column:subject;type:varchar;size:255;" json:"subject" db:"subject"`
+	Summary       string    `gorm:"column:summary;type:varchar;size:512;" json:"summary" db:"summary"`
+	Params        string    `gorm:"column:params;type:json;" json:"params" db:"params"`
+	Status        string    `gorm:"column:status;type:varchar;size:16;" json:"status" db:"status"`
+	RequestedBy   string    `gorm:"column:requested_by;type:varchar;size:255;" json:"requested_by" db:"requested_by"`
+	SourceIP      string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	RequestID     string    `gorm:"column:request_id;type:varchar;size:64;" json:"request_id" db:"request_id"`
+	ReviewedBy    string    `gorm:"column:reviewed_by;type:varchar;size:255;" json:"reviewed_by" db:"reviewed_by"`
+	ReviewComment string    `gorm:"column:review_comment;type:varchar;size:255;" json:"review_comment" db:"review_comment"`
+	ReviewedAt    null.Time `gorm:"column:reviewed_at;type:timestamp;" json:"reviewed_at" db:"reviewed_at"`
+	Result        string    `gorm:"column:result;type:text;" json:"result" db:"result"`
+	ExpiresAt     time.Time `gorm:"column:expires_at;type:timestamp;" json:"expires_at" db:"expires_at"`
+	ExecutedAt    null.Time `gorm:"column:executed_at;type:timestamp;" json:"executed_at" db:"executed_at"`
+	CreatedAt     time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (c *ChangeRequest) TableName() string {
+	return "change_request"
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `event_dead_letter` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `sink` varchar(64) NOT NULL,
+  `event_id` varchar(64) NOT NULL,
+  `category` varchar(32) NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `payload` text NOT NULL,
+  `error` varchar(1024) NOT NULL DEFAULT '',
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `sink_idx` (`sink`),
+  KEY `created_at_idx` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// EventDeadLetter struct is a row record of the event_dead_letter table in the cap database
+// Events an event sink failed to deliver
+type EventDeadLetter struct {
+	ID        uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Sink      string    `gorm:"column:sink;type:varchar;size:64;" json:"sink" db:"sink"`
+	EventID   string    `gorm:"column:event_id;type:varchar;size:64;" json:"event_id" db:"event_id"`
+	Category  string    `gorm:"column:category;type:varchar;size:32;" json:"category" db:"category"`
+	Type      string    `gorm:"column:type;type:varchar;size:32;" json:"type" db:"type"`
+	Payload   string    `gorm:"column:payload;type:text;" json:"payload" db:"payload"`
+	Error     string    `gorm:"column:error;type:varchar;size:1024;" json:"error" db:"error"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (e *EventDeadLetter) TableName() string {
+	return "event_dead_letter"
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `expiry_alert` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `kind` varchar(16) NOT NULL,
+  `serial_number` varchar(128) NOT NULL,
+  `authority_key_identifier` varchar(128) NOT NULL,
+  `threshold` bigint(20) NOT NULL,
+  `common_name` varchar(255) NOT NULL DEFAULT '',
+  `expiry` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `channel` varchar(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `cert_threshold_channel_idx` (`serial_number`,`authority_key_identifier`,`threshold`,`channel`),
+  KEY `expiry_idx` (`expiry`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// ExpiryAlert struct is a row record of the expiry_alert table in the cap database
+// One row per certificate, threshold and channel already notified, threshold is in seconds.
+// Rows without channel were claimed for all channels before they were tracked separately
+type ExpiryAlert struct {
+	ID                     uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Kind                   string    `gorm:"column:kind;type:varchar;size:16;" json:"kind" db:"kind"`
+	SerialNumber           string    `gorm:"column:serial_number;type:varchar;size:128;" json:"serial_number" db:"serial_number"`
+	AuthorityKeyIdentifier string    `gorm:"column:authority_key_identifier;type:varchar;size:128;" json:"authority_key_identifier" db:"authority_key_identifier"`
+	Threshold              int64     `gorm:"column:threshold;type:bigint;" json:"threshold" db:"threshold"`
+	CommonName             string    `gorm:"column:common_name;type:varchar;size:255;" json:"common_name" db:"common_name"`
+	Expiry                 time.Time `gorm:"column:expiry;type:timestamp;" json:"expiry" db:"expiry"`
+	CreatedAt              time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	Channel                string    `gorm:"column:channel;type:varchar;size:64;" json:"channel" db:"channel"`
+}
+
+// TableName sets the insert table name for this struct type
+func (e *ExpiryAlert) TableName() string {
+	return "expiry_alert"
+}
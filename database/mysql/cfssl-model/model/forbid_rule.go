@@ -0,0 +1,88 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `forbid_rule` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `kind` varchar(32) NOT NULL,
+  `pattern` varchar(255) NOT NULL,
+  `effect` varchar(16) NOT NULL,
+  `max_ttl` int(11) NOT NULL DEFAULT '0',
+  `reason` varchar(255) NOT NULL DEFAULT '',
+  `created_by` varchar(255) NOT NULL DEFAULT '',
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `kind_pattern_idx` (`kind`,`pattern`),
+  KEY `deleted_at_idx` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+CREATE TABLE `forbid_revision` (
+  `id` tinyint(3) unsigned NOT NULL,
+  `revision` bigint(20) unsigned NOT NULL DEFAULT '0',
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// ForbidRule struct is a row record of the forbid_rule table in the cap database
+// Pattern-based rules refusing or shortening certificates, exact unique_ids stay in the forbid table
+type ForbidRule struct {
+	ID        uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Kind      string    `gorm:"column:kind;type:varchar;size:32;" json:"kind" db:"kind"`
+	Pattern   string    `gorm:"column:pattern;type:varchar;size:255;" json:"pattern" db:"pattern"`
+	Effect    string    `gorm:"column:effect;type:varchar;size:16;" json:"effect" db:"effect"`
+	MaxTTL    int       `gorm:"column:max_ttl;type:int;" json:"max_ttl" db:"max_ttl"`
+	Reason    string    `gorm:"column:reason;type:varchar;size:255;" json:"reason" db:"reason"`
+	CreatedBy string    `gorm:"column:created_by;type:varchar;size:255;" json:"created_by" db:"created_by"`
+	SourceIP  string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	RequestID string    `gorm:"column:request_id;type:varchar;size:64;" json:"request_id" db:"request_id"`
+	ExpiresAt null.Time `gorm:"column:expires_at;type:timestamp;" json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;" json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+	DeletedAt null.Time `gorm:"column:deleted_at;type:timestamp;" json:"deleted_at" db:"deleted_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (f *ForbidRule) TableName() string {
+	return "forbid_rule"
+}
+
+// ForbidRevision struct is the single row of the forbid_revision table, bumped on every change of the forbid rules
+type ForbidRevision struct {
+	ID        uint8     `gorm:"primary_key;column:id;type:utinyint;" json:"id" db:"id"`
+	Revision  uint64    `gorm:"column:revision;type:ubigint;" json:"revision" db:"revision"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (f *ForbidRevision) TableName() string {
+	return "forbid_revision"
+}
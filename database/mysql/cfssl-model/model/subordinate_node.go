@@ -0,0 +1,79 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+/*
+DB Table Details
+-------------------------------------
+
+
+CREATE TABLE `subordinate_node` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(128) NOT NULL,
+  `site_id` varchar(128) NOT NULL DEFAULT '',
+  `cluster_id` varchar(128) NOT NULL DEFAULT '',
+  `version` varchar(64) NOT NULL DEFAULT '',
+  `hostname` varchar(255) NOT NULL DEFAULT '',
+  `serial_number` varchar(128) NOT NULL DEFAULT '',
+  `authority_key_identifier` varchar(128) NOT NULL DEFAULT '',
+  `not_after` timestamp NULL DEFAULT NULL,
+  `issued_total` bigint(20) NOT NULL DEFAULT '0',
+  `issued_hour` bigint(20) NOT NULL DEFAULT '0',
+  `issued_day` bigint(20) NOT NULL DEFAULT '0',
+  `revoked_total` bigint(20) NOT NULL DEFAULT '0',
+  `source_ip` varchar(64) NOT NULL DEFAULT '',
+  `stale` tinyint(1) NOT NULL DEFAULT '0',
+  `first_seen_at` timestamp NULL DEFAULT NULL,
+  `last_seen_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name_idx` (`name`),
+  KEY `stale_last_seen_idx` (`stale`,`last_seen_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+
+*/
+
+// SubordinateNode struct is a row record of the subordinate_node table in the cap database
+// Latest heartbeat of a subordinate CA, by the OU of its intermediate certificate
+type SubordinateNode struct {
+	ID                     uint64    `gorm:"primary_key;AUTO_INCREMENT;column:id;type:ubigint;" json:"id" db:"id"`
+	Name                   string    `gorm:"column:name;type:varchar;size:128;" json:"name" db:"name"`
+	SiteID                 string    `gorm:"column:site_id;type:varchar;size:128;" json:"site_id" db:"site_id"`
+	ClusterID              string    `gorm:"column:cluster_id;type:varchar;size:128;" json:"cluster_id" db:"cluster_id"`
+	Version                string    `gorm:"column:version;type:varchar;size:64;" json:"version" db:"version"`
+	Hostname               string    `gorm:"column:hostname;type:varchar;size:255;" json:"hostname" db:"hostname"`
+	SerialNumber           string    `gorm:"column:serial_number;type:varchar;size:128;" json:"serial_number" db:"serial_number"`
+	AuthorityKeyIdentifier string    `gorm:"column:authority_key_identifier;type:varchar;size:128;" json:"authority_key_identifier" db:"authority_key_identifier"`
+	NotAfter               null.Time `gorm:"column:not_after;type:timestamp;" json:"not_after" db:"not_after"`
+	IssuedTotal            int64     `gorm:"column:issued_total;type:bigint;" json:"issued_total" db:"issued_total"`
+	IssuedHour             int64     `gorm:"column:issued_hour;type:bigint;" json:"issued_hour" db:"issued_hour"`
+	IssuedDay              int64     `gorm:"column:issued_day;type:bigint;" json:"issued_day" db:"issued_day"`
+	RevokedTotal           int64     `gorm:"column:revoked_total;type:bigint;" json:"revoked_total" db:"revoked_total"`
+	SourceIP               string    `gorm:"column:source_ip;type:varchar;size:64;" json:"source_ip" db:"source_ip"`
+	Stale                  bool      `gorm:"column:stale;type:tinyint;" json:"stale" db:"stale"`
+	FirstSeenAt            time.Time `gorm:"column:first_seen_at;type:timestamp;" json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt             time.Time `gorm:"column:last_seen_at;type:timestamp;" json:"last_seen_at" db:"last_seen_at"`
+	UpdatedAt              time.Time `gorm:"column:updated_at;type:timestamp;" json:"updated_at" db:"updated_at"`
+}
+
+// TableName sets the insert table name for this struct type
+func (s *SubordinateNode) TableName() string {
+	return "subordinate_node"
+}
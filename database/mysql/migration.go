@@ -18,12 +18,16 @@ import (
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/ztalab/ZACA/pkg/logger"
 	_ "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+// MigrationsURL Migrations applied by every service at start
+const MigrationsURL = "file://database/mysql/migrations/"
+
 func Migrate(db *gorm.DB) error {
 	lo := logger.Named("migration")
 	sql, err := db.DB()
@@ -32,7 +36,7 @@ func Migrate(db *gorm.DB) error {
 	}
 	driver, err := mysql.WithInstance(sql, &mysql.Config{})
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://database/mysql/migrations/",
+		MigrationsURL,
 		"mysql", driver)
 	if err != nil {
 		return fmt.Errorf("migrate instance error: %v", err)
@@ -47,3 +51,41 @@ func Migrate(db *gorm.DB) error {
 	lo.Info("Migrations success.")
 	return nil
 }
+
+// MigrationStatus Applied and latest available versions, without migrating. applied is 0 before the first migration
+func MigrationStatus(db *gorm.DB) (applied, latest uint, dirty bool, err error) {
+	sql, err := db.DB()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to get DB instance: %v", err)
+	}
+	driver, err := mysql.WithInstance(sql, &mysql.Config{})
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("migrate driver error: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance(MigrationsURL, "mysql", driver)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("migrate instance error: %v", err)
+	}
+	applied, dirty, err = m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return 0, 0, false, err
+	}
+
+	src, err := source.Open(MigrationsURL)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("migrations source error: %v", err)
+	}
+	defer src.Close()
+	latest, err = src.First()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("migrations source error: %v", err)
+	}
+	for {
+		next, err := src.Next(latest)
+		if err != nil {
+			break
+		}
+		latest = next
+	}
+	return applied, latest, dirty, nil
+}
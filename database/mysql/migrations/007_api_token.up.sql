@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `api_token` (
+    `id` int(11) unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(64) NOT NULL,
+    `token_hash` varchar(64) NOT NULL,
+    `role` varchar(16) NOT NULL,
+    `created_by` varchar(128) DEFAULT NULL,
+    `expires_at` timestamp NULL DEFAULT NULL,
+    `last_used_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    `deleted_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    UNIQUE KEY `token_hash_idx` (`token_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
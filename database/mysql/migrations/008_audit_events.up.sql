@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `audit_events` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `seq` bigint(20) unsigned NOT NULL,
+    `category` varchar(32) NOT NULL,
+    `type` varchar(32) NOT NULL,
+    `operator` varchar(255) NOT NULL,
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `request_id` varchar(64) NOT NULL DEFAULT '',
+    `unique_id` varchar(255) NOT NULL DEFAULT '',
+    `serial_number` varchar(128) NOT NULL DEFAULT '',
+    `object` text NOT NULL,
+    `prev_hash` char(64) NOT NULL,
+    `hash` char(64) NOT NULL,
+    `created_at` timestamp(6) NOT NULL,
+    PRIMARY KEY `id` (`id`),
+    UNIQUE KEY `seq_idx` (`seq`),
+    KEY `type_idx` (`type`),
+    KEY `unique_id_idx` (`unique_id`),
+    KEY `serial_number_idx` (`serial_number`),
+    KEY `request_id_idx` (`request_id`),
+    KEY `created_at_idx` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
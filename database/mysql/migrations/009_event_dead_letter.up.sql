@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `event_dead_letter` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `sink` varchar(64) NOT NULL,
+    `event_id` varchar(64) NOT NULL,
+    `category` varchar(32) NOT NULL,
+    `type` varchar(32) NOT NULL,
+    `payload` text NOT NULL,
+    `error` varchar(1024) NOT NULL DEFAULT '',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    KEY `sink_idx` (`sink`),
+    KEY `created_at_idx` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS expiry_alert;
\ No newline at end of file
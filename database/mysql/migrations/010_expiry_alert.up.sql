@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `expiry_alert` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `kind` varchar(16) NOT NULL,
+    `serial_number` varchar(128) NOT NULL,
+    `authority_key_identifier` varchar(128) NOT NULL,
+    `threshold` bigint(20) NOT NULL,
+    `common_name` varchar(255) NOT NULL DEFAULT '',
+    `expiry` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    UNIQUE KEY `cert_threshold_idx` (`serial_number`, `authority_key_identifier`, `threshold`),
+    KEY `expiry_idx` (`expiry`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
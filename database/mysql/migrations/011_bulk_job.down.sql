@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bulk_job;
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `bulk_job` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `kind` varchar(16) NOT NULL,
+    `status` varchar(16) NOT NULL,
+    `selector` json NOT NULL,
+    `cursor_sn` varchar(128) NOT NULL DEFAULT '',
+    `cursor_aki` varchar(128) NOT NULL DEFAULT '',
+    `total` bigint(20) NOT NULL DEFAULT 0,
+    `processed` bigint(20) NOT NULL DEFAULT 0,
+    `error` text,
+    `cancel_requested` tinyint(1) NOT NULL DEFAULT 0,
+    `created_by` varchar(255) NOT NULL DEFAULT '',
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `request_id` varchar(64) NOT NULL DEFAULT '',
+    `owner` varchar(255) NOT NULL DEFAULT '',
+    `lease_expires_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    `started_at` timestamp NULL DEFAULT NULL,
+    `finished_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    KEY `status_idx` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1,6 @@
+DROP INDEX `hold_until_idx` ON `certificates`;
+
+ALTER TABLE `certificates`
+    DROP COLUMN `invalidity_date`,
+    DROP COLUMN `hold_until`,
+    DROP COLUMN `revocation_comment`;
@@ -0,0 +1,6 @@
+ALTER TABLE `certificates`
+    ADD COLUMN `invalidity_date` timestamp NULL DEFAULT NULL COMMENT 'Known or suspected time the certificate became invalid',
+    ADD COLUMN `hold_until` timestamp NULL DEFAULT NULL COMMENT 'certificateHold is released at this time',
+    ADD COLUMN `revocation_comment` varchar(255) DEFAULT NULL COMMENT 'Operator comment';
+
+CREATE INDEX `hold_until_idx` ON `certificates`(`hold_until`) USING BTREE;
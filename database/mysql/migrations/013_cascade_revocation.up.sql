@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS `cascade_revocation` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `direction` varchar(16) NOT NULL COMMENT 'downstream: sent to a subordinate CA, upstream: received from the upper CA',
+    `upstream_id` bigint(20) unsigned DEFAULT NULL COMMENT 'ID of the revocation at the upper CA',
+    `name` varchar(128) NOT NULL COMMENT 'OU of the subordinate CA',
+    `serial_numbers` json NOT NULL,
+    `subject_key_ids` json NOT NULL,
+    `reason` int(11) NOT NULL DEFAULT 0,
+    `comment` varchar(255) NOT NULL DEFAULT '',
+    `url` varchar(255) NOT NULL DEFAULT '',
+    `status` varchar(16) NOT NULL,
+    `attempts` int(11) NOT NULL DEFAULT 0,
+    `last_error` text,
+    `progress` json DEFAULT NULL,
+    `created_by` varchar(255) NOT NULL DEFAULT '',
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `request_id` varchar(64) NOT NULL DEFAULT '',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `delivered_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    UNIQUE KEY `upstream_id_idx` (`upstream_id`),
+    KEY `direction_status_idx` (`direction`, `status`),
+    KEY `name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS forbid_revision;
+DROP TABLE IF EXISTS forbid_rule;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `forbid_rule` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `kind` varchar(32) NOT NULL COMMENT 'site / cluster / unique_id / unique_id_prefix / unique_id_glob / dns',
+    `pattern` varchar(255) NOT NULL,
+    `effect` varchar(16) NOT NULL COMMENT 'deny / short_ttl',
+    `max_ttl` int(11) NOT NULL DEFAULT 0 COMMENT 'Seconds, short_ttl rules',
+    `reason` varchar(255) NOT NULL DEFAULT '',
+    `created_by` varchar(255) NOT NULL DEFAULT '',
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `request_id` varchar(64) NOT NULL DEFAULT '',
+    `expires_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    `deleted_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    KEY `kind_pattern_idx` (`kind`, `pattern`),
+    KEY `deleted_at_idx` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `forbid_revision` (
+    `id` tinyint(3) unsigned NOT NULL,
+    `revision` bigint(20) unsigned NOT NULL DEFAULT 0 COMMENT 'Bumped on every change of forbid and forbid_rule',
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO `forbid_revision` (`id`, `revision`, `updated_at`) VALUES (1, 0, NOW());
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `change_request` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `operation` varchar(32) NOT NULL COMMENT 'unit-revoke / unit-forbid / cascade-revoke / intermediate-sign / policy-change',
+    `subject` varchar(255) NOT NULL DEFAULT '' COMMENT 'What the operation applies to',
+    `summary` varchar(512) NOT NULL DEFAULT '',
+    `params` json NOT NULL,
+    `status` varchar(16) NOT NULL COMMENT 'pending / approved / rejected / expired / executed / failed',
+    `requested_by` varchar(255) NOT NULL DEFAULT '',
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `request_id` varchar(64) NOT NULL DEFAULT '',
+    `reviewed_by` varchar(255) NOT NULL DEFAULT '',
+    `review_comment` varchar(255) NOT NULL DEFAULT '',
+    `reviewed_at` timestamp NULL DEFAULT NULL,
+    `result` text,
+    `expires_at` timestamp NULL DEFAULT NULL,
+    `executed_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    KEY `status_expires_idx` (`status`, `expires_at`),
+    KEY `operation_subject_idx` (`operation`, `subject`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `subordinate_node` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(128) NOT NULL COMMENT 'OU of the intermediate certificate of the subordinate CA',
+    `site_id` varchar(128) NOT NULL DEFAULT '' COMMENT 'SPIFFE trust domain of the OU',
+    `cluster_id` varchar(128) NOT NULL DEFAULT '' COMMENT 'First SPIFFE path segment of the OU',
+    `version` varchar(64) NOT NULL DEFAULT '' COMMENT 'Software version reported by the subordinate',
+    `hostname` varchar(255) NOT NULL DEFAULT '',
+    `serial_number` varchar(128) NOT NULL DEFAULT '' COMMENT 'Certificate the subordinate signs with',
+    `authority_key_identifier` varchar(128) NOT NULL DEFAULT '',
+    `not_after` timestamp NULL DEFAULT NULL,
+    `issued_total` bigint(20) NOT NULL DEFAULT 0,
+    `issued_hour` bigint(20) NOT NULL DEFAULT 0,
+    `issued_day` bigint(20) NOT NULL DEFAULT 0,
+    `revoked_total` bigint(20) NOT NULL DEFAULT 0,
+    `source_ip` varchar(64) NOT NULL DEFAULT '',
+    `stale` tinyint(1) NOT NULL DEFAULT 0 COMMENT 'No heartbeat within the stale period',
+    `first_seen_at` timestamp NULL DEFAULT NULL,
+    `last_seen_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY `id` (`id`),
+    UNIQUE KEY `name_idx` (`name`),
+    KEY `stale_last_seen_idx` (`stale`, `last_seen_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS leader_lease;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `leader_lease` (
+    `name` varchar(64) NOT NULL COMMENT 'Lease of a service, held by one replica',
+    `holder` varchar(255) NOT NULL DEFAULT '' COMMENT 'Replica holding the lease',
+    `token` bigint(20) NOT NULL DEFAULT 0 COMMENT 'Fencing token, incremented each time the lease changes holder',
+    `expires_at` datetime(6) NOT NULL,
+    `updated_at` datetime(6) NOT NULL,
+    PRIMARY KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
@@ -0,0 +1,6 @@
+DELETE FROM `expiry_alert` WHERE `channel` <> '';
+
+ALTER TABLE `expiry_alert`
+    DROP INDEX `cert_threshold_channel_idx`,
+    DROP COLUMN `channel`,
+    ADD UNIQUE KEY `cert_threshold_idx` (`serial_number`, `authority_key_identifier`, `threshold`);
\ No newline at end of file
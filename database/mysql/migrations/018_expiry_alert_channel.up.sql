@@ -0,0 +1,4 @@
+ALTER TABLE `expiry_alert`
+    ADD COLUMN `channel` varchar(64) NOT NULL DEFAULT '' COMMENT 'Alert channel notified, empty for the rows claimed for all channels',
+    DROP INDEX `cert_threshold_idx`,
+    ADD UNIQUE KEY `cert_threshold_channel_idx` (`serial_number`, `authority_key_identifier`, `threshold`, `channel`);
@@ -0,0 +1,6 @@
+DELETE FROM `audit_events` WHERE `seq` IS NULL;
+
+ALTER TABLE `audit_events`
+    MODIFY `seq` bigint(20) unsigned NOT NULL,
+    MODIFY `prev_hash` char(64) NOT NULL,
+    MODIFY `hash` char(64) NOT NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE `audit_events`
+    MODIFY `seq` bigint(20) unsigned NULL COMMENT 'Position in the hash chain, NULL until the chainer links the event',
+    MODIFY `prev_hash` char(64) NULL,
+    MODIFY `hash` char(64) NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS request_nonce;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `request_nonce` (
+    `nonce` char(64) NOT NULL COMMENT 'Hex SHA-256 of a nonce of a replay-safe request',
+    `expires_at` timestamp(6) NOT NULL,
+    PRIMARY KEY (`nonce`),
+    KEY `expires_at_idx` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
\ No newline at end of file
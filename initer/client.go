@@ -24,26 +24,41 @@ import (
 	influx_client "github.com/ztalab/ZACA/pkg/influxdb/influxdb-client/v2"
 	mysqlDriver "gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/database/mysql"
 	"github.com/ztalab/ZACA/pkg/influxdb"
+	"github.com/ztalab/ZACA/pkg/tracing"
 )
 
 func mysqlDialer(config *core.Config, logger *core.Logger) (*gorm.DB, error) {
+	db, err := mysqlOpen(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = mysql.Migrate(db); err != nil {
+		logger.Errorf("MySQL Schema migrate error: %v", err)
+	}
+	return db, nil
+}
+
+// mysqlOpen Connect without migrating, gormLogger nil for the default logger
+func mysqlOpen(config *core.Config, gormLogger gormlogger.Interface) (*gorm.DB, error) {
 	db, err := gorm.Open(mysqlDriver.Open(config.Mysql.Dsn), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   gormLogger,
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err = db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
 	d, _ := db.DB()
 	if err = d.Ping(); err != nil {
 		return nil, err
 	}
-	if err = mysql.Migrate(db); err != nil {
-		logger.Errorf("MySQL Schema migrate error: %v", err)
-	}
 	return db, nil
 }
 
@@ -0,0 +1,68 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package initer
+
+import (
+	"github.com/urfave/cli"
+	"github.com/ztalab/cfssl/hook"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/vaultsecret"
+)
+
+// Probe Errors of the steps of InitProbe, nil when the step succeeded or is not configured
+type Probe struct {
+	ConfigErr error
+	MysqlErr  error
+	VaultErr  error
+	KeeperErr error
+}
+
+// InitProbe Wire the components like Init for diagnostics: a failed step is recorded instead of exiting,
+// migrations are not applied and no event bus is started. core.Is holds what could be wired
+func InitProbe(c *cli.Context) *Probe {
+	p := &Probe{}
+	conf, err := InitConfigs(c, "conf.yml")
+	if err != nil {
+		p.ConfigErr = err
+		return p
+	}
+	initLogger(&conf)
+	hook.EnableVaultStorage = conf.Vault.Enabled
+
+	l := &core.Logger{Logger: logger.S()}
+	i := &core.I{
+		Config: &conf,
+		Logger: l,
+	}
+	core.Is = i
+	// The report carries the errors, the standard output is kept for it
+	if i.Db, err = mysqlOpen(&conf, gormlogger.Default.LogMode(gormlogger.Silent)); err != nil {
+		p.MysqlErr = err
+	}
+	if hook.EnableVaultStorage {
+		vaultClient, err := vaultDialer(&conf, l)
+		if err != nil {
+			p.VaultErr = err
+		} else {
+			i.VaultClient = vaultClient
+			i.VaultSecret = vaultsecret.NewVaultSecret(vaultClient, conf.Vault.Prefix)
+		}
+	}
+	p.KeeperErr = keymanager.InitKeeper()
+	return p
+}
@@ -58,6 +58,102 @@ func InitConfigs(c *cli.Context, configURL string) (core.Config, error) {
 	if v := os.Getenv("IS_KEYMANAGER_SELF_SIGN"); v != "true" {
 		conf.Keymanager.SelfSign = true
 	}
+	if conf.Singleca.RequestAuth.Window <= 0 {
+		conf.Singleca.RequestAuth.Window = 300
+	}
+	if conf.Singleca.RequestAuth.NonceStore == "" {
+		conf.Singleca.RequestAuth.NonceStore = "mysql"
+	}
+	if conf.Singleca.RequestAuth.NonceCacheSize <= 0 {
+		conf.Singleca.RequestAuth.NonceCacheSize = 100000
+	}
+	if conf.Events.QueueSize <= 0 {
+		conf.Events.QueueSize = 1000
+	}
+	if conf.Events.Timeout <= 0 {
+		conf.Events.Timeout = 60
+	}
+	if conf.ExpiryMonitor.Interval <= 0 {
+		conf.ExpiryMonitor.Interval = 60
+	}
+	if len(conf.ExpiryMonitor.Thresholds["default"]) == 0 {
+		if conf.ExpiryMonitor.Thresholds == nil {
+			conf.ExpiryMonitor.Thresholds = make(map[string][]int)
+		}
+		conf.ExpiryMonitor.Thresholds["default"] = []int{30, 7, 1}
+	}
+	if len(conf.ExpiryMonitor.CAThresholds) == 0 {
+		conf.ExpiryMonitor.CAThresholds = []int{90, 30, 7}
+	}
+	if conf.Keymanager.UpperCaFailover.Timeout <= 0 {
+		conf.Keymanager.UpperCaFailover.Timeout = 10
+	}
+	if conf.Keymanager.UpperCaFailover.BackoffBase <= 0 {
+		conf.Keymanager.UpperCaFailover.BackoffBase = 200
+	}
+	if conf.Keymanager.UpperCaFailover.BackoffMax <= 0 {
+		conf.Keymanager.UpperCaFailover.BackoffMax = 5000
+	}
+	if conf.Keymanager.UpperCaFailover.FailureThreshold <= 0 {
+		conf.Keymanager.UpperCaFailover.FailureThreshold = 5
+	}
+	if conf.Keymanager.UpperCaFailover.OpenDuration <= 0 {
+		conf.Keymanager.UpperCaFailover.OpenDuration = 30
+	}
+	if conf.Keymanager.ManualEnroll.PollInterval <= 0 {
+		conf.Keymanager.ManualEnroll.PollInterval = 10
+	}
+	if conf.Registry.Interval <= 0 {
+		conf.Registry.Interval = 60
+	}
+	if conf.Registry.StaleAfter <= 0 {
+		conf.Registry.StaleAfter = 5 * conf.Registry.Interval
+	}
+	if conf.Topology.CacheTTL <= 0 {
+		conf.Topology.CacheTTL = 60
+	}
+	if conf.Topology.Timeout <= 0 {
+		conf.Topology.Timeout = 10
+	}
+	if conf.Topology.MaxDepth <= 0 {
+		conf.Topology.MaxDepth = 8
+	}
+	if conf.Topology.ExpiryWarning <= 0 {
+		conf.Topology.ExpiryWarning = 30
+	}
+	if conf.Election.LeaseTTL <= 0 {
+		conf.Election.LeaseTTL = 15
+	}
+	if conf.Election.RenewInterval <= 0 || conf.Election.RenewInterval >= conf.Election.LeaseTTL {
+		conf.Election.RenewInterval = conf.Election.LeaseTTL / 3
+	}
+	if conf.Jobs.BatchSize <= 0 {
+		conf.Jobs.BatchSize = 500
+	}
+	if conf.Jobs.PollInterval <= 0 {
+		conf.Jobs.PollInterval = 5
+	}
+	if conf.Jobs.Lease <= 0 {
+		conf.Jobs.Lease = 120
+	}
+	if conf.Cascade.Interval <= 0 {
+		conf.Cascade.Interval = 30
+	}
+	if conf.Forbid.RefreshInterval <= 0 {
+		conf.Forbid.RefreshInterval = 5
+	}
+	if conf.Approval.TTL <= 0 {
+		conf.Approval.TTL = 24
+	}
+	if conf.Crl.Validity <= 0 {
+		conf.Crl.Validity = 24
+	}
+	if conf.Crl.CacheTime <= 0 {
+		conf.Crl.CacheTime = 5
+	}
+	if conf.Inventory.Interval <= 0 {
+		conf.Inventory.Interval = 5
+	}
 	// ref: https://github.com/golang-migrate/migrate/issues/313
 	if !strings.Contains(conf.Mysql.Dsn, "multiStatements") {
 		conf.Mysql.Dsn += "&multiStatements=true"
@@ -68,6 +164,7 @@ func InitConfigs(c *cli.Context, configURL string) (core.Config, error) {
 		return core.Config{}, fmt.Errorf("cfssl configuration file %s Error: %s", conf.Singleca.ConfigPath, err)
 	}
 	cfg.Signing.Default.OCSP = conf.OCSPHost
+	cfg.Signing.Default.CRL = conf.CRLHost
 	conf.Singleca.CfsslConfig = cfg
 
 	return core.Config{
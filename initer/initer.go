@@ -18,6 +18,7 @@ import (
 	"github.com/ztalab/ZACA/ca/datastore"
 	"github.com/ztalab/ZACA/ca/keymanager"
 	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/logic/events"
 	"github.com/ztalab/ZACA/pkg/logger"
 	"github.com/ztalab/ZACA/pkg/vaultsecret"
 	"github.com/ztalab/cfssl/hook"
@@ -63,6 +64,9 @@ func Init(c *cli.Context) error {
 	}
 
 	core.Is = i
+	if err := events.InitBus(conf.Events, conf.Version); err != nil {
+		return err
+	}
 	// Initialize incluxdb
 	go influxdbDialer(&conf, l)
 
@@ -0,0 +1,387 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package approval Four-eyes approval of sensitive operations.
+// A protected operation is stored as a pending change request, another operator approves or rejects it,
+// it executes on approval or expires. Every step is written to the audit log.
+package approval
+
+import (
+	"context"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/jobs"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// Protected operations
+const (
+	OpUnitRevoke       = "unit-revoke"
+	OpUnitForbid       = "unit-forbid"
+	OpCascadeRevoke    = "cascade-revoke"
+	OpIntermediateSign = "intermediate-sign"
+	OpPolicyChange     = "policy-change"
+	// OpBulkJob Stored when the operation matching the kind of the job is protected, see JobOperation
+	OpBulkJob = "bulk-job"
+	// Reverse operations, protected together with the operation they undo
+	OpUnitRecover  = "unit-recover"
+	OpUnitUnforbid = "unit-unforbid"
+)
+
+// reverses Protected operation undone by a reverse operation
+var reverses = map[string]string{
+	OpUnitRecover:  OpUnitRevoke,
+	OpUnitUnforbid: OpUnitForbid,
+}
+
+// Statuses
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"  // Intermediate signing waits for the next request of the subordinate, until expires_at
+	StatusExecuting = "executing" // Claimed by one signing of the intermediate CA
+	StatusRejected  = "rejected"
+	StatusExpired   = "expired"
+	StatusExecuted  = "executed"
+	StatusFailed    = "failed"
+)
+
+// ExpireInterval ...
+const ExpireInterval = time.Minute
+
+// Protected Whether the operation needs the approval of a second operator, a reverse operation whenever the one it undoes does
+func Protected(op string) bool {
+	if undone, ok := reverses[op]; ok {
+		op = undone
+	}
+	conf := core.Is.Config.Approval
+	if !conf.Enabled {
+		return false
+	}
+	if len(conf.Operations) == 0 {
+		return true
+	}
+	for _, o := range conf.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// JobOperation Protected operation a bulk job of kind performs, empty when it performs none
+func JobOperation(kind string) string {
+	switch kind {
+	case jobs.KindRevoke, jobs.KindReissue:
+		return OpUnitRevoke
+	case jobs.KindForbid:
+		return OpUnitForbid
+	case jobs.KindRecover:
+		return OpUnitRecover
+	}
+	return ""
+}
+
+// Logic ...
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+// NewLogic ...
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("approval").SugaredLogger,
+	}
+}
+
+// Submit Store a protected operation until it is reviewed, params are what the operation executes with
+func (l *Logic) Submit(op, subject, summary string, params interface{}, origin events.Origin) (*model.ChangeRequest, error) {
+	paramsJSON, err := jsoniter.MarshalToString(params)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	row := &model.ChangeRequest{
+		Operation:   op,
+		Subject:     truncate(subject, 255),
+		Summary:     truncate(summary, 512),
+		Params:      paramsJSON,
+		Status:      StatusPending,
+		RequestedBy: origin.Operator,
+		SourceIP:    origin.SourceIP,
+		RequestID:   origin.RequestID,
+		ExpiresAt:   now.Add(time.Duration(core.Is.Config.Approval.TTL) * time.Hour),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, _, err := dao.AddChangeRequest(l.db, row); err != nil {
+		l.logger.With("operation", op).Errorf("Database insert error: %s", err)
+		return nil, err
+	}
+	events.NewChangeRequest("change-request", events.OperatorMSP, requestOp(row)).WithOrigin(origin).Log()
+	return row, nil
+}
+
+// ReviewParams ...
+type ReviewParams struct {
+	ID      uint64        `json:"id" binding:"required"`
+	Comment string        `json:"comment"`
+	Origin  events.Origin `json:"-"`
+}
+
+// Approve Approve a pending request and execute it
+func (l *Logic) Approve(params *ReviewParams) (*model.ChangeRequest, error) {
+	row, err := l.review(params, StatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	events.NewChangeRequest("approve", events.OperatorMSP, requestOp(row)).WithOrigin(params.Origin).Log()
+	if row.Operation == OpIntermediateSign {
+		return row, nil
+	}
+
+	result, err := l.execute(row)
+	status, text := StatusExecuted, result
+	if err != nil {
+		status, text = StatusFailed, err.Error()
+	}
+	if err := l.finish(row, StatusApproved, status, text); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// Reject ...
+func (l *Logic) Reject(params *ReviewParams) (*model.ChangeRequest, error) {
+	row, err := l.review(params, StatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	events.NewChangeRequest("reject", events.OperatorMSP, requestOp(row)).WithOrigin(params.Origin).Log()
+	return row, nil
+}
+
+// review Move a pending request to status, the reviewer must be an authenticated operator other than the requester
+func (l *Logic) review(params *ReviewParams, status string) (*model.ChangeRequest, error) {
+	row, err := dao.GetChangeRequest(l.db.Where("id = ?", params.ID))
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, err
+	}
+	if row == nil {
+		return nil, errors.New("change request not found")
+	}
+	now := time.Now()
+	reviewer := params.Origin.Operator
+	if err := checkReview(row, reviewer, now); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"status":         status,
+		"reviewed_by":    reviewer,
+		"review_comment": truncate(params.Comment, 255),
+		"reviewed_at":    now,
+		"updated_at":     now,
+	}
+	if status == StatusApproved && row.Operation == OpIntermediateSign {
+		// The subordinate has a full TTL to pick the approval up
+		row.ExpiresAt = now.Add(time.Duration(core.Is.Config.Approval.TTL) * time.Hour)
+		updates["expires_at"] = row.ExpiresAt
+	}
+	res := l.db.Model(&model.ChangeRequest{}).Where("id = ? AND status = ?", row.ID, StatusPending).Updates(updates)
+	if res.Error != nil {
+		l.logger.Errorf("Database update error: %s", res.Error)
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("change request was reviewed concurrently")
+	}
+	row.Status = status
+	row.ReviewedBy = reviewer
+	row.ReviewComment = truncate(params.Comment, 255)
+	row.ReviewedAt.SetValid(now)
+	row.UpdatedAt = now
+	return row, nil
+}
+
+// checkReview A pending request can be reviewed until it expires, by an authenticated operator other than the requester
+func checkReview(row *model.ChangeRequest, reviewer string, now time.Time) error {
+	if row.Status != StatusPending {
+		return errors.Errorf("change request is %s", row.Status)
+	}
+	if !now.Before(row.ExpiresAt) {
+		return errors.New("change request has expired")
+	}
+	if reviewer == "" || row.RequestedBy == "" {
+		return errors.New("approval requires authenticated operators, enable http.auth")
+	}
+	if reviewer == row.RequestedBy {
+		return errors.New("a change request must be reviewed by another operator")
+	}
+	return nil
+}
+
+// finish Record the outcome of a request executed from status from, an outcome is recorded once
+func (l *Logic) finish(row *model.ChangeRequest, from, status, result string) error {
+	now := time.Now()
+	res := l.db.Model(&model.ChangeRequest{}).Where("id = ? AND status = ?", row.ID, from).Updates(map[string]interface{}{
+		"status":      status,
+		"result":      result,
+		"executed_at": now,
+		"updated_at":  now,
+	})
+	if res.Error != nil {
+		l.logger.Errorf("Database update error: %s", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.Errorf("change request %d is no longer %s", row.ID, from)
+	}
+	row.Status = status
+	row.Result = result
+	row.ExecutedAt.SetValid(now)
+	row.UpdatedAt = now
+
+	op := "execute"
+	if status == StatusFailed {
+		op = "execute-failed"
+	}
+	events.NewChangeRequest(op, events.OperatorSystem, requestOp(row)).WithOrigin(requesterOrigin(row)).Log()
+	return nil
+}
+
+// ListParams ...
+type ListParams struct {
+	Page, PageSize       int
+	Status, Operation    string
+	RequestedBy, Subject string
+}
+
+// List Change requests, newest first
+func (l *Logic) List(params *ListParams) ([]*model.ChangeRequest, int64, error) {
+	db := l.db.Session(&gorm.Session{})
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	if params.Operation != "" {
+		db = db.Where("operation = ?", params.Operation)
+	}
+	if params.RequestedBy != "" {
+		db = db.Where("requested_by = ?", params.RequestedBy)
+	}
+	if params.Subject != "" {
+		db = db.Where("subject = ?", params.Subject)
+	}
+	list, total, err := dao.GetAllChangeRequest(db, params.Page, params.PageSize, "id desc")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// Get ...
+func (l *Logic) Get(id uint64) (*model.ChangeRequest, error) {
+	row, err := dao.GetChangeRequest(l.db.Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, errors.New("change request not found")
+	}
+	return row, nil
+}
+
+// Run Expire pending requests until ctx is done, a singleton job of the leader when an elector is configured
+func (l *Logic) Run(ctx context.Context) {
+	ticker := time.NewTicker(ExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Expire(ctx); err != nil {
+				l.logger.Errorf("Change request expiry error: %s", err)
+			}
+		}
+	}
+}
+
+// Expire Mark pending requests and unused approvals past their expiry
+func (l *Logic) Expire(ctx context.Context) error {
+	var rows []*model.ChangeRequest
+	err := l.db.Where("status IN ? AND expires_at <= ?", []string{StatusPending, StatusApproved}, time.Now()).Find(&rows).Error
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := core.Fence(ctx); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		res := l.db.Model(&model.ChangeRequest{}).Where("id = ? AND status = ?", row.ID, row.Status).
+			Updates(map[string]interface{}{"status": StatusExpired, "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		row.Status = StatusExpired
+		events.NewChangeRequest("expire", events.OperatorSystem, requestOp(row)).Log()
+	}
+	return nil
+}
+
+func requestOp(row *model.ChangeRequest) events.ChangeRequestOp {
+	return events.ChangeRequestOp{
+		ID:         row.ID,
+		Operation:  row.Operation,
+		Subject:    row.Subject,
+		Summary:    row.Summary,
+		Status:     row.Status,
+		ReviewedBy: row.ReviewedBy,
+		Comment:    row.ReviewComment,
+		Result:     row.Result,
+	}
+}
+
+// requesterOrigin The operation executes on behalf of the requester
+func requesterOrigin(row *model.ChangeRequest) events.Origin {
+	return events.Origin{
+		Operator:  row.RequestedBy,
+		SourceIP:  row.SourceIP,
+		RequestID: row.RequestID,
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
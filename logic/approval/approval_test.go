@@ -0,0 +1,211 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/jobs"
+)
+
+// fakeConn Records the statements executed, each affecting the next count of affected
+type fakeConn struct {
+	affected []int64
+	queries  []string
+	args     [][]interface{}
+}
+
+func (c *fakeConn) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+	var n int64
+	if len(c.affected) > 0 {
+		n, c.affected = c.affected[0], c.affected[1:]
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (c *fakeConn) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func newTestLogic(t *testing.T, conn *fakeConn) *Logic {
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}),
+		&gorm.Config{SkipDefaultTransaction: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Logic{db: db, logger: zap.NewNop().Sugar()}
+}
+
+func TestCheckReview(t *testing.T) {
+	now := time.Now()
+	pending := func() *model.ChangeRequest {
+		return &model.ChangeRequest{Status: StatusPending, RequestedBy: "alice", ExpiresAt: now.Add(time.Hour)}
+	}
+	expired := pending()
+	expired.ExpiresAt = now.Add(-time.Second)
+	approved := pending()
+	approved.Status = StatusApproved
+	anonymous := pending()
+	anonymous.RequestedBy = ""
+
+	cases := []struct {
+		name     string
+		row      *model.ChangeRequest
+		reviewer string
+		ok       bool
+	}{
+		{"other operator", pending(), "bob", true},
+		{"same operator", pending(), "alice", false},
+		{"unauthenticated reviewer", pending(), "", false},
+		{"unauthenticated requester", anonymous, "bob", false},
+		{"expired", expired, "bob", false},
+		{"already reviewed", approved, "bob", false},
+	}
+	for _, c := range cases {
+		if err := checkReview(c.row, c.reviewer, now); (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+func TestMatchSign(t *testing.T) {
+	now := time.Now()
+	params := &IntermediateSign{
+		Profile:         "intermediate",
+		CommonName:      "sub-ca",
+		OU:              []string{"sub"},
+		Hosts:           []string{"sub.example.com"},
+		PublicKeySHA256: "00",
+	}
+	row := func(status string, expiresAt time.Time, mutate func(*IntermediateSign)) *model.ChangeRequest {
+		p := *params
+		if mutate != nil {
+			mutate(&p)
+		}
+		s, _ := jsoniter.MarshalToString(&p)
+		return &model.ChangeRequest{Status: status, Params: s, ExpiresAt: expiresAt}
+	}
+	later, earlier := now.Add(time.Hour), now.Add(-time.Second)
+
+	cases := []struct {
+		name string
+		row  *model.ChangeRequest
+		ok   bool
+	}{
+		{"none", nil, false},
+		{"approved", row(StatusApproved, later, nil), true},
+		{"pending", row(StatusPending, later, nil), true},
+		{"rejected and expired", row(StatusRejected, earlier, nil), true},
+		{"approval expired", row(StatusApproved, earlier, nil), false},
+		{"execution stuck past expiry", row(StatusExecuting, earlier, nil), false},
+		{"other profile", row(StatusApproved, later, func(p *IntermediateSign) { p.Profile = "leaf" }), false},
+		{"other common name", row(StatusApproved, later, func(p *IntermediateSign) { p.CommonName = "other" }), false},
+		{"other OU", row(StatusApproved, later, func(p *IntermediateSign) { p.OU = []string{"sub", "more"} }), false},
+		{"other hosts", row(StatusApproved, later, func(p *IntermediateSign) { p.Hosts = []string{"evil.example.com"} }), false},
+		{"no hosts", row(StatusApproved, later, func(p *IntermediateSign) { p.Hosts = nil }), false},
+		{"params unreadable", &model.ChangeRequest{Status: StatusApproved, Params: "{", ExpiresAt: later}, false},
+	}
+	for _, c := range cases {
+		if got := matchSign(c.row, params, now); (got != nil) != c.ok {
+			t.Errorf("%s: expected match %v", c.name, c.ok)
+		}
+	}
+}
+
+func TestClaimOnce(t *testing.T) {
+	conn := &fakeConn{affected: []int64{1, 0}}
+	l := newTestLogic(t, conn)
+
+	row := &model.ChangeRequest{ID: 7, Status: StatusApproved, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := l.claim(row); err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	if row.Status != StatusExecuting {
+		t.Errorf("claimed request is %s", row.Status)
+	}
+	query := conn.queries[0]
+	if !strings.Contains(query, "status = ?") || !strings.Contains(query, "expires_at > ?") {
+		t.Errorf("claim is not conditional: %s", query)
+	}
+	if conn.args[0][len(conn.args[0])-2] != StatusApproved {
+		t.Errorf("claim must require an approved request, args %v", conn.args[0])
+	}
+
+	// Replayed, or used concurrently by another signing
+	replay := &model.ChangeRequest{ID: 7, Status: StatusApproved, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := l.claim(replay); err == nil {
+		t.Error("a claimed request must not be claimed again")
+	}
+	if replay.Status != StatusApproved {
+		t.Errorf("refused claim changed the status to %s", replay.Status)
+	}
+}
+
+func TestFinishOnce(t *testing.T) {
+	conn := &fakeConn{affected: []int64{0}}
+	l := newTestLogic(t, conn)
+
+	row := &model.ChangeRequest{ID: 7, Status: StatusExecuting}
+	if err := l.SignExecuted(row, "1"); err == nil {
+		t.Error("the outcome of a request no longer executing must not be recorded")
+	}
+	if !strings.Contains(conn.queries[0], "status = ?") {
+		t.Errorf("outcome is not conditional: %s", conn.queries[0])
+	}
+}
+
+func TestProtectedReverse(t *testing.T) {
+	conf := &core.Config{}
+	conf.Approval = config.Approval{Enabled: true, Operations: []string{OpUnitForbid}}
+	core.Is = &core.I{Config: conf}
+	defer func() { core.Is = nil }()
+
+	for op, want := range map[string]bool{
+		OpUnitForbid:   true,
+		OpUnitUnforbid: true,
+		OpUnitRevoke:   false,
+		OpUnitRecover:  false,
+	} {
+		if Protected(op) != want {
+			t.Errorf("%s: expected protected %v", op, want)
+		}
+	}
+	if JobOperation(jobs.KindForbid) != OpUnitForbid || JobOperation(jobs.KindRecover) != OpUnitRecover {
+		t.Error("jobs must follow the operations they perform")
+	}
+}
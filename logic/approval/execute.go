@@ -0,0 +1,246 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/cascade"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/forbid"
+	"github.com/ztalab/ZACA/logic/jobs"
+	"github.com/ztalab/ZACA/logic/workload"
+)
+
+// Policy change actions
+const (
+	PolicyCreateRule = "create-rule"
+	PolicyDeleteRule = "delete-rule"
+)
+
+// PolicyChange Change of the forbid rules
+type PolicyChange struct {
+	Action string               `json:"action"`
+	Rule   *forbid.CreateParams `json:"rule,omitempty"`
+	ID     uint64               `json:"id,omitempty"`
+}
+
+// IntermediateSign Intermediate CA certificate requested by a subordinate
+type IntermediateSign struct {
+	Profile    string   `json:"profile"`
+	CommonName string   `json:"common_name"`
+	OU         []string `json:"ou"`
+	Hosts      []string `json:"hosts"`
+	// Hex SHA-256 of the public key, the subject of the change request
+	PublicKeySHA256 string `json:"public_key_sha256"`
+}
+
+// execute Run an approved request, the result is stored with the request
+func (l *Logic) execute(row *model.ChangeRequest) (string, error) {
+	origin := requesterOrigin(row)
+	switch row.Operation {
+	case OpUnitRevoke:
+		var params workload.RevokeCertsParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		if err := workload.NewLogic().RevokeCerts(&params); err != nil {
+			return "", err
+		}
+		return "revoked", nil
+	case OpUnitRecover:
+		var params workload.RecoverCertsParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		if err := workload.NewLogic().RecoverCerts(&params); err != nil {
+			return "", err
+		}
+		return "recovered", nil
+	case OpUnitForbid:
+		var params workload.ForbidNewCertsParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		if err := workload.NewLogic().ForbidNewCerts(&params); err != nil {
+			return "", err
+		}
+		return "forbidden", nil
+	case OpUnitUnforbid:
+		var params workload.ForbidNewCertsParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		if err := workload.NewLogic().RecoverForbidNewCerts(&params); err != nil {
+			return "", err
+		}
+		return "allowed", nil
+	case OpCascadeRevoke:
+		var params cascade.RevokeParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		revocation, err := cascade.NewLogic().Revoke(&params)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("cascade revocation %d", revocation.ID), nil
+	case OpBulkJob:
+		var params jobs.CreateParams
+		if err := jsoniter.UnmarshalFromString(row.Params, &params); err != nil {
+			return "", err
+		}
+		params.Origin = origin
+		job, err := jobs.NewLogic().Create(&params)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("job %d", job.ID), nil
+	case OpPolicyChange:
+		var change PolicyChange
+		if err := jsoniter.UnmarshalFromString(row.Params, &change); err != nil {
+			return "", err
+		}
+		switch {
+		case change.Action == PolicyCreateRule && change.Rule != nil:
+			change.Rule.Origin = origin
+			rule, err := forbid.NewLogic().Create(change.Rule)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("forbid rule %d", rule.ID), nil
+		case change.Action == PolicyDeleteRule:
+			if err := forbid.NewLogic().Delete(change.ID, origin); err != nil {
+				return "", err
+			}
+			return "deleted", nil
+		}
+		return "", errors.Errorf("unknown policy change %q", change.Action)
+	}
+	return "", errors.Errorf("operation %s cannot be executed", row.Operation)
+}
+
+// ErrPending The operation was stored for approval
+type ErrPending struct {
+	Request *model.ChangeRequest
+}
+
+func (e *ErrPending) Error() string {
+	return fmt.Sprintf("change request %d is %s, awaiting approval by a second operator", e.Request.ID, e.Request.Status)
+}
+
+// SignApproval Approved request for signing an intermediate CA with the public key and exactly the same params,
+// claimed for this signing only: report the outcome with SignExecuted or SignFailed.
+// Without one a pending request is created, or the existing one is reported, as an *ErrPending
+func (l *Logic) SignApproval(params *IntermediateSign, origin events.Origin) (*model.ChangeRequest, error) {
+	row, err := dao.GetChangeRequest(l.db.Where("operation = ? AND subject = ?", OpIntermediateSign, params.PublicKeySHA256).
+		Where("status IN ?", []string{StatusPending, StatusApproved, StatusExecuting, StatusRejected}).Order("id desc"))
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, err
+	}
+	if row = matchSign(row, params, time.Now()); row != nil {
+		switch row.Status {
+		case StatusApproved:
+			if err := l.claim(row); err != nil {
+				return nil, err
+			}
+			return row, nil
+		case StatusExecuting:
+			return nil, errors.Errorf("change request %d is being executed", row.ID)
+		case StatusRejected:
+			return nil, errors.Errorf("change request %d was rejected by %s", row.ID, row.ReviewedBy)
+		}
+		return nil, &ErrPending{Request: row}
+	}
+	summary := fmt.Sprintf("Sign intermediate CA %s (%s)", params.CommonName, strings.Join(params.OU, ", "))
+	row, err = l.Submit(OpIntermediateSign, params.PublicKeySHA256, summary, params, origin)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &ErrPending{Request: row}
+}
+
+// matchSign The latest request for the key while it applies to params, nil when a new request is needed:
+// other params are reviewed on their own, and expired requests and approvals are not used
+func matchSign(row *model.ChangeRequest, params *IntermediateSign, now time.Time) *model.ChangeRequest {
+	if row == nil || !sameSign(row, params) {
+		return nil
+	}
+	if row.Status != StatusRejected && !now.Before(row.ExpiresAt) {
+		return nil
+	}
+	return row
+}
+
+// claim Take an approved request for one signing, refused when it was used concurrently or expired in between
+func (l *Logic) claim(row *model.ChangeRequest) error {
+	now := time.Now()
+	res := l.db.Model(&model.ChangeRequest{}).
+		Where("id = ? AND status = ? AND expires_at > ?", row.ID, StatusApproved, now).
+		Updates(map[string]interface{}{"status": StatusExecuting, "updated_at": now})
+	if res.Error != nil {
+		l.logger.Errorf("Database update error: %s", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return errors.Errorf("change request %d was already used or has expired", row.ID)
+	}
+	row.Status, row.UpdatedAt = StatusExecuting, now
+	return nil
+}
+
+// sameSign Whether the request was submitted with params
+func sameSign(row *model.ChangeRequest, params *IntermediateSign) bool {
+	var submitted IntermediateSign
+	if err := jsoniter.UnmarshalFromString(row.Params, &submitted); err != nil {
+		return false
+	}
+	return submitted.Profile == params.Profile && submitted.CommonName == params.CommonName &&
+		sameStrings(submitted.OU, params.OU) && sameStrings(submitted.Hosts, params.Hosts)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SignExecuted Record the certificate signed for a claimed request
+func (l *Logic) SignExecuted(row *model.ChangeRequest, sn string) error {
+	return l.finish(row, StatusExecuting, StatusExecuted, "certificate "+sn)
+}
+
+// SignFailed Record the failed signing of a claimed request, the subordinate needs a new approval
+func (l *Logic) SignFailed(row *model.ChangeRequest, err error) error {
+	return l.finish(row, StatusExecuting, StatusFailed, err.Error())
+}
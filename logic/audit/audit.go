@@ -0,0 +1,147 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"github.com/araddon/dateparse"
+	"github.com/pkg/errors"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/auditchain"
+)
+
+// verifyBatchSize Rows read per query during verification
+const verifyBatchSize = 1000
+
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("logic").SugaredLogger,
+	}
+}
+
+type EventListParams struct {
+	// query criteria
+	Category, Type, Operator string
+	UniqueID, SN, RequestID  string
+	SourceIP                 string
+	StartTime, EndTime       string
+	// Paging condition
+	Page, PageSize int
+}
+
+type EventListResult struct {
+	List  []*model.AuditEvent
+	Total int64
+}
+
+// EventList Audit events, newest first, including those not chained yet
+func (l *Logic) EventList(params *EventListParams) (*EventListResult, error) {
+	query := l.db.Session(&gorm.Session{})
+	for column, value := range map[string]string{
+		"category":      params.Category,
+		"type":          params.Type,
+		"operator":      params.Operator,
+		"unique_id":     params.UniqueID,
+		"serial_number": params.SN,
+		"request_id":    params.RequestID,
+		"source_ip":     params.SourceIP,
+	} {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if params.StartTime != "" {
+		date, err := dateparse.ParseAny(params.StartTime)
+		if err != nil {
+			return nil, errors.Wrap(err, "Start time error")
+		}
+		query = query.Where("created_at >= ?", date)
+	}
+	if params.EndTime != "" {
+		date, err := dateparse.ParseAny(params.EndTime)
+		if err != nil {
+			return nil, errors.Wrap(err, "End time error")
+		}
+		query = query.Where("created_at < ?", date)
+	}
+
+	list, total, err := dao.GetAllAuditEvents(query, params.Page, params.PageSize, "id desc")
+	if err != nil {
+		l.logger.With("params", params).Errorf("Database query error: %s", err)
+		return nil, errors.Wrap(err, "Database query error")
+	}
+	return &EventListResult{List: list, Total: total}, nil
+}
+
+type VerifyResult struct {
+	Count    int    `json:"count"`
+	HeadSeq  uint64 `json:"head_seq"`
+	HeadHash string `json:"head_hash"`
+}
+
+// Verify Walk the whole chain, returns an *auditchain.Error on the first gap or tampered entry.
+// Truncated tails are only detected by comparing the head with a previously recorded one.
+func (l *Logic) Verify() (*VerifyResult, error) {
+	checker := auditchain.NewChecker(0, auditchain.Genesis)
+	var last uint64
+	for {
+		var rows []*model.AuditEvent
+		err := l.db.Where("seq > ?", last).Order("seq asc").Limit(verifyBatchSize).Find(&rows).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "Database query error")
+		}
+		for _, row := range rows {
+			if err := checker.Check(auditchain.Link{
+				Seq:      row.Seq,
+				PrevHash: row.PrevHash,
+				Hash:     row.Hash,
+				Fields:   row.HashFields(),
+			}); err != nil {
+				return nil, err
+			}
+			last = row.Seq
+		}
+		if len(rows) < verifyBatchSize {
+			break
+		}
+	}
+	seq, hash := checker.Head()
+	return &VerifyResult{Count: checker.Count(), HeadSeq: seq, HeadHash: hash}, nil
+}
+
+// CheckHead Whether the entry with the given seq still has the hash recorded earlier
+func (l *Logic) CheckHead(seq uint64, hash string) error {
+	row, err := dao.GetAuditEvent(l.db.Where("seq = ?", seq))
+	if err != nil {
+		return errors.Wrap(err, "Database query error")
+	}
+	if row == nil {
+		return &auditchain.Error{Seq: seq, Reason: "recorded entry is missing"}
+	}
+	if row.Hash != hash {
+		return &auditchain.Error{Seq: seq, Reason: "recorded hash does not match"}
+	}
+	return nil
+}
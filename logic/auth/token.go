@@ -0,0 +1,177 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/pkg/errors"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+)
+
+// TokenPrefix API tokens are recognisable, other bearer tokens are handed to OIDC
+const TokenPrefix = "zaca_"
+
+// Roles
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole ...
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAllows Whether role grants at least the required role
+func RoleAllows(role, required string) bool {
+	return roleLevels[role] > 0 && roleLevels[role] >= roleLevels[required]
+}
+
+// HashToken Only the hex SHA-256 of a token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewToken 256 bit random token
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + hex.EncodeToString(b), nil
+}
+
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("logic").SugaredLogger,
+	}
+}
+
+type CreateTokenParams struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"required"`
+	// TTL Validity in hours, 0 never expires
+	TTL      int    `json:"ttl"`
+	Operator string `json:"-"`
+}
+
+type CreateTokenResult struct {
+	*model.APIToken
+	// Token Plain token, only returned once
+	Token string `json:"token"`
+}
+
+// CreateToken Create an API token, the plain token is not stored
+func (l *Logic) CreateToken(params *CreateTokenParams) (*CreateTokenResult, error) {
+	if !ValidRole(params.Role) {
+		return nil, errors.Errorf("Unknown role %s", params.Role)
+	}
+	token, err := NewToken()
+	if err != nil {
+		return nil, err
+	}
+	record := &model.APIToken{
+		Name:      params.Name,
+		TokenHash: HashToken(token),
+		Role:      params.Role,
+		CreatedBy: sql.NullString{String: params.Operator, Valid: params.Operator != ""},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if params.TTL > 0 {
+		record.ExpiresAt = null.TimeFrom(time.Now().Add(time.Duration(params.TTL) * time.Hour))
+	}
+	if _, _, err := dao.AddAPIToken(l.db, record); err != nil {
+		l.logger.With("name", params.Name).Errorf("Database insert error: %s", err)
+		return nil, err
+	}
+	l.logger.With("name", record.Name, "role", record.Role, "operator", params.Operator).Info("API token created")
+	return &CreateTokenResult{APIToken: record, Token: token}, nil
+}
+
+// ListTokens Tokens that are not deleted
+func (l *Logic) ListTokens() ([]*model.APIToken, error) {
+	db := l.db.Session(&gorm.Session{}).Where("deleted_at IS NULL")
+	list, _, err := dao.GetAllAPIToken(db, 0, 1000, "id desc")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, errors.Wrap(err, "Database query error")
+	}
+	return list, nil
+}
+
+type DeleteTokenParams struct {
+	ID       uint32 `json:"id" binding:"required"`
+	Operator string `json:"-"`
+}
+
+// DeleteToken Revoke an API token
+func (l *Logic) DeleteToken(params *DeleteTokenParams) error {
+	res := l.db.Model(&model.APIToken{}).Where("id = ? AND deleted_at IS NULL", params.ID).
+		Update("deleted_at", time.Now())
+	if res.Error != nil {
+		l.logger.With("id", params.ID).Errorf("Database update error: %s", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("Token not found")
+	}
+	l.logger.With("id", params.ID, "operator", params.Operator).Info("API token deleted")
+	return nil
+}
+
+// LookupToken Find the valid token record, returns nil if the token is unknown, deleted or expired
+func (l *Logic) LookupToken(token string) (*model.APIToken, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, nil
+	}
+	db := l.db.Where("token_hash = ? AND deleted_at IS NULL", HashToken(token))
+	record, err := dao.GetAPIToken(db)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if record.ExpiresAt.Valid && record.ExpiresAt.Time.Before(time.Now()) {
+		return nil, nil
+	}
+	l.db.Model(&model.APIToken{}).Where("id = ?", record.ID).Update("last_used_at", time.Now())
+	return record, nil
+}
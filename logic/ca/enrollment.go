@@ -0,0 +1,45 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/logic/events"
+)
+
+// EnrollmentParams Signed certificate of the pending request
+type EnrollmentParams struct {
+	Certificate string `json:"certificate" binding:"required"` // PEM, may be followed by the chain
+	Trust       string `json:"trust"`                          // PEM root certificates, default the root at the end of the chain
+
+	Origin events.Origin `json:"-"`
+}
+
+// Enrollment Status of the manual enrollment, the key and the request are generated on first use
+func (l *Logic) Enrollment() (*keymanager.Enrollment, error) {
+	return keymanager.GetKeeper().BeginEnrollment()
+}
+
+// CompleteEnrollment Import the certificate of the pending request
+func (l *Logic) CompleteEnrollment(params *EnrollmentParams) (*keymanager.Enrollment, error) {
+	enrollment, err := keymanager.GetKeeper().CompleteEnrollment([]byte(params.Certificate), []byte(params.Trust))
+	if err != nil {
+		l.logger.With("operator", params.Origin.Operator).Warnf("Enrollment import rejected: %v", err)
+		return nil, errors.Wrap(err, "import rejected")
+	}
+	l.logger.With("operator", params.Origin.Operator, "sn", enrollment.SN).Info("Enrollment completed")
+	return enrollment, nil
+}
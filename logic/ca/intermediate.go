@@ -14,34 +14,20 @@ limitations under the License.
 package ca
 
 import (
-	"crypto/tls"
+	"context"
 	"net/http"
 
-	"github.com/go-resty/resty/v2"
-	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/ztalab/cfssl/helpers"
 	"gorm.io/gorm"
 
-	"github.com/ztalab/ZACA/ca/upperca"
 	"github.com/ztalab/ZACA/core"
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/logic/registry"
 	"github.com/ztalab/ZACA/logic/schema"
 	"github.com/ztalab/zaca-sdk/caclient"
 )
 
-const (
-	UpperCaApiIntermediateTopology = "/api/v1/cap/ca/intermediate_topology"
-)
-
-var httpClient = resty.NewWithClient(&http.Client{
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	},
-})
-
 type IntermediateObject struct {
 	Certs    []*schema.FullCert    `mapstructure:"certs" json:"certs"`
 	Metadata schema.CaMetadata     `mapstructure:"metadata" json:"metadata"`
@@ -103,29 +89,11 @@ func (l *Logic) UpperCaIntermediateTopology() ([]*IntermediateObject, error) {
 		return l.IntermediateTopology()
 	}
 
-	var resp *resty.Response
-	err := upperca.ProxyRequest(func(host string) error {
-		res, err := httpClient.R().Get(host + UpperCaApiIntermediateTopology)
-		if err != nil {
-			l.logger.With("upperca", host).Errorf("UpperCA Request error: %s", err)
-			return err
-		}
-		resp = res
-		return nil
-	})
-	if err != nil {
+	// Signed with the key of this CA, the upper CA only answers its subordinates
+	var list []*IntermediateObject
+	if err := registry.Do(context.Background(), http.MethodGet, registry.PathIntermediateTopology, nil, &list); err != nil {
 		l.logger.Errorf("UpperCA Sub CA topology acquisition failed: %s", err)
 		return nil, err
 	}
-
-	body := resp.Body()
-	var response struct {
-		Data []*IntermediateObject `json:"data"`
-	}
-	if err := jsoniter.Unmarshal(body, &response); err != nil {
-		l.logger.With("body", string(body)).Errorf("json Parsing error: %s", err)
-		return nil, errors.Wrap(err, "json Parsing error")
-	}
-
-	return response.Data, nil
+	return list, nil
 }
@@ -0,0 +1,332 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cascade Revocation of a subordinate CA together with everything it issued.
+// The CA revokes the intermediate certificates of the subordinate and tells it through an API
+// authenticated with the CA key, the subordinate mass-revokes, stops signing and cascades further down.
+package cascade
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/hook"
+	"github.com/ztalab/zaca-sdk/caclient"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/workload"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// Directions
+const (
+	DirectionDownstream = "downstream" // Sent to a subordinate CA
+	DirectionUpstream   = "upstream"   // Received from the upper CA
+)
+
+// Statuses
+const (
+	StatusPending     = "pending"     // Not delivered yet
+	StatusFailed      = "failed"      // Last delivery failed, retried
+	StatusUnreachable = "unreachable" // No address configured for the subordinate
+	StatusDelivered   = "delivered"   // The subordinate is revoking
+	StatusReceived    = "received"    // Revoking what this CA issued
+	StatusCompleted   = "completed"   // Everything below is revoked
+)
+
+// Logic ...
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+// NewLogic ...
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("cascade").SugaredLogger,
+	}
+}
+
+// RevokeParams ...
+type RevokeParams struct {
+	// Name OU of the intermediate certificates of the subordinate CA
+	Name string `json:"name" binding:"required"`
+	// RFC 5280 reason, default cACompromise, certificateHold is not allowed
+	Reason  string        `json:"reason"`
+	Comment string        `json:"comment"`
+	Origin  events.Origin `json:"-"`
+}
+
+// Revoke Revoke the intermediate certificates of a subordinate CA and queue the notification of the subordinate
+func (l *Logic) Revoke(params *RevokeParams) (*model.CascadeRevocation, error) {
+	rev, err := workload.NewRevocation(params.Reason, revocation.CACompromise, nil, nil, params.Comment)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Reason == revocation.CertificateHold {
+		return nil, errors.New("a cascade revocation cannot be put on hold")
+	}
+
+	records, certs, err := l.intermediates(revocable(l.db, rev.Reason), params.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf("no intermediate certificate of %s to revoke", params.Name)
+	}
+
+	sns := make([]string, 0, len(certs))
+	skis := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		sns = append(sns, cert.SerialNumber.String())
+		skis = append(skis, hex.EncodeToString(cert.SubjectKeyId))
+	}
+	snsJSON, _ := jsoniter.MarshalToString(sns)
+	skisJSON, _ := jsoniter.MarshalToString(skis)
+
+	now := time.Now()
+	row := &model.CascadeRevocation{
+		Direction:     DirectionDownstream,
+		Name:          params.Name,
+		SerialNumbers: snsJSON,
+		SubjectKeyIDs: skisJSON,
+		Reason:        rev.Reason,
+		Comment:       rev.Comment,
+		Status:        StatusUnreachable,
+		CreatedBy:     params.Origin.Operator,
+		SourceIP:      params.Origin.SourceIP,
+		RequestID:     params.Origin.RequestID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if url, ok := subordinateURL(params.Name); ok {
+		row.URL = url
+		row.Status = StatusPending
+	}
+
+	err = l.db.Transaction(func(tx *gorm.DB) error {
+		if err := workload.RevokeBatch(tx, records, rev); err != nil {
+			return err
+		}
+		_, _, err := dao.AddCascadeRevocation(tx, row)
+		return err
+	})
+	if err != nil {
+		l.logger.Errorf("Cascade revocation of %s error: %s", params.Name, err)
+		return nil, errors.Wrap(err, "Cascade revocation error")
+	}
+
+	for _, record := range records {
+		events.NewWorkloadLifeCycle("revoke", events.OperatorMSP, rev.CertOp(record)).
+			WithOrigin(params.Origin).Log()
+	}
+	events.NewCascadeRevocation("cascade-revoke", events.OperatorMSP, cascadeOp(row, sns)).
+		WithOrigin(params.Origin).Log()
+	if row.Status == StatusUnreachable {
+		l.logger.Warnf("No address configured for subordinate CA %s, it has to be revoked by hand", params.Name)
+	}
+	return row, nil
+}
+
+// revocable Unexpired certificates that can be revoked with reason
+func revocable(db *gorm.DB, reason int) *gorm.DB {
+	return workload.Revocable(db.Session(&gorm.Session{}), reason).Where("expiry > ?", time.Now())
+}
+
+// intermediates Intermediate certificates issued by this CA to the subordinate name, among those matching db
+func (l *Logic) intermediates(db *gorm.DB, name string) ([]*model.Certificates, []*x509.Certificate, error) {
+	records, certs, err := l.loadIntermediates(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	var n int
+	for i, cert := range certs {
+		if ou(cert) == name {
+			records[n], certs[n] = records[i], cert
+			n++
+		}
+	}
+	return records[:n], certs[:n], nil
+}
+
+// subordinateNames OUs of the intermediate certificates signed with one of the keys
+func (l *Logic) subordinateNames(db *gorm.DB, skis []string) ([]string, error) {
+	_, certs, err := l.loadIntermediates(db.Where("authority_key_identifier IN ?", skis))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, cert := range certs {
+		if name := ou(cert); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (l *Logic) loadIntermediates(db *gorm.DB) ([]*model.Certificates, []*x509.Certificate, error) {
+	var rows []*model.Certificates
+	err := db.Where("ca_label = ?", caclient.RoleIntermediate).
+		Select("serial_number", "authority_key_identifier", "common_name", "status", "expiry", "pem").
+		Find(&rows).Error
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Database query error")
+	}
+	records := make([]*model.Certificates, 0, len(rows))
+	certs := make([]*x509.Certificate, 0, len(rows))
+	for _, row := range rows {
+		if hook.EnableVaultStorage && row.Pem == "" {
+			pem, err := core.Is.VaultSecret.GetCertPEM(row.SerialNumber)
+			if err != nil {
+				l.logger.With("sn", row.SerialNumber).Warnf("Vault Get error: %v", err)
+				continue
+			}
+			row.Pem = *pem
+		}
+		cert, err := helpers.ParseCertificatePEM([]byte(row.Pem))
+		if err != nil {
+			l.logger.With("sn", row.SerialNumber).Errorf("CA Certificate parsing error: %s", err)
+			continue
+		}
+		records = append(records, row)
+		certs = append(certs, cert)
+	}
+	return records, certs, nil
+}
+
+func ou(cert *x509.Certificate) string {
+	if len(cert.Subject.OrganizationalUnit) == 0 {
+		return ""
+	}
+	return cert.Subject.OrganizationalUnit[0]
+}
+
+func subordinateURL(name string) (string, bool) {
+	for _, sub := range core.Is.Config.Cascade.Subordinates {
+		if sub.Name == name && sub.URL != "" {
+			return sub.URL, true
+		}
+	}
+	return "", false
+}
+
+func cascadeOp(row *model.CascadeRevocation, sns []string) events.CascadeOp {
+	return events.CascadeOp{
+		ID:            row.ID,
+		Direction:     row.Direction,
+		Name:          row.Name,
+		SerialNumbers: sns,
+		Reason:        revocation.ReasonString(row.Reason),
+		Status:        row.Status,
+	}
+}
+
+// ListParams ...
+type ListParams struct {
+	Page, PageSize  int
+	Direction, Name string
+	Status          string
+}
+
+// List Cascade revocations, newest first
+func (l *Logic) List(params *ListParams) ([]*model.CascadeRevocation, int64, error) {
+	db := l.db.Session(&gorm.Session{})
+	if params.Direction != "" {
+		db = db.Where("direction = ?", params.Direction)
+	}
+	if params.Name != "" {
+		db = db.Where("name = ?", params.Name)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	list, total, err := dao.GetAllCascadeRevocation(db, params.Page, params.PageSize, "id desc")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// Detail Cascade revocation with the progress reported by the subordinate
+type Detail struct {
+	*model.CascadeRevocation
+	Progress *Progress `json:"progress"`
+}
+
+// Get ...
+func (l *Logic) Get(id uint64) (*Detail, error) {
+	row, err := dao.GetCascadeRevocation(l.db.Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, errors.New("cascade revocation not found")
+	}
+	detail := &Detail{CascadeRevocation: row}
+	if row.Progress != "" {
+		detail.Progress = &Progress{}
+		if err := jsoniter.UnmarshalFromString(row.Progress, detail.Progress); err != nil {
+			return nil, err
+		}
+	}
+	return detail, nil
+}
+
+// SubordinateStatus Latest cascade revocation of a subordinate CA
+type SubordinateStatus struct {
+	Name       string                   `json:"name"`
+	URL        string                   `json:"url"`
+	Revocation *model.CascadeRevocation `json:"revocation"`
+}
+
+// Subordinates Configured subordinate CAs and those revoked before, with their latest cascade revocation
+func (l *Logic) Subordinates() ([]*SubordinateStatus, error) {
+	var latest []*model.CascadeRevocation
+	err := l.db.Where("id IN (?)", l.db.Model(&model.CascadeRevocation{}).
+		Select("MAX(id)").Where("direction = ?", DirectionDownstream).Group("name")).
+		Order("name").Find(&latest).Error
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, err
+	}
+	byName := make(map[string]*model.CascadeRevocation, len(latest))
+	for _, row := range latest {
+		byName[row.Name] = row
+	}
+
+	var result []*SubordinateStatus
+	for _, sub := range core.Is.Config.Cascade.Subordinates {
+		result = append(result, &SubordinateStatus{Name: sub.Name, URL: sub.URL, Revocation: byName[sub.Name]})
+		delete(byName, sub.Name)
+	}
+	for _, row := range latest {
+		if _, ok := byName[row.Name]; ok {
+			result = append(result, &SubordinateStatus{Name: row.Name, URL: row.URL, Revocation: row})
+		}
+	}
+	return result, nil
+}
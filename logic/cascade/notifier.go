@@ -0,0 +1,272 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cascade
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/revocation"
+	"github.com/ztalab/ZACA/pkg/tracing"
+)
+
+// newClient Client verifying subordinates against the trust bundle of this CA, which signed their certificates.
+// The bundle follows key rotation, so it is loaded for each request
+func newClient() (*http.Client, error) {
+	pool, err := keymanager.GetKeeper().GetTrustCertPool()
+	if err != nil {
+		return nil, errors.Wrap(err, "trust bundle")
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: pool,
+			},
+		},
+	}, nil
+}
+
+// Preview Impact of revoking the subordinate name: its intermediate certificates here,
+// the units and subordinates below as reported by the subordinate
+func (l *Logic) Preview(ctx context.Context, name string) *Preview {
+	preview := &Preview{Name: name}
+	_, certs, err := l.intermediates(revocable(l.db.WithContext(ctx), revocation.CACompromise), name)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	if len(certs) == 0 {
+		preview.Error = "no intermediate certificate to revoke"
+		return preview
+	}
+	skis := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		preview.Certificates = append(preview.Certificates, &PreviewCert{
+			SN:       cert.SerialNumber.String(),
+			AKI:      hex.EncodeToString(cert.AuthorityKeyId),
+			NotAfter: cert.NotAfter,
+		})
+		skis = append(skis, hex.EncodeToString(cert.SubjectKeyId))
+	}
+
+	addr, ok := subordinateURL(name)
+	if !ok {
+		preview.Error = "no address configured for the subordinate, units below are unknown"
+		return preview
+	}
+	preview.URL = addr
+	query := url.Values{"subject_key_ids": skis}
+	var remote Preview
+	if err := call(ctx, http.MethodGet, addr+PathPreview+"?"+query.Encode(), nil, &remote); err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	preview.Units, preview.CertsCount, preview.Truncated = remote.Units, remote.CertsCount, remote.Truncated
+	preview.Subordinates = remote.Subordinates
+	return preview
+}
+
+// Notifier Delivers cascade revocations to subordinate CAs and follows their progress
+type Notifier struct {
+	db       *gorm.DB
+	logger   *zap.SugaredLogger
+	interval time.Duration
+}
+
+// NewNotifier ...
+func NewNotifier(conf config.Cascade) *Notifier {
+	return &Notifier{
+		db:       core.Is.Db,
+		logger:   logger.Named("cascade").SugaredLogger,
+		interval: time.Duration(conf.Interval) * time.Second,
+	}
+}
+
+// Run Deliver until ctx is done, a singleton job of the leader when an elector is configured
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		if err := n.Deliver(ctx); err != nil {
+			n.logger.Errorf("Cascade revocation delivery error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver Notify the subordinates of undelivered revocations and poll those still revoking
+func (n *Notifier) Deliver(ctx context.Context) error {
+	var rows []*model.CascadeRevocation
+	err := n.db.Where("direction = ? AND status IN ?", DirectionDownstream,
+		[]string{StatusPending, StatusFailed, StatusDelivered}).
+		Order("id").Find(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if row.Status == StatusDelivered {
+			n.poll(ctx, row)
+			continue
+		}
+		if err := core.Fence(ctx); err != nil {
+			return err
+		}
+		n.notify(ctx, row)
+	}
+	return nil
+}
+
+func (n *Notifier) notify(ctx context.Context, row *model.CascadeRevocation) {
+	notification := &Notification{
+		ID:      row.ID,
+		Name:    row.Name,
+		Reason:  row.Reason,
+		Comment: row.Comment,
+	}
+	_ = jsoniter.UnmarshalFromString(row.SerialNumbers, &notification.SerialNumbers)
+	_ = jsoniter.UnmarshalFromString(row.SubjectKeyIDs, &notification.SubjectKeyIDs)
+	body, _ := jsoniter.Marshal(notification)
+
+	err := call(ctx, http.MethodPost, row.URL+PathNotify, body, nil)
+	updates := map[string]interface{}{
+		"attempts":   row.Attempts + 1,
+		"updated_at": time.Now(),
+	}
+	if err != nil {
+		n.logger.With("name", row.Name, "id", row.ID).Warnf("Cascade revocation delivery failed: %s", err)
+		updates["status"] = StatusFailed
+		updates["last_error"] = err.Error()
+	} else {
+		updates["status"] = StatusDelivered
+		updates["last_error"] = ""
+		updates["delivered_at"] = time.Now()
+	}
+	if err := n.db.Model(row).Updates(updates).Error; err != nil {
+		n.logger.Errorf("Database update error: %s", err)
+		return
+	}
+	if err == nil {
+		row.Status = StatusDelivered
+		events.NewCascadeRevocation("cascade-deliver", events.OperatorSystem,
+			cascadeOp(row, notification.SerialNumbers)).Log()
+		n.logger.With("name", row.Name, "id", row.ID).Info("Cascade revocation delivered")
+	}
+}
+
+func (n *Notifier) poll(ctx context.Context, row *model.CascadeRevocation) {
+	var progress Progress
+	addr := row.URL + PathStatus + "?" + url.Values{"id": {strconv.FormatUint(row.ID, 10)}}.Encode()
+	if err := call(ctx, http.MethodGet, addr, nil, &progress); err != nil {
+		n.logger.With("name", row.Name, "id", row.ID).Warnf("Cascade revocation status query failed: %s", err)
+		n.db.Model(row).Updates(map[string]interface{}{"last_error": err.Error(), "updated_at": time.Now()})
+		return
+	}
+	updates := map[string]interface{}{
+		"last_error": "",
+		"updated_at": time.Now(),
+	}
+	updates["progress"], _ = jsoniter.MarshalToString(&progress)
+	switch {
+	case progress.Done:
+		updates["status"] = StatusCompleted
+	case progress.Failed:
+		// Delivered again, the subordinate retries the steps that failed
+		updates["status"] = StatusFailed
+		updates["last_error"] = "the subordinate reported failed steps"
+	}
+	if err := n.db.Model(row).Updates(updates).Error; err != nil {
+		n.logger.Errorf("Database update error: %s", err)
+		return
+	}
+	if progress.Done {
+		n.logger.With("name", row.Name, "id", row.ID).Info("Cascade revocation completed by the subordinate")
+	}
+}
+
+// call Request the API of a subordinate signed with the CA key, out receives the data of the response
+func call(ctx context.Context, method, addr string, body []byte, out interface{}) error {
+	ctx, span := tracing.Start(ctx, "cascade."+strings.ToLower(method))
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	priv, _, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, addr, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err = reqauth.SignWithKey(req, body, priv); err != nil {
+		return err
+	}
+	tracing.Inject(ctx, req.Header)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Data jsoniter.RawMessage `json:"data"`
+		Msg  string              `json:"message"`
+	}
+	_ = jsoniter.Unmarshal(data, &response)
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("subordinate responded %d: %s", resp.StatusCode, response.Msg)
+		return err
+	}
+	if out != nil {
+		err = jsoniter.Unmarshal(response.Data, out)
+	}
+	return err
+}
@@ -0,0 +1,442 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cascade
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/ztalab/zaca-sdk/caclient"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/jobs"
+	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// API of a subordinate CA called by its upper CA, requests are signed with the key of the upper CA
+const (
+	PathNotify   = "/api/v1/cascade/upstream/notify"
+	PathStatus   = "/api/v1/cascade/upstream/status"
+	PathPreview  = "/api/v1/cascade/upstream/preview"
+	PathTopology = "/api/v1/cascade/upstream/topology"
+)
+
+// OperatorUpperCA Operator of the revocations a subordinate makes on behalf of its upper CA
+const OperatorUpperCA = "upper CA"
+
+// Notification Cascade revocation sent to a subordinate CA
+type Notification struct {
+	ID            uint64   `json:"id"` // ID at the upper CA
+	Name          string   `json:"name"`
+	SerialNumbers []string `json:"serial_numbers"`
+	// SubjectKeyIDs Keys of the revoked certificates, everything issued with them is revoked
+	SubjectKeyIDs []string `json:"subject_key_ids"`
+	Reason        int      `json:"reason"`
+	Comment       string   `json:"comment"`
+}
+
+// Progress Work a subordinate CA started for a cascade revocation
+type Progress struct {
+	Jobs         []*JobProgress         `json:"jobs"`
+	Subordinates []*SubordinateProgress `json:"subordinates"`
+	Errors       []string               `json:"errors,omitempty"`
+	Done         bool                   `json:"done"`
+	// Failed Some step failed, the next delivery of the upper CA retries it
+	Failed bool `json:"failed"`
+}
+
+// JobProgress Bulk revocation of the certificates issued with one key
+type JobProgress struct {
+	ID        uint64 `json:"id"`
+	AKI       string `json:"aki,omitempty"`
+	Status    string `json:"status"`
+	Total     int64  `json:"total"`
+	Processed int64  `json:"processed"`
+}
+
+// SubordinateProgress Cascade revocation of a subordinate of the subordinate
+type SubordinateProgress struct {
+	ID       uint64    `json:"id"`
+	Name     string    `json:"name"`
+	Status   string    `json:"status"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+var (
+	verifierOnce sync.Once
+	verifier     *reqauth.Verifier
+)
+
+// Authenticate Verify that a request was signed with the key of the upper CA
+func Authenticate(r *http.Request, body []byte) error {
+	if core.Is.Config.Keymanager.SelfSign {
+		return errors.New("a root CA has no upper CA")
+	}
+	authReq, ok, err := reqauth.FromHTTP(r, body)
+	if !ok {
+		return errors.New("request is not signed")
+	}
+	if err != nil {
+		return err
+	}
+	pub, err := upperPublicKey()
+	if err != nil {
+		return err
+	}
+	verifierOnce.Do(func() {
+		verifier = core.RequestVerifier()
+	})
+	return verifier.VerifySignature(authReq, pub)
+}
+
+// upperPublicKey Key of the certificate that issued ours, found among the trust certificates of the upper CA
+func upperPublicKey() (crypto.PublicKey, error) {
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	trusts, err := keymanager.GetKeeper().GetL3CachedTrustCerts()
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range trusts {
+		if bytes.Equal(cert.SubjectKeyId, self.AuthorityKeyId) {
+			return cert.PublicKey, nil
+		}
+	}
+	return nil, errors.New("certificate of the upper CA not found")
+}
+
+// Receive Record a cascade revocation from the upper CA, stop signing and revoke everything issued with the revoked keys.
+// Deliveries are retried, a notification already handled retries the steps that failed
+func (l *Logic) Receive(n *Notification, origin events.Origin) (*model.CascadeRevocation, error) {
+	if self := core.Is.Config.Keymanager.CsrTemplates.IntermediateCa.Ou; n.Name != self {
+		return nil, errors.Errorf("revocation of %s sent to %s", n.Name, self)
+	}
+	if len(n.SerialNumbers) == 0 || len(n.SubjectKeyIDs) == 0 {
+		return nil, errors.New("serial_numbers and subject_key_ids are required")
+	}
+	origin.Operator = OperatorUpperCA
+
+	row, err := l.receiveRow(n, origin)
+	if err != nil {
+		return nil, err
+	}
+	progress, retry := &Progress{}, row.Progress != ""
+	if retry {
+		if err := jsoniter.UnmarshalFromString(row.Progress, progress); err != nil {
+			return nil, err
+		}
+		if err := l.refresh(progress); err != nil {
+			return nil, err
+		}
+		if !progress.Failed {
+			return row, nil
+		}
+		l.logger.With("id", row.ID).Warnf("Retrying the failed steps of cascade revocation %d", n.ID)
+	}
+
+	l.revokeIssued(n, origin, progress)
+	row.Progress, _ = jsoniter.MarshalToString(progress)
+	if err := l.db.Model(row).Updates(map[string]interface{}{
+		"progress":   row.Progress,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	if retry {
+		return row, nil
+	}
+
+	events.NewCascadeRevocation("cascade-receive", OperatorUpperCA, cascadeOp(row, n.SerialNumbers)).
+		WithOrigin(origin).Log()
+	l.logger.Warnf("Upper CA revoked this CA with cascade revocation %d, signing stopped", n.ID)
+	return row, nil
+}
+
+func (l *Logic) receiveRow(n *Notification, origin events.Origin) (*model.CascadeRevocation, error) {
+	query := func() *gorm.DB {
+		return l.db.Where("direction = ? AND upstream_id = ?", DirectionUpstream, n.ID)
+	}
+	row, err := dao.GetCascadeRevocation(query())
+	if err != nil || row != nil {
+		return row, err
+	}
+
+	sns, _ := jsoniter.MarshalToString(n.SerialNumbers)
+	skis, _ := jsoniter.MarshalToString(n.SubjectKeyIDs)
+	now := time.Now()
+	row = &model.CascadeRevocation{
+		Direction:     DirectionUpstream,
+		Name:          n.Name,
+		SerialNumbers: sns,
+		SubjectKeyIDs: skis,
+		Reason:        n.Reason,
+		Comment:       n.Comment,
+		Status:        StatusReceived,
+		CreatedBy:     origin.Operator,
+		SourceIP:      origin.SourceIP,
+		RequestID:     origin.RequestID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	row.UpstreamID.SetValid(int64(n.ID))
+	if _, _, err := dao.AddCascadeRevocation(l.db, row); err != nil {
+		// Delivered concurrently by another instance of the upper CA
+		if existing, _ := dao.GetCascadeRevocation(query()); existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return row, nil
+}
+
+// revokeIssued Revoke our own subordinates signed with the revoked keys, then everything else issued with them.
+// Failures are reported in the progress, the jobs and deliveries started are retried on their own.
+// On a retry, the steps of progress that failed are started again
+func (l *Logic) revokeIssued(n *Notification, origin events.Origin, progress *Progress) {
+	progress.Errors = nil
+	for _, sp := range progress.Subordinates {
+		// Revoked here already, delivered once an address is configured
+		if sp.Status != StatusUnreachable {
+			continue
+		}
+		addr, ok := subordinateURL(sp.Name)
+		if !ok {
+			progress.Errors = append(progress.Errors, fmt.Sprintf("%s: no address configured", sp.Name))
+			continue
+		}
+		if err := l.db.Model(&model.CascadeRevocation{}).Where("id = ? AND status = ?", sp.ID, StatusUnreachable).
+			Updates(map[string]interface{}{
+				"url":        addr,
+				"status":     StatusPending,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+			progress.Errors = append(progress.Errors, fmt.Sprintf("%s: %s", sp.Name, err))
+			continue
+		}
+		sp.Status = StatusPending
+	}
+
+	names, err := l.subordinateNames(revocable(l.db, revocation.CACompromise), n.SubjectKeyIDs)
+	if err != nil {
+		progress.Errors = append(progress.Errors, err.Error())
+	}
+	for _, name := range names {
+		child, err := l.Revoke(&RevokeParams{
+			Name:    name,
+			Reason:  revocation.ReasonString(n.Reason),
+			Comment: fmt.Sprintf("cascade of revocation %d at the upper CA", n.ID),
+			Origin:  origin,
+		})
+		if err != nil {
+			progress.Errors = append(progress.Errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		progress.Subordinates = append(progress.Subordinates, &SubordinateProgress{ID: child.ID, Name: name, Status: child.Status})
+	}
+
+	// The leaves of a revoked CA are revoked as cACompromise whatever the reason of the CA
+	started := make(map[string]bool, len(progress.Jobs))
+	for _, jp := range progress.Jobs {
+		if jp.Status != jobs.StatusFailed && jp.Status != jobs.StatusCancelled {
+			started[jp.AKI] = true
+		}
+	}
+	jobLogic := jobs.NewLogic()
+	for _, ski := range n.SubjectKeyIDs {
+		if started[ski] {
+			continue
+		}
+		job, err := jobLogic.Create(&jobs.CreateParams{
+			Kind:     jobs.KindRevoke,
+			Selector: jobs.Selector{AKI: ski},
+			Origin:   origin,
+		})
+		if err != nil {
+			progress.Errors = append(progress.Errors, fmt.Sprintf("job for %s: %s", ski, err))
+			continue
+		}
+		progress.Jobs = append(progress.Jobs, &JobProgress{ID: job.ID, AKI: ski, Status: job.Status})
+	}
+	progress.Failed = len(progress.Errors) > 0
+}
+
+// Status Current progress of a cascade revocation received from the upper CA
+func (l *Logic) Status(upstreamID uint64) (*Progress, error) {
+	row, err := dao.GetCascadeRevocation(l.db.Where("direction = ? AND upstream_id = ?", DirectionUpstream, upstreamID))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil || row.Progress == "" {
+		return nil, errors.New("cascade revocation not found")
+	}
+	progress := &Progress{}
+	if err := jsoniter.UnmarshalFromString(row.Progress, progress); err != nil {
+		return nil, err
+	}
+	if err := l.refresh(progress); err != nil {
+		return nil, err
+	}
+
+	if progress.Done && row.Status != StatusCompleted {
+		if err := l.db.Model(row).Updates(map[string]interface{}{
+			"status":     StatusCompleted,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return progress, nil
+}
+
+// refresh Update the jobs and subordinates of progress. It is done once all of them completed,
+// failed when one of them cannot complete without a retry
+func (l *Logic) refresh(progress *Progress) error {
+	done, failed := true, len(progress.Errors) > 0
+	for _, jp := range progress.Jobs {
+		job, err := dao.GetBulkJob(l.db.Where("id = ?", jp.ID))
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			jp.Status = jobs.StatusFailed
+		} else {
+			jp.Status, jp.Total, jp.Processed = job.Status, job.Total, job.Processed
+		}
+		switch jp.Status {
+		case jobs.StatusSucceeded:
+		case jobs.StatusFailed, jobs.StatusCancelled:
+			failed = true
+		default:
+			done = false
+		}
+	}
+	for _, sp := range progress.Subordinates {
+		child, err := l.Get(sp.ID)
+		if err != nil {
+			return err
+		}
+		sp.Status, sp.Progress = child.Status, child.Progress
+		switch child.Status {
+		case StatusCompleted:
+		case StatusUnreachable:
+			failed = true
+		default:
+			done = false
+		}
+	}
+	progress.Done, progress.Failed = done && !failed, failed
+	return nil
+}
+
+// blocked The signing processes see a revocation received by the api service within 10 seconds
+var blocked = memorycacher.New(10*time.Second, time.Minute, 16)
+
+// SigningBlocked Whether the upper CA revoked the certificate this CA signs with
+func SigningBlocked(ctx context.Context) (bool, error) {
+	if core.Is.Config.Keymanager.SelfSign {
+		return false, nil
+	}
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return false, err
+	}
+	sn := self.SerialNumber.String()
+	if v, ok := blocked.Get(sn); ok {
+		return v.(bool), nil
+	}
+	var n int64
+	err = core.Is.Db.WithContext(ctx).Model(&model.CascadeRevocation{}).
+		Where("direction = ? AND JSON_CONTAINS(serial_numbers, JSON_QUOTE(?))", DirectionUpstream, sn).
+		Count(&n).Error
+	if err != nil {
+		return false, err
+	}
+	blocked.SetDefault(sn, n > 0)
+	return n > 0, nil
+}
+
+// Preview Impact of revoking a subordinate CA
+type Preview struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+	// Certificates Intermediate certificates of the subordinate revoked by this CA
+	Certificates []*PreviewCert `json:"certificates,omitempty"`
+	// Units Units of the certificates the subordinate would revoke, at most MaxPreviewUnits
+	Units        []string   `json:"units"`
+	CertsCount   int64      `json:"certs_count"`
+	Truncated    bool       `json:"truncated"`
+	Subordinates []*Preview `json:"subordinates"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// PreviewCert ...
+type PreviewCert struct {
+	SN       string    `json:"sn"`
+	AKI      string    `json:"aki"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// MaxPreviewUnits Units listed per subordinate in a preview
+const MaxPreviewUnits = 1000
+
+// PreviewRequest Keys of the intermediate certificates the upper CA would revoke
+type PreviewRequest struct {
+	SubjectKeyIDs []string `json:"subject_key_ids" form:"subject_key_ids"`
+}
+
+// IssuedImpact What this CA would revoke if the keys were revoked by the upper CA, with our own subordinates
+func (l *Logic) IssuedImpact(ctx context.Context, skis []string) (*Preview, error) {
+	preview := &Preview{Name: core.Is.Config.Keymanager.CsrTemplates.IntermediateCa.Ou}
+	query := func() *gorm.DB {
+		return revocable(l.db.WithContext(ctx), revocation.CACompromise).
+			Where("authority_key_identifier IN ?", skis).
+			Where("(ca_label IS NULL OR ca_label != ?)", caclient.RoleIntermediate)
+	}
+	if err := query().Model(&model.Certificates{}).Count(&preview.CertsCount).Error; err != nil {
+		return nil, err
+	}
+	err := query().Model(&model.Certificates{}).Distinct("common_name").
+		Order("common_name").Limit(MaxPreviewUnits+1).Pluck("common_name", &preview.Units).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(preview.Units) > MaxPreviewUnits {
+		preview.Units, preview.Truncated = preview.Units[:MaxPreviewUnits], true
+	}
+
+	names, err := l.subordinateNames(revocable(l.db.WithContext(ctx), revocation.CACompromise), skis)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		preview.Subordinates = append(preview.Subordinates, l.Preview(ctx, name))
+	}
+	return preview, nil
+}
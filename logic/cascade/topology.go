@@ -0,0 +1,261 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cascade
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/topology"
+)
+
+// topologyCache Subtrees by depth, a refresh bypasses it
+var topologyCache = memorycacher.New(time.Minute, 5*time.Minute, 64)
+
+// Topology Tree from the root CA down to every subordinate, this CA marked as current.
+// CAs above this one are known from the trust certificates only
+func (l *Logic) Topology(ctx context.Context, refresh bool) (*topology.Node, error) {
+	self, err := l.Subtree(ctx, core.Is.Config.Topology.MaxDepth, refresh)
+	if err != nil {
+		return nil, err
+	}
+	if core.Is.Config.Keymanager.SelfSign {
+		return self, nil
+	}
+	return l.ancestors(self), nil
+}
+
+// Subtree This CA and its subordinates down to depth levels, each subordinate reports its own subtree
+func (l *Logic) Subtree(ctx context.Context, depth int, refresh bool) (*topology.Node, error) {
+	key := strconv.Itoa(depth)
+	if !refresh {
+		if v, ok := topologyCache.Get(key); ok {
+			return v.(*topology.Node), nil
+		}
+	}
+	node, err := l.selfNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		children, err := l.children(ctx, depth-1, refresh)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = children
+	}
+	node.Sort()
+	topologyCache.Set(key, node, time.Duration(core.Is.Config.Topology.CacheTTL)*time.Second)
+	return node, nil
+}
+
+func (l *Logic) selfNode(ctx context.Context) (*topology.Node, error) {
+	conf := core.Is.Config
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	now, notAfter := time.Now(), self.NotAfter
+	node := &topology.Node{
+		Name:       conf.Keymanager.CsrTemplates.IntermediateCa.Ou,
+		Role:       topology.RoleIntermediate,
+		Current:    true,
+		NotAfter:   &notAfter,
+		ReportedAt: &now,
+		Status:     topology.CertStatus(notAfter, now, expiryWarning()),
+		Children:   []*topology.Node{},
+	}
+	if conf.Keymanager.SelfSign {
+		node.Name, node.Role = conf.Keymanager.CsrTemplates.RootCa.O, topology.RoleRoot
+	}
+	if blocked, err := SigningBlocked(ctx); err != nil {
+		l.logger.Warnf("Signing block query error: %s", err)
+	} else if blocked {
+		node.Status = topology.StatusRevoked
+	}
+
+	count := func(since time.Time, n *int64) error {
+		return l.db.WithContext(ctx).Model(&model.Certificates{}).Where("issued_at >= ?", since).Count(n).Error
+	}
+	if err := count(now.Add(-time.Hour), &node.IssuedHour); err != nil {
+		return nil, err
+	}
+	if err := count(now.Add(-24*time.Hour), &node.IssuedDay); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// children Subordinates signed here, grouped by OU, asked concurrently for their subtree
+func (l *Logic) children(ctx context.Context, depth int, refresh bool) ([]*topology.Node, error) {
+	records, certs, err := l.loadIntermediates(l.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	byName := make(map[string]*topology.Node)
+	var children []*topology.Node
+	for i, cert := range certs {
+		name := ou(cert)
+		if name == "" {
+			continue
+		}
+		node, ok := byName[name]
+		if !ok {
+			node = &topology.Node{Name: name, Role: topology.RoleIntermediate, Status: topology.StatusExpired, Children: []*topology.Node{}}
+			byName[name] = node
+			children = append(children, node)
+		}
+		node.Certs = append(node.Certs, &topology.Cert{
+			SN:       cert.SerialNumber.String(),
+			AKI:      hex.EncodeToString(cert.AuthorityKeyId),
+			NotAfter: cert.NotAfter,
+			Status:   records[i].Status,
+		})
+		if records[i].Status == "revoked" {
+			if node.NotAfter == nil {
+				node.Status = topology.StatusRevoked
+			}
+			continue
+		}
+		// The subordinate signs with its latest valid certificate
+		if node.NotAfter == nil || cert.NotAfter.After(*node.NotAfter) {
+			notAfter := cert.NotAfter
+			node.NotAfter = &notAfter
+			node.Status = topology.CertStatus(notAfter, now, expiryWarning())
+		}
+	}
+
+	var seen []*model.SubordinateNode
+	if err := l.db.WithContext(ctx).Select("name", "last_seen_at").Find(&seen).Error; err != nil {
+		l.logger.Warnf("Subordinate registry query error: %s", err)
+	}
+	lastSeen := make(map[string]time.Time, len(seen))
+	for _, row := range seen {
+		lastSeen[row.Name] = row.LastSeenAt
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range children {
+		if t, ok := lastSeen[node.Name]; ok {
+			node.LastHeartbeat = &t
+		}
+		addr, ok := subordinateURL(node.Name)
+		switch {
+		case node.Status == topology.StatusRevoked || node.Status == topology.StatusExpired:
+			// Cannot sign anymore, nothing to ask
+		case !ok:
+			node.Status, node.Error = topology.StatusUnknown, "no address configured for the subordinate"
+		default:
+			node.URL = addr
+			wg.Add(1)
+			go func(node *topology.Node) {
+				defer wg.Done()
+				l.fetchSubtree(ctx, node, depth, refresh)
+			}(node)
+		}
+	}
+	wg.Wait()
+	return children, nil
+}
+
+// fetchSubtree Merge the subtree reported by the subordinate into node
+func (l *Logic) fetchSubtree(ctx context.Context, node *topology.Node, depth int, refresh bool) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(core.Is.Config.Topology.Timeout)*time.Second)
+	defer cancel()
+	query := url.Values{"depth": {strconv.Itoa(depth)}, "refresh": {strconv.FormatBool(refresh)}}
+	var remote topology.Node
+	err := call(ctx, http.MethodGet, node.URL+PathTopology+"?"+query.Encode(), nil, &remote)
+
+	if err != nil {
+		l.logger.With("name", node.Name).Warnf("Subordinate topology query error: %s", err)
+		node.Status, node.Error = topology.StatusUnreachable, err.Error()
+		return
+	}
+	// Its own view wins: it may sign with a certificate renewed elsewhere, or know it is revoked
+	node.Status, node.NotAfter, node.ReportedAt = remote.Status, remote.NotAfter, remote.ReportedAt
+	node.IssuedHour, node.IssuedDay = remote.IssuedHour, remote.IssuedDay
+	node.Children = remote.Children
+	for _, child := range node.Children {
+		child.Walk(func(n *topology.Node, _ int) { n.Current = false })
+	}
+}
+
+// ancestors Chain of the upper CAs above self, from the trust certificates
+func (l *Logic) ancestors(self *topology.Node) *topology.Node {
+	trust, err := keymanager.GetKeeper().GetL3CachedTrustCerts()
+	if err != nil {
+		l.logger.Warnf("Trust certificates unavailable, the tree starts at this CA: %s", err)
+		return self
+	}
+	_, cert, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return self
+	}
+	bySKI := make(map[string]*x509.Certificate, len(trust))
+	for _, c := range trust {
+		bySKI[hex.EncodeToString(c.SubjectKeyId)] = c
+	}
+	now := time.Now()
+	top := self
+	for depth := 0; depth < len(trust); depth++ {
+		parent, ok := bySKI[hex.EncodeToString(cert.AuthorityKeyId)]
+		if !ok || parent == cert {
+			break
+		}
+		notAfter := parent.NotAfter
+		node := &topology.Node{
+			Name:     caName(parent),
+			Role:     topology.RoleIntermediate,
+			NotAfter: &notAfter,
+			Status:   topology.CertStatus(notAfter, now, expiryWarning()),
+			Children: []*topology.Node{top},
+		}
+		if node.Status == topology.StatusHealthy {
+			// Only its validity is known from here
+			node.Status = topology.StatusUnknown
+		}
+		if len(parent.AuthorityKeyId) == 0 || bytes.Equal(parent.AuthorityKeyId, parent.SubjectKeyId) {
+			node.Role = topology.RoleRoot
+		}
+		top, cert = node, parent
+	}
+	return top
+}
+
+// caName OU of an intermediate, O of a root
+func caName(cert *x509.Certificate) string {
+	if name := ou(cert); name != "" {
+		return name
+	}
+	if len(cert.Subject.Organization) > 0 {
+		return cert.Subject.Organization[0]
+	}
+	return cert.Subject.CommonName
+}
+
+func expiryWarning() time.Duration {
+	return time.Duration(core.Is.Config.Topology.ExpiryWarning) * 24 * time.Hour
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/auditchain"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// chainBatchSize Events linked per transaction
+const chainBatchSize = 500
+
+// chainInterval Delay of an event before it is linked
+const chainInterval = time.Second
+
+// persist Insert the operation into audit_events, Chain links it to the hash chain later.
+// The row is written after the operation, not in its transaction: an operation whose process
+// exits before persist returns is not audited, as it is not logged either.
+func persist(o *Op, obj string) (*model.AuditEvent, error) {
+	if core.Is == nil || core.Is.Db == nil {
+		return nil, nil
+	}
+	record := &model.AuditEvent{
+		Category:  o.Category,
+		Type:      o.Type,
+		Operator:  o.Operator,
+		SourceIP:  o.SourceIP,
+		RequestID: o.RequestID,
+		Object:    obj,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	switch v := o.Obj.(type) {
+	case CertOp:
+		record.UniqueID, record.SerialNumber = v.UniqueId, v.SN
+	case KeyPairOp:
+		record.UniqueID, record.SerialNumber = v.CommonName, v.SN
+	}
+	// NULL until chained, a unique seq cannot hold several zeros
+	if err := core.Is.Db.Omit("seq", "prev_hash", "hash").Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RunChain Link the persisted events every interval, run as a singleton
+func RunChain(ctx context.Context) {
+	ticker := time.NewTicker(chainInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := Chain(ctx)
+			if err != nil {
+				logger.Named(LoggerName).Errorf("Audit chain error: %s", err)
+			}
+			if err != nil || n < chainBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Chain Link the oldest unchained events in id order, returns how many were linked.
+// The head is locked, the chainers of the api and tls services take turns.
+func Chain(ctx context.Context) (int, error) {
+	if err := core.Fence(ctx); err != nil {
+		return 0, err
+	}
+	var n int
+	err := core.Is.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		last, err := dao.GetAuditEvent(locked.Where("seq IS NOT NULL").Order("seq desc"))
+		if err != nil {
+			return err
+		}
+		var rows []*model.AuditEvent
+		if err := locked.Where("seq IS NULL").Order("id asc").Limit(chainBatchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		seq, prev := uint64(0), auditchain.Genesis
+		if last != nil {
+			seq, prev = last.Seq, last.Hash
+		}
+		for _, row := range rows {
+			seq++
+			hash := auditchain.Hash(seq, prev, row.HashFields()...)
+			err := tx.Model(&model.AuditEvent{}).Where("id = ? AND seq IS NULL", row.ID).
+				Updates(map[string]interface{}{"seq": seq, "prev_hash": prev, "hash": hash}).Error
+			if err != nil {
+				return err
+			}
+			prev = hash
+		}
+		n = len(rows)
+		return nil
+	})
+	return n, err
+}
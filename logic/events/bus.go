@@ -0,0 +1,137 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/eventbus"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/requestid"
+)
+
+var bus *eventbus.Bus
+
+// InitBus Start the configured sinks, operations are only logged and persisted without sinks
+func InitBus(conf config.Events, version string) error {
+	if len(conf.Sinks) == 0 {
+		return nil
+	}
+	b := eventbus.New(deadLetter, time.Duration(conf.Timeout)*time.Second)
+	for _, s := range conf.Sinks {
+		sink, err := newSink(s, version)
+		if err != nil {
+			return err
+		}
+		b.Subscribe(sink, eventbus.Filter{Categories: s.Categories, Types: s.Types}, conf.QueueSize)
+		logger.Named(LoggerName).Infof("Event sink %s (%s) enabled", s.Name, s.Type)
+	}
+	bus = b
+	return nil
+}
+
+func newSink(s config.EventSink, version string) (eventbus.Sink, error) {
+	if s.Name == "" {
+		return nil, fmt.Errorf("event sink without name")
+	}
+	switch s.Type {
+	case "webhook":
+		if s.URL == "" {
+			return nil, fmt.Errorf("event sink %s: url required", s.Name)
+		}
+		return eventbus.NewWebhook(s.Name, eventbus.WebhookConfig{
+			URL:        s.URL,
+			Secret:     s.Secret,
+			MaxRetries: s.MaxRetries,
+		}), nil
+	case "jsonl":
+		if s.Path == "" {
+			return nil, fmt.Errorf("event sink %s: path required", s.Name)
+		}
+		return eventbus.NewJSONLines(s.Name, s.Path), nil
+	case "syslog":
+		if s.Network == "" || s.Address == "" {
+			return nil, fmt.Errorf("event sink %s: network and address required", s.Name)
+		}
+		return eventbus.NewSyslog(s.Name, eventbus.SyslogConfig{
+			Network: s.Network,
+			Address: s.Address,
+			Version: version,
+		}), nil
+	}
+	return nil, fmt.Errorf("event sink %s: unknown type %q", s.Name, s.Type)
+}
+
+// CloseBus Deliver the queued events before exiting
+func CloseBus(ctx context.Context) {
+	if bus != nil {
+		bus.Close(ctx)
+	}
+}
+
+// publish The ID is the audit event ID when the operation was persisted
+func publish(o *Op, record *model.AuditEvent) {
+	if bus == nil {
+		return
+	}
+	e := &eventbus.Event{
+		ID:        requestid.New(),
+		Time:      time.Now(),
+		Category:  o.Category,
+		Type:      o.Type,
+		Operator:  o.Operator,
+		SourceIP:  o.SourceIP,
+		RequestID: o.RequestID,
+		Object:    o.Obj,
+	}
+	if record != nil {
+		e.ID = strconv.FormatUint(record.ID, 10)
+		e.Time = record.CreatedAt
+	}
+	bus.Publish(e)
+}
+
+// deadLetter Keep undeliverable events for a later replay
+func deadLetter(sink string, e *eventbus.Event, err error) {
+	l := logger.Named(LoggerName).With("sink", sink, "event_id", e.ID)
+	l.Warnf("Event delivery failed: %s", err)
+	if core.Is == nil || core.Is.Db == nil {
+		return
+	}
+	payload, _ := json.Marshal(e)
+	msg := err.Error()
+	if len(msg) > 1024 {
+		msg = msg[:1024]
+	}
+	_, _, dbErr := dao.AddEventDeadLetter(core.Is.Db, &model.EventDeadLetter{
+		Sink:      sink,
+		EventID:   e.ID,
+		Category:  e.Category,
+		Type:      e.Type,
+		Payload:   string(payload),
+		Error:     msg,
+		CreatedAt: time.Now(),
+	})
+	if dbErr != nil {
+		l.Errorf("Dead letter not stored: %s", dbErr)
+	}
+}
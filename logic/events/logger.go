@@ -15,43 +15,102 @@ package events
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/requestid"
 )
 
 const (
 	LoggerName                = "events"
 	CategoryWorkloadLifecycle = "workload_lifecycle"
+	CategoryCA                = "ca"
+	CategoryChangeRequest     = "change_request"
 )
 
 var CategoriesStrings = map[string]string{
 	CategoryWorkloadLifecycle: "Workload life cycle",
+	CategoryCA:                "CA",
+	CategoryChangeRequest:     "Change request",
 }
 
 const (
-	OperatorMSP = "MSP platform"
-	OperatorSDK = "SDK"
+	OperatorMSP    = "MSP platform"
+	OperatorSDK    = "SDK"
+	OperatorSystem = "ZACA"
 )
 
+// Origin Caller of an operation
+type Origin struct {
+	Operator  string
+	SourceIP  string
+	RequestID string
+}
+
+// OriginFromRequest ...
+func OriginFromRequest(r *http.Request, operator string) Origin {
+	return Origin{
+		Operator:  operator,
+		SourceIP:  requestid.SourceIP(r),
+		RequestID: requestid.FromRequest(r),
+	}
+}
+
 type CertOp struct {
 	UniqueId string `json:"unique_id"`
 	SN       string `json:"sn"`
 	AKI      string `json:"aki"`
+	// RenewedFrom Serial number of the certificate being renewed
+	RenewedFrom string `json:"renewed_from,omitempty"`
+	// Revocation details, RFC 5280 reason name
+	Reason         string     `json:"reason,omitempty"`
+	InvalidityDate *time.Time `json:"invalidity_date,omitempty"`
+	HoldUntil      *time.Time `json:"hold_until,omitempty"`
+	Comment        string     `json:"comment,omitempty"`
 }
 
 // Op Operation record
 type Op struct {
-	Operator string      `json:"operator"` // Operator
-	Category string      `json:"category"` // Classification
-	Type     string      `json:"type"`     // Operation type
-	Obj      interface{} `json:"obj"`      // Operation object
+	Operator  string      `json:"operator"`             // Operator
+	Category  string      `json:"category"`             // Classification
+	Type      string      `json:"type"`                 // Operation type
+	Obj       interface{} `json:"obj"`                  // Operation object
+	SourceIP  string      `json:"source_ip,omitempty"`  // Caller address
+	RequestID string      `json:"request_id,omitempty"` // Correlation ID
+}
+
+// WithOrigin Record the caller, the operator is kept if the origin has none
+func (o *Op) WithOrigin(origin Origin) *Op {
+	if origin.Operator != "" {
+		o.Operator = origin.Operator
+	}
+	o.SourceIP = origin.SourceIP
+	o.RequestID = origin.RequestID
+	return o
+}
+
+// WithRequest Record the caller address and request ID of a cfssl request
+func (o *Op) WithRequest(r *http.Request) *Op {
+	return o.WithOrigin(OriginFromRequest(r, ""))
 }
 
+// Log Persist the operation to the audit log, write the log line and publish it to the event sinks
 func (o *Op) Log() {
 	objStr, _ := jsoniter.MarshalToString(o.Obj)
-	logger.Named(LoggerName).
+	l := logger.Named(LoggerName).
 		With("flag", fmt.Sprintf("%s.%s", o.Category, o.Type)).
-		With("data", o.Obj).
-		Infof("Classification: %s, Operation: %s, Operator: %s, Operation object: %v", CategoriesStrings[o.Category], o.Type, o.Operator, objStr)
+		With("data", o.Obj)
+	if o.RequestID != "" {
+		l = l.With("request_id", o.RequestID)
+	}
+	record, err := persist(o, objStr)
+	if err != nil {
+		l.Errorf("Audit event not persisted: %s", err)
+	} else if record != nil {
+		l = l.With("audit_id", record.ID)
+	}
+	l.Infof("Classification: %s, Operation: %s, Operator: %s, Operation object: %v", CategoriesStrings[o.Category], o.Type, o.Operator, objStr)
+	publish(o, record)
 }
@@ -21,3 +21,118 @@ func NewWorkloadLifeCycle(op string, author string, cert CertOp) *Op {
 		Obj:      cert,
 	}
 }
+
+// KeyPairOp CA certificate that became the signing key pair
+type KeyPairOp struct {
+	CommonName string `json:"common_name"`
+	SN         string `json:"sn"`
+	AKI        string `json:"aki"`
+	SKI        string `json:"ski"`
+	NotAfter   string `json:"not_after"`
+}
+
+func NewCAKeyPair(op string, author string, kp KeyPairOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryCA,
+		Type:     op,
+		Obj:      kp,
+	}
+}
+
+// JobOp Bulk lifecycle job
+type JobOp struct {
+	ID        uint64 `json:"id"`
+	Kind      string `json:"kind"`
+	Selector  string `json:"selector"`
+	Status    string `json:"status"`
+	Processed int64  `json:"processed"`
+}
+
+func NewBulkJob(op string, author string, job JobOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryWorkloadLifecycle,
+		Type:     op,
+		Obj:      job,
+	}
+}
+
+// CascadeOp Revocation of a subordinate CA and everything it issued
+type CascadeOp struct {
+	ID            uint64   `json:"id"`
+	Direction     string   `json:"direction"`
+	Name          string   `json:"name"`
+	SerialNumbers []string `json:"serial_numbers"`
+	Reason        string   `json:"reason"`
+	Status        string   `json:"status"`
+}
+
+func NewCascadeRevocation(op string, author string, cascade CascadeOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryCA,
+		Type:     op,
+		Obj:      cascade,
+	}
+}
+
+// ForbidRuleOp Pattern-based forbid rule
+type ForbidRuleOp struct {
+	ID        uint64 `json:"id"`
+	Kind      string `json:"kind"`
+	Pattern   string `json:"pattern"`
+	Effect    string `json:"effect"`
+	MaxTTL    int    `json:"max_ttl,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+func NewForbidRule(op string, author string, rule ForbidRuleOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryWorkloadLifecycle,
+		Type:     op,
+		Obj:      rule,
+	}
+}
+
+// ChangeRequestOp Protected operation and its review
+type ChangeRequestOp struct {
+	ID         uint64 `json:"id"`
+	Operation  string `json:"operation"`
+	Subject    string `json:"subject"`
+	Summary    string `json:"summary"`
+	Status     string `json:"status"`
+	ReviewedBy string `json:"reviewed_by,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Result     string `json:"result,omitempty"`
+}
+
+func NewChangeRequest(op string, author string, cr ChangeRequestOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryChangeRequest,
+		Type:     op,
+		Obj:      cr,
+	}
+}
+
+// SubordinateOp Subordinate CA known from its heartbeats
+type SubordinateOp struct {
+	Name         string `json:"name"`
+	SiteID       string `json:"site_id"`
+	ClusterID    string `json:"cluster_id"`
+	Version      string `json:"version"`
+	SerialNumber string `json:"serial_number"`
+	LastSeenAt   string `json:"last_seen_at"`
+}
+
+func NewSubordinate(op string, author string, sub SubordinateOp) *Op {
+	return &Op{
+		Operator: author,
+		Category: CategoryCA,
+		Type:     op,
+		Obj:      sub,
+	}
+}
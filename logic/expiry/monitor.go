@@ -0,0 +1,287 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expiry
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/notify"
+)
+
+const (
+	day = 24 * time.Hour
+	// retention Notified rows are kept this long after the certificate expired
+	retention = 30 * day
+)
+
+// Monitor Scans for live certificates nearing expiry that were not renewed
+type Monitor struct {
+	db        *gorm.DB
+	logger    *zap.SugaredLogger
+	interval  time.Duration
+	roles     map[string]notify.Thresholds
+	ca        notify.Thresholds
+	notifiers []notify.Notifier
+}
+
+// NewMonitor ...
+func NewMonitor(conf config.ExpiryMonitor) (*Monitor, error) {
+	m := &Monitor{
+		db:       core.Is.Db,
+		logger:   logger.Named("expiry").SugaredLogger,
+		interval: time.Duration(conf.Interval) * time.Minute,
+		roles:    make(map[string]notify.Thresholds, len(conf.Thresholds)),
+		ca:       days(conf.CAThresholds),
+	}
+	for role, th := range conf.Thresholds {
+		m.roles[role] = days(th)
+	}
+	for _, ch := range conf.Channels {
+		n, err := newNotifier(ch)
+		if err != nil {
+			return nil, err
+		}
+		m.notifiers = append(m.notifiers, n)
+	}
+	return m, nil
+}
+
+func days(list []int) notify.Thresholds {
+	th := make(notify.Thresholds, 0, len(list))
+	for _, d := range list {
+		th = append(th, time.Duration(d)*day)
+	}
+	return th
+}
+
+func newNotifier(ch config.AlertChannel) (notify.Notifier, error) {
+	switch ch.Type {
+	case "webhook":
+		if ch.URL == "" {
+			return nil, fmt.Errorf("alert channel %s: url required", ch.Name)
+		}
+		return notify.NewWebhook(ch.Name, ch.URL, ch.Secret), nil
+	case "smtp":
+		if ch.SMTPAddr == "" || ch.From == "" || len(ch.To) == 0 {
+			return nil, fmt.Errorf("alert channel %s: smtp-addr, from and to required", ch.Name)
+		}
+		return notify.NewSMTP(ch.Name, notify.SMTPConfig{
+			Addr:     ch.SMTPAddr,
+			From:     ch.From,
+			To:       ch.To,
+			Username: ch.Username,
+			Password: ch.Password,
+			StartTLS: ch.StartTLS,
+		}), nil
+	case "prometheus":
+		return notify.NewPrometheus(prometheus.DefaultRegisterer)
+	}
+	return nil, fmt.Errorf("alert channel %s: unknown type %q", ch.Name, ch.Type)
+}
+
+// Run Scan until ctx is done, a singleton job of the leader when an elector is configured
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Scan(ctx); err != nil {
+			m.logger.Errorf("Expiry scan error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan Notify the alerts of one scan, channels without state only get alerts not notified to them before
+func (m *Monitor) Scan(ctx context.Context) error {
+	now := time.Now()
+	alerts, err := m.workloadAlerts(now)
+	if err != nil {
+		return err
+	}
+	alerts = append(alerts, m.caAlerts(now)...)
+
+	notified := 0
+	for _, n := range m.notifiers {
+		if err := core.Fence(ctx); err != nil {
+			return err
+		}
+		list := alerts
+		if !n.Stateful() {
+			if list, err = m.claim(n.Name(), alerts); err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				continue
+			}
+		}
+		if err := n.Notify(ctx, list); err != nil {
+			m.logger.With("channel", n.Name()).Errorf("Alert notification error: %s", err)
+			// The next scan retries this channel only
+			if !n.Stateful() {
+				m.release(n.Name(), list)
+			}
+			continue
+		}
+		if !n.Stateful() {
+			notified += len(list)
+		}
+	}
+	m.logger.Infof("Expiry scan: %d certificates within thresholds, %d new alerts notified", len(alerts), notified)
+
+	return m.db.Where("expiry < ?", now.Add(-retention)).Delete(&model.ExpiryAlert{}).Error
+}
+
+// workloadAlerts Live certificates without a newer live certificate for the same common name
+func (m *Monitor) workloadAlerts(now time.Time) ([]notify.Alert, error) {
+	var max time.Duration
+	for _, th := range m.roles {
+		if th.Max() > max {
+			max = th.Max()
+		}
+	}
+	var rows []*model.Certificates
+	err := m.db.Model(&model.Certificates{}).
+		Select("serial_number", "authority_key_identifier", "ca_label", "common_name", "expiry").
+		Where("status = ?", "good").
+		Where("expiry > ? AND expiry < ?", now, now.Add(max)).
+		Where("NOT EXISTS (SELECT 1 FROM certificates n WHERE n.common_name = certificates.common_name " +
+			"AND n.status = 'good' AND n.expiry > certificates.expiry)").
+		Find(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "Database query error")
+	}
+
+	alerts := make([]notify.Alert, 0, len(rows))
+	for _, row := range rows {
+		th, ok := m.roles[row.CaLabel.String]
+		if !ok {
+			th = m.roles["default"]
+		}
+		remaining := row.Expiry.Sub(now)
+		stage, ok := th.Stage(remaining)
+		if !ok {
+			continue
+		}
+		alerts = append(alerts, notify.Alert{
+			Kind:      notify.KindWorkload,
+			Role:      row.CaLabel.String,
+			UniqueID:  row.CommonName.String,
+			SN:        row.SerialNumber,
+			AKI:       row.AuthorityKeyIdentifier,
+			NotAfter:  row.Expiry,
+			Threshold: notify.Duration(stage),
+			Remaining: notify.Duration(remaining),
+		})
+	}
+	return alerts, nil
+}
+
+// caAlerts Certificate of this CA and the upper CA certificates
+func (m *Monitor) caAlerts(now time.Time) []notify.Alert {
+	var alerts []notify.Alert
+	add := func(kind string, cert *x509.Certificate) {
+		remaining := cert.NotAfter.Sub(now)
+		stage, ok := m.ca.Stage(remaining)
+		if !ok {
+			return
+		}
+		alerts = append(alerts, notify.Alert{
+			Kind:      kind,
+			UniqueID:  cert.Subject.CommonName,
+			SN:        cert.SerialNumber.String(),
+			AKI:       hex.EncodeToString(cert.AuthorityKeyId),
+			NotAfter:  cert.NotAfter,
+			Threshold: notify.Duration(stage),
+			Remaining: notify.Duration(remaining),
+		})
+	}
+
+	keeper := keymanager.GetKeeper()
+	if _, self, err := keeper.GetCachedSelfKeyPair(); err != nil {
+		m.logger.Warnf("CA certificate not available: %s", err)
+	} else if self != nil {
+		add(notify.KindSelf, self)
+	}
+	uppers, err := keeper.GetL3CachedTrustCerts()
+	if err != nil {
+		m.logger.Warnf("Upper CA certificates not available: %s", err)
+	}
+	for _, cert := range uppers {
+		add(notify.KindUpper, cert)
+	}
+	return alerts
+}
+
+// claim Record the alerts for the channel, returns the ones not notified to it before
+func (m *Monitor) claim(channel string, alerts []notify.Alert) ([]notify.Alert, error) {
+	var fresh []notify.Alert
+	for _, a := range alerts {
+		threshold := int64(time.Duration(a.Threshold).Seconds())
+		var legacy int64
+		err := m.db.Model(&model.ExpiryAlert{}).Where("serial_number = ? AND authority_key_identifier = ? AND threshold = ? AND channel = ?",
+			a.SN, a.AKI, threshold, "").Count(&legacy).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "Database query error")
+		}
+		if legacy > 0 {
+			continue
+		}
+		res := m.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ExpiryAlert{
+			Kind:                   a.Kind,
+			SerialNumber:           a.SN,
+			AuthorityKeyIdentifier: a.AKI,
+			Threshold:              threshold,
+			CommonName:             a.UniqueID,
+			Expiry:                 a.NotAfter,
+			CreatedAt:              time.Now(),
+			Channel:                channel,
+		})
+		if res.Error != nil {
+			return nil, errors.Wrap(res.Error, "Database insert error")
+		}
+		if res.RowsAffected == 1 {
+			fresh = append(fresh, a)
+		}
+	}
+	return fresh, nil
+}
+
+func (m *Monitor) release(channel string, alerts []notify.Alert) {
+	for _, a := range alerts {
+		err := m.db.Where("serial_number = ? AND authority_key_identifier = ? AND threshold = ? AND channel = ?",
+			a.SN, a.AKI, int64(time.Duration(a.Threshold).Seconds()), channel).Delete(&model.ExpiryAlert{}).Error
+		if err != nil {
+			m.logger.With("sn", a.SN).Errorf("Database delete error: %s", err)
+		}
+	}
+}
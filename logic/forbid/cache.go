@@ -0,0 +1,150 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forbid
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/forbidrule"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// ReasonForbiddenUnit Reason of the rules loaded from the forbid table
+const ReasonForbiddenUnit = "unique_id forbidden for signing certs"
+
+// Cache Forbid rules held in memory, the revision is checked at most once per interval
+type Cache struct {
+	db       *gorm.DB
+	logger   *zap.SugaredLogger
+	interval time.Duration
+
+	mu       sync.Mutex
+	matcher  *forbidrule.Matcher
+	revision uint64
+	checked  time.Time
+}
+
+var (
+	cacheOnce    sync.Once
+	defaultCache *Cache
+)
+
+// GetCache Cache of the process, built on first use
+func GetCache() *Cache {
+	cacheOnce.Do(func() {
+		defaultCache = NewCache(core.Is.Db, time.Duration(core.Is.Config.Forbid.RefreshInterval)*time.Second)
+	})
+	return defaultCache
+}
+
+// NewCache ...
+func NewCache(db *gorm.DB, interval time.Duration) *Cache {
+	return &Cache{
+		db:       db,
+		logger:   logger.Named("forbid").SugaredLogger,
+		interval: interval,
+	}
+}
+
+// Match The rule applying to the hosts, nil when none does
+func (c *Cache) Match(ctx context.Context, hosts []string) (*forbidrule.Decision, error) {
+	m, err := c.Matcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.Match(hosts, time.Now()), nil
+}
+
+// Matcher Current rules, reloaded when the revision changed.
+// If the database is unavailable the last loaded rules keep being used
+func (c *Cache) Matcher(ctx context.Context) (*forbidrule.Matcher, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.matcher != nil && time.Since(c.checked) < c.interval {
+		return c.matcher, nil
+	}
+
+	var rev model.ForbidRevision
+	if err := c.db.WithContext(ctx).Where("id = ?", 1).Take(&rev).Error; err != nil {
+		if c.matcher != nil {
+			c.logger.Warnf("Forbid revision query error, using the cached rules: %s", err)
+			c.checked = time.Now()
+			return c.matcher, nil
+		}
+		return nil, err
+	}
+	c.checked = time.Now()
+	if c.matcher != nil && rev.Revision == c.revision {
+		return c.matcher, nil
+	}
+
+	rules, err := c.load(ctx)
+	if err != nil {
+		if c.matcher != nil {
+			c.logger.Warnf("Forbid rules query error, using the cached rules: %s", err)
+			return c.matcher, nil
+		}
+		return nil, err
+	}
+	c.matcher = forbidrule.NewMatcher(rules)
+	c.revision = rev.Revision
+	c.logger.Infof("Loaded %d forbid rules at revision %d", c.matcher.Len(), c.revision)
+	return c.matcher, nil
+}
+
+func (c *Cache) load(ctx context.Context) ([]*forbidrule.Rule, error) {
+	db := c.db.WithContext(ctx)
+	var units []string
+	if err := db.Model(&model.Forbid{}).Where("deleted_at IS NULL").Pluck("unique_id", &units).Error; err != nil {
+		return nil, err
+	}
+	var records []*model.ForbidRule
+	err := db.Where("deleted_at IS NULL").Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*forbidrule.Rule, 0, len(units)+len(records))
+	for _, uid := range units {
+		rules = append(rules, &forbidrule.Rule{
+			Kind:    forbidrule.KindUniqueID,
+			Pattern: uid,
+			Effect:  forbidrule.EffectDeny,
+			Reason:  ReasonForbiddenUnit,
+		})
+	}
+	for _, record := range records {
+		rule := &forbidrule.Rule{
+			ID:      record.ID,
+			Kind:    record.Kind,
+			Pattern: record.Pattern,
+			Effect:  record.Effect,
+			MaxTTL:  time.Duration(record.MaxTTL) * time.Second,
+			Reason:  record.Reason,
+		}
+		if record.ExpiresAt.Valid {
+			rule.ExpiresAt = record.ExpiresAt.Time
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
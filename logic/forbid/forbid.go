@@ -0,0 +1,189 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package forbid Pattern-based and time-bound forbid rules.
+// Every change of the forbid and forbid_rule tables bumps forbid_revision in the same transaction,
+// the signing service keeps the rules in memory and reloads them when the revision moves.
+package forbid
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/forbidrule"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// Touch Bump the rule revision, call in the transaction changing forbid or forbid_rule
+func Touch(tx *gorm.DB) error {
+	return tx.Model(&model.ForbidRevision{}).Where("id = ?", 1).Updates(map[string]interface{}{
+		"revision":   gorm.Expr("revision + 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// Logic ...
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+// NewLogic ...
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("forbid").SugaredLogger,
+	}
+}
+
+// CreateParams ...
+type CreateParams struct {
+	// site / cluster / unique_id / unique_id_prefix / unique_id_glob / dns
+	Kind string `json:"kind" binding:"required"`
+	// site: trust domain, cluster: site/cluster, globs use path.Match syntax, e.g. gateway-* or *.example.com
+	Pattern string `json:"pattern" binding:"required"`
+	// deny (default) / short_ttl
+	Effect string `json:"effect"`
+	// Seconds, maximum validity of certificates signed under a short_ttl rule
+	MaxTTL    int           `json:"max_ttl"`
+	Reason    string        `json:"reason"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+	Origin    events.Origin `json:"-"`
+}
+
+// Create ...
+func (l *Logic) Create(params *CreateParams) (*model.ForbidRule, error) {
+	if params.Effect == "" {
+		params.Effect = forbidrule.EffectDeny
+	}
+	rule := &forbidrule.Rule{
+		Kind:    params.Kind,
+		Pattern: params.Pattern,
+		Effect:  params.Effect,
+		MaxTTL:  time.Duration(params.MaxTTL) * time.Second,
+	}
+	if err := forbidrule.Validate(rule); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(now) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	record := &model.ForbidRule{
+		Kind:      params.Kind,
+		Pattern:   params.Pattern,
+		Effect:    params.Effect,
+		MaxTTL:    params.MaxTTL,
+		Reason:    params.Reason,
+		CreatedBy: params.Origin.Operator,
+		SourceIP:  params.Origin.SourceIP,
+		RequestID: params.Origin.RequestID,
+		ExpiresAt: null.TimeFromPtr(params.ExpiresAt),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if record.Effect != forbidrule.EffectShortTTL {
+		record.MaxTTL = 0
+	}
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		if _, _, err := dao.AddForbidRule(tx, record); err != nil {
+			return err
+		}
+		return Touch(tx)
+	})
+	if err != nil {
+		l.logger.With("record", record).Errorf("Database insert error: %s", err)
+		return nil, err
+	}
+
+	events.NewForbidRule("forbid-rule", events.OperatorMSP, ruleOp(record)).WithOrigin(params.Origin).Log()
+	return record, nil
+}
+
+// Delete ...
+func (l *Logic) Delete(id uint64, origin events.Origin) error {
+	record, err := dao.GetForbidRule(l.db.Where("id = ? AND deleted_at IS NULL", id))
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return err
+	}
+	if record == nil {
+		return errors.New("forbid rule not found")
+	}
+	err = l.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(record).Where("deleted_at IS NULL").Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return Touch(tx)
+	})
+	if err != nil {
+		l.logger.With("id", id).Errorf("Database update error: %s", err)
+		return err
+	}
+
+	events.NewForbidRule("recover-forbid-rule", events.OperatorMSP, ruleOp(record)).WithOrigin(origin).Log()
+	return nil
+}
+
+// ListParams ...
+type ListParams struct {
+	Page, PageSize int
+	Kind           string
+	// Include deleted and expired rules
+	All bool
+}
+
+// List Forbid rules, newest first
+func (l *Logic) List(params *ListParams) ([]*model.ForbidRule, int64, error) {
+	db := l.db.Session(&gorm.Session{})
+	if params.Kind != "" {
+		db = db.Where("kind = ?", params.Kind)
+	}
+	if !params.All {
+		db = db.Where("deleted_at IS NULL").Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+	list, total, err := dao.GetAllForbidRule(db, params.Page, params.PageSize, "id desc")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func ruleOp(record *model.ForbidRule) events.ForbidRuleOp {
+	op := events.ForbidRuleOp{
+		ID:      record.ID,
+		Kind:    record.Kind,
+		Pattern: record.Pattern,
+		Effect:  record.Effect,
+		MaxTTL:  record.MaxTTL,
+		Reason:  record.Reason,
+	}
+	if record.ExpiresAt.Valid {
+		op.ExpiresAt = record.ExpiresAt.Time.Format(time.RFC3339)
+	}
+	return op
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory Periodic export of the certificate inventory as Prometheus gauges
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/inventory"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// Exporter Refreshes the inventory collector from the database
+type Exporter struct {
+	db        *gorm.DB
+	logger    *zap.SugaredLogger
+	conf      config.Inventory
+	collector *inventory.Collector
+}
+
+// NewExporter Register the collector on reg
+func NewExporter(conf config.Inventory, reg prometheus.Registerer) (*Exporter, error) {
+	c := inventory.NewCollector()
+	if err := reg.Register(c); err != nil {
+		return nil, errors.Wrap(err, "inventory collector registration error")
+	}
+	return &Exporter{
+		db:        core.Is.Db,
+		logger:    logger.Named("inventory").SugaredLogger,
+		conf:      conf,
+		collector: c,
+	}, nil
+}
+
+// Run Refresh until ctx is done, a singleton job of the leader when an elector is configured
+// so that dashboards do not sum the same units from every instance
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(e.conf.Interval) * time.Minute)
+	defer ticker.Stop()
+	// The leadership was lost, the new leader exports
+	defer e.collector.Reset()
+	for {
+		if err := e.Refresh(); err != nil {
+			e.logger.Errorf("Inventory refresh error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type unitRow struct {
+	CommonName string
+	CaLabel    string
+	Earliest   time.Time
+	Latest     time.Time
+	Num        int
+}
+
+// Refresh Load the live units and publish the selected ones
+func (e *Exporter) Refresh() error {
+	now := time.Now()
+	var live []unitRow
+	err := e.db.Model(&model.Certificates{}).
+		Select("common_name, MAX(ca_label) AS ca_label, MIN(expiry) AS earliest, MAX(expiry) AS latest, COUNT(*) AS num").
+		Where("status = ? AND expiry > ?", "good", now).
+		Group("common_name").
+		Scan(&live).Error
+	if err != nil {
+		return errors.Wrap(err, "Database query error")
+	}
+
+	var revoked []unitRow
+	err = e.db.Model(&model.Certificates{}).
+		Select("common_name, COUNT(*) AS num").
+		Where("status = ? AND expiry > ?", "revoked", now).
+		Group("common_name").
+		Scan(&revoked).Error
+	if err != nil {
+		return errors.Wrap(err, "Database query error")
+	}
+	revokedNum := make(map[string]int, len(revoked))
+	for _, r := range revoked {
+		revokedNum[r.CommonName] = r.Num
+	}
+
+	var forbidden []string
+	err = e.db.Model(&model.Forbid{}).
+		Where("deleted_at IS NULL").
+		Distinct().
+		Pluck("unique_id", &forbidden).Error
+	if err != nil {
+		return errors.Wrap(err, "Database query error")
+	}
+	isForbidden := make(map[string]bool, len(forbidden))
+	for _, id := range forbidden {
+		isForbidden[id] = true
+	}
+
+	units := make([]inventory.Unit, 0, len(live))
+	for _, r := range live {
+		units = append(units, inventory.Unit{
+			UniqueID:       r.CommonName,
+			Role:           r.CaLabel,
+			EarliestExpiry: r.Earliest,
+			LatestExpiry:   r.Latest,
+			Valid:          r.Num,
+			Revoked:        revokedNum[r.CommonName],
+			Forbidden:      isForbidden[r.CommonName],
+		})
+	}
+	selected := inventory.Select(units, e.conf.AllowList, e.conf.TopN)
+	e.collector.Update(selected, len(units), now)
+	e.logger.Debugf("Inventory refreshed: %d live units, %d exported", len(units), len(selected))
+	return nil
+}
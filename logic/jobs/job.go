@@ -0,0 +1,244 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jobs Bulk lifecycle operations processed asynchronously in resumable batches
+package jobs
+
+import (
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/logger"
+)
+
+// Kinds
+const (
+	KindRevoke  = "revoke"
+	KindRecover = "recover"
+	KindForbid  = "forbid"
+	// KindReissue Revoke as superseded. No certificate is issued by the server, the workloads
+	// enroll again on their own once they see their certificate revoked
+	KindReissue = "reissue"
+)
+
+// Statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var kinds = map[string]bool{KindRevoke: true, KindRecover: true, KindForbid: true, KindReissue: true}
+
+// Selector Certificates a job applies to, all given criteria must match
+type Selector struct {
+	UniqueIds []string `json:"unique_ids,omitempty"`
+	// SPIFFE cluster, e.g. spiffe://site/cluster
+	Cluster      string     `json:"cluster,omitempty"`
+	AKI          string     `json:"aki,omitempty"`
+	IssuedAfter  *time.Time `json:"issued_after,omitempty"`
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+}
+
+// Validate ...
+func (s Selector) Validate() error {
+	if len(s.UniqueIds) == 0 && s.Cluster == "" && s.AKI == "" && s.IssuedAfter == nil && s.IssuedBefore == nil {
+		return errors.New("selector requires unique_ids, cluster, aki or an issuance time range")
+	}
+	if s.Cluster != "" && !strings.HasPrefix(s.Cluster, "spiffe://") {
+		return errors.New("cluster must be a spiffe:// URI")
+	}
+	if s.IssuedAfter != nil && s.IssuedBefore != nil && !s.IssuedAfter.Before(*s.IssuedBefore) {
+		return errors.New("issued_after must be before issued_before")
+	}
+	return nil
+}
+
+// unitsOnly Forbid jobs with only a unit list apply to the units whether or not they hold certificates
+func (s Selector) unitsOnly() bool {
+	return len(s.UniqueIds) > 0 && s.Cluster == "" && s.AKI == "" && s.IssuedAfter == nil && s.IssuedBefore == nil
+}
+
+// Apply Filter certificates
+func (s Selector) Apply(db *gorm.DB) *gorm.DB {
+	if len(s.UniqueIds) > 0 {
+		db = db.Where("common_name IN ?", s.UniqueIds)
+	}
+	if s.Cluster != "" {
+		// SANs are stored as a JSON array, the SPIFFE ID of a workload is <cluster>/<unique_id>
+		pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSuffix(s.Cluster, "/"))
+		db = db.Where("JSON_SEARCH(sans, 'one', ?) IS NOT NULL", pattern+"/%")
+	}
+	if s.AKI != "" {
+		db = db.Where("authority_key_identifier = ?", s.AKI)
+	}
+	if s.IssuedAfter != nil {
+		db = db.Where("issued_at >= ?", *s.IssuedAfter)
+	}
+	if s.IssuedBefore != nil {
+		db = db.Where("issued_at < ?", *s.IssuedBefore)
+	}
+	return db
+}
+
+// Logic ...
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+// NewLogic ...
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("jobs").SugaredLogger,
+	}
+}
+
+// CreateParams ...
+type CreateParams struct {
+	Kind     string        `json:"kind"`
+	Selector Selector      `json:"selector"`
+	Origin   events.Origin `json:"-"`
+}
+
+// Create Queue a job, the runner of any api instance picks it up
+func (l *Logic) Create(params *CreateParams) (*model.BulkJob, error) {
+	if !kinds[params.Kind] {
+		return nil, errors.Errorf("unknown job kind %q", params.Kind)
+	}
+	if err := params.Selector.Validate(); err != nil {
+		return nil, err
+	}
+	selector, _ := jsoniter.MarshalToString(params.Selector)
+	now := time.Now()
+	job := &model.BulkJob{
+		Kind:      params.Kind,
+		Status:    StatusPending,
+		Selector:  selector,
+		CreatedBy: params.Origin.Operator,
+		SourceIP:  params.Origin.SourceIP,
+		RequestID: params.Origin.RequestID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, _, err := dao.AddBulkJob(l.db, job); err != nil {
+		l.logger.Errorf("Database insert error: %s", err)
+		return nil, err
+	}
+	events.NewBulkJob("job-create", events.OperatorMSP, jobOp(job)).WithOrigin(params.Origin).Log()
+	return job, nil
+}
+
+// ListParams ...
+type ListParams struct {
+	Page, PageSize int
+	Status, Kind   string
+}
+
+// List ...
+func (l *Logic) List(params *ListParams) ([]*model.BulkJob, int64, error) {
+	db := l.db.Session(&gorm.Session{})
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	if params.Kind != "" {
+		db = db.Where("kind = ?", params.Kind)
+	}
+	list, total, err := dao.GetAllBulkJob(db, params.Page, params.PageSize, "id desc")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// Get ...
+func (l *Logic) Get(id uint64) (*model.BulkJob, error) {
+	job, err := dao.GetBulkJob(l.db.Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("job not found")
+	}
+	return job, nil
+}
+
+// CancelParams ...
+type CancelParams struct {
+	ID     uint64        `json:"id"`
+	Origin events.Origin `json:"-"`
+}
+
+// Cancel Pending jobs stop at once, running jobs after the current batch
+func (l *Logic) Cancel(params *CancelParams) (*model.BulkJob, error) {
+	now := time.Now()
+	res := l.db.Model(&model.BulkJob{}).
+		Where("id = ? AND status = ?", params.ID, StatusPending).
+		Updates(map[string]interface{}{
+			"status":           StatusCancelled,
+			"cancel_requested": true,
+			"finished_at":      now,
+			"updated_at":       now,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		res = l.db.Model(&model.BulkJob{}).
+			Where("id = ? AND status = ?", params.ID, StatusRunning).
+			Updates(map[string]interface{}{"cancel_requested": true, "updated_at": now})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+	}
+	job, err := l.Get(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.Errorf("job is %s", job.Status)
+	}
+	events.NewBulkJob("job-cancel", events.OperatorMSP, jobOp(job)).WithOrigin(params.Origin).Log()
+	return job, nil
+}
+
+func jobOp(job *model.BulkJob) events.JobOp {
+	return events.JobOp{
+		ID:        job.ID,
+		Kind:      job.Kind,
+		Selector:  job.Selector,
+		Status:    job.Status,
+		Processed: job.Processed,
+	}
+}
+
+func origin(job *model.BulkJob) events.Origin {
+	return events.Origin{
+		Operator:  job.CreatedBy,
+		SourceIP:  job.SourceIP,
+		RequestID: job.RequestID,
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/forbid"
+	"github.com/ztalab/ZACA/logic/workload"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// errStop The job was cancelled or taken over by another runner
+var errStop = errors.New("job stopped")
+
+// Runner Claims queued jobs and jobs whose runner died, every api instance runs one
+type Runner struct {
+	db        *gorm.DB
+	logger    *zap.SugaredLogger
+	owner     string
+	batchSize int
+	interval  time.Duration
+	lease     time.Duration
+}
+
+// NewRunner ...
+func NewRunner(conf config.Jobs) *Runner {
+	host, _ := os.Hostname()
+	return &Runner{
+		db:        core.Is.Db,
+		logger:    logger.Named("jobs").SugaredLogger,
+		owner:     fmt.Sprintf("%s-%d", host, os.Getpid()),
+		batchSize: conf.BatchSize,
+		interval:  time.Duration(conf.PollInterval) * time.Second,
+		lease:     time.Duration(conf.Lease) * time.Second,
+	}
+}
+
+// Run Process jobs until ctx is done, a job in progress is released before it returns
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := r.claim()
+			if err != nil {
+				r.logger.Errorf("Job claim error: %s", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			r.process(ctx, job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim Take the oldest pending job, or a running one whose lease expired after a restart
+func (r *Runner) claim() (*model.BulkJob, error) {
+	now := time.Now()
+	var job model.BulkJob
+	err := r.db.Where("status = ? OR (status = ? AND lease_expires_at < ?)", StatusPending, StatusRunning, now).
+		Order("id").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := r.db.Model(&model.BulkJob{}).
+		Where("id = ? AND status = ? AND owner = ?", job.ID, job.Status, job.Owner).
+		Updates(map[string]interface{}{
+			"status":           StatusRunning,
+			"owner":            r.owner,
+			"lease_expires_at": now.Add(r.lease),
+			"started_at":       gorm.Expr("COALESCE(started_at, ?)", now),
+			"updated_at":       now,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Claimed by another runner in between
+		return nil, nil
+	}
+	if job.Status == StatusRunning {
+		r.logger.Infof("Resuming job %d of %s after %d processed", job.ID, job.Owner, job.Processed)
+	}
+	job.Status, job.Owner = StatusRunning, r.owner
+	return &job, nil
+}
+
+func (r *Runner) process(ctx context.Context, job *model.BulkJob) {
+	log := r.logger.With("job", job.ID, "kind", job.Kind)
+	var sel Selector
+	err := jsoniter.UnmarshalFromString(job.Selector, &sel)
+	if err == nil && job.Total == 0 && job.Processed == 0 {
+		err = r.count(job, sel)
+	}
+	if err == nil {
+		if job.Kind == KindForbid {
+			err = r.forbid(ctx, job, sel)
+		} else {
+			err = r.certs(ctx, job, sel)
+		}
+	}
+
+	status := StatusSucceeded
+	switch {
+	case err == errStop:
+		if !r.cancelled(job.ID) {
+			log.Warn("Job taken over by another runner")
+			return
+		}
+		status = StatusCancelled
+	case err != nil && ctx.Err() != nil:
+		// Shutdown, hand the job back so that another runner resumes it without waiting for the lease
+		err := r.db.Model(&model.BulkJob{}).Where("id = ? AND owner = ?", job.ID, r.owner).Updates(map[string]interface{}{
+			"status":           StatusPending,
+			"owner":            "",
+			"lease_expires_at": nil,
+			"updated_at":       time.Now(),
+		}).Error
+		if err != nil {
+			log.Errorf("Job release error: %s", err)
+			return
+		}
+		log.Infof("Job released on shutdown, %d processed", job.Processed)
+		return
+	case err != nil:
+		status = StatusFailed
+		log.Errorf("Job failed: %s", err)
+	}
+	updates := map[string]interface{}{
+		"status":           status,
+		"owner":            "",
+		"lease_expires_at": nil,
+		"finished_at":      time.Now(),
+		"updated_at":       time.Now(),
+	}
+	if err != nil && err != errStop {
+		updates["error"] = err.Error()
+	}
+	if err := r.db.Model(&model.BulkJob{}).Where("id = ? AND owner = ?", job.ID, r.owner).Updates(updates).Error; err != nil {
+		log.Errorf("Job status update error: %s", err)
+		return
+	}
+	job.Status = status
+	events.NewBulkJob("job-finish", events.OperatorSystem, jobOp(job)).WithOrigin(origin(job)).Log()
+	log.Infof("Job %s, %d processed", status, job.Processed)
+}
+
+// filter Certificates the job changes
+func filter(db *gorm.DB, kind string, sel Selector) *gorm.DB {
+	db = sel.Apply(db).Where("expiry > ?", time.Now())
+	switch kind {
+	case KindRecover:
+		return workload.Held(db)
+	case KindForbid:
+		return db.Where("status = ?", "good")
+	}
+	return workload.Revocable(db, jobRevocation(kind).Reason)
+}
+
+// jobRevocation Reissue supersedes the certificates, revoke takes them out as the lifecycle API does
+func jobRevocation(kind string) *workload.Revocation {
+	if kind == KindReissue {
+		return &workload.Revocation{Reason: revocation.Superseded}
+	}
+	return &workload.Revocation{Reason: revocation.CACompromise}
+}
+
+func (r *Runner) count(job *model.BulkJob, sel Selector) error {
+	var total int64
+	var err error
+	if job.Kind == KindForbid && sel.unitsOnly() {
+		total = int64(len(sel.UniqueIds))
+	} else if job.Kind == KindForbid {
+		err = filter(r.db.Model(&model.Certificates{}), job.Kind, sel).
+			Distinct("common_name").Count(&total).Error
+	} else {
+		err = filter(r.db.Model(&model.Certificates{}), job.Kind, sel).Count(&total).Error
+	}
+	if err != nil {
+		return err
+	}
+	job.Total = total
+	return r.db.Model(&model.BulkJob{}).Where("id = ?", job.ID).Update("total", total).Error
+}
+
+// certs Revoke, recover or reissue batch by batch, the cursor is committed with each batch
+func (r *Runner) certs(ctx context.Context, job *model.BulkJob, sel Selector) error {
+	rev := jobRevocation(job.Kind)
+	cursor := workload.Cursor{SN: job.CursorSN, AKI: job.CursorAKI}
+	return workload.ForEachCertBatch(filter(r.db, job.Kind, sel), cursor, r.batchSize, func(certs []*model.Certificates) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		last := certs[len(certs)-1]
+		err := r.batch(job, last.SerialNumber, last.AuthorityKeyIdentifier, len(certs), func(tx *gorm.DB) error {
+			if job.Kind == KindRecover {
+				return workload.RecoverBatch(tx, certs)
+			}
+			return workload.RevokeBatch(tx, certs, rev)
+		})
+		if err != nil {
+			return err
+		}
+		for _, cert := range certs {
+			op := events.CertOp{UniqueId: cert.CommonName.String, SN: cert.SerialNumber, AKI: cert.AuthorityKeyIdentifier}
+			if job.Kind != KindRecover {
+				op = rev.CertOp(cert)
+			}
+			events.NewWorkloadLifeCycle(job.Kind, events.OperatorMSP, op).WithOrigin(origin(job)).Log()
+		}
+		return nil
+	})
+}
+
+// forbid Forbid units batch by batch, the cursor is the last unique_id
+func (r *Runner) forbid(ctx context.Context, job *model.BulkJob, sel Selector) error {
+	cursor := job.CursorSN
+	for ctx.Err() == nil {
+		units, err := r.units(job, sel, cursor)
+		if err != nil {
+			return err
+		}
+		if len(units) == 0 {
+			return nil
+		}
+		cursor = units[len(units)-1]
+		var added []string
+		err = r.batch(job, cursor, "", len(units), func(tx *gorm.DB) error {
+			added = added[:0]
+			for _, uid := range units {
+				var n int64
+				if err := tx.Model(&model.Forbid{}).Where("unique_id = ? AND deleted_at IS NULL", uid).Count(&n).Error; err != nil {
+					return err
+				}
+				if n > 0 {
+					continue
+				}
+				now := time.Now()
+				if err := tx.Create(&model.Forbid{UniqueID: uid, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+					return err
+				}
+				added = append(added, uid)
+			}
+			if len(added) == 0 {
+				return nil
+			}
+			return forbid.Touch(tx)
+		})
+		if err != nil {
+			return err
+		}
+		for _, uid := range added {
+			events.NewWorkloadLifeCycle("forbid", events.OperatorMSP, events.CertOp{
+				UniqueId: uid,
+			}).WithOrigin(origin(job)).Log()
+		}
+		if len(units) < r.batchSize {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func (r *Runner) units(job *model.BulkJob, sel Selector, after string) ([]string, error) {
+	var units []string
+	if sel.unitsOnly() {
+		seen := make(map[string]bool, len(sel.UniqueIds))
+		for _, uid := range sel.UniqueIds {
+			if uid > after && !seen[uid] {
+				seen[uid] = true
+				units = append(units, uid)
+			}
+		}
+		sort.Strings(units)
+		if len(units) > r.batchSize {
+			units = units[:r.batchSize]
+		}
+		return units, nil
+	}
+	err := filter(r.db.Model(&model.Certificates{}), job.Kind, sel).
+		Where("common_name > ?", after).
+		Distinct().Order("common_name").Limit(r.batchSize).
+		Pluck("common_name", &units).Error
+	return units, err
+}
+
+// batch Apply f and advance the cursor in one transaction, so a resumed job continues after the last committed batch
+func (r *Runner) batch(job *model.BulkJob, cursorSN, cursorAKI string, n int, f func(tx *gorm.DB) error) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var cur model.BulkJob
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", job.ID).First(&cur).Error; err != nil {
+			return err
+		}
+		if cur.Owner != r.owner || cur.CancelRequested {
+			return errStop
+		}
+		if err := f(tx); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&model.BulkJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"cursor_sn":        cursorSN,
+			"cursor_aki":       cursorAKI,
+			"processed":        gorm.Expr("processed + ?", n),
+			"lease_expires_at": now.Add(r.lease),
+			"updated_at":       now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	job.CursorSN, job.CursorAKI = cursorSN, cursorAKI
+	job.Processed += int64(n)
+	return nil
+}
+
+func (r *Runner) cancelled(id uint64) bool {
+	var job model.BulkJob
+	if err := r.db.Where("id = ?", id).First(&job).Error; err != nil {
+		return false
+	}
+	return job.CancelRequested && job.Owner == r.owner
+}
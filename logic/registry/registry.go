@@ -0,0 +1,324 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry Subordinate CAs report to their upper CA with heartbeats signed with the CA key.
+// The upper CA keeps the latest heartbeat of each subordinate and flags those that stopped reporting.
+package registry
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/pkg/errors"
+	"github.com/ztalab/cfssl/helpers"
+	"github.com/ztalab/cfssl/hook"
+	"github.com/ztalab/zaca-sdk/caclient"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+)
+
+// API of the upper CA called by its subordinates on the TLS port, requests are signed with the key of the subordinate
+const (
+	PathHeartbeat            = "/api/v1/registry/heartbeat"
+	PathIntermediateTopology = "/api/v1/registry/intermediate_topology"
+)
+
+// HeaderSerial Serial number of the intermediate certificate whose key signed the request
+const HeaderSerial = "X-Zaca-Ca-Serial"
+
+// OperatorSubordinate Operator of the heartbeats
+const OperatorSubordinate = "Subordinate CA"
+
+// Heartbeat State of a subordinate CA
+type Heartbeat struct {
+	SiteID       string `json:"site_id"`
+	ClusterID    string `json:"cluster_id"`
+	Version      string `json:"version"`
+	Hostname     string `json:"hostname"`
+	SerialNumber string `json:"serial_number"` // Certificate the subordinate signs with
+	IssuedTotal  int64  `json:"issued_total"`
+	IssuedHour   int64  `json:"issued_hour"`
+	IssuedDay    int64  `json:"issued_day"`
+	RevokedTotal int64  `json:"revoked_total"`
+}
+
+// Logic ...
+type Logic struct {
+	db     *gorm.DB
+	logger *zap.SugaredLogger
+}
+
+// NewLogic ...
+func NewLogic() *Logic {
+	return &Logic{
+		db:     core.Is.Db,
+		logger: logger.Named("registry").SugaredLogger,
+	}
+}
+
+var (
+	verifierOnce sync.Once
+	verifier     *reqauth.Verifier
+)
+
+// Authenticate Intermediate certificate issued here whose key signed the request, it must be valid and not revoked
+func (l *Logic) Authenticate(r *http.Request, body []byte) (*x509.Certificate, error) {
+	authReq, ok, err := reqauth.FromHTTP(r, body)
+	if !ok {
+		return nil, errors.New("request is not signed")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sn := r.Header.Get(HeaderSerial)
+	if sn == "" {
+		return nil, errors.New("missing " + HeaderSerial + " header")
+	}
+	row := &model.Certificates{}
+	err = l.db.WithContext(r.Context()).
+		Where("serial_number = ? AND ca_label = ?", sn, caclient.RoleIntermediate).
+		Select("serial_number", "status", "expiry", "pem").
+		First(row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("not a subordinate CA of this CA")
+		}
+		return nil, errors.Wrap(err, "Database query error")
+	}
+	if row.Status != "good" {
+		return nil, errors.New("intermediate certificate is " + row.Status)
+	}
+	if hook.EnableVaultStorage && row.Pem == "" {
+		pem, err := core.Is.VaultSecret.GetCertPEM(row.SerialNumber)
+		if err != nil {
+			return nil, errors.Wrap(err, "Vault Get error")
+		}
+		row.Pem = *pem
+	}
+	cert, err := helpers.ParseCertificatePEM([]byte(row.Pem))
+	if err != nil {
+		return nil, errors.Wrap(err, "CA Certificate parsing error")
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, errors.New("intermediate certificate has expired")
+	}
+	verifierOnce.Do(func() {
+		verifier = core.RequestVerifier()
+	})
+	if err := verifier.VerifySignature(authReq, cert.PublicKey); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// Record Store the heartbeat of the subordinate signing with cert. Identity and site/cluster are taken from the certificate,
+// a heartbeat of a stale subordinate clears the flag
+func (l *Logic) Record(ctx context.Context, cert *x509.Certificate, hb *Heartbeat, origin events.Origin) (*model.SubordinateNode, error) {
+	if len(cert.Subject.OrganizationalUnit) == 0 {
+		return nil, errors.New("intermediate certificate without OU")
+	}
+	name := cert.Subject.OrganizationalUnit[0]
+	if hb.SerialNumber != "" && hb.SerialNumber != cert.SerialNumber.String() {
+		return nil, errors.New("heartbeat reports another certificate than the one that signed it")
+	}
+	siteID, clusterID := hb.SiteID, hb.ClusterID
+	if id, err := spiffe.ParseIDGIdentity(name); err == nil {
+		siteID, clusterID = id.SiteID, id.ClusterID
+	}
+
+	previous, err := dao.GetSubordinateNode(l.db.WithContext(ctx).Where("name = ?", name))
+	if err != nil {
+		return nil, errors.Wrap(err, "Database query error")
+	}
+	now := time.Now()
+	row := &model.SubordinateNode{
+		Name:                   name,
+		SiteID:                 siteID,
+		ClusterID:              clusterID,
+		Version:                hb.Version,
+		Hostname:               hb.Hostname,
+		SerialNumber:           cert.SerialNumber.String(),
+		AuthorityKeyIdentifier: hex.EncodeToString(cert.AuthorityKeyId),
+		NotAfter:               null.TimeFrom(cert.NotAfter),
+		IssuedTotal:            hb.IssuedTotal,
+		IssuedHour:             hb.IssuedHour,
+		IssuedDay:              hb.IssuedDay,
+		RevokedTotal:           hb.RevokedTotal,
+		SourceIP:               origin.SourceIP,
+		FirstSeenAt:            now,
+		LastSeenAt:             now,
+		UpdatedAt:              now,
+	}
+	// Replicas of the subordinate report concurrently, first_seen_at is kept on conflict
+	err = l.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"site_id", "cluster_id", "version", "hostname", "serial_number", "authority_key_identifier", "not_after",
+			"issued_total", "issued_hour", "issued_day", "revoked_total", "source_ip", "stale", "last_seen_at", "updated_at",
+		}),
+	}).Create(row).Error
+	if err != nil {
+		l.logger.Errorf("Subordinate heartbeat storage error: %s", err)
+		return nil, err
+	}
+	if previous != nil {
+		row.ID, row.FirstSeenAt = previous.ID, previous.FirstSeenAt
+	}
+
+	switch {
+	case previous == nil:
+		l.logger.With("name", name, "version", hb.Version).Info("Subordinate CA registered")
+		events.NewSubordinate("subordinate-register", OperatorSubordinate, subordinateOp(row)).WithOrigin(origin).Log()
+	case previous.Stale:
+		l.logger.With("name", name).Info("Subordinate CA reports again")
+		events.NewSubordinate("subordinate-recover", OperatorSubordinate, subordinateOp(row)).WithOrigin(origin).Log()
+	}
+	return row, nil
+}
+
+// ListParams ...
+type ListParams struct {
+	Page, PageSize int
+	Name           string
+	Stale          *bool
+}
+
+// List Registered subordinates by name, the stale flag reflects the current time
+func (l *Logic) List(params *ListParams) ([]*model.SubordinateNode, int64, error) {
+	db := l.db.Session(&gorm.Session{})
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", "%"+params.Name+"%")
+	}
+	cutoff := time.Now().Add(-staleAfter())
+	if params.Stale != nil {
+		if *params.Stale {
+			db = db.Where("(stale = ? OR last_seen_at < ?)", true, cutoff)
+		} else {
+			db = db.Where("stale = ? AND last_seen_at >= ?", false, cutoff)
+		}
+	}
+	list, total, err := dao.GetAllSubordinateNode(db, params.Page, params.PageSize, "name")
+	if err != nil {
+		l.logger.Errorf("Database query error: %s", err)
+		return nil, 0, err
+	}
+	for _, row := range list {
+		row.Stale = row.Stale || row.LastSeenAt.Before(cutoff)
+	}
+	return list, total, nil
+}
+
+// Delete Forget a decommissioned subordinate, it is registered again by its next heartbeat
+func (l *Logic) Delete(name string, origin events.Origin) error {
+	row, err := dao.GetSubordinateNode(l.db.Where("name = ?", name))
+	if err != nil {
+		return errors.Wrap(err, "Database query error")
+	}
+	if row == nil {
+		return errors.New("subordinate not registered")
+	}
+	if err := l.db.Delete(row).Error; err != nil {
+		return errors.Wrap(err, "Database delete error")
+	}
+	events.NewSubordinate("subordinate-delete", events.OperatorMSP, subordinateOp(row)).WithOrigin(origin).Log()
+	return nil
+}
+
+// Run Flag the subordinates without heartbeat every interval, on the upper CA
+func (l *Logic) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(core.Is.Config.Registry.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := l.MarkStale(ctx); err != nil {
+			l.logger.Errorf("Stale subordinate check error: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MarkStale Flag the subordinates silent for longer than the stale period, each one is reported once
+func (l *Logic) MarkStale(ctx context.Context) error {
+	db := l.db.WithContext(ctx)
+	var rows []*model.SubordinateNode
+	err := db.Where("stale = ? AND last_seen_at < ?", false, time.Now().Add(-staleAfter())).Find(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := core.Fence(ctx); err != nil {
+			return err
+		}
+		// A leader that lost its lease may have flagged it already
+		res := db.Model(&model.SubordinateNode{}).Where("id = ? AND stale = ?", row.ID, false).Update("stale", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		l.logger.With("name", row.Name, "last_seen_at", row.LastSeenAt).Warn("Subordinate CA stopped reporting")
+		events.NewSubordinate("subordinate-stale", events.OperatorSystem, subordinateOp(row)).Log()
+	}
+
+	var counts []struct {
+		Stale bool
+		N     int64
+	}
+	if err := db.Model(&model.SubordinateNode{}).Select("stale, COUNT(*) AS n").Group("stale").Scan(&counts).Error; err != nil {
+		return err
+	}
+	metrics.Subordinates.WithLabelValues("alive").Set(0)
+	metrics.Subordinates.WithLabelValues("stale").Set(0)
+	for _, c := range counts {
+		state := "alive"
+		if c.Stale {
+			state = "stale"
+		}
+		metrics.Subordinates.WithLabelValues(state).Set(float64(c.N))
+	}
+	return nil
+}
+
+func staleAfter() time.Duration {
+	return time.Duration(core.Is.Config.Registry.StaleAfter) * time.Second
+}
+
+func subordinateOp(row *model.SubordinateNode) events.SubordinateOp {
+	return events.SubordinateOp{
+		Name:         row.Name,
+		SiteID:       row.SiteID,
+		ClusterID:    row.ClusterID,
+		Version:      row.Version,
+		SerialNumber: row.SerialNumber,
+		LastSeenAt:   row.LastSeenAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/ca/keymanager"
+	"github.com/ztalab/ZACA/ca/upperca"
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/core/config"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/reqauth"
+	"github.com/ztalab/ZACA/pkg/spiffe"
+	"github.com/ztalab/ZACA/pkg/tracing"
+)
+
+// NewRequest Request to the upper CA signed with the key of this CA, identified by the serial number of its certificate
+func NewRequest(ctx context.Context, method, addr string, body []byte) (*http.Request, error) {
+	priv, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, addr, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSerial, self.SerialNumber.String())
+	if err := reqauth.SignWithKey(req, body, priv); err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, req.Header)
+	return req, nil
+}
+
+// Do Send a signed request to the upper CAs, failing over between them. out receives the result of the response
+func Do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	client := keymanager.GetKeeper().RootClient.HTTPClient()
+	return upperca.ProxyRequest(ctx, func(host string) error {
+		req, err := NewRequest(ctx, method, host+path, body)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		var response struct {
+			Result jsoniter.RawMessage `json:"result"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		_ = jsoniter.Unmarshal(data, &response)
+		if resp.StatusCode != http.StatusOK {
+			msg := http.StatusText(resp.StatusCode)
+			if len(response.Errors) > 0 {
+				msg = response.Errors[0].Message
+			}
+			return errors.Errorf("upper CA responded %d: %s", resp.StatusCode, msg)
+		}
+		if out != nil {
+			return jsoniter.Unmarshal(response.Result, out)
+		}
+		return nil
+	})
+}
+
+// Reporter Sends the heartbeat of this CA to the upper CA every interval
+type Reporter struct {
+	db       *gorm.DB
+	logger   *zap.SugaredLogger
+	interval time.Duration
+}
+
+// NewReporter ...
+func NewReporter(conf config.SubordinateRegistry) *Reporter {
+	return &Reporter{
+		db:       core.Is.Db,
+		logger:   logger.Named("registry").SugaredLogger,
+		interval: time.Duration(conf.Interval) * time.Second,
+	}
+}
+
+// Run Report until ctx is done, failures are retried at the next interval. A singleton job of the tls service
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Report(ctx); err != nil {
+			r.logger.Warnf("Heartbeat to the upper CA failed: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report Send one heartbeat
+func (r *Reporter) Report(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+	hb, err := r.Heartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := jsoniter.Marshal(hb)
+	if err != nil {
+		return err
+	}
+	return Do(ctx, http.MethodPost, PathHeartbeat, body, nil)
+}
+
+// Heartbeat Current state of this CA
+func (r *Reporter) Heartbeat(ctx context.Context) (*Heartbeat, error) {
+	_, self, err := keymanager.GetKeeper().GetCachedSelfKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	hb := &Heartbeat{
+		Version:      core.Is.Config.Version,
+		Hostname:     core.Is.Config.Hostname,
+		SerialNumber: self.SerialNumber.String(),
+	}
+	if hb.Hostname == "" {
+		hb.Hostname, _ = os.Hostname()
+	}
+	if id, err := spiffe.ParseIDGIdentity(core.Is.Config.Keymanager.CsrTemplates.IntermediateCa.Ou); err == nil {
+		hb.SiteID, hb.ClusterID = id.SiteID, id.ClusterID
+	}
+
+	now := time.Now()
+	db := r.db.WithContext(ctx).Model(&model.Certificates{})
+	for _, c := range []struct {
+		n     *int64
+		query func(db *gorm.DB) *gorm.DB
+	}{
+		{&hb.IssuedTotal, func(db *gorm.DB) *gorm.DB { return db }},
+		{&hb.IssuedHour, func(db *gorm.DB) *gorm.DB { return db.Where("issued_at >= ?", now.Add(-time.Hour)) }},
+		{&hb.IssuedDay, func(db *gorm.DB) *gorm.DB { return db.Where("issued_at >= ?", now.Add(-24*time.Hour)) }},
+		{&hb.RevokedTotal, func(db *gorm.DB) *gorm.DB { return db.Where("status = ?", "revoked") }},
+	} {
+		if err := c.query(db.Session(&gorm.Session{})).Count(c.n).Error; err != nil {
+			return nil, errors.Wrap(err, "Database query error")
+		}
+	}
+	return hb, nil
+}
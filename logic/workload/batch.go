@@ -0,0 +1,106 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+)
+
+// BatchSize Certificates updated per transaction
+const BatchSize = 500
+
+// Cursor Primary key of the last certificate processed, the zero value starts at the first one
+type Cursor struct {
+	SN  string
+	AKI string
+}
+
+// ForEachCertBatch Call f with the certificates matching db after cursor in primary key order, batch by batch.
+// Rows updated by f may leave the filter of db, the keyset cursor keeps the iteration stable either way
+func ForEachCertBatch(db *gorm.DB, cursor Cursor, size int, f func(certs []*model.Certificates) error) error {
+	for {
+		q := db.Session(&gorm.Session{}).Model(&model.Certificates{}).
+			Select("serial_number", "authority_key_identifier", "common_name", "status", "expiry")
+		if cursor.SN != "" || cursor.AKI != "" {
+			q = q.Where("(serial_number > ? OR (serial_number = ? AND authority_key_identifier > ?))",
+				cursor.SN, cursor.SN, cursor.AKI)
+		}
+		var certs []*model.Certificates
+		if err := q.Order("serial_number, authority_key_identifier").Limit(size).Find(&certs).Error; err != nil {
+			return err
+		}
+		if len(certs) == 0 {
+			return nil
+		}
+		if err := f(certs); err != nil {
+			return err
+		}
+		last := certs[len(certs)-1]
+		cursor = Cursor{SN: last.SerialNumber, AKI: last.AuthorityKeyIdentifier}
+		if len(certs) < size {
+			return nil
+		}
+	}
+}
+
+// RevokeBatch Mark certificates as revoked within tx
+func RevokeBatch(tx *gorm.DB, certs []*model.Certificates, rev *Revocation) error {
+	now := time.Now()
+	for _, cert := range certs {
+		err := Revocable(tx.Model(&model.Certificates{}), rev.Reason).Where(&model.Certificates{
+			SerialNumber:           cert.SerialNumber,
+			AuthorityKeyIdentifier: cert.AuthorityKeyIdentifier,
+		}).Updates(map[string]interface{}{
+			"status":             "revoked",
+			"reason":             rev.Reason,
+			"revoked_at":         now,
+			"invalidity_date":    rev.InvalidityDate,
+			"hold_until":         rev.HoldUntil,
+			"revocation_comment": rev.Comment,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecoverBatch Release held certificates within tx, other revocations are final
+func RecoverBatch(tx *gorm.DB, certs []*model.Certificates) error {
+	for _, cert := range certs {
+		err := Held(tx.Model(&model.Certificates{})).Where(&model.Certificates{
+			SerialNumber:           cert.SerialNumber,
+			AuthorityKeyIdentifier: cert.AuthorityKeyIdentifier,
+		}).Updates(recoveredColumns()).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recoveredColumns() map[string]interface{} {
+	return map[string]interface{}{
+		"status":             "good",
+		"reason":             0,
+		"revoked_at":         nil,
+		"invalidity_date":    nil,
+		"hold_until":         nil,
+		"revocation_comment": nil,
+	}
+}
@@ -17,28 +17,47 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/ztalab/cfssl/ocsp"
 	"gorm.io/gorm"
 
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/dao"
 	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
 	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/logic/forbid"
+	"github.com/ztalab/ZACA/pkg/metrics"
+	"github.com/ztalab/ZACA/pkg/revocation"
 )
 
 type RevokeCertsParams struct {
 	SN       string `json:"sn"`
 	AKI      string `json:"aki"`
 	UniqueId string `json:"unique_id"`
+	// RFC 5280 reason, e.g. keyCompromise, superseded, certificateHold, default cACompromise
+	Reason         string        `json:"reason"`
+	InvalidityDate *time.Time    `json:"invalidity_date"`
+	HoldUntil      *time.Time    `json:"hold_until"` // Only with certificateHold, empty holds until recovered
+	Comment        string        `json:"comment"`
+	Origin         events.Origin `json:"-"`
 }
 
 // RevokeCerts Revocation of certificate
 // 	1. Revoke certificate through snaki
 //  2. Unified revocation of certificates through uniqueID
-func (l *Logic) RevokeCerts(params *RevokeCertsParams) error {
-	// 1. Certificate found by identity
-	db := l.db.Session(&gorm.Session{})
+//
+// Certificates on hold can be revoked again with a final reason
+func (l *Logic) RevokeCerts(params *RevokeCertsParams) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.RevokeRequests.WithLabelValues("api", metrics.Result(err)).Inc()
+		metrics.RevokeDuration.WithLabelValues("api").Observe(metrics.Since(start))
+	}()
+
+	rev, err := NewRevocation(params.Reason, revocation.CACompromise, params.InvalidityDate, params.HoldUntil, params.Comment)
+	if err != nil {
+		return err
+	}
 
-	db = db.Where("status = ?", "good").
+	// 1. Certificate found by identity
+	db := Revocable(l.db.Session(&gorm.Session{}), rev.Reason).
 		Where("expiry > ?", time.Now())
 
 	if params.UniqueId != "" {
@@ -49,63 +68,49 @@ func (l *Logic) RevokeCerts(params *RevokeCertsParams) error {
 		return errors.New("Parameter error")
 	}
 
-	certs, _, err := dao.GetAllCertificates(db, 1, 1000, "issued_at desc")
-	if err != nil {
-		l.logger.With("params", params).Errorf("Database query error: %s", err)
-		return errors.Wrap(err, "Database query error")
-	}
-
-	if len(certs) == 0 {
-		return errors.New("Certificate not found")
-	}
-
 	// 2. Batch revocation certificate
-	reason, _ := ocsp.ReasonStringToCode("cacompromise")
-	err = l.db.Transaction(func(tx *gorm.DB) error {
+	var found bool
+	err = ForEachCertBatch(db, Cursor{}, BatchSize, func(certs []*model.Certificates) error {
+		found = true
+		if err := l.db.Transaction(func(tx *gorm.DB) error {
+			return RevokeBatch(tx, certs, rev)
+		}); err != nil {
+			l.logger.Errorf("Batch revocation certificate error: %s", err)
+			return errors.Wrap(err, "Batch revocation certificate error")
+		}
+
+		// 3. Record operation log
 		for _, cert := range certs {
-			err := tx.Model(&model.Certificates{}).Where(&model.Certificates{
-				SerialNumber:           cert.SerialNumber,
-				AuthorityKeyIdentifier: cert.AuthorityKeyIdentifier,
-			}).Update("status", "revoked").
-				Update("reason", reason).
-				Update("revoked_at", time.Now()).Error
-			if err != nil {
-				return err
-			}
+			events.NewWorkloadLifeCycle("revoke", events.OperatorMSP, rev.CertOp(cert)).
+				WithOrigin(params.Origin).Log()
 		}
 		return nil
 	})
 	if err != nil {
-		l.logger.Errorf("Batch revocation certificate error: %s", err)
-		return errors.Wrap(err, "Batch revocation certificate error")
+		return err
 	}
-
-	// 3. Record operation log
-	for _, cert := range certs {
-		events.NewWorkloadLifeCycle("revoke", events.OperatorMSP, events.CertOp{
-			UniqueId: cert.CommonName.String,
-			SN:       cert.SerialNumber,
-			AKI:      cert.AuthorityKeyIdentifier,
-		}).Log()
+	if !found {
+		return errors.New("Certificate not found")
 	}
 
 	return nil
 }
 
 type RecoverCertsParams struct {
-	SN       string `json:"sn"`
-	AKI      string `json:"aki"`
-	UniqueId string `json:"unique_id"`
+	SN       string        `json:"sn"`
+	AKI      string        `json:"aki"`
+	UniqueId string        `json:"unique_id"`
+	Origin   events.Origin `json:"-"`
 }
 
 // RecoverCerts Restore certificate
 // 	1. Recover certificate through snaki
 //  2. Unified certificate recovery through uniqueID
+//
+// Only certificates on certificateHold can be recovered
 func (l *Logic) RecoverCerts(params *RecoverCertsParams) error {
 	// 1. Certificate found by identity
-	db := l.db.Session(&gorm.Session{})
-
-	db = db.Where("status = ?", "revoked").
+	db := Held(l.db.Session(&gorm.Session{})).
 		Where("expiry > ?", time.Now())
 
 	switch {
@@ -117,49 +122,39 @@ func (l *Logic) RecoverCerts(params *RecoverCertsParams) error {
 		return errors.New("Parameter error")
 	}
 
-	certs, _, err := dao.GetAllCertificates(db, 1, 1000, "issued_at desc")
-	if err != nil {
-		l.logger.With("params", params).Errorf("Database query error: %s", err)
-		return errors.Wrap(err, "Database query error")
-	}
-
-	if len(certs) == 0 {
-		return errors.New("Certificate not found")
-	}
-
 	// 2. Batch recovery certificate
-	err = l.db.Transaction(func(tx *gorm.DB) error {
+	var found bool
+	err := ForEachCertBatch(db, Cursor{}, BatchSize, func(certs []*model.Certificates) error {
+		found = true
+		if err := l.db.Transaction(func(tx *gorm.DB) error {
+			return RecoverBatch(tx, certs)
+		}); err != nil {
+			return err
+		}
+
+		// 3. Record operation log
 		for _, cert := range certs {
-			err := tx.Model(&model.Certificates{}).Where(&model.Certificates{
-				SerialNumber:           cert.SerialNumber,
-				AuthorityKeyIdentifier: cert.AuthorityKeyIdentifier,
-			}).Update("status", "good").
-				Update("reason", 0).
-				Update("revoked_at", nil).Error
-			if err != nil {
-				return err
-			}
+			events.NewWorkloadLifeCycle("recover", events.OperatorMSP, events.CertOp{
+				UniqueId: cert.CommonName.String,
+				SN:       cert.SerialNumber,
+				AKI:      cert.AuthorityKeyIdentifier,
+			}).WithOrigin(params.Origin).Log()
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
-
-	// 3. Record operation log
-	for _, cert := range certs {
-		events.NewWorkloadLifeCycle("recover", events.OperatorMSP, events.CertOp{
-			UniqueId: cert.CommonName.String,
-			SN:       cert.SerialNumber,
-			AKI:      cert.AuthorityKeyIdentifier,
-		}).Log()
+	if !found {
+		return errors.New("No certificate on hold found")
 	}
 
 	return nil
 }
 
 type ForbidNewCertsParams struct {
-	UniqueIds []string `json:"unique_ids"`
+	UniqueIds []string      `json:"unique_ids"`
+	Origin    events.Origin `json:"-"`
 }
 
 // ForbidNewCerts Prohibit a uniqueID from requesting a certificate
@@ -179,7 +174,7 @@ func (l *Logic) ForbidNewCerts(params *ForbidNewCertsParams) error {
 				return err
 			}
 		}
-		return nil
+		return forbid.Touch(tx)
 	})
 	if err != nil {
 		l.logger.Errorf("Database insert error: %s", err)
@@ -190,7 +185,7 @@ func (l *Logic) ForbidNewCerts(params *ForbidNewCertsParams) error {
 	for _, uid := range params.UniqueIds {
 		events.NewWorkloadLifeCycle("forbid", events.OperatorMSP, events.CertOp{
 			UniqueId: uid,
-		}).Log()
+		}).WithOrigin(params.Origin).Log()
 	}
 
 	return nil
@@ -209,7 +204,7 @@ func (l *Logic) RecoverForbidNewCerts(params *ForbidNewCertsParams) error {
 				return err
 			}
 		}
-		return nil
+		return forbid.Touch(tx)
 	})
 	if err != nil {
 		l.logger.Errorf("Database update error: %s", err)
@@ -220,7 +215,7 @@ func (l *Logic) RecoverForbidNewCerts(params *ForbidNewCertsParams) error {
 	for _, uid := range params.UniqueIds {
 		events.NewWorkloadLifeCycle("recover-forbid", events.OperatorMSP, events.CertOp{
 			UniqueId: uid,
-		}).Log()
+		}).WithOrigin(params.Origin).Log()
 	}
 
 	return nil
@@ -0,0 +1,157 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/ztalab/ZACA/core"
+	"github.com/ztalab/ZACA/database/mysql/cfssl-model/model"
+	"github.com/ztalab/ZACA/logic/events"
+	"github.com/ztalab/ZACA/pkg/revocation"
+)
+
+// HoldReleaseInterval How often expired certificate holds are released
+const HoldReleaseInterval = time.Minute
+
+// Revocation Reason and details recorded with a revocation
+type Revocation struct {
+	Reason         int
+	InvalidityDate *time.Time
+	// HoldUntil Release time of a certificateHold, nil holds until recovered
+	HoldUntil *time.Time
+	Comment   string
+}
+
+// NewRevocation Validate the revocation details of a request, an empty reason falls back to defaultReason
+func NewRevocation(reason string, defaultReason int, invalidityDate, holdUntil *time.Time, comment string) (*Revocation, error) {
+	code := defaultReason
+	if reason != "" {
+		var err error
+		if code, err = revocation.ParseReason(reason); err != nil {
+			return nil, err
+		}
+	}
+	if err := revocation.CheckRevokeReason(code); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if invalidityDate != nil && invalidityDate.After(now) {
+		return nil, errors.New("invalidity_date is in the future")
+	}
+	if holdUntil != nil {
+		if code != revocation.CertificateHold {
+			return nil, errors.New("hold_until requires reason certificateHold")
+		}
+		if !holdUntil.After(now) {
+			return nil, errors.New("hold_until is in the past")
+		}
+	}
+	if len(comment) > 255 {
+		return nil, errors.New("comment is longer than 255 characters")
+	}
+	return &Revocation{
+		Reason:         code,
+		InvalidityDate: invalidityDate,
+		HoldUntil:      holdUntil,
+		Comment:        comment,
+	}, nil
+}
+
+// CertOp Event object of a certificate revoked with r
+func (r *Revocation) CertOp(cert *model.Certificates) events.CertOp {
+	return events.CertOp{
+		UniqueId:       cert.CommonName.String,
+		SN:             cert.SerialNumber,
+		AKI:            cert.AuthorityKeyIdentifier,
+		Reason:         revocation.ReasonString(r.Reason),
+		InvalidityDate: r.InvalidityDate,
+		HoldUntil:      r.HoldUntil,
+		Comment:        r.Comment,
+	}
+}
+
+// Revocable Certificates that can be revoked with reason.
+// A certificate on hold can still be revoked for good, any other revocation is final
+func Revocable(db *gorm.DB, reason int) *gorm.DB {
+	if reason == revocation.CertificateHold {
+		return db.Where("status = ?", "good")
+	}
+	return db.Where("(status = ? OR (status = ? AND reason = ?))", "good", "revoked", revocation.CertificateHold)
+}
+
+// Held Certificates on certificateHold, the only revocation RFC 5280 allows to recover from
+func Held(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? AND reason = ?", "revoked", revocation.CertificateHold)
+}
+
+// HoldExpired Whether the hold of a certificate has been released by time, ahead of ReleaseExpiredHolds
+func HoldExpired(cert *model.Certificates, now time.Time) bool {
+	return cert.Status == "revoked" && cert.Reason.Int64 == revocation.CertificateHold &&
+		cert.HoldUntil.Valid && !cert.HoldUntil.Time.After(now)
+}
+
+// ReleaseHolds Recover certificates whose hold expired until ctx is done, a singleton job of the leader when an elector is configured
+func (l *Logic) ReleaseHolds(ctx context.Context) {
+	ticker := time.NewTicker(HoldReleaseInterval)
+	defer ticker.Stop()
+	for {
+		if err := core.Fence(ctx); err != nil {
+			l.logger.Warnf("Release of expired certificate holds skipped: %s", err)
+		} else if n, err := l.ReleaseExpiredHolds(); err != nil {
+			l.logger.Errorf("Release of expired certificate holds failed: %s", err)
+		} else if n > 0 {
+			l.logger.Infof("Released %d expired certificate holds", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReleaseExpiredHolds Recover held certificates past hold_until.
+// Concurrent releases are safe, a certificate is logged by the one whose update took effect
+func (l *Logic) ReleaseExpiredHolds() (int, error) {
+	now := time.Now()
+	db := Held(l.db.Session(&gorm.Session{})).Where("hold_until <= ?", now)
+	var released int
+	err := ForEachCertBatch(db, Cursor{}, BatchSize, func(certs []*model.Certificates) error {
+		for _, cert := range certs {
+			res := Held(l.db.Model(&model.Certificates{})).Where(&model.Certificates{
+				SerialNumber:           cert.SerialNumber,
+				AuthorityKeyIdentifier: cert.AuthorityKeyIdentifier,
+			}).Where("hold_until <= ?", now).Updates(recoveredColumns())
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			released++
+			events.NewWorkloadLifeCycle("hold-release", events.OperatorSystem, events.CertOp{
+				UniqueId: cert.CommonName.String,
+				SN:       cert.SerialNumber,
+				AKI:      cert.AuthorityKeyIdentifier,
+			}).Log()
+		}
+		return nil
+	})
+	return released, err
+}
@@ -17,9 +17,12 @@ import (
 	"context"
 	"github.com/urfave/cli"
 	"github.com/ztalab/ZACA/cmd"
+	"github.com/ztalab/ZACA/cmd/ceremony"
+	"github.com/ztalab/ZACA/cmd/ctl"
 	"github.com/ztalab/ZACA/initer"
 	"github.com/ztalab/ZACA/pkg/logger"
 	"os"
+	"time"
 )
 
 func main() {
@@ -33,6 +36,11 @@ func main() {
 		newApiCmd(ctx),
 		newTlsCmd(ctx),
 		newOcspCmd(ctx),
+		newAuditCmd(),
+		ctl.NewCommand(),
+		ceremony.NewCommand(),
+		newCaCmd(),
+		newDoctorCmd(),
 	}
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
@@ -41,18 +49,19 @@ func main() {
 			Value: "conf.yml",
 		},
 	}
-	app.Before = initer.Init
 	err := app.Run(os.Args)
 	if err != nil {
 		logger.Named("Init").Errorf(err.Error())
+		os.Exit(1)
 	}
 }
 
 // newApiCmd Running API services
 func newApiCmd(ctx context.Context) cli.Command {
 	return cli.Command{
-		Name:  "api",
-		Usage: "Running API service",
+		Name:   "api",
+		Usage:  "Running API service",
+		Before: initer.Init,
 		Action: func(c *cli.Context) error {
 			return cmd.RunHttp(ctx)
 		},
@@ -62,8 +71,9 @@ func newApiCmd(ctx context.Context) cli.Command {
 // newTlsCmd Running TLS service
 func newTlsCmd(ctx context.Context) cli.Command {
 	return cli.Command{
-		Name:  "tls",
-		Usage: "Running TLS service",
+		Name:   "tls",
+		Usage:  "Running TLS service",
+		Before: initer.Init,
 		Action: func(c *cli.Context) error {
 			return cmd.RunTls(ctx)
 		},
@@ -73,10 +83,109 @@ func newTlsCmd(ctx context.Context) cli.Command {
 // newOcspCmd Running OCSP service
 func newOcspCmd(ctx context.Context) cli.Command {
 	return cli.Command{
-		Name:  "ocsp",
-		Usage: "Run OCSP service",
+		Name:   "ocsp",
+		Usage:  "Run OCSP service",
+		Before: initer.Init,
 		Action: func(c *cli.Context) error {
 			return cmd.RunOcsp(ctx)
 		},
 	}
 }
+
+// newAuditCmd Audit log maintenance
+func newAuditCmd() cli.Command {
+	return cli.Command{
+		Name:   "audit",
+		Usage:  "Audit log commands",
+		Before: initer.Init,
+		Subcommands: []cli.Command{
+			{
+				Name:  "verify",
+				Usage: "Verify the hash chain of the audit log",
+				Flags: []cli.Flag{
+					&cli.Uint64Flag{
+						Name:  "head-seq",
+						Usage: "Seq of a head recorded earlier, detects truncation",
+					},
+					&cli.StringFlag{
+						Name:  "head-hash",
+						Usage: "Hash of the recorded head",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.RunAuditVerify(c.Uint64("head-seq"), c.String("head-hash"))
+				},
+			},
+		},
+	}
+}
+
+// newCaCmd Certificate of this CA signed by an offline root or enrolled manually
+func newCaCmd() cli.Command {
+	return cli.Command{
+		Name:   "ca",
+		Usage:  "Certificate of this CA, for an offline root or manual enrollment",
+		Before: initer.Init,
+		Subcommands: []cli.Command{
+			{
+				Name:  "csr",
+				Usage: "Write a new key and a certificate request for the root ceremony",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "key-out", Value: "ca-key.pem"},
+					cli.StringFlag{Name: "csr-out", Value: "ca.csr"},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.RunCaCSR(c.String("key-out"), c.String("csr-out"))
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "Install the certificate signed by the offline root",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "key", Usage: "Key written by zaca ca csr"},
+					cli.StringFlag{Name: "cert", Usage: "Certificate or chain file of zaca root sign-csr"},
+					cli.StringFlag{Name: "trust", Usage: "Root certificate"},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.RunCaImport(c.String("key"), c.String("cert"), c.String("trust"))
+				},
+			},
+			{
+				Name:  "enroll-csr",
+				Usage: "Write the request of the pending manual enrollment",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "out", Usage: "Request file, default standard output"},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.RunCaEnrollCSR(c.String("out"))
+				},
+			},
+			{
+				Name:  "enroll",
+				Usage: "Import the signed certificate of the pending manual enrollment",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "cert", Usage: "Certificate, may be followed by the chain"},
+					cli.StringFlag{Name: "trust", Usage: "Root certificate, default the root at the end of the chain"},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.RunCaEnroll(c.String("cert"), c.String("trust"))
+				},
+			},
+		},
+	}
+}
+
+// newDoctorCmd Diagnostics of the deployment, exits non-zero when a check fails
+func newDoctorCmd() cli.Command {
+	return cli.Command{
+		Name:  "doctor",
+		Usage: "Check the database, vault, CA key pair and chain, upper CA and clock, with remediation hints",
+		Flags: []cli.Flag{
+			cli.BoolFlag{Name: "json", Usage: "JSON report"},
+			cli.DurationFlag{Name: "timeout", Usage: "Per check", Value: 10 * time.Second},
+		},
+		Action: func(c *cli.Context) error {
+			return cmd.RunDoctor(initer.InitProbe(c), c.Bool("json"), c.Duration("timeout"))
+		},
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auditchain Hash chain linking audit entries.
+// Each entry hashes its sequence number, the hash of the previous entry and its own fields,
+// so removing, reordering or editing an entry breaks every following link.
+package auditchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Genesis Previous hash of the first entry
+const Genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Hash hex SHA-256 over the sequence, the previous hash and the length prefixed fields
+func Hash(seq uint64, prevHash string, fields ...string) string {
+	h := sha256.New()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], seq)
+	h.Write(buf[:])
+	h.Write([]byte(prevHash))
+	for _, f := range fields {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(f)))
+		h.Write(buf[:])
+		h.Write([]byte(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Link One stored entry
+type Link struct {
+	Seq      uint64
+	PrevHash string
+	Hash     string
+	Fields   []string
+}
+
+// Error Position and kind of a broken link
+type Error struct {
+	Seq    uint64
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("audit chain broken at seq %d: %s", e.Seq, e.Reason)
+}
+
+// Checker Verifies links in sequence order
+type Checker struct {
+	seq  uint64
+	prev string
+	n    int
+}
+
+// NewChecker Start after the given sequence and hash, (0, Genesis) for a full verification
+func NewChecker(seq uint64, prevHash string) *Checker {
+	return &Checker{seq: seq, prev: prevHash}
+}
+
+// Check Detects missing entries, broken links and edited entries
+func (c *Checker) Check(l Link) error {
+	if l.Seq != c.seq+1 {
+		return &Error{Seq: c.seq + 1, Reason: fmt.Sprintf("gap, next entry has seq %d", l.Seq)}
+	}
+	if l.PrevHash != c.prev {
+		return &Error{Seq: l.Seq, Reason: "previous hash does not match"}
+	}
+	if Hash(l.Seq, l.PrevHash, l.Fields...) != l.Hash {
+		return &Error{Seq: l.Seq, Reason: "entry content does not match its hash"}
+	}
+	c.seq, c.prev = l.Seq, l.Hash
+	c.n++
+	return nil
+}
+
+// Head Sequence and hash of the last verified entry
+func (c *Checker) Head() (uint64, string) {
+	return c.seq, c.prev
+}
+
+// Count Number of verified entries
+func (c *Checker) Count() int {
+	return c.n
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auditchain
+
+import (
+	"strings"
+	"testing"
+)
+
+func buildChain(n int) []Link {
+	links := make([]Link, 0, n)
+	prev := Genesis
+	for i := 1; i <= n; i++ {
+		fields := []string{"revoke", "token:ops", strings.Repeat("x", i)}
+		l := Link{Seq: uint64(i), PrevHash: prev, Fields: fields}
+		l.Hash = Hash(l.Seq, l.PrevHash, l.Fields...)
+		prev = l.Hash
+		links = append(links, l)
+	}
+	return links
+}
+
+func checkAll(links []Link) error {
+	c := NewChecker(0, Genesis)
+	for _, l := range links {
+		if err := c.Check(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestChecker(t *testing.T) {
+	links := buildChain(5)
+	if err := checkAll(links); err != nil {
+		t.Fatal(err)
+	}
+
+	gap := append(append([]Link{}, links[:2]...), links[3:]...)
+	if err := checkAll(gap); err == nil || err.(*Error).Seq != 3 {
+		t.Errorf("expected gap at seq 3, got %v", err)
+	}
+
+	edited := append([]Link{}, links...)
+	edited[1].Fields = []string{"revoke", "token:someone-else", "xx"}
+	if err := checkAll(edited); err == nil || err.(*Error).Seq != 2 {
+		t.Errorf("expected tampering at seq 2, got %v", err)
+	}
+
+	rehashed := append([]Link{}, links...)
+	rehashed[1].Fields = []string{"recover"}
+	rehashed[1].Hash = Hash(2, rehashed[1].PrevHash, rehashed[1].Fields...)
+	if err := checkAll(rehashed); err == nil || err.(*Error).Seq != 3 {
+		t.Errorf("expected broken link at seq 3, got %v", err)
+	}
+
+	if Hash(1, Genesis, "ab", "c") == Hash(1, Genesis, "a", "bc") {
+		t.Error("field boundaries should change the hash")
+	}
+}
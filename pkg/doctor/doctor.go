@@ -0,0 +1,130 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package doctor Diagnostic checks of a deployment and their pass/warn/fail report
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Status Outcome of a check
+type Status string
+
+// Statuses, a failure makes the report fail
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip" // Not applicable to the configuration, or a check it depends on failed
+)
+
+// Result ...
+type Result struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Message  string        `json:"message"`
+	Hint     string        `json:"hint,omitempty"` // Remediation of a warning or failure
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Pass ...
+func Pass(format string, args ...interface{}) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warn ...
+func Warn(hint, format string, args ...interface{}) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Fail ...
+func Fail(hint, format string, args ...interface{}) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Skip ...
+func Skip(format string, args ...interface{}) Result {
+	return Result{Status: StatusSkip, Message: fmt.Sprintf(format, args...)}
+}
+
+// Check One diagnostic
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// Report Results in the order of the checks
+type Report struct {
+	Results []Result       `json:"results"`
+	Summary map[Status]int `json:"summary"`
+	OK      bool           `json:"ok"` // No check failed
+}
+
+// Run Run the checks one after the other, each within timeout. Later checks may rely on the state left by earlier ones
+func Run(ctx context.Context, timeout time.Duration, checks []Check) *Report {
+	report := &Report{
+		Summary: map[Status]int{StatusPass: 0, StatusWarn: 0, StatusFail: 0, StatusSkip: 0},
+		OK:      true,
+	}
+	for _, check := range checks {
+		start := time.Now()
+		res := run(ctx, timeout, check)
+		res.Name, res.Duration = check.Name, time.Since(start)
+		report.Results = append(report.Results, res)
+		report.Summary[res.Status]++
+		if res.Status == StatusFail {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, timeout time.Duration, check Check) (res Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan Result, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- Fail("", "check panicked: %v", err)
+			}
+		}()
+		done <- check.Run(ctx)
+	}()
+	select {
+	case res = <-done:
+		return res
+	case <-ctx.Done():
+		return Fail("", "no answer within %s", timeout)
+	}
+}
+
+// WriteText Aligned report for terminals, hints below their check
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(string(res.Status)), res.Name, res.Message)
+		if res.Hint != "" && (res.Status == StatusWarn || res.Status == StatusFail) {
+			fmt.Fprintf(tw, "\t\thint: %s\n", res.Hint)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		r.Summary[StatusPass], r.Summary[StatusWarn], r.Summary[StatusFail], r.Summary[StatusSkip])
+	return tw.Flush()
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	report := Run(context.Background(), 50*time.Millisecond, []Check{
+		{Name: "ok", Run: func(context.Context) Result { return Pass("fine") }},
+		{Name: "slow", Run: func(ctx context.Context) Result {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			return Pass("late")
+		}},
+		{Name: "panic", Run: func(context.Context) Result { panic("boom") }},
+		{Name: "skew", Run: func(context.Context) Result { return Warn("sync the clock", "%ds", 3) }},
+	})
+	if report.OK {
+		t.Fatal("expected a failed report")
+	}
+	want := []Status{StatusPass, StatusFail, StatusFail, StatusWarn}
+	for i, res := range report.Results {
+		if res.Status != want[i] {
+			t.Errorf("%s: expected %s, got %s (%s)", res.Name, want[i], res.Status, res.Message)
+		}
+	}
+	if report.Summary[StatusFail] != 2 || report.Summary[StatusPass] != 1 || report.Summary[StatusWarn] != 1 {
+		t.Errorf("unexpected summary %v", report.Summary)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"PASS", "ok", "hint: sync the clock", "1 passed, 1 warnings, 2 failed, 0 skipped"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("missing %q in\n%s", s, out)
+		}
+	}
+}
+
+func TestRunOK(t *testing.T) {
+	report := Run(context.Background(), time.Second, []Check{
+		{Name: "vault", Run: func(context.Context) Result { return Skip("disabled") }},
+		{Name: "skew", Run: func(context.Context) Result { return Warn("", "1s") }},
+	})
+	if !report.OK {
+		t.Fatal("warnings and skips must not fail the report")
+	}
+}
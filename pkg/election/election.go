@@ -0,0 +1,249 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package election Leader election between the replicas of a service on a lease,
+// so that singleton jobs run on one replica only
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ztalab/ZACA/pkg/metrics"
+)
+
+// ErrNotLeader The lease is held by another replica, or was lost
+var ErrNotLeader = errors.New("not the leader")
+
+// Store Leases shared by the replicas
+type Store interface {
+	// Acquire Take or renew the lease for ttl. The token grows each time the lease changes holder
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (token int64, ok bool, err error)
+	// Release Expire the lease now if holder has it
+	Release(ctx context.Context, name, holder string) error
+	// Check Whether holder still has the unexpired lease with token
+	Check(ctx context.Context, name, holder string, token int64) (bool, error)
+}
+
+// Config ...
+type Config struct {
+	Name          string        // Lease name, one per service
+	Holder        string        // Unique per replica
+	TTL           time.Duration // Lease duration, a dead leader is replaced within TTL + RenewInterval
+	RenewInterval time.Duration // Must be well below TTL, a lost lease is noticed by the jobs within RenewInterval
+}
+
+// Elector Campaigns for a lease until its context is done
+type Elector struct {
+	store  Store
+	conf   Config
+	logger *zap.SugaredLogger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	leader    bool
+	token     int64
+	deadline  time.Time
+	callbacks []func(leader bool, token int64)
+}
+
+type job struct {
+	name   string
+	parent context.Context
+	fn     func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New ...
+func New(store Store, conf Config, logger *zap.SugaredLogger) *Elector {
+	if conf.RenewInterval <= 0 || conf.RenewInterval >= conf.TTL {
+		conf.RenewInterval = conf.TTL / 3
+	}
+	metrics.Leader.WithLabelValues(conf.Name).Set(0)
+	return &Elector{
+		store:  store,
+		conf:   conf,
+		logger: logger.With("lease", conf.Name, "holder", conf.Holder),
+		now:    time.Now,
+	}
+}
+
+// Run Renew every RenewInterval until ctx is done, then release the lease so that another replica takes over at once
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.conf.RenewInterval)
+	defer ticker.Stop()
+	for {
+		e.renew(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew One round of the campaign
+func (e *Elector) renew(ctx context.Context) {
+	// The lease expires TTL after the store applied it, counting from before the call keeps the local deadline earlier
+	start := e.now()
+	ctx, cancel := context.WithTimeout(ctx, e.conf.RenewInterval)
+	defer cancel()
+	token, ok, err := e.store.Acquire(ctx, e.conf.Name, e.conf.Holder, e.conf.TTL)
+	if err != nil {
+		e.logger.Warnf("Lease renewal error: %s", err)
+		e.mu.Lock()
+		expired := !e.now().Before(e.deadline)
+		e.mu.Unlock()
+		if expired {
+			e.set(false, 0, time.Time{})
+		}
+		return
+	}
+	if !ok {
+		e.set(false, token, time.Time{})
+		return
+	}
+	e.set(true, token, start.Add(e.conf.TTL))
+}
+
+func (e *Elector) resign() {
+	e.set(false, 0, time.Time{})
+	ctx, cancel := context.WithTimeout(context.Background(), e.conf.RenewInterval)
+	defer cancel()
+	if err := e.store.Release(ctx, e.conf.Name, e.conf.Holder); err != nil {
+		e.logger.Warnf("Lease release error: %s", err)
+	}
+}
+
+// set Record the state, callbacks run when the leadership or its token changed
+func (e *Elector) set(leader bool, token int64, deadline time.Time) {
+	e.mu.Lock()
+	changed := leader != e.leader || (leader && token != e.token)
+	e.leader, e.token, e.deadline = leader, token, deadline
+	callbacks := e.callbacks
+	e.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if leader {
+		e.logger.Infof("Leadership acquired, token %d", token)
+		metrics.Leader.WithLabelValues(e.conf.Name).Set(1)
+		metrics.LeaderChanges.WithLabelValues(e.conf.Name, "acquired").Inc()
+	} else {
+		e.logger.Info("Leadership lost")
+		metrics.Leader.WithLabelValues(e.conf.Name).Set(0)
+		metrics.LeaderChanges.WithLabelValues(e.conf.Name, "lost").Inc()
+	}
+	for _, fn := range callbacks {
+		fn(leader, token)
+	}
+}
+
+// IsLeader Whether this replica holds the lease, false as soon as the local deadline passed without renewal
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && e.now().Before(e.deadline)
+}
+
+// Token Fencing token of the current leadership, 0 when not the leader
+func (e *Elector) Token() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leader {
+		return 0
+	}
+	return e.token
+}
+
+// Fence Check against the store that the lease was not taken over, before a side effect a second leader must not repeat
+func (e *Elector) Fence(ctx context.Context) error {
+	e.mu.Lock()
+	leader, token := e.leader && e.now().Before(e.deadline), e.token
+	e.mu.Unlock()
+	if !leader {
+		return ErrNotLeader
+	}
+	ok, err := e.store.Check(ctx, e.conf.Name, e.conf.Holder, token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// OnChange Called with the new state each time the leadership is acquired, lost or taken again with another token
+func (e *Elector) OnChange(fn func(leader bool, token int64)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.callbacks = append(e.callbacks, fn)
+}
+
+// Go Register a singleton job: it runs while this replica leads, with a context cancelled when the leadership is lost or ctx is done.
+// A job started again waits for its previous run to return
+func (e *Elector) Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	j := &job{name: name, parent: ctx, fn: fn}
+	e.OnChange(func(leader bool, token int64) {
+		// A new token means another replica may have led in between, the job starts over
+		e.stop(j)
+		if leader {
+			e.start(j, token)
+		}
+	})
+	if e.IsLeader() {
+		e.start(j, e.Token())
+	}
+	go func() {
+		<-ctx.Done()
+		e.stop(j)
+	}()
+}
+
+func (e *Elector) start(j *job, token int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if j.cancel != nil || j.parent.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(j.parent)
+	prev, done := j.done, make(chan struct{})
+	j.cancel, j.done = cancel, done
+	e.logger.With("job", j.name).Infof("Singleton job started, token %d", token)
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		j.fn(ctx)
+	}()
+}
+
+func (e *Elector) stop(j *job) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if j.cancel == nil {
+		return
+	}
+	j.cancel()
+	j.cancel = nil
+	e.logger.With("job", j.name).Info("Singleton job stopped")
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type lease struct {
+	holder  string
+	token   int64
+	expires time.Time
+}
+
+// memStore Store with the semantics of MysqlStore
+type memStore struct {
+	mu     sync.Mutex
+	clock  *clock
+	leases map[string]*lease
+}
+
+func (s *memStore) Acquire(_ context.Context, name, holder string, ttl time.Duration) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	l, ok := s.leases[name]
+	switch {
+	case !ok:
+		s.leases[name] = &lease{holder: holder, token: 1, expires: now.Add(ttl)}
+		return 1, true, nil
+	case l.holder == holder && now.Before(l.expires):
+		l.expires = now.Add(ttl)
+		return l.token, true, nil
+	case !now.Before(l.expires):
+		l.holder, l.token, l.expires = holder, l.token+1, now.Add(ttl)
+		return l.token, true, nil
+	}
+	return l.token, false, nil
+}
+
+func (s *memStore) Release(_ context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[name]; ok && l.holder == holder {
+		l.expires = s.clock.Now()
+	}
+	return nil
+}
+
+func (s *memStore) Check(_ context.Context, name, holder string, token int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.leases[name]
+	return ok && l.holder == holder && l.token == token && s.clock.Now().Before(l.expires), nil
+}
+
+func newElectors(names ...string) (*clock, []*Elector) {
+	c := &clock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := &memStore{clock: c, leases: make(map[string]*lease)}
+	var electors []*Elector
+	for _, name := range names {
+		e := New(store, Config{Name: "test", Holder: name, TTL: 15 * time.Second, RenewInterval: 5 * time.Second}, zap.NewNop().Sugar())
+		e.now = c.Now
+		electors = append(electors, e)
+	}
+	return c, electors
+}
+
+func TestFailover(t *testing.T) {
+	c, electors := newElectors("a", "b")
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+
+	var changes []bool
+	a.OnChange(func(leader bool, _ int64) { changes = append(changes, leader) })
+
+	a.renew(ctx)
+	b.renew(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected a to lead alone, a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	if a.Token() != 1 || a.Fence(ctx) != nil {
+		t.Fatalf("unexpected token %d", a.Token())
+	}
+
+	// a stops renewing, b takes over once the lease expired
+	c.Add(10 * time.Second)
+	b.renew(ctx)
+	if b.IsLeader() {
+		t.Fatal("b took an unexpired lease")
+	}
+	c.Add(5 * time.Second)
+	if a.IsLeader() {
+		t.Fatal("a still leads past its deadline")
+	}
+	b.renew(ctx)
+	if !b.IsLeader() || b.Token() != 2 {
+		t.Fatalf("expected b to lead with token 2, got %v %d", b.IsLeader(), b.Token())
+	}
+	if err := a.Fence(ctx); err != ErrNotLeader {
+		t.Fatalf("expected ErrNotLeader, got %v", err)
+	}
+
+	a.renew(ctx)
+	if a.IsLeader() || a.Token() != 0 {
+		t.Fatal("a leads again")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+}
+
+func TestResign(t *testing.T) {
+	_, electors := newElectors("a", "b")
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+
+	a.renew(ctx)
+	a.resign()
+	if a.IsLeader() {
+		t.Fatal("a leads after resigning")
+	}
+	b.renew(ctx)
+	if !b.IsLeader() || b.Token() != 2 {
+		t.Fatalf("expected b to take over at once, got %v %d", b.IsLeader(), b.Token())
+	}
+}
+
+func TestGo(t *testing.T) {
+	c, electors := newElectors("a", "b")
+	a, b := electors[0], electors[1]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started, stopped := make(chan struct{}, 2), make(chan struct{}, 2)
+	a.Go(ctx, "job", func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- struct{}{}
+	})
+	select {
+	case <-started:
+		t.Fatal("job started before leading")
+	default:
+	}
+
+	a.renew(ctx)
+	wait(t, started, "start")
+	a.renew(ctx)
+
+	c.Add(15 * time.Second)
+	b.renew(ctx)
+	a.renew(ctx)
+	wait(t, stopped, "stop")
+	select {
+	case <-started:
+		t.Fatal("job started twice")
+	default:
+	}
+}
+
+func wait(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatalf("job did not %s", what)
+	}
+}
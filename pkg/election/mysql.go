@@ -0,0 +1,85 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MysqlStore Leases in the leader_lease table. Expiry uses the clock of the database, the replicas may drift.
+// A lease row outlives connections, unlike GET_LOCK which the connection pool would hand to other sessions
+type MysqlStore struct {
+	db *gorm.DB
+}
+
+// NewMysqlStore ...
+func NewMysqlStore(db *gorm.DB) *MysqlStore {
+	return &MysqlStore{db: db}
+}
+
+// Acquire ...
+func (s *MysqlStore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (token int64, ok bool, err error) {
+	us := ttl.Microseconds()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row struct {
+			Holder  string
+			Token   int64
+			Expired bool
+		}
+		res := tx.Raw("SELECT holder, token, expires_at <= NOW(6) AS expired FROM leader_lease WHERE name = ? FOR UPDATE", name).Scan(&row)
+		if res.Error != nil {
+			return res.Error
+		}
+		switch {
+		case res.RowsAffected == 0:
+			// Replicas starting together race on the insert, the others see the row next time
+			res := tx.Exec("INSERT IGNORE INTO leader_lease (name, holder, token, expires_at, updated_at) "+
+				"VALUES (?, ?, 1, NOW(6) + INTERVAL ? MICROSECOND, NOW(6))", name, holder, us)
+			token, ok = 1, res.RowsAffected == 1
+			return res.Error
+		case row.Holder == holder && !row.Expired:
+			token, ok = row.Token, true
+			return tx.Exec("UPDATE leader_lease SET expires_at = NOW(6) + INTERVAL ? MICROSECOND, updated_at = NOW(6) "+
+				"WHERE name = ?", us, name).Error
+		case row.Expired:
+			token, ok = row.Token+1, true
+			return tx.Exec("UPDATE leader_lease SET holder = ?, token = ?, expires_at = NOW(6) + INTERVAL ? MICROSECOND, updated_at = NOW(6) "+
+				"WHERE name = ?", holder, token, us, name).Error
+		default:
+			token, ok = row.Token, false
+			return nil
+		}
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return token, ok, nil
+}
+
+// Release ...
+func (s *MysqlStore) Release(ctx context.Context, name, holder string) error {
+	return s.db.WithContext(ctx).Exec("UPDATE leader_lease SET expires_at = NOW(6), updated_at = NOW(6) "+
+		"WHERE name = ? AND holder = ? AND expires_at > NOW(6)", name, holder).Error
+}
+
+// Check ...
+func (s *MysqlStore) Check(ctx context.Context, name, holder string, token int64) (bool, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM leader_lease WHERE name = ? AND holder = ? AND token = ? AND expires_at > NOW(6)",
+		name, holder, token).Scan(&n).Error
+	return n == 1, err
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package eventbus Fan-out of CA events to external sinks.
+// Every sink has its own queue and worker, a slow or failing sink does not delay the others
+// nor the request that produced the event.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event Operation published on the bus
+type Event struct {
+	ID        string      `json:"id"`
+	Time      time.Time   `json:"time"`
+	Category  string      `json:"category"`
+	Type      string      `json:"type"`
+	Operator  string      `json:"operator"`
+	SourceIP  string      `json:"source_ip,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Object    interface{} `json:"object"`
+}
+
+// Sink Destination of events, Send is only called from the worker of the sink
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, e *Event) error
+}
+
+// Filter Subscription of a sink, empty lists match everything
+type Filter struct {
+	Categories []string
+	Types      []string
+}
+
+// Match ...
+func (f Filter) Match(e *Event) bool {
+	return matchAny(f.Categories, e.Category) && matchAny(f.Types, e.Type)
+}
+
+func matchAny(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s == v || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorHandler Called when a sink fails to deliver an event or its queue is full
+type ErrorHandler func(sink string, e *Event, err error)
+
+type subscription struct {
+	sink   Sink
+	filter Filter
+	queue  chan *Event
+}
+
+// Bus ...
+type Bus struct {
+	subs    []*subscription
+	onError ErrorHandler
+	timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu Publish holds it for reading, Close for writing so that no send races with closing the queues
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New timeout bounds a single Send call, retries of a sink included
+func New(onError ErrorHandler, timeout time.Duration) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	if onError == nil {
+		onError = func(string, *Event, error) {}
+	}
+	return &Bus{
+		onError: onError,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Errors passed to the ErrorHandler with the dropped event
+var (
+	ErrQueueFull = errors.New("sink queue full, event dropped")
+	ErrClosed    = errors.New("bus closed, event dropped")
+)
+
+// Subscribe Start the worker of a sink, must be called before Publish
+func (b *Bus) Subscribe(sink Sink, filter Filter, queueSize int) {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	sub := &subscription{sink: sink, filter: filter, queue: make(chan *Event, queueSize)}
+	b.subs = append(b.subs, sub)
+	b.wg.Add(1)
+	go b.run(sub)
+}
+
+func (b *Bus) run(sub *subscription) {
+	defer b.wg.Done()
+	for e := range sub.queue {
+		ctx := b.ctx
+		var cancel context.CancelFunc = func() {}
+		if b.timeout > 0 {
+			ctx, cancel = context.WithTimeout(b.ctx, b.timeout)
+		}
+		if err := sub.sink.Send(ctx, e); err != nil {
+			b.onError(sub.sink.Name(), e, err)
+		}
+		cancel()
+	}
+}
+
+// Publish Queue the event for every matching sink without blocking
+func (b *Bus) Publish(e *Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		if b.closed {
+			b.onError(sub.sink.Name(), e, ErrClosed)
+			continue
+		}
+		select {
+		case sub.queue <- e:
+		default:
+			b.onError(sub.sink.Name(), e, ErrQueueFull)
+		}
+	}
+}
+
+// Close Deliver the queued events, then stop the workers.
+// Sends still running when ctx is done are cancelled, events published afterwards are dropped with ErrClosed.
+func (b *Bus) Close(ctx context.Context) {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, sub := range b.subs {
+			close(sub.queue)
+		}
+	}
+	b.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		b.cancel()
+		<-done
+	}
+	b.cancel()
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testEvent(typ string) *Event {
+	return &Event{
+		ID:       "1",
+		Time:     time.Unix(1650000000, 0),
+		Category: "workload_lifecycle",
+		Type:     typ,
+		Operator: "token:ops",
+		Object:   map[string]string{"unique_id": "a=b|c"},
+	}
+}
+
+type recordSink struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (r *recordSink) Name() string { return "record" }
+
+func (r *recordSink) Send(_ context.Context, e *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestBusFilter(t *testing.T) {
+	bus := New(nil, time.Second)
+	all, revokes := &recordSink{}, &recordSink{}
+	bus.Subscribe(all, Filter{}, 10)
+	bus.Subscribe(revokes, Filter{Categories: []string{"workload_lifecycle"}, Types: []string{"revoke"}}, 10)
+	bus.Publish(testEvent("sign"))
+	bus.Publish(testEvent("revoke"))
+	bus.Close(context.Background())
+
+	if len(all.events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(all.events))
+	}
+	if len(revokes.events) != 1 || revokes.events[0].Type != "revoke" {
+		t.Errorf("unexpected filtered events %v", revokes.events)
+	}
+}
+
+func TestBusPublishAfterClose(t *testing.T) {
+	var mu sync.Mutex
+	var dropped int
+	bus := New(func(_ string, _ *Event, err error) {
+		if err == ErrClosed {
+			mu.Lock()
+			dropped++
+			mu.Unlock()
+		}
+	}, time.Second)
+	bus.Subscribe(&recordSink{}, Filter{}, 1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish(testEvent("sign"))
+			}
+		}()
+	}
+	bus.Close(context.Background())
+	wg.Wait()
+	bus.Publish(testEvent("sign"))
+	bus.Close(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dropped == 0 {
+		t.Error("expected the events published after Close to be dropped")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if !VerifyWebhook([]byte("secret"), ts, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	wh := NewWebhook("hook", WebhookConfig{URL: srv.URL, Secret: "secret", MaxRetries: 2, Backoff: time.Millisecond})
+	if err := wh.Send(context.Background(), testEvent("revoke")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+
+	wrong := NewWebhook("hook", WebhookConfig{URL: srv.URL, Secret: "other", MaxRetries: 2, Backoff: time.Millisecond})
+	err := wrong.Send(context.Background(), testEvent("revoke"))
+	derr, ok := err.(*DeliveryError)
+	if !ok || derr.Attempts != 1 || derr.Status != http.StatusUnauthorized {
+		t.Errorf("client errors should not be retried, got %v", err)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := NewJSONLines("file", path)
+	for _, typ := range []string{"sign", "revoke"} {
+		if err := sink.Send(context.Background(), testEvent(typ)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var types []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, e.Type)
+	}
+	if strings.Join(types, ",") != "sign,revoke" {
+		t.Errorf("unexpected lines %v", types)
+	}
+}
+
+func TestSyslogCEF(t *testing.T) {
+	cef := FormatCEF(testEvent("revoke"), "1.0|x")
+	want := `CEF:0|Ztalab|ZACA|1.0\|x|workload_lifecycle.revoke|revoke|6|`
+	if !strings.HasPrefix(cef, want) {
+		t.Errorf("unexpected header %s", cef)
+	}
+	if !strings.Contains(cef, `msg={"unique_id":"a\=b|c"}`) || !strings.Contains(cef, "suser=token:ops") {
+		t.Errorf("unexpected extension %s", cef)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	sink := NewSyslog("siem", SyslogConfig{Network: "udp", Address: pc.LocalAddr().String(), Version: "1.0"})
+	if err := sink.Send(context.Background(), testEvent("revoke")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<133>") || !strings.Contains(msg, "zaca: CEF:0|") {
+		t.Errorf("unexpected syslog message %s", msg)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// JSONLines Appends one JSON document per line.
+// The file is opened for every event so that external rotation needs no signal.
+type JSONLines struct {
+	name string
+	path string
+}
+
+// NewJSONLines ...
+func NewJSONLines(name, path string) *JSONLines {
+	return &JSONLines{name: name, path: path}
+}
+
+// Name ...
+func (j *JSONLines) Name() string {
+	return j.name
+}
+
+// Send ...
+func (j *JSONLines) Send(_ context.Context, e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
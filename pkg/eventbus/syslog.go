@@ -0,0 +1,153 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CEF header fields
+const (
+	cefVendor  = "Ztalab"
+	cefProduct = "ZACA"
+)
+
+// facilityLocal0 Syslog facility of the messages
+const facilityLocal0 = 16
+
+// cefSeverities Severity (0-10) per event type, others are 3
+var cefSeverities = map[string]int{
+	"revoke":         6,
+	"self-revoke":    5,
+	"forbid":         6,
+	"forbid-sign":    6,
+	"keypair-change": 8,
+}
+
+// SyslogConfig Network is udp, tcp or unix
+type SyslogConfig struct {
+	Network string
+	Address string
+	Version string
+}
+
+// Syslog CEF messages in RFC 3164 framing, TCP messages are newline terminated
+type Syslog struct {
+	name     string
+	conf     SyslogConfig
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslog The connection is dialed on the first event
+func NewSyslog(name string, conf SyslogConfig) *Syslog {
+	hostname, _ := os.Hostname()
+	return &Syslog{name: name, conf: conf, hostname: hostname}
+}
+
+// Name ...
+func (s *Syslog) Name() string {
+	return s.name
+}
+
+// Send One reconnection is attempted when the write fails
+func (s *Syslog) Send(ctx context.Context, e *Event) error {
+	msg := s.message(e)
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			var d net.Dialer
+			if s.conn, err = d.DialContext(ctx, s.conf.Network, s.conf.Address); err != nil {
+				return err
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = s.conn.SetWriteDeadline(deadline)
+		}
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *Syslog) message(e *Event) []byte {
+	severity := 6 // informational
+	if cefSeverity(e.Type) >= 6 {
+		severity = 5 // notice
+	}
+	msg := fmt.Sprintf("<%d>%s %s zaca: %s", facilityLocal0*8+severity,
+		e.Time.Format(time.Stamp), s.hostname, FormatCEF(e, s.conf.Version))
+	if s.conf.Network == "tcp" {
+		msg += "\n"
+	}
+	return []byte(msg)
+}
+
+func cefSeverity(eventType string) int {
+	if sev, ok := cefSeverities[eventType]; ok {
+		return sev
+	}
+	return 3
+}
+
+// FormatCEF ArcSight Common Event Format
+func FormatCEF(e *Event, version string) string {
+	obj, _ := json.Marshal(e.Object)
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Time.UnixNano()/int64(time.Millisecond), 10),
+		"suser=" + cefExt(e.Operator),
+		"externalId=" + cefExt(e.ID),
+		"cat=" + cefExt(e.Category),
+	}
+	if e.SourceIP != "" {
+		ext = append(ext, "src="+cefExt(e.SourceIP))
+	}
+	if e.RequestID != "" {
+		ext = append(ext, "cs1Label=requestId", "cs1="+cefExt(e.RequestID))
+	}
+	ext = append(ext, "msg="+cefExt(string(obj)))
+	return strings.Join([]string{
+		"CEF:0",
+		cefHeader(cefVendor),
+		cefHeader(cefProduct),
+		cefHeader(version),
+		cefHeader(e.Category + "." + e.Type),
+		cefHeader(e.Type),
+		strconv.Itoa(cefSeverity(e.Type)),
+		strings.Join(ext, " "),
+	}, "|")
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string {
+	return cefHeaderEscaper.Replace(s)
+}
+
+func cefExt(s string) string {
+	return cefExtEscaper.Replace(s)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook headers
+const (
+	HeaderEventID   = "X-Zaca-Event-Id"
+	HeaderEventType = "X-Zaca-Event-Type"
+	HeaderTimestamp = "X-Zaca-Webhook-Timestamp"
+	HeaderSignature = "X-Zaca-Webhook-Signature"
+)
+
+// WebhookConfig ...
+type WebhookConfig struct {
+	URL    string
+	Secret string
+	// MaxRetries Attempts after the first one, Backoff doubles after every attempt
+	MaxRetries int
+	Backoff    time.Duration
+	Client     *http.Client
+}
+
+// Webhook POSTs every event as JSON, signed with HMAC-SHA256 of the secret
+type Webhook struct {
+	name string
+	conf WebhookConfig
+}
+
+// NewWebhook ...
+func NewWebhook(name string, conf WebhookConfig) *Webhook {
+	if conf.Backoff <= 0 {
+		conf.Backoff = time.Second
+	}
+	if conf.Client == nil {
+		conf.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Webhook{name: name, conf: conf}
+}
+
+// Name ...
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// SignWebhook "sha256=" hex HMAC of "timestamp.body"
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook Check a delivery on the receiving side
+func VerifyWebhook(secret []byte, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}
+
+// DeliveryError Final failure after all attempts
+type DeliveryError struct {
+	Attempts int
+	Status   int
+	Err      error
+}
+
+func (e *DeliveryError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("webhook failed after %d attempts: %v", e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("webhook failed after %d attempts: status %d", e.Attempts, e.Status)
+}
+
+// Send Retries network errors, 408, 429 and 5xx responses
+func (w *Webhook) Send(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	backoff := w.conf.Backoff
+	derr := &DeliveryError{}
+	for attempt := 0; attempt <= w.conf.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				derr.Err = ctx.Err()
+				return derr
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		derr.Attempts++
+		status, err := w.post(ctx, e, body)
+		derr.Status, derr.Err = status, err
+		if err == nil && status >= 200 && status < 300 {
+			return nil
+		}
+		if err == nil && !retryable(status) {
+			return derr
+		}
+	}
+	return derr
+}
+
+func (w *Webhook) post(ctx context.Context, e *Event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, e.ID)
+	req.Header.Set(HeaderEventType, e.Category+"."+e.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if w.conf.Secret != "" {
+		req.Header.Set(HeaderSignature, SignWebhook([]byte(w.conf.Secret), ts, body))
+	}
+	resp, err := w.conf.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package failover Calls over redundant endpoints, ordered by health, priority and latency, with circuit breaking
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// Circuit states
+const (
+	StateClosed   = "closed"    // Calls go through
+	StateOpen     = "open"      // Skipped until the open duration elapsed
+	StateHalfOpen = "half-open" // One trial call decides whether to close the circuit again
+)
+
+// ErrUnavailable Every circuit is open
+var ErrUnavailable = errors.New("no endpoint available, all circuits are open")
+
+// Config Call and circuit settings, zero values disable the timeout and the retries
+type Config struct {
+	Timeout          time.Duration // Per call
+	Retries          int           // Rounds over the endpoints after the first one failed
+	BackoffBase      time.Duration // Before the second round, doubled after each round
+	BackoffMax       time.Duration
+	FailureThreshold int           // Consecutive failures opening the circuit, default 5
+	OpenDuration     time.Duration // Before a half-open trial, default 30s
+}
+
+// Endpoint Name and priority, lower priorities are preferred
+type Endpoint struct {
+	Name     string
+	Priority int
+}
+
+// Status Circuit and health of an endpoint
+type Status struct {
+	Name      string        `json:"name"`
+	Priority  int           `json:"priority"`
+	State     string        `json:"state"`
+	Failures  int           `json:"failures"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"`
+	OpenUntil *time.Time    `json:"open_until,omitempty"`
+}
+
+type endpoint struct {
+	Endpoint
+	index     int
+	failures  int
+	openUntil time.Time
+	trial     bool // Half-open trial call in flight
+	unhealthy bool // Last health check failed
+	latency   time.Duration
+}
+
+// Pool Endpoints sharing a circuit configuration, safe for concurrent use
+type Pool struct {
+	conf      Config
+	mu        sync.Mutex
+	endpoints []*endpoint
+	byName    map[string]*endpoint
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New Pool over endpoints, ties in priority keep the given order until latencies are known
+func New(conf Config, endpoints []Endpoint) *Pool {
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = 5
+	}
+	if conf.OpenDuration <= 0 {
+		conf.OpenDuration = 30 * time.Second
+	}
+	p := &Pool{
+		conf:   conf,
+		byName: make(map[string]*endpoint, len(endpoints)),
+		now:    time.Now,
+		sleep:  sleep,
+	}
+	for i, e := range endpoints {
+		ep := &endpoint{Endpoint: e, index: i}
+		p.endpoints = append(p.endpoints, ep)
+		p.byName[e.Name] = ep
+	}
+	return p
+}
+
+// Do Call fn on the endpoints in order until one succeeds. fn gets a context bounded by the call timeout.
+// When a whole round failed the next one starts after an exponential backoff with jitter
+func (p *Pool) Do(ctx context.Context, fn func(ctx context.Context, name string) error) error {
+	if len(p.endpoints) == 0 {
+		return errors.New("no endpoint configured")
+	}
+	var errs error
+	for round := 0; round <= p.conf.Retries; round++ {
+		if round > 0 {
+			if err := p.sleep(ctx, p.Backoff(round)); err != nil {
+				return multierr.Append(errs, err)
+			}
+		}
+		tried := 0
+		for _, name := range p.Candidates() {
+			if !p.acquire(name) {
+				continue
+			}
+			tried++
+			err := p.call(ctx, name, fn)
+			if err == nil {
+				return nil
+			}
+			multierr.AppendInto(&errs, fmt.Errorf("%s: %w", name, err))
+			if ctx.Err() != nil {
+				return errs
+			}
+		}
+		if tried == 0 {
+			multierr.AppendInto(&errs, ErrUnavailable)
+		}
+	}
+	return errs
+}
+
+func (p *Pool) call(ctx context.Context, name string, fn func(ctx context.Context, name string) error) error {
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.conf.Timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, p.conf.Timeout)
+	}
+	defer cancel()
+	start := p.now()
+	err := fn(callCtx, name)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up, not a failure of the endpoint
+		p.release(name)
+		return err
+	}
+	p.Report(name, err, p.now().Sub(start))
+	return err
+}
+
+func (p *Pool) release(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.byName[name]; ok {
+		e.trial = false
+	}
+}
+
+// Backoff Wait before round n, the base doubled n-1 times, capped and jittered down to half
+func (p *Pool) Backoff(round int) time.Duration {
+	d := p.conf.BackoffBase
+	for i := 1; i < round && (p.conf.BackoffMax <= 0 || d < p.conf.BackoffMax); i++ {
+		d *= 2
+	}
+	if p.conf.BackoffMax > 0 && d > p.conf.BackoffMax {
+		d = p.conf.BackoffMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)) //nolint:gosec
+}
+
+// Candidates Endpoints in calling order: closed circuits first, healthy before unhealthy,
+// then by priority and latency. Open circuits due for a trial come last, the others are left out
+func (p *Pool) Candidates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var list []*endpoint
+	for _, e := range p.endpoints {
+		if p.state(e, now) != StateOpen {
+			list = append(list, e)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if sa, sb := p.state(a, now) == StateClosed, p.state(b, now) == StateClosed; sa != sb {
+			return sa
+		}
+		if a.unhealthy != b.unhealthy {
+			return !a.unhealthy
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.latency != b.latency && a.latency > 0 && b.latency > 0 {
+			return a.latency < b.latency
+		}
+		return a.index < b.index
+	})
+	names := make([]string, len(list))
+	for i, e := range list {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// acquire Whether name may be called now, a half-open circuit lets a single trial through
+func (p *Pool) acquire(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.byName[name]
+	if !ok {
+		return false
+	}
+	switch p.state(e, p.now()) {
+	case StateClosed:
+		return true
+	case StateHalfOpen:
+		if e.trial {
+			return false
+		}
+		e.trial = true
+		return true
+	}
+	return false
+}
+
+// Report Result of a call, failures open the circuit at the threshold and a failed trial opens it again
+func (p *Pool) Report(name string, err error, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.byName[name]
+	if !ok {
+		return
+	}
+	trial := e.trial
+	e.trial = false
+	if err == nil {
+		e.failures = 0
+		e.openUntil = time.Time{}
+		e.observe(latency)
+		return
+	}
+	e.failures++
+	if trial || e.failures >= p.conf.FailureThreshold {
+		e.openUntil = p.now().Add(p.conf.OpenDuration)
+	}
+}
+
+// ReportHealth Result of an out-of-band health check. A failed check demotes the endpoint,
+// a successful one makes an open circuit due for its trial call
+func (p *Pool) ReportHealth(name string, err error, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.byName[name]
+	if !ok {
+		return
+	}
+	e.unhealthy = err != nil
+	if err != nil {
+		return
+	}
+	e.observe(latency)
+	if now := p.now(); e.openUntil.After(now) {
+		e.openUntil = now
+	}
+}
+
+// Status Circuits and health of the endpoints, in configuration order
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	list := make([]Status, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		s := Status{
+			Name:     e.Name,
+			Priority: e.Priority,
+			State:    p.state(e, now),
+			Failures: e.failures,
+			Healthy:  !e.unhealthy,
+			Latency:  e.latency,
+		}
+		if s.State == StateOpen {
+			until := e.openUntil
+			s.OpenUntil = &until
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func (p *Pool) state(e *endpoint, now time.Time) string {
+	switch {
+	case e.openUntil.IsZero():
+		return StateClosed
+	case now.Before(e.openUntil):
+		return StateOpen
+	default:
+		return StateHalfOpen
+	}
+}
+
+// observe Moving average of the latency
+func (e *endpoint) observe(latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+	if e.latency == 0 {
+		e.latency = latency
+		return
+	}
+	e.latency = (e.latency*7 + latency*3) / 10
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
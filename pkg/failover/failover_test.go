@@ -0,0 +1,147 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failover
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newPool(conf Config, endpoints ...Endpoint) (*Pool, *clock, *[]time.Duration) {
+	p := New(conf, endpoints)
+	c := &clock{t: time.Unix(1600000000, 0)}
+	var slept []time.Duration
+	p.now = c.now
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return p, c, &slept
+}
+
+var errDown = errors.New("down")
+
+func TestCandidatesOrder(t *testing.T) {
+	p, _, _ := newPool(Config{}, Endpoint{Name: "a", Priority: 1}, Endpoint{Name: "b"}, Endpoint{Name: "c"})
+	if got := p.Candidates(); !reflect.DeepEqual(got, []string{"b", "c", "a"}) {
+		t.Errorf("priority order %v", got)
+	}
+	p.ReportHealth("b", nil, 80*time.Millisecond)
+	p.ReportHealth("c", nil, 20*time.Millisecond)
+	if got := p.Candidates(); !reflect.DeepEqual(got, []string{"c", "b", "a"}) {
+		t.Errorf("latency order %v", got)
+	}
+	p.ReportHealth("c", errDown, 0)
+	if got := p.Candidates(); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("unhealthy endpoints should come last %v", got)
+	}
+}
+
+func TestCircuit(t *testing.T) {
+	p, c, _ := newPool(Config{FailureThreshold: 2, OpenDuration: time.Minute}, Endpoint{Name: "a"}, Endpoint{Name: "b"})
+	p.Report("a", errDown, 0)
+	if got := p.Candidates(); len(got) != 2 {
+		t.Fatalf("below the threshold the circuit stays closed %v", got)
+	}
+	p.Report("a", errDown, 0)
+	if got := p.Candidates(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("open circuit should be skipped %v", got)
+	}
+	if s := p.Status()[0]; s.State != StateOpen || s.OpenUntil == nil {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	c.t = c.t.Add(time.Minute)
+	if got := p.Candidates(); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatalf("half-open circuit should come last %v", got)
+	}
+	if !p.acquire("a") || p.acquire("a") {
+		t.Fatal("a half-open circuit lets a single trial through")
+	}
+	p.Report("a", errDown, 0)
+	if p.Status()[0].State != StateOpen {
+		t.Fatal("a failed trial should open the circuit again")
+	}
+
+	p.ReportHealth("a", nil, time.Millisecond)
+	if p.Status()[0].State != StateHalfOpen {
+		t.Fatal("a successful health check should make the circuit due for a trial")
+	}
+	if !p.acquire("a") {
+		t.Fatal("trial refused")
+	}
+	p.Report("a", nil, time.Millisecond)
+	if s := p.Status()[0]; s.State != StateClosed || s.Failures != 0 {
+		t.Errorf("a successful trial should close the circuit %+v", s)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p, _, slept := newPool(Config{Retries: 2, BackoffBase: 100 * time.Millisecond, BackoffMax: 150 * time.Millisecond},
+		Endpoint{Name: "a"}, Endpoint{Name: "b"})
+	var calls []string
+	err := p.Do(context.Background(), func(ctx context.Context, name string) error {
+		calls = append(calls, name)
+		if len(calls) < 4 || name != "b" {
+			return errDown
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"a", "b", "a", "b"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if len(*slept) != 1 || (*slept)[0] < 50*time.Millisecond || (*slept)[0] > 100*time.Millisecond {
+		t.Errorf("unexpected backoff %v", *slept)
+	}
+
+	err = p.Do(context.Background(), func(ctx context.Context, name string) error { return errDown })
+	if err == nil || len(*slept) != 3 {
+		t.Errorf("all rounds should fail with a backoff between them: %v %v", err, *slept)
+	}
+	for _, d := range (*slept)[1:] {
+		if d > 150*time.Millisecond {
+			t.Errorf("backoff above the cap %v", d)
+		}
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	p := New(Config{Timeout: 10 * time.Millisecond}, []Endpoint{{Name: "a"}})
+	err := p.Do(context.Background(), func(ctx context.Context, name string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the call timeout, got %v", err)
+	}
+}
+
+func TestDoUnavailable(t *testing.T) {
+	p, _, _ := newPool(Config{FailureThreshold: 1}, Endpoint{Name: "a"})
+	p.Report("a", errDown, 0)
+	err := p.Do(context.Background(), func(ctx context.Context, name string) error { return nil })
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package forbidrule Rules refusing or restricting certificate requests, matched in memory against the requested hosts
+package forbidrule
+
+import (
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ztalab/ZACA/pkg/spiffe"
+)
+
+// Kinds
+const (
+	KindSite     = "site"      // SPIFFE trust domain
+	KindCluster  = "cluster"   // site/cluster
+	KindUniqueID = "unique_id" // Exact unique_id
+	KindPrefix   = "unique_id_prefix"
+	KindGlob     = "unique_id_glob" // path.Match syntax
+	KindDNS      = "dns"            // DNS SAN, exact or path.Match syntax such as *.example.com
+)
+
+// Effects
+const (
+	EffectDeny     = "deny"      // Refuse the request
+	EffectShortTTL = "short_ttl" // Sign with a validity of at most MaxTTL
+)
+
+var kinds = map[string]bool{KindSite: true, KindCluster: true, KindUniqueID: true, KindPrefix: true, KindGlob: true, KindDNS: true}
+
+// Rule ...
+type Rule struct {
+	ID        uint64
+	Kind      string
+	Pattern   string
+	Effect    string
+	MaxTTL    time.Duration
+	Reason    string
+	ExpiresAt time.Time // Zero never expires
+}
+
+// Active Whether the rule applies at now
+func (r *Rule) Active(now time.Time) bool {
+	return r.ExpiresAt.IsZero() || now.Before(r.ExpiresAt)
+}
+
+// Validate ...
+func Validate(r *Rule) error {
+	if !kinds[r.Kind] {
+		return errors.Errorf("unknown forbid rule kind %q", r.Kind)
+	}
+	if r.Pattern == "" {
+		return errors.New("forbid rule pattern is empty")
+	}
+	switch r.Kind {
+	case KindCluster:
+		if parts := strings.Split(r.Pattern, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.Errorf("cluster pattern %q must be site/cluster", r.Pattern)
+		}
+	case KindGlob, KindDNS:
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return errors.Errorf("invalid pattern %q: %s", r.Pattern, err)
+		}
+	}
+	switch r.Effect {
+	case EffectDeny:
+	case EffectShortTTL:
+		if r.MaxTTL <= 0 {
+			return errors.New("short_ttl rules need a positive max TTL")
+		}
+	default:
+		return errors.Errorf("unknown forbid rule effect %q", r.Effect)
+	}
+	return nil
+}
+
+// Decision Outcome of matching a request
+type Decision struct {
+	Rule *Rule
+	Host string
+}
+
+// Deny ...
+func (d *Decision) Deny() bool {
+	return d.Rule.Effect == EffectDeny
+}
+
+// Matcher Rules indexed by kind, exact patterns and prefixes are map lookups, only globs are scanned
+type Matcher struct {
+	sites     map[string][]*Rule
+	clusters  map[string][]*Rule
+	uniqueIDs map[string][]*Rule
+	prefixes  map[string][]*Rule
+	dns       map[string][]*Rule
+	dnsSuffix map[string][]*Rule // *.example.com is stored as .example.com
+	globs     []*Rule
+	dnsGlobs  []*Rule
+	size      int
+}
+
+// NewMatcher Index the rules, invalid ones are left out
+func NewMatcher(rules []*Rule) *Matcher {
+	m := &Matcher{
+		sites:     make(map[string][]*Rule),
+		clusters:  make(map[string][]*Rule),
+		uniqueIDs: make(map[string][]*Rule),
+		prefixes:  make(map[string][]*Rule),
+		dns:       make(map[string][]*Rule),
+		dnsSuffix: make(map[string][]*Rule),
+	}
+	for _, r := range rules {
+		if Validate(r) != nil {
+			continue
+		}
+		m.size++
+		switch r.Kind {
+		case KindSite:
+			m.sites[r.Pattern] = append(m.sites[r.Pattern], r)
+		case KindCluster:
+			m.clusters[r.Pattern] = append(m.clusters[r.Pattern], r)
+		case KindUniqueID:
+			m.uniqueIDs[r.Pattern] = append(m.uniqueIDs[r.Pattern], r)
+		case KindPrefix:
+			m.prefixes[r.Pattern] = append(m.prefixes[r.Pattern], r)
+		case KindGlob:
+			m.globs = append(m.globs, r)
+		case KindDNS:
+			pattern := normalizeDNS(r.Pattern)
+			switch {
+			case !hasMeta(pattern):
+				m.dns[pattern] = append(m.dns[pattern], r)
+			case strings.HasPrefix(pattern, "*.") && !hasMeta(pattern[1:]):
+				m.dnsSuffix[pattern[1:]] = append(m.dnsSuffix[pattern[1:]], r)
+			default:
+				m.dnsGlobs = append(m.dnsGlobs, r)
+			}
+		}
+	}
+	return m
+}
+
+// Len Number of indexed rules
+func (m *Matcher) Len() int {
+	return m.size
+}
+
+// Match The strongest active rule matching one of the hosts: deny before short_ttl, then the shortest TTL.
+// Returns nil when no rule applies
+func (m *Matcher) Match(hosts []string, now time.Time) *Decision {
+	var best *Decision
+	consider := func(host string, rules []*Rule) {
+		for _, r := range rules {
+			if !r.Active(now) {
+				continue
+			}
+			if best == nil || stronger(r, best.Rule) {
+				best = &Decision{Rule: r, Host: host}
+			}
+		}
+	}
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "spiffe://") {
+			id, err := spiffe.ParseIDGIdentity(host)
+			if err != nil {
+				continue
+			}
+			consider(host, m.sites[id.SiteID])
+			consider(host, m.clusters[id.SiteID+"/"+id.ClusterID])
+			if id.UniqueID == "" {
+				continue
+			}
+			consider(host, m.uniqueIDs[id.UniqueID])
+			for i := 1; i <= len(id.UniqueID); i++ {
+				consider(host, m.prefixes[id.UniqueID[:i]])
+			}
+			for _, r := range m.globs {
+				if ok, _ := path.Match(r.Pattern, id.UniqueID); ok {
+					consider(host, []*Rule{r})
+				}
+			}
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			continue
+		}
+		name := normalizeDNS(host)
+		consider(host, m.dns[name])
+		for i := strings.IndexByte(name, '.'); i >= 0; {
+			consider(host, m.dnsSuffix[name[i:]])
+			next := strings.IndexByte(name[i+1:], '.')
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+		for _, r := range m.dnsGlobs {
+			if ok, _ := path.Match(normalizeDNS(r.Pattern), name); ok {
+				consider(host, []*Rule{r})
+			}
+		}
+	}
+	return best
+}
+
+func stronger(a, b *Rule) bool {
+	if a.Effect != b.Effect {
+		return a.Effect == EffectDeny
+	}
+	return a.Effect == EffectShortTTL && a.MaxTTL < b.MaxTTL
+}
+
+func normalizeDNS(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forbidrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	for _, r := range []*Rule{
+		{Kind: "host", Pattern: "a", Effect: EffectDeny},
+		{Kind: KindSite, Effect: EffectDeny},
+		{Kind: KindCluster, Pattern: "site", Effect: EffectDeny},
+		{Kind: KindGlob, Pattern: "[a", Effect: EffectDeny},
+		{Kind: KindUniqueID, Pattern: "a", Effect: EffectShortTTL},
+		{Kind: KindUniqueID, Pattern: "a", Effect: "allow"},
+	} {
+		if err := Validate(r); err == nil {
+			t.Errorf("Validate(%+v) should fail", r)
+		}
+	}
+	if err := Validate(&Rule{Kind: KindCluster, Pattern: "site/cluster", Effect: EffectShortTTL, MaxTTL: time.Hour}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Now()
+	m := NewMatcher([]*Rule{
+		{ID: 1, Kind: KindSite, Pattern: "blocked", Effect: EffectDeny},
+		{ID: 2, Kind: KindCluster, Pattern: "site/quarantine", Effect: EffectShortTTL, MaxTTL: time.Hour},
+		{ID: 3, Kind: KindUniqueID, Pattern: "gateway-1", Effect: EffectDeny},
+		{ID: 4, Kind: KindPrefix, Pattern: "test-", Effect: EffectShortTTL, MaxTTL: 2 * time.Hour},
+		{ID: 5, Kind: KindGlob, Pattern: "batch-*-tmp", Effect: EffectDeny},
+		{ID: 6, Kind: KindDNS, Pattern: "*.internal.example.com", Effect: EffectDeny},
+		{ID: 7, Kind: KindDNS, Pattern: "api.example.com", Effect: EffectShortTTL, MaxTTL: time.Minute},
+		{ID: 8, Kind: KindDNS, Pattern: "db-?.example.com", Effect: EffectDeny},
+		{ID: 9, Kind: KindUniqueID, Pattern: "expired", Effect: EffectDeny, ExpiresAt: now.Add(-time.Minute)},
+		{ID: 10, Kind: KindUniqueID, Pattern: "invalid", Effect: "allow"},
+	})
+	if m.Len() != 9 {
+		t.Errorf("Len() = %d, want 9", m.Len())
+	}
+
+	for hosts, want := range map[string]uint64{
+		"spiffe://blocked/c/u":                  1,
+		"spiffe://site/quarantine/u":            2,
+		"spiffe://site/c/gateway-1":             3,
+		"spiffe://site/c/test-42":               4,
+		"spiffe://site/c/batch-7-tmp":           5,
+		"a.internal.example.com":                6,
+		"B.A.Internal.Example.com.":             6,
+		"api.example.com":                       7,
+		"db-1.example.com":                      8,
+		"spiffe://site/c/expired":               0,
+		"spiffe://site/c/invalid":               0,
+		"internal.example.com":                  0,
+		"10.0.0.1":                              0,
+		"spiffe://site/c/gateway-2":             0,
+		"spiffe://site/quarantine/test-1":       2,
+		"spiffe://site/quarantine/gateway-1":    3,
+		"spiffe://site/c/other api.example.com": 7,
+	} {
+		d := m.Match(strings.Fields(hosts), now)
+		var got uint64
+		if d != nil {
+			got = d.Rule.ID
+		}
+		if got != want {
+			t.Errorf("Match(%q) = rule %d, want %d", hosts, got, want)
+		}
+	}
+
+	if d := m.Match([]string{"spiffe://site/c/gateway-1"}, now); d == nil || !d.Deny() {
+		t.Error("unique_id rule should deny")
+	}
+	if d := m.Match([]string{"spiffe://site/c/expired"}, now.Add(-time.Hour)); d == nil || d.Rule.ID != 9 {
+		t.Error("rule should apply before its expiry")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory Per-unit certificate gauges with bounded cardinality
+package inventory
+
+import (
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Unit Certificates of one unique_id
+type Unit struct {
+	UniqueID       string
+	Role           string
+	EarliestExpiry time.Time
+	LatestExpiry   time.Time
+	Valid          int // Good and not expired
+	Revoked        int // Revoked and not expired
+	Forbidden      bool
+}
+
+// Select Units matching an allow-list pattern (path.Match syntax) plus the topN others expiring first,
+// topN < 0 exports all units
+func Select(units []Unit, allow []string, topN int) []Unit {
+	var selected, rest []Unit
+	for _, u := range units {
+		if allowed(u.UniqueID, allow) {
+			selected = append(selected, u)
+		} else {
+			rest = append(rest, u)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		if !rest[i].EarliestExpiry.Equal(rest[j].EarliestExpiry) {
+			return rest[i].EarliestExpiry.Before(rest[j].EarliestExpiry)
+		}
+		return rest[i].UniqueID < rest[j].UniqueID
+	})
+	if topN >= 0 && len(rest) > topN {
+		rest = rest[:topN]
+	}
+	return append(selected, rest...)
+}
+
+func allowed(uniqueID string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, uniqueID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	unitLabels = []string{"unique_id", "role"}
+
+	earliestDesc = prometheus.NewDesc("zaca_inventory_unit_earliest_expiry_timestamp_seconds",
+		"Earliest expiry of the valid certificates of a unit", unitLabels, nil)
+	latestDesc = prometheus.NewDesc("zaca_inventory_unit_latest_expiry_timestamp_seconds",
+		"Latest expiry of the valid certificates of a unit", unitLabels, nil)
+	validDesc = prometheus.NewDesc("zaca_inventory_unit_valid_certificates",
+		"Valid certificates of a unit", unitLabels, nil)
+	revokedDesc = prometheus.NewDesc("zaca_inventory_unit_revoked_certificates",
+		"Revoked certificates of a unit that have not expired", unitLabels, nil)
+	forbiddenDesc = prometheus.NewDesc("zaca_inventory_unit_forbidden",
+		"1 if the unit is forbidden to apply for certificates", unitLabels, nil)
+	unitsDesc = prometheus.NewDesc("zaca_inventory_units",
+		"Live units, exported is the number with per-unit series", []string{"set"}, nil)
+	refreshDesc = prometheus.NewDesc("zaca_inventory_last_refresh_timestamp_seconds",
+		"Time of the last inventory refresh", nil, nil)
+)
+
+// Collector Serves the last snapshot, nothing is exported before the first Update
+type Collector struct {
+	mu        sync.RWMutex
+	units     []Unit
+	total     int
+	refreshed time.Time
+}
+
+// NewCollector ...
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Update Replace the snapshot, total is the number of live units before selection
+func (c *Collector) Update(units []Unit, total int, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.units, c.total, c.refreshed = units, total, at
+}
+
+// Reset Drop the snapshot, e.g. when another instance took over the refresh
+func (c *Collector) Reset() {
+	c.Update(nil, 0, time.Time{})
+}
+
+// Describe ...
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{earliestDesc, latestDesc, validDesc, revokedDesc, forbiddenDesc, unitsDesc, refreshDesc} {
+		ch <- d
+	}
+}
+
+// Collect ...
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.refreshed.IsZero() {
+		return
+	}
+	for _, u := range c.units {
+		gauge := func(desc *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, u.UniqueID, u.Role)
+		}
+		gauge(earliestDesc, float64(u.EarliestExpiry.Unix()))
+		gauge(latestDesc, float64(u.LatestExpiry.Unix()))
+		gauge(validDesc, float64(u.Valid))
+		gauge(revokedDesc, float64(u.Revoked))
+		forbidden := 0.0
+		if u.Forbidden {
+			forbidden = 1
+		}
+		gauge(forbiddenDesc, forbidden)
+	}
+	ch <- prometheus.MustNewConstMetric(unitsDesc, prometheus.GaugeValue, float64(c.total), "live")
+	ch <- prometheus.MustNewConstMetric(unitsDesc, prometheus.GaugeValue, float64(len(c.units)), "exported")
+	ch <- prometheus.MustNewConstMetric(refreshDesc, prometheus.GaugeValue, float64(c.refreshed.Unix()))
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSelect(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	units := []Unit{
+		{UniqueID: "gateway-1", EarliestExpiry: now.Add(90 * time.Hour)},
+		{UniqueID: "web-1", EarliestExpiry: now.Add(3 * time.Hour)},
+		{UniqueID: "web-2", EarliestExpiry: now.Add(1 * time.Hour)},
+		{UniqueID: "db-1", EarliestExpiry: now.Add(2 * time.Hour)},
+	}
+	ids := func(list []Unit) string {
+		var out []string
+		for _, u := range list {
+			out = append(out, u.UniqueID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	cases := []struct {
+		allow []string
+		topN  int
+		want  string
+	}{
+		{nil, -1, "web-2,db-1,web-1,gateway-1"},
+		{nil, 2, "web-2,db-1"},
+		{[]string{"gateway-*"}, 1, "gateway-1,web-2"},
+		{[]string{"gateway-*", "web-[0-9]"}, 0, "gateway-1,web-1,web-2"},
+	}
+	for _, c := range cases {
+		if got := ids(Select(units, c.allow, c.topN)); got != c.want {
+			t.Errorf("Select(%v, %d) = %s, want %s", c.allow, c.topN, got, c.want)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	if n, err := testutil.GatherAndCount(reg); err != nil || n != 0 {
+		t.Fatalf("expected no series before the first update, got %d (%v)", n, err)
+	}
+
+	c.Update([]Unit{{
+		UniqueID:       "web-1",
+		Role:           "default",
+		EarliestExpiry: time.Unix(100, 0),
+		LatestExpiry:   time.Unix(200, 0),
+		Valid:          2,
+		Forbidden:      true,
+	}}, 3, time.Unix(50, 0))
+
+	expected := `
+# HELP zaca_inventory_unit_forbidden 1 if the unit is forbidden to apply for certificates
+# TYPE zaca_inventory_unit_forbidden gauge
+zaca_inventory_unit_forbidden{role="default",unique_id="web-1"} 1
+# HELP zaca_inventory_unit_valid_certificates Valid certificates of a unit
+# TYPE zaca_inventory_unit_valid_certificates gauge
+zaca_inventory_unit_valid_certificates{role="default",unique_id="web-1"} 2
+# HELP zaca_inventory_units Live units, exported is the number with per-unit series
+# TYPE zaca_inventory_units gauge
+zaca_inventory_units{set="exported"} 1
+zaca_inventory_units{set="live"} 3
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"zaca_inventory_unit_forbidden", "zaca_inventory_unit_valid_certificates", "zaca_inventory_units"); err != nil {
+		t.Error(err)
+	}
+
+	c.Reset()
+	if n, _ := testutil.GatherAndCount(reg); n != 0 {
+		t.Errorf("expected no series after reset, got %d", n)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keyfile Private keys encrypted with a passphrase.
+// The PKCS#8 key is sealed with AES-256-GCM under a key derived by scrypt,
+// the KDF parameters and the salt travel in the PEM headers.
+package keyfile
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// BlockType PEM type of an encrypted key
+const BlockType = "ZACA ENCRYPTED PRIVATE KEY"
+
+// scrypt cost of new files, high since the key is only opened during ceremonies
+const (
+	scryptN = 1 << 17
+	scryptR = 8
+	scryptP = 1
+	saltLen = 16
+	keyLen  = 32
+)
+
+// ErrDecrypt Wrong passphrase or a modified file, GCM cannot tell them apart
+var ErrDecrypt = errors.New("keyfile: wrong passphrase or corrupted key")
+
+// Encrypt PEM encoded encrypted key
+func Encrypt(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("keyfile: empty passphrase")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"KDF":  fmt.Sprintf("scrypt,%d,%d,%d", scryptN, scryptR, scryptP),
+		"Salt": hex.EncodeToString(salt),
+	}
+	// The headers are authenticated, a tampered KDF setting fails to open
+	sealed := aead.Seal(nonce, nonce, der, additionalData(headers))
+	return pem.EncodeToMemory(&pem.Block{Type: BlockType, Headers: headers, Bytes: sealed}), nil
+}
+
+// Decrypt Key of a PEM encoded encrypted key
+func Decrypt(data, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != BlockType {
+		return nil, errors.Errorf("keyfile: no %s block", BlockType)
+	}
+	var n, r, p int
+	if _, err := fmt.Sscanf(block.Headers["KDF"], "scrypt,%d,%d,%d", &n, &r, &p); err != nil {
+		return nil, errors.Errorf("keyfile: unsupported KDF %q", block.Headers["KDF"])
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("keyfile: invalid salt")
+	}
+	aead, err := newAEAD(passphrase, salt, n, r, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Bytes) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := block.Bytes[:aead.NonceSize()], block.Bytes[aead.NonceSize():]
+	der, err := aead.Open(nil, nonce, sealed, additionalData(block.Headers))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("keyfile: key cannot sign")
+	}
+	return signer, nil
+}
+
+func newAEAD(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, keyLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "keyfile")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(headers map[string]string) []byte {
+	kdf, salt := headers["KDF"], headers["Salt"]
+	return []byte(BlockType + "\n" + strconv.Itoa(len(kdf)) + kdf + salt)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyfile
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encrypt(key, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("PRIVATE KEY-----\nMI")) {
+		t.Fatal("key is stored in the clear")
+	}
+
+	got, err := Decrypt(data, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(got.Public()) {
+		t.Fatal("decrypted key differs")
+	}
+
+	if _, err := Decrypt(data, []byte("battery staple")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong passphrase: err = %v, want ErrDecrypt", err)
+	}
+
+	// The KDF parameters are authenticated
+	block, _ := pem.Decode(data)
+	salt := block.Headers["Salt"]
+	if salt[0] == '0' {
+		block.Headers["Salt"] = "1" + salt[1:]
+	} else {
+		block.Headers["Salt"] = "0" + salt[1:]
+	}
+	if _, err := Decrypt(pem.EncodeToMemory(block), []byte("correct horse")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("modified salt: err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptEmptyPassphrase(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := Encrypt(key, nil); err == nil {
+		t.Fatal("empty passphrase accepted")
+	}
+}
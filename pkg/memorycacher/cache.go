@@ -29,6 +29,7 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -69,6 +70,9 @@ type Cache struct {
 }
 
 type cache struct {
+	// hits and misses of Get and GetWithExpiration, first for 64 bit alignment
+	hits              uint64
+	misses            uint64
 	defaultExpiration time.Duration
 	maxItemsCount     int //Maximum number of items
 	items             map[string]Item
@@ -171,18 +175,26 @@ func (c *cache) Get(k string) (interface{}, bool) {
 	item, found := c.items[k]
 	if !found {
 		c.mu.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
 		return nil, false
 	}
 	if item.Expiration > 0 {
 		if time.Now().UnixNano() > item.Expiration {
 			c.mu.RUnlock()
+			atomic.AddUint64(&c.misses, 1)
 			return nil, false
 		}
 	}
 	c.mu.RUnlock()
+	atomic.AddUint64(&c.hits, 1)
 	return item.Object, true
 }
 
+// Stats Number of lookups that found a live item and that did not
+func (c *cache) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
+
 // GetWithExpiration returns an item and its expiration time from the cache.
 // It returns the item or nil, the expiration time if one is set (if the item
 // never expires a zero value for time.Time is returned), and a bool indicating
@@ -193,23 +205,27 @@ func (c *cache) GetWithExpiration(k string) (interface{}, time.Time, bool) {
 	item, found := c.items[k]
 	if !found {
 		c.mu.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
 		return nil, time.Time{}, false
 	}
 
 	if item.Expiration > 0 {
 		if time.Now().UnixNano() > item.Expiration {
 			c.mu.RUnlock()
+			atomic.AddUint64(&c.misses, 1)
 			return nil, time.Time{}, false
 		}
 
 		// Return the item and the expiration time
 		c.mu.RUnlock()
+		atomic.AddUint64(&c.hits, 1)
 		return item.Object, time.Unix(0, item.Expiration), true
 	}
 
 	// If expiration <= 0 (i.e. no expiration time set) then return the item
 	// and a zeroed time.Time
 	c.mu.RUnlock()
+	atomic.AddUint64(&c.hits, 1)
 	return item.Object, time.Time{}, true
 }
 
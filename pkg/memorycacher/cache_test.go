@@ -159,6 +159,17 @@ func TestNewFrom(t *testing.T) {
 	}
 }
 
+func TestStats(t *testing.T) {
+	tc := New(DefaultExpiration, 0, maxItemsCount)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Get("a")
+	tc.GetWithExpiration("a")
+	tc.Get("b")
+	if hits, misses := tc.Stats(); hits != 2 || misses != 1 {
+		t.Errorf("unexpected stats %d hits %d misses", hits, misses)
+	}
+}
+
 func TestStorePointerToStruct(t *testing.T) {
 	tc := New(DefaultExpiration, 0, maxItemsCount)
 	tc.Set("foo", &TestStruct{Num: 1}, DefaultExpiration)
@@ -0,0 +1,171 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics Prometheus collectors of the CA, served on /metrics by every service
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "zaca"
+
+// Results
+const (
+	ResultSuccess  = "success"
+	ResultError    = "error"
+	ResultRejected = "rejected" // Refused by authentication or policy
+)
+
+var (
+	// SignRequests zaca_sign_requests_total{profile, result}
+	SignRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sign_requests_total",
+		Help:      "Certificate signing requests",
+	}, []string{"profile", "result"})
+	// SignDuration zaca_sign_duration_seconds{profile}
+	SignDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sign_duration_seconds",
+		Help:      "Latency of certificate signing requests",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"profile"})
+
+	// RevokeRequests zaca_revoke_requests_total{source, result}, source is sdk or api
+	RevokeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revoke_requests_total",
+		Help:      "Certificate revocation requests",
+	}, []string{"source", "result"})
+	// RevokeDuration zaca_revoke_duration_seconds{source}
+	RevokeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "revoke_duration_seconds",
+		Help:      "Latency of certificate revocation requests",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source"})
+
+	// OCSPRequests zaca_ocsp_requests_total{status, cache}
+	OCSPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ocsp_requests_total",
+		Help:      "OCSP requests by certificate status and response cache",
+	}, []string{"status", "cache"})
+	// OCSPDuration zaca_ocsp_duration_seconds{cache}
+	OCSPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ocsp_duration_seconds",
+		Help:      "Latency of OCSP responses",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"cache"})
+
+	// UpperCARequests zaca_upper_ca_requests_total{upstream, result}
+	UpperCARequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upper_ca_requests_total",
+		Help:      "Requests to the upper CA",
+	}, []string{"upstream", "result"})
+	// UpperCADuration zaca_upper_ca_duration_seconds{upstream}
+	UpperCADuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upper_ca_duration_seconds",
+		Help:      "Latency of requests to the upper CA",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"upstream"})
+	// UpperCACircuitOpen zaca_upper_ca_circuit_open{upstream}
+	UpperCACircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upper_ca_circuit_open",
+		Help:      "Whether calls to the upper CA are suspended after consecutive failures",
+	}, []string{"upstream"})
+
+	// Subordinates zaca_subordinates{state}, state is alive or stale
+	Subordinates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subordinates",
+		Help:      "Subordinate CAs registered by their heartbeats",
+	}, []string{"state"})
+
+	// Leader zaca_leader{lease}, 1 on the replica holding the lease
+	Leader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this replica leads the singleton jobs of the lease",
+	}, []string{"lease"})
+	// LeaderChanges zaca_leader_changes_total{lease, change}, change is acquired or lost
+	LeaderChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_changes_total",
+		Help:      "Leadership changes of this replica",
+	}, []string{"lease", "change"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		SignRequests, SignDuration,
+		RevokeRequests, RevokeDuration,
+		OCSPRequests, OCSPDuration,
+		UpperCARequests, UpperCADuration, UpperCACircuitOpen,
+		Subordinates,
+		Leader, LeaderChanges,
+	)
+}
+
+// Result Label of an error
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+// Since Seconds elapsed, for the histograms
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// CacheStats Implemented by memorycacher.Cache
+type CacheStats interface {
+	Stats() (hits, misses uint64)
+}
+
+// RegisterCache Expose zaca_cache_requests_total{cache, result} of a cache, registering a name twice is a no-op
+func RegisterCache(name string, cache CacheStats) {
+	registerCache(prometheus.DefaultRegisterer, name, cache)
+}
+
+func registerCache(reg prometheus.Registerer, name string, cache CacheStats) {
+	for _, result := range []string{"hit", "miss"} {
+		result := result
+		c := prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_requests_total",
+			Help:        "Cache lookups, the hit ratio is hit / (hit + miss)",
+			ConstLabels: prometheus.Labels{"cache": name, "result": result},
+		}, func() float64 {
+			hits, misses := cache.Stats()
+			if result == "hit" {
+				return float64(hits)
+			}
+			return float64(misses)
+		})
+		if err := reg.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				panic(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stats struct{ hits, misses uint64 }
+
+func (s stats) Stats() (uint64, uint64) { return s.hits, s.misses }
+
+func TestRegisterCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	registerCache(reg, "ocsp", stats{hits: 3, misses: 1})
+	registerCache(reg, "ocsp", stats{})
+
+	expected := `
+# HELP zaca_cache_requests_total Cache lookups, the hit ratio is hit / (hit + miss)
+# TYPE zaca_cache_requests_total counter
+zaca_cache_requests_total{cache="ocsp",result="hit"} 3
+zaca_cache_requests_total{cache="ocsp",result="miss"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "zaca_cache_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignRequests(t *testing.T) {
+	SignRequests.WithLabelValues("default", Result(nil)).Inc()
+	if v := testutil.ToFloat64(SignRequests.WithLabelValues("default", ResultSuccess)); v != 1 {
+		t.Errorf("unexpected counter %v", v)
+	}
+}
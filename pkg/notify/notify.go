@@ -0,0 +1,83 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify Alert channels for certificates nearing expiry
+package notify
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Certificate kinds
+const (
+	KindWorkload = "workload" // Issued by this CA
+	KindSelf     = "self"     // Certificate of this CA
+	KindUpper    = "upper"    // Upper CA and root certificates
+)
+
+// Alert Certificate that crossed an expiry threshold
+type Alert struct {
+	Kind      string    `json:"kind"`
+	Role      string    `json:"role,omitempty"`
+	UniqueID  string    `json:"unique_id"`
+	SN        string    `json:"sn"`
+	AKI       string    `json:"aki"`
+	NotAfter  time.Time `json:"not_after"`
+	Threshold Duration  `json:"threshold"`
+	Remaining Duration  `json:"remaining"`
+}
+
+// Duration Encoded as a Go duration string
+type Duration time.Duration
+
+// MarshalText ...
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Notifier Alert channel
+type Notifier interface {
+	Name() string
+	// Stateful Channels reflecting the current state get every alert of every scan,
+	// the others only get alerts not notified before
+	Stateful() bool
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// Thresholds Remaining validity that triggers an alert
+type Thresholds []time.Duration
+
+// Stage Smallest threshold the remaining validity is within, ok is false if none is reached
+func (t Thresholds) Stage(remaining time.Duration) (time.Duration, bool) {
+	sorted := append(Thresholds{}, t...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, th := range sorted {
+		if remaining <= th {
+			return th, true
+		}
+	}
+	return 0, false
+}
+
+// Max Largest threshold
+func (t Thresholds) Max() time.Duration {
+	var max time.Duration
+	for _, th := range t {
+		if th > max {
+			max = th
+		}
+	}
+	return max
+}
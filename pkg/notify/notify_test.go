@@ -0,0 +1,149 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var testAlerts = []Alert{{
+	Kind:      KindWorkload,
+	Role:      "default",
+	UniqueID:  "unit-a",
+	SN:        "123",
+	NotAfter:  time.Now().Add(48 * time.Hour),
+	Threshold: Duration(7 * 24 * time.Hour),
+	Remaining: Duration(48 * time.Hour),
+}}
+
+func TestThresholds(t *testing.T) {
+	th := Thresholds{30 * 24 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+	if stage, ok := th.Stage(48 * time.Hour); !ok || stage != 7*24*time.Hour {
+		t.Errorf("unexpected stage %v %v", stage, ok)
+	}
+	if _, ok := th.Stage(40 * 24 * time.Hour); ok {
+		t.Error("no threshold should be reached")
+	}
+	if th.Max() != 30*24*time.Hour {
+		t.Errorf("unexpected max %v", th.Max())
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got struct {
+		Alerts []map[string]interface{} `json:"alerts"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook("hook", srv.URL, "secret").Notify(context.Background(), testAlerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Alerts) != 1 || got.Alerts[0]["unique_id"] != "unit-a" || got.Alerts[0]["remaining"] != "48h0m0s" {
+		t.Errorf("unexpected payload %v", got)
+	}
+}
+
+// fakeSMTP Minimal SMTP server recording the message data
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				data <- msg.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestSMTP(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	s := NewSMTP("mail", SMTPConfig{Addr: addr, From: "ca@example.com", To: []string{"ops@example.com"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, testAlerts); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-data
+	if !strings.Contains(msg, "Subject: [ZACA] 1 certificate(s) expiring soon") || !strings.Contains(msg, "unit-a") {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p, err := NewPrometheus(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = p.Notify(context.Background(), testAlerts)
+	if n := testutil.CollectAndCount(p.remaining); n != 1 {
+		t.Errorf("expected 1 series, got %d", n)
+	}
+	_ = p.Notify(context.Background(), nil)
+	if n := testutil.CollectAndCount(p.remaining); n != 0 {
+		t.Errorf("expected resolved alerts to be removed, got %d", n)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus Gauges of the certificates within a threshold, replaced on every scan
+type Prometheus struct {
+	remaining *prometheus.GaugeVec
+}
+
+// NewPrometheus Registers zaca_cert_expiry_remaining_seconds
+func NewPrometheus(reg prometheus.Registerer) (*Prometheus, error) {
+	p := &Prometheus{
+		remaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "zaca_cert_expiry_remaining_seconds",
+			Help: "Remaining validity of certificates within an expiry threshold",
+		}, []string{"kind", "role", "unique_id", "sn", "threshold"}),
+	}
+	if err := reg.Register(p.remaining); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Name ...
+func (p *Prometheus) Name() string {
+	return "prometheus"
+}
+
+// Stateful ...
+func (p *Prometheus) Stateful() bool {
+	return true
+}
+
+// Notify ...
+func (p *Prometheus) Notify(_ context.Context, alerts []Alert) error {
+	p.remaining.Reset()
+	for _, a := range alerts {
+		p.remaining.WithLabelValues(a.Kind, a.Role, a.UniqueID, a.SN, time.Duration(a.Threshold).String()).
+			Set(time.Duration(a.Remaining).Seconds())
+	}
+	return nil
+}
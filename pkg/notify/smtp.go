@@ -0,0 +1,126 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig ...
+type SMTPConfig struct {
+	Addr     string // host:port
+	From     string
+	To       []string
+	Username string
+	Password string
+	// StartTLS Required unless the server is on localhost, authentication is only sent over TLS
+	StartTLS bool
+}
+
+// SMTP One mail per scan listing the new alerts
+type SMTP struct {
+	name string
+	conf SMTPConfig
+}
+
+// NewSMTP ...
+func NewSMTP(name string, conf SMTPConfig) *SMTP {
+	return &SMTP{name: name, conf: conf}
+}
+
+// Name ...
+func (s *SMTP) Name() string {
+	return s.name
+}
+
+// Stateful ...
+func (s *SMTP) Stateful() bool {
+	return false
+}
+
+// Notify ...
+func (s *SMTP) Notify(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(s.conf.Addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.conf.StartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if s.conf.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.conf.Username, s.conf.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.conf.From); err != nil {
+		return err
+	}
+	for _, to := range s.conf.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(alerts)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTP) message(alerts []Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.conf.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.conf.To, ", "))
+	fmt.Fprintf(&b, "Subject: [ZACA] %d certificate(s) expiring soon\r\n", len(alerts))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&b, "%s %s (role %s) sn %s expires %s, in %s\r\n",
+			a.Kind, a.UniqueID, a.Role, a.SN, a.NotAfter.Format(time.RFC3339), time.Duration(a.Remaining).Round(time.Minute))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ztalab/ZACA/pkg/eventbus"
+)
+
+// Webhook POSTs {"alerts": [...]}, signed like the event webhooks
+type Webhook struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhook ...
+func NewWebhook(name, url, secret string) *Webhook {
+	return &Webhook{
+		name:   name,
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name ...
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// Stateful ...
+func (w *Webhook) Stateful() bool {
+	return false
+}
+
+// Notify ...
+func (w *Webhook) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(map[string]interface{}{"alerts": alerts})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventbus.HeaderEventType, "expiry.alert")
+	req.Header.Set(eventbus.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if w.secret != "" {
+		req.Header.Set(eventbus.HeaderSignature, eventbus.SignWebhook([]byte(w.secret), ts, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", w.name, resp.StatusCode)
+	}
+	return nil
+}
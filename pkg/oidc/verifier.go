@@ -0,0 +1,165 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oidc Validation of OIDC bearer tokens against the JWKS of the issuer
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// Asymmetric algorithms only, HMAC and "none" tokens are refused
+var allowedAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// Config ...
+type Config struct {
+	Issuer   string
+	Audience string
+	JWKSURL  string
+	// Minimum interval between two JWKS downloads, an unknown kid triggers a download
+	RefreshInterval time.Duration
+	Leeway          time.Duration
+}
+
+// Verifier ...
+type Verifier struct {
+	conf   Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      *jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// NewVerifier ...
+func NewVerifier(conf Config) *Verifier {
+	if conf.RefreshInterval <= 0 {
+		conf.RefreshInterval = 5 * time.Minute
+	}
+	if conf.Leeway <= 0 {
+		conf.Leeway = jwt.DefaultLeeway
+	}
+	return &Verifier{
+		conf:   conf,
+		client: &http.Client{Timeout: 5 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// Verify Check signature, issuer, audience and validity, returns all claims of the token
+func (v *Verifier) Verify(raw string) (map[string]interface{}, error) {
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token")
+	}
+	if len(tok.Headers) != 1 || !allowedAlgorithms[tok.Headers[0].Algorithm] {
+		return nil, errors.New("token algorithm not allowed")
+	}
+	key, err := v.key(tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var std jwt.Claims
+	claims := make(map[string]interface{})
+	if err := tok.Claims(key.Key, &std, &claims); err != nil {
+		return nil, errors.Wrap(err, "invalid token signature")
+	}
+	expected := jwt.Expected{Issuer: v.conf.Issuer, Time: v.now()}
+	if v.conf.Audience != "" {
+		expected.Audience = jwt.Audience{v.conf.Audience}
+	}
+	if err := std.ValidateWithLeeway(expected, v.conf.Leeway); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) key(kid string) (*jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k := findKey(v.keys, kid); k != nil {
+		return k, nil
+	}
+	if v.keys != nil && v.now().Sub(v.fetchedAt) < v.conf.RefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	keys, err := v.fetch()
+	if err != nil {
+		return nil, errors.Wrap(err, "JWKS download error")
+	}
+	v.keys, v.fetchedAt = keys, v.now()
+	if k := findKey(v.keys, kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func findKey(keys *jose.JSONWebKeySet, kid string) *jose.JSONWebKey {
+	if keys == nil {
+		return nil
+	}
+	for i, k := range keys.Keys {
+		if k.KeyID == kid && k.IsPublic() && (k.Use == "" || k.Use == "sig") {
+			return &keys.Keys[i]
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) fetch() (*jose.JSONWebKeySet, error) {
+	resp, err := v.client.Get(v.conf.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	keys := &jose.JSONWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// StringsClaim Read a claim that is either a string or a list of strings, e.g. "groups" or "roles"
+func StringsClaim(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestVerifier(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "ES256", Use: "sig"}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer srv.Close()
+
+	sign := func(kid string, claims interface{}) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: priv},
+			(&jose.SignerOptions{}).WithHeader("kid", kid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	now := time.Now()
+	std := jwt.Claims{
+		Issuer:   "https://idp",
+		Subject:  "alice",
+		Audience: jwt.Audience{"zaca"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	v := NewVerifier(Config{Issuer: "https://idp", Audience: "zaca", JWKSURL: srv.URL})
+	claims, err := v.Verify(sign("k1", map[string]interface{}{
+		"iss": std.Issuer, "sub": std.Subject, "aud": "zaca", "exp": std.Expiry, "roles": []string{"zaca-admin"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roles := StringsClaim(claims, "roles"); len(roles) != 1 || roles[0] != "zaca-admin" {
+		t.Errorf("unexpected roles %v", roles)
+	}
+
+	if _, err := v.Verify(sign("k2", std)); err == nil {
+		t.Error("unknown key id should be refused")
+	}
+	wrongAud := std
+	wrongAud.Audience = jwt.Audience{"other"}
+	if _, err := v.Verify(sign("k1", wrongAud)); err == nil {
+		t.Error("wrong audience should be refused")
+	}
+	expired := std
+	expired.Expiry = jwt.NewNumericDate(now.Add(-time.Hour))
+	if _, err := v.Verify(sign("k1", expired)); err == nil {
+		t.Error("expired token should be refused")
+	}
+
+	hs, _ := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}, nil)
+	raw, _ := jwt.Signed(hs).Claims(std).CompactSerialize()
+	if _, err := v.Verify(raw); err == nil {
+		t.Error("HMAC tokens should be refused")
+	}
+}
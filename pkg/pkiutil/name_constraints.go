@@ -0,0 +1,197 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkiutil
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// OidNameConstraints The OID for the name constraints extension (RFC 5280 4.2.1.10)
+var OidNameConstraints = asn1.ObjectIdentifier{2, 5, 29, 30}
+
+// NameConstraints Permitted and excluded subtrees of a CA certificate
+// URI constraints only restrict the host part of the URI, for SPIFFE IDs that is the trust domain
+type NameConstraints struct {
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	PermittedURIDomains []string
+	ExcludedURIDomains  []string
+}
+
+// IsEmpty No subtree is configured
+func (nc NameConstraints) IsEmpty() bool {
+	return len(nc.PermittedDNSDomains) == 0 && len(nc.ExcludedDNSDomains) == 0 &&
+		len(nc.PermittedURIDomains) == 0 && len(nc.ExcludedURIDomains) == 0
+}
+
+//	NameConstraints ::= SEQUENCE {
+//	     permittedSubtrees       [0]     GeneralSubtrees OPTIONAL,
+//	     excludedSubtrees        [1]     GeneralSubtrees OPTIONAL }
+//
+//	GeneralSubtree ::= SEQUENCE {
+//	     base                    GeneralName,
+//	     minimum         [0]     BaseDistance DEFAULT 0,
+//	     maximum         [1]     BaseDistance OPTIONAL }
+type generalSubtree struct {
+	Base asn1.RawValue
+}
+
+type nameConstraintsASN1 struct {
+	Permitted []generalSubtree `asn1:"optional,tag:0"`
+	Excluded  []generalSubtree `asn1:"optional,tag:1"`
+}
+
+func buildSubtrees(dns, uris []string) []generalSubtree {
+	var subtrees []generalSubtree
+	for _, d := range dns {
+		subtrees = append(subtrees, generalSubtree{Base: asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   oidTagMap[TypeDNS],
+			Bytes: []byte(d),
+		}})
+	}
+	for _, u := range uris {
+		subtrees = append(subtrees, generalSubtree{Base: asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   oidTagMap[TypeURI],
+			Bytes: []byte(u),
+		}})
+	}
+	return subtrees
+}
+
+// BuildNameConstraintsExtension builds the name constraints extension for a CA certificate.
+func BuildNameConstraintsExtension(nc NameConstraints, critical bool) (*pkix.Extension, error) {
+	if nc.IsEmpty() {
+		return nil, fmt.Errorf("empty name constraints")
+	}
+	for _, list := range [][]string{nc.PermittedDNSDomains, nc.ExcludedDNSDomains, nc.PermittedURIDomains, nc.ExcludedURIDomains} {
+		for _, d := range list {
+			if d == "" || strings.ContainsAny(d, "/:@ ") {
+				return nil, fmt.Errorf("invalid name constraint %q", d)
+			}
+		}
+	}
+	bs, err := asn1.Marshal(nameConstraintsASN1{
+		Permitted: buildSubtrees(nc.PermittedDNSDomains, nc.PermittedURIDomains),
+		Excluded:  buildSubtrees(nc.ExcludedDNSDomains, nc.ExcludedURIDomains),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pkix.Extension{Id: OidNameConstraints, Critical: critical, Value: bs}, nil
+}
+
+// HasNameConstraintsExtension Whether the extension list already carries name constraints
+func HasNameConstraintsExtension(exts []pkix.Extension) bool {
+	for _, ext := range exts {
+		if ext.Id.Equal(OidNameConstraints) {
+			return true
+		}
+	}
+	return false
+}
+
+// NameConstraintsFromCert Read the constraints parsed by crypto/x509
+func NameConstraintsFromCert(cert *x509.Certificate) NameConstraints {
+	return NameConstraints{
+		PermittedDNSDomains: cert.PermittedDNSDomains,
+		ExcludedDNSDomains:  cert.ExcludedDNSDomains,
+		PermittedURIDomains: cert.PermittedURIDomains,
+		ExcludedURIDomains:  cert.ExcludedURIDomains,
+	}
+}
+
+// CheckNameConstraints Verify that the hosts to be issued are allowed by the constraints of the CA certificate.
+// hosts are the same strings as cfssl SignRequest.Hosts: DNS names, IPs or URIs
+func CheckNameConstraints(ca *x509.Certificate, hosts []string) error {
+	for _, host := range hosts {
+		if err := checkHost(ca, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkHost(ca *x509.Certificate, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return checkIP(ca, ip)
+	}
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return fmt.Errorf("unparsable URI %q: %v", host, err)
+		}
+		return checkDomain("URI", host, u.Hostname(), ca.PermittedURIDomains, ca.ExcludedURIDomains)
+	}
+	if strings.Contains(host, "@") {
+		// Mailbox constraints are not used by ZACA
+		return nil
+	}
+	return checkDomain("DNS name", host, host, ca.PermittedDNSDomains, ca.ExcludedDNSDomains)
+}
+
+func checkIP(ca *x509.Certificate, ip net.IP) error {
+	for _, r := range ca.ExcludedIPRanges {
+		if r.Contains(ip) {
+			return fmt.Errorf("IP %s is excluded by CA name constraints", ip)
+		}
+	}
+	if len(ca.PermittedIPRanges) == 0 {
+		return nil
+	}
+	for _, r := range ca.PermittedIPRanges {
+		if r.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("IP %s is not permitted by CA name constraints", ip)
+}
+
+func checkDomain(kind, name, domain string, permitted, excluded []string) error {
+	for _, c := range excluded {
+		if MatchDomainConstraint(domain, c) {
+			return fmt.Errorf("%s %q is excluded by CA name constraint %q", kind, name, c)
+		}
+	}
+	if len(permitted) == 0 {
+		return nil
+	}
+	for _, c := range permitted {
+		if MatchDomainConstraint(domain, c) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q is not permitted by CA name constraints", kind, name)
+}
+
+// MatchDomainConstraint Same semantics as crypto/x509:
+// "example.com" matches example.com and its subdomains, ".example.com" only matches subdomains
+func MatchDomainConstraint(domain, constraint string) bool {
+	if constraint == "" {
+		return true
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	constraint = strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(domain, constraint) && len(domain) > len(constraint)
+	}
+	return domain == constraint || strings.HasSuffix(domain, "."+constraint)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkiutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBuildNameConstraintsExtension(t *testing.T) {
+	ext, err := BuildNameConstraintsExtension(NameConstraints{
+		PermittedDNSDomains: []string{"cluster1.example.com"},
+		PermittedURIDomains: []string{"site1"},
+		ExcludedDNSDomains:  []string{"admin.cluster1.example.com"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "intermediate"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtraExtensions:       []pkix.Extension{*ext},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.PermittedDNSDomainsCritical {
+		t.Error("name constraints should be critical")
+	}
+	if len(ca.PermittedURIDomains) != 1 || ca.PermittedURIDomains[0] != "site1" {
+		t.Errorf("unexpected permitted URI domains: %v", ca.PermittedURIDomains)
+	}
+	if len(ca.PermittedDNSDomains) != 1 || ca.PermittedDNSDomains[0] != "cluster1.example.com" {
+		t.Errorf("unexpected permitted DNS domains: %v", ca.PermittedDNSDomains)
+	}
+
+	cases := []struct {
+		hosts []string
+		ok    bool
+	}{
+		{[]string{"spiffe://site1/cluster1/unit"}, true},
+		{[]string{"a.cluster1.example.com", "127.0.0.1"}, true},
+		{[]string{"spiffe://site2/cluster1/unit"}, false},
+		{[]string{"example.com"}, false},
+		{[]string{"admin.cluster1.example.com"}, false},
+	}
+	for _, c := range cases {
+		err := CheckNameConstraints(ca, c.hosts)
+		if (err == nil) != c.ok {
+			t.Errorf("hosts %v: expected ok=%v, got %v", c.hosts, c.ok, err)
+		}
+	}
+
+	// Relying parties must reject leaves outside of the constraints as well
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafURI, _ := url.Parse("spiffe://site2/cluster1/unit")
+	leafDer, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{leafURI},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, leafKey.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDer)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots}); err == nil {
+		t.Error("leaf outside of the permitted URI domains should not verify")
+	}
+}
+
+func TestMatchDomainConstraint(t *testing.T) {
+	cases := []struct {
+		domain, constraint string
+		match              bool
+	}{
+		{"example.com", "example.com", true},
+		{"a.example.com", "example.com", true},
+		{"aexample.com", "example.com", false},
+		{"example.com", ".example.com", false},
+		{"a.example.com", ".example.com", true},
+		{"anything", "", true},
+	}
+	for _, c := range cases {
+		if got := MatchDomainConstraint(c.domain, c.constraint); got != c.match {
+			t.Errorf("MatchDomainConstraint(%q, %q) = %v", c.domain, c.constraint, got)
+		}
+	}
+}
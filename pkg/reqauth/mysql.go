@@ -0,0 +1,66 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reqauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MysqlStore Nonces in the request_nonce table, seen by every replica. The unique key refuses
+// a nonce used concurrently on two replicas, expiry uses the clock of the database
+type MysqlStore struct {
+	db *gorm.DB
+
+	mu     sync.Mutex
+	purged time.Time
+}
+
+// NewMysqlStore ...
+func NewMysqlStore(db *gorm.DB) *MysqlStore {
+	return &MysqlStore{db: db}
+}
+
+// Use ...
+func (s *MysqlStore) Use(nonce string, ttl time.Duration) error {
+	s.purge(ttl)
+	// Nonces are chosen by the client, the key has a fixed size
+	sum := sha256.Sum256([]byte(nonce))
+	res := s.db.Exec("INSERT IGNORE INTO request_nonce (nonce, expires_at) VALUES (?, NOW(6) + INTERVAL ? MICROSECOND)",
+		hex.EncodeToString(sum[:]), ttl.Microseconds())
+	if res.Error != nil {
+		return res.Error
+	}
+	// A row not purged yet is refused even if expired, its timestamp is no longer fresh anyway
+	if res.RowsAffected == 0 {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// purge Delete the expired nonces at most once per ttl, a failed purge is retried the next time
+func (s *MysqlStore) purge(ttl time.Duration) {
+	s.mu.Lock()
+	if time.Since(s.purged) < ttl {
+		s.mu.Unlock()
+		return
+	}
+	s.purged = time.Now()
+	s.mu.Unlock()
+	s.db.Exec("DELETE FROM request_nonce WHERE expires_at <= NOW(6)")
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reqauth Replay-safe request authentication.
+// The client signs the method, path, body hash, timestamp and a nonce,
+// the server checks the freshness window and refuses nonces it has already seen.
+package reqauth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ztalab/ZACA/pkg/memorycacher"
+	"github.com/ztalab/ZACA/pkg/signature"
+)
+
+// Request headers
+const (
+	HeaderScheme    = "X-Zaca-Auth"
+	HeaderTimestamp = "X-Zaca-Timestamp"
+	HeaderNonce     = "X-Zaca-Nonce"
+	HeaderSignature = "X-Zaca-Signature"
+)
+
+// Schemes
+const (
+	SchemeHMAC = "hmac-sha256" // HMAC with the auth key of the profile
+	SchemeCert = "cert"        // Signature with the private key of a certificate
+)
+
+var (
+	ErrMissingHeaders = errors.New("missing request authentication headers")
+	ErrStale          = errors.New("request timestamp outside of the freshness window")
+	ErrReplayed       = errors.New("request nonce already used")
+	ErrNonceCacheFull = errors.New("too many requests in the freshness window")
+	ErrBadSignature   = errors.New("invalid request signature")
+)
+
+// Request Authentication fields of an incoming request
+type Request struct {
+	Scheme    string
+	Method    string
+	Path      string
+	Body      []byte
+	Timestamp int64
+	Nonce     string
+	Signature string
+}
+
+// FromHTTP Read the authentication headers, ok is false if the request does not use this scheme
+func FromHTTP(r *http.Request, body []byte) (req *Request, ok bool, err error) {
+	scheme := r.Header.Get(HeaderScheme)
+	if scheme == "" {
+		return nil, false, nil
+	}
+	req = &Request{
+		Scheme:    scheme,
+		Method:    r.Method,
+		Path:      r.URL.RequestURI(),
+		Body:      body,
+		Nonce:     r.Header.Get(HeaderNonce),
+		Signature: r.Header.Get(HeaderSignature),
+	}
+	if req.Nonce == "" || req.Signature == "" {
+		return nil, true, ErrMissingHeaders
+	}
+	req.Timestamp, err = strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, true, ErrMissingHeaders
+	}
+	return req, true, nil
+}
+
+// Canonical The signed string
+func (r *Request) Canonical() []byte {
+	return CanonicalString(r.Method, r.Path, r.Body, r.Timestamp, r.Nonce)
+}
+
+// CanonicalString METHOD \n path \n hex(sha256(body)) \n timestamp \n nonce
+func CanonicalString(method, path string, body []byte, timestamp int64, nonce string) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		hex.EncodeToString(sum[:]),
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}, "\n"))
+}
+
+// HMAC hex encoded HMAC-SHA256 of the canonical string
+func HMAC(key, canonical []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignHMAC Set the authentication headers on an outgoing request
+func SignHMAC(r *http.Request, body, key []byte) error {
+	return sign(r, body, SchemeHMAC, func(canonical []byte) (string, error) {
+		return HMAC(key, canonical), nil
+	})
+}
+
+// SignWithKey Set the authentication headers signed with the private key of a certificate
+func SignWithKey(r *http.Request, body []byte, priv crypto.PrivateKey) error {
+	s := signature.NewSigner(priv)
+	return sign(r, body, SchemeCert, func(canonical []byte) (string, error) {
+		return s.Sign(canonical)
+	})
+}
+
+func sign(r *http.Request, body []byte, scheme string, f func([]byte) (string, error)) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	sig, err := f(CanonicalString(r.Method, r.URL.RequestURI(), body, ts, nonce))
+	if err != nil {
+		return err
+	}
+	r.Header.Set(HeaderScheme, scheme)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, sig)
+	return nil
+}
+
+// NewNonce 128 bit random nonce
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NonceStore Nonces already used, each is remembered for ttl
+type NonceStore interface {
+	// Use Record the nonce, ErrReplayed if it is already recorded
+	Use(nonce string, ttl time.Duration) error
+}
+
+// Verifier Checks signatures, freshness and nonce reuse
+type Verifier struct {
+	window time.Duration
+	nonces NonceStore
+	now    func() time.Time
+}
+
+// NewVerifier Nonces are remembered for twice the window, so any timestamp accepted can not be reused.
+// The cache is bounded by maxNonces, requests are refused when it is full.
+// It is local to the process, a request can be replayed against another replica
+func NewVerifier(window time.Duration, maxNonces int) *Verifier {
+	return NewVerifierWithStore(window, NewMemoryStore(window, maxNonces))
+}
+
+// NewVerifierWithStore Same as NewVerifier with nonces kept in store, e.g. a MysqlStore shared by the replicas
+func NewVerifierWithStore(window time.Duration, store NonceStore) *Verifier {
+	return &Verifier{
+		window: window,
+		nonces: store,
+		now:    time.Now,
+	}
+}
+
+// VerifyHMAC Verify a request signed with a shared key
+func (v *Verifier) VerifyHMAC(req *Request, key []byte) error {
+	if req.Scheme != SchemeHMAC {
+		return fmt.Errorf("unexpected auth scheme %q", req.Scheme)
+	}
+	if err := v.checkFresh(req); err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(HMAC(key, req.Canonical())), []byte(strings.ToLower(req.Signature))) {
+		return ErrBadSignature
+	}
+	return v.useNonce(req)
+}
+
+// VerifySignature Verify a request signed with the private key of the certificate
+func (v *Verifier) VerifySignature(req *Request, pub crypto.PublicKey) error {
+	if req.Scheme != SchemeCert {
+		return fmt.Errorf("unexpected auth scheme %q", req.Scheme)
+	}
+	if err := v.checkFresh(req); err != nil {
+		return err
+	}
+	valid, err := signature.NewVerifier(pub).Verify(req.Canonical(), req.Signature)
+	if err != nil || !valid {
+		return ErrBadSignature
+	}
+	return v.useNonce(req)
+}
+
+// UseNonce Record a nonce of a request authenticated by other means
+func (v *Verifier) UseNonce(nonce string) error {
+	return v.useNonce(&Request{Nonce: nonce})
+}
+
+func (v *Verifier) checkFresh(req *Request) error {
+	d := v.now().Sub(time.Unix(req.Timestamp, 0))
+	if d > v.window || d < -v.window {
+		return ErrStale
+	}
+	return nil
+}
+
+// useNonce Only called once the signature is valid, so forged requests can not fill the cache
+func (v *Verifier) useNonce(req *Request) error {
+	if req.Nonce == "" {
+		return ErrMissingHeaders
+	}
+	return v.nonces.Use(req.Nonce, 2*v.window)
+}
+
+// MemoryStore Nonces of a single process
+type MemoryStore struct {
+	cache *memorycacher.Cache
+}
+
+// NewMemoryStore Nonces expire after twice the window, at most maxNonces are kept
+func NewMemoryStore(window time.Duration, maxNonces int) *MemoryStore {
+	return &MemoryStore{cache: memorycacher.New(2*window, window, maxNonces)}
+}
+
+// Use ...
+func (s *MemoryStore) Use(nonce string, _ time.Duration) error {
+	if _, found := s.cache.Get(nonce); found {
+		return ErrReplayed
+	}
+	if err := s.cache.Add(nonce, struct{}{}, memorycacher.DefaultExpiration); err != nil {
+		if s.cache.IsReachMaxItemsCount() {
+			return ErrNonceCacheFull
+		}
+		return ErrReplayed
+	}
+	return nil
+}
+
+// DecodeAuthKey cfssl auth keys are hex encoded
+func DecodeAuthKey(key string) []byte {
+	if b, err := hex.DecodeString(key); err == nil {
+		return b
+	}
+	return []byte(key)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reqauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newRequest(t *testing.T, body []byte) *http.Request {
+	r, err := http.NewRequest(http.MethodPost, "https://ca/api/v1/cfssl/authsign", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	key := DecodeAuthKey("0123456789ABCDEF0123456789ABCDEF")
+	body := []byte(`{"profile":"default"}`)
+	r := newRequest(t, body)
+	if err := SignHMAC(r, body, key); err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewVerifier(time.Minute, 10)
+	req, ok, err := FromHTTP(r, body)
+	if !ok || err != nil {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	if err := v.VerifyHMAC(req, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyHMAC(req, key); err != ErrReplayed {
+		t.Errorf("expected replay to be refused, got %v", err)
+	}
+
+	tampered, _, _ := FromHTTP(r, []byte(`{"profile":"intermediate"}`))
+	if err := v.VerifyHMAC(tampered, key); err != ErrBadSignature {
+		t.Errorf("expected bad signature, got %v", err)
+	}
+
+	v.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if err := v.VerifyHMAC(req, key); err != ErrStale {
+		t.Errorf("expected stale request, got %v", err)
+	}
+
+	if _, ok, _ := FromHTTP(newRequest(t, body), body); ok {
+		t.Error("request without headers should not use the scheme")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	body := []byte(`{"serial":"1"}`)
+	v := NewVerifier(time.Minute, 2)
+	for i := 0; i < 3; i++ {
+		r := newRequest(t, body)
+		if err := SignWithKey(r, body, priv); err != nil {
+			t.Fatal(err)
+		}
+		req, _, _ := FromHTTP(r, body)
+		err := v.VerifySignature(req, priv.Public())
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && err != ErrNonceCacheFull {
+			t.Errorf("expected full nonce cache, got %v", err)
+		}
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r := newRequest(t, body)
+	_ = SignWithKey(r, body, other)
+	req, _, _ := FromHTTP(r, body)
+	if err := NewVerifier(time.Minute, 2).VerifySignature(req, priv.Public()); err != ErrBadSignature {
+		t.Errorf("expected bad signature, got %v", err)
+	}
+}
+
+// fakeConn Inserts affect one row unless the nonce was inserted before, as the primary key does
+type fakeConn struct {
+	nonces  map[interface{}]bool
+	queries []string
+}
+
+func (c *fakeConn) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries = append(c.queries, query)
+	if !strings.HasPrefix(query, "INSERT") {
+		return driver.RowsAffected(0), nil
+	}
+	if c.nonces[args[0]] {
+		return driver.RowsAffected(0), nil
+	}
+	c.nonces[args[0]] = true
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestSharedNonceStore(t *testing.T) {
+	conn := &fakeConn{nonces: make(map[interface{}]bool)}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}),
+		&gorm.Config{SkipDefaultTransaction: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMysqlStore(db)
+	// Two replicas of the service
+	first, second := NewVerifierWithStore(time.Minute, store), NewVerifierWithStore(time.Minute, store)
+
+	key := DecodeAuthKey("0123456789ABCDEF0123456789ABCDEF")
+	body := []byte(`{"profile":"default"}`)
+	r := newRequest(t, body)
+	if err := SignHMAC(r, body, key); err != nil {
+		t.Fatal(err)
+	}
+	req, _, _ := FromHTTP(r, body)
+	if err := first.VerifyHMAC(req, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.VerifyHMAC(req, key); err != ErrReplayed {
+		t.Errorf("expected replay on another replica to be refused, got %v", err)
+	}
+
+	if len(conn.nonces) != 1 {
+		t.Fatalf("%d nonces stored", len(conn.nonces))
+	}
+	for stored := range conn.nonces {
+		if stored == req.Nonce || len(stored.(string)) != 64 {
+			t.Errorf("nonce stored as %v, expected its hash", stored)
+		}
+	}
+	purges := 0
+	for _, q := range conn.queries {
+		if strings.HasPrefix(q, "DELETE") {
+			purges++
+		}
+	}
+	if purges != 1 {
+		t.Errorf("expired nonces purged %d times, expected once per ttl", purges)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package requestid Correlation ID of a request, taken from the client or generated
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// Header ...
+const Header = "X-Request-Id"
+
+const maxLen = 64
+
+// New 128 bit random ID
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Ensure Keep a valid client supplied ID or replace it, the ID is set on the request and the response
+func Ensure(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(Header)
+	if !valid(id) {
+		id = New()
+		r.Header.Set(Header, id)
+	}
+	w.Header().Set(Header, id)
+	return id
+}
+
+// Handler Middleware calling Ensure
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Ensure(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FromRequest ID of a request passed through Handler
+func FromRequest(r *http.Request) string {
+	if id := r.Header.Get(Header); valid(id) {
+		return id
+	}
+	return ""
+}
+
+// SourceIP Host part of the remote address
+func SourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
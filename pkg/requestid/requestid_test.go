@@ -0,0 +1,47 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var got string
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(Header, "abc-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got != "abc-123" || w.Header().Get(Header) != "abc-123" {
+		t.Errorf("client ID should be kept, got %q", got)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(Header, "bad\nid")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if len(got) != 32 || w.Header().Get(Header) != got {
+		t.Errorf("invalid ID should be replaced, got %q", got)
+	}
+
+	if ip := SourceIP(r); ip != "192.0.2.1" {
+		t.Errorf("unexpected source IP %q", ip)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package revocation RFC 5280 revocation reasons and the CRL entry extensions that carry them
+package revocation
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Reason codes, RFC 5280 section 5.3.1
+const (
+	Unspecified          = 0
+	KeyCompromise        = 1
+	CACompromise         = 2
+	AffiliationChanged   = 3
+	Superseded           = 4
+	CessationOfOperation = 5
+	CertificateHold      = 6
+	RemoveFromCRL        = 8
+	PrivilegeWithdrawn   = 9
+	AACompromise         = 10
+)
+
+var (
+	oidReasonCode     = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidInvalidityDate = asn1.ObjectIdentifier{2, 5, 29, 24}
+)
+
+var reasonNames = map[int]string{
+	Unspecified:          "unspecified",
+	KeyCompromise:        "keyCompromise",
+	CACompromise:         "cACompromise",
+	AffiliationChanged:   "affiliationChanged",
+	Superseded:           "superseded",
+	CessationOfOperation: "cessationOfOperation",
+	CertificateHold:      "certificateHold",
+	RemoveFromCRL:        "removeFromCRL",
+	PrivilegeWithdrawn:   "privilegeWithdrawn",
+	AACompromise:         "aACompromise",
+}
+
+// ParseReason Reason code of a name as spelled in RFC 5280, case, '-' and '_' are ignored
+func ParseReason(name string) (int, error) {
+	key := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for code, n := range reasonNames {
+		if strings.ToLower(n) == key {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revocation reason %q", name)
+}
+
+// ReasonString RFC 5280 name of a reason code
+func ReasonString(code int) string {
+	if name, ok := reasonNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason(%d)", code)
+}
+
+// CheckRevokeReason Whether a certificate may be revoked with the reason,
+// removeFromCRL only appears in delta CRLs and is not a reason to revoke
+func CheckRevokeReason(code int) error {
+	if _, ok := reasonNames[code]; !ok || code == RemoveFromCRL {
+		return fmt.Errorf("%s is not a revocation reason", ReasonString(code))
+	}
+	return nil
+}
+
+// Entry A revoked certificate
+type Entry struct {
+	SerialNumber *big.Int
+	RevokedAt    time.Time
+	Reason       int
+	// InvalidityDate Known or suspected time the key was compromised or the certificate became invalid
+	InvalidityDate *time.Time
+}
+
+// ReasonCodeExtension CRL entry reasonCode extension
+func ReasonCodeExtension(code int) (pkix.Extension, error) {
+	value, err := asn1.Marshal(asn1.Enumerated(code))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidReasonCode, Value: value}, nil
+}
+
+// InvalidityDateExtension CRL entry invalidityDate extension, also valid as an OCSP single extension
+func InvalidityDateExtension(t time.Time) (pkix.Extension, error) {
+	value, err := asn1.MarshalWithParams(t.UTC(), "generalized")
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidInvalidityDate, Value: value}, nil
+}
+
+// ParseInvalidityDate Find the invalidityDate among extensions
+func ParseInvalidityDate(exts []pkix.Extension) (*time.Time, error) {
+	for _, ext := range exts {
+		if !ext.Id.Equal(oidInvalidityDate) {
+			continue
+		}
+		var t time.Time
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &t, "generalized"); err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+	return nil, nil
+}
+
+// Extensions CRL entry extensions of e, reasonCode is left out for unspecified as RFC 5280 recommends
+func (e Entry) Extensions() ([]pkix.Extension, error) {
+	var exts []pkix.Extension
+	if e.Reason != Unspecified {
+		ext, err := ReasonCodeExtension(e.Reason)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	if e.InvalidityDate != nil {
+		ext, err := InvalidityDateExtension(*e.InvalidityDate)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// CreateCRL DER encoded CRL of entries signed by issuer
+func CreateCRL(issuer *x509.Certificate, key crypto.Signer, number *big.Int, thisUpdate, nextUpdate time.Time, entries []Entry) ([]byte, error) {
+	revoked := make([]pkix.RevokedCertificate, 0, len(entries))
+	for _, e := range entries {
+		exts, err := e.Extensions()
+		if err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   e.SerialNumber,
+			RevocationTime: e.RevokedAt.UTC(),
+			Extensions:     exts,
+		})
+	}
+	return x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: revoked,
+		Number:              number,
+		ThisUpdate:          thisUpdate,
+		NextUpdate:          nextUpdate,
+	}, issuer, key)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revocation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseReason(t *testing.T) {
+	for name, want := range map[string]int{
+		"keyCompromise":    KeyCompromise,
+		"cacompromise":     CACompromise,
+		"certificate-hold": CertificateHold,
+		"AA_COMPROMISE":    AACompromise,
+	} {
+		got, err := ParseReason(name)
+		if err != nil || got != want {
+			t.Errorf("ParseReason(%q) = %d, %v, want %d", name, got, err, want)
+		}
+	}
+	if _, err := ParseReason("lost"); err == nil {
+		t.Error("unknown reason should fail")
+	}
+	if err := CheckRevokeReason(RemoveFromCRL); err == nil {
+		t.Error("removeFromCRL should not be accepted for revocation")
+	}
+	if err := CheckRevokeReason(7); err == nil {
+		t.Error("unassigned reason code 7 should not be accepted")
+	}
+}
+
+func TestCreateCRL(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+
+	now := time.Now().Truncate(time.Second)
+	invalid := now.Add(-24 * time.Hour)
+	crlDER, err := CreateCRL(ca, priv, big.NewInt(7), now, now.Add(time.Hour), []Entry{
+		{SerialNumber: big.NewInt(100), RevokedAt: now, Reason: KeyCompromise, InvalidityDate: &invalid},
+		{SerialNumber: big.NewInt(101), RevokedAt: now, Reason: Unspecified},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(crlDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.CheckCRLSignature(crl); err != nil {
+		t.Fatal(err)
+	}
+	revoked := crl.TBSCertList.RevokedCertificates
+	if len(revoked) != 2 {
+		t.Fatalf("got %d entries, want 2", len(revoked))
+	}
+
+	var reason asn1.Enumerated
+	var found bool
+	for _, ext := range revoked[0].Extensions {
+		if ext.Id.Equal(oidReasonCode) {
+			found = true
+			if _, err := asn1.Unmarshal(ext.Value, &reason); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !found || int(reason) != KeyCompromise {
+		t.Errorf("reason code = %d (present %v), want %d", reason, found, KeyCompromise)
+	}
+	date, err := ParseInvalidityDate(revoked[0].Extensions)
+	if err != nil || date == nil || !date.Equal(invalid) {
+		t.Errorf("invalidity date = %v, %v, want %v", date, err, invalid)
+	}
+	if len(revoked[1].Extensions) != 0 {
+		t.Errorf("unspecified entry should carry no extensions, got %d", len(revoked[1].Extensions))
+	}
+}
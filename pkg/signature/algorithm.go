@@ -0,0 +1,165 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm JWS algorithm names (RFC 7518, RFC 8037)
+type Algorithm string
+
+const (
+	RS256 Algorithm = "RS256" // RSASSA-PKCS1-v1_5 SHA-256
+	PS256 Algorithm = "PS256" // RSASSA-PSS SHA-256
+	ES256 Algorithm = "ES256" // ECDSA P-256 SHA-256
+	ES384 Algorithm = "ES384" // ECDSA P-384 SHA-384
+	ES512 Algorithm = "ES512" // ECDSA P-521 SHA-512
+	EdDSA Algorithm = "EdDSA" // Ed25519
+)
+
+var errAlgoNotSupported = errors.New("algo not supported")
+
+// DefaultAlgorithm Algorithm used for a key when none is requested, PSS for RSA keys
+func DefaultAlgorithm(pub crypto.PublicKey) (Algorithm, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(pub.Curve)
+	case *rsa.PublicKey:
+		return PS256, nil
+	case ed25519.PublicKey:
+		return EdDSA, nil
+	}
+	return "", errAlgoNotSupported
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (Algorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return ES256, nil
+	case elliptic.P384():
+		return ES384, nil
+	case elliptic.P521():
+		return ES512, nil
+	}
+	return "", errAlgoNotSupported
+}
+
+func (alg Algorithm) hash() crypto.Hash {
+	switch alg {
+	case ES384:
+		return crypto.SHA384
+	case ES512:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+func digest(h crypto.Hash, text []byte) []byte {
+	hh := h.New()
+	hh.Write(text)
+	return hh.Sum(nil)
+}
+
+// signRaw Signature bytes as defined by JWS, ECDSA signatures are the fixed size concatenation of r and s.
+// The key signs through crypto.Signer, so that keys held by an HSM sign as well
+func signRaw(priv crypto.PrivateKey, alg Algorithm, text []byte) ([]byte, error) {
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errAlgoNotSupported
+	}
+	switch pub := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		if want, err := ecdsaAlgorithm(pub.Curve); err != nil || want != alg {
+			return nil, errAlgoNotSupported
+		}
+		der, err := signer.Sign(rand.Reader, digest(alg.hash(), text), alg.hash())
+		if err != nil {
+			return nil, err
+		}
+		var rs struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(der, &rs); err != nil || len(rest) > 0 {
+			return nil, errors.New("malformed ECDSA signature")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		out := make([]byte, 2*size)
+		rs.R.FillBytes(out[:size])
+		rs.S.FillBytes(out[size:])
+		return out, nil
+	case *rsa.PublicKey:
+		switch alg {
+		case RS256:
+			return signer.Sign(rand.Reader, digest(crypto.SHA256, text), crypto.SHA256)
+		case PS256:
+			return signer.Sign(rand.Reader, digest(crypto.SHA256, text),
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+		}
+	case ed25519.PublicKey:
+		if alg == EdDSA {
+			return signer.Sign(rand.Reader, text, crypto.Hash(0))
+		}
+	}
+	return nil, errAlgoNotSupported
+}
+
+// verifyRaw The algorithm has to match the key type, a signature can not downgrade the verification
+func verifyRaw(pub crypto.PublicKey, alg Algorithm, text, sig []byte) (bool, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if want, err := ecdsaAlgorithm(pub.Curve); err != nil || want != alg {
+			return false, errAlgoNotSupported
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false, nil
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest(alg.hash(), text), r, s), nil
+	case *rsa.PublicKey:
+		switch alg {
+		case RS256:
+			return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest(crypto.SHA256, text), sig) == nil, nil
+		case PS256:
+			return rsa.VerifyPSS(pub, crypto.SHA256, digest(crypto.SHA256, text), sig,
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil, nil
+		}
+	case ed25519.PublicKey:
+		if alg == EdDSA {
+			return ed25519.Verify(pub, text, sig), nil
+		}
+	case *ed25519.PublicKey:
+		return verifyRaw(*pub, alg, text, sig)
+	}
+	return false, errAlgoNotSupported
+}
+
+func publicKey(priv crypto.PrivateKey) crypto.PublicKey {
+	if s, ok := priv.(crypto.Signer); ok {
+		return s.Public()
+	}
+	if p, ok := priv.(*ed25519.PrivateKey); ok {
+		return p.Public()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type jwsHeader struct {
+	Alg Algorithm `json:"alg"`
+	Kid string    `json:"kid,omitempty"`
+	Typ string    `json:"typ,omitempty"`
+}
+
+// SignJWS JWS compact serialization (RFC 7515) of the payload
+func (s *Signer) SignJWS(payload []byte) (string, error) {
+	return s.SignJWSWithKeyID(payload, "")
+}
+
+// SignJWSWithKeyID Same as SignJWS with a "kid" header
+func (s *Signer) SignJWSWithKeyID(payload []byte, kid string) (string, error) {
+	return s.signJWS(payload, jwsHeader{Alg: s.alg, Kid: kid})
+}
+
+// SignJWSWithType Same as SignJWS with a "typ" header, e.g. "zaca-ceremony+jws"
+func (s *Signer) SignJWSWithType(payload []byte, typ string) (string, error) {
+	return s.signJWS(payload, jwsHeader{Alg: s.alg, Typ: typ})
+}
+
+func (s *Signer) signJWS(payload []byte, h jwsHeader) (string, error) {
+	if s.alg == "" {
+		return "", errAlgoNotSupported
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := signRaw(s.priv, s.alg, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJWS Verify a JWS compact serialization and return its payload
+func (v *Verifier) VerifyJWS(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWS")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed JWS header")
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.Wrap(err, "malformed JWS header")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed JWS payload")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed JWS signature")
+	}
+	valid, err := verifyRaw(v.pub, header.Alg, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, errors.New("invalid JWS signature")
+	}
+	return payload, nil
+}
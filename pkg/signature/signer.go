@@ -16,33 +16,48 @@ package signature
 import (
 	"crypto"
 	"crypto/ecdsa"
-	"crypto/rsa"
+	"encoding/base64"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
+// Encoded signatures are "<alg>:<base64url signature>", signatures without
+// an algorithm are the legacy hex encoded ECDSA r,s pairs
+const algSeparator = ":"
+
 // Signer ...
 type Signer struct {
 	priv crypto.PrivateKey
+	alg  Algorithm
 }
 
-// NewSigner ...
+// NewSigner Uses the default algorithm of the key, RSA keys sign with PSS
 func NewSigner(priv crypto.PrivateKey) *Signer {
-	return &Signer{priv: priv}
+	alg, _ := DefaultAlgorithm(publicKey(priv))
+	return &Signer{priv: priv, alg: alg}
+}
+
+// NewSignerWithAlgorithm Select the algorithm, e.g. RS256 for RSA-PKCS1v15
+func NewSignerWithAlgorithm(priv crypto.PrivateKey, alg Algorithm) *Signer {
+	return &Signer{priv: priv, alg: alg}
+}
+
+// Algorithm ...
+func (s *Signer) Algorithm() Algorithm {
+	return s.alg
 }
 
 // Sign
 func (s *Signer) Sign(text []byte) (sign string, err error) {
-	switch priv := s.priv.(type) {
-	case *ecdsa.PrivateKey:
-		sign, err = EcdsaSign(priv, text)
-		return
-	case *rsa.PrivateKey:
-		// Todo supports RSA
-		return "", errors.New("algo not supported")
-	default:
-		return "", errors.New("algo not supported")
+	if s.alg == "" {
+		return "", errAlgoNotSupported
+	}
+	sig, err := signRaw(s.priv, s.alg, text)
+	if err != nil {
+		return "", err
 	}
+	return string(s.alg) + algSeparator + base64.RawURLEncoding.EncodeToString(sig), nil
 }
 
 // Verifier ...
@@ -57,12 +72,17 @@ func NewVerifier(pub crypto.PublicKey) *Verifier {
 
 // Verify Verify signature
 func (v *Verifier) Verify(text []byte, sign string) (bool, error) {
-	switch pub := v.pub.(type) {
-	case *ecdsa.PublicKey:
-		return EcdsaVerify(text, sign, pub)
-	case *rsa.PublicKey:
-		// Todo supports RSA
-	default:
+	i := strings.Index(sign, algSeparator)
+	if i < 0 {
+		// Legacy ECDSA signature
+		if pub, ok := v.pub.(*ecdsa.PublicKey); ok {
+			return EcdsaVerify(text, sign, pub)
+		}
+		return false, errAlgoNotSupported
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sign[i+1:])
+	if err != nil {
+		return false, errors.Wrap(err, "decode fail")
 	}
-	return false, errors.New("algo not supported")
+	return verifyRaw(v.pub, Algorithm(sign[:i]), text, sig)
 }
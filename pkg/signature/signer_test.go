@@ -14,9 +14,16 @@ limitations under the License.
 package signature
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"fmt"
 	"github.com/ztalab/ZACA/pkg/keygen"
+	"strings"
 	"testing"
 )
 
@@ -46,3 +53,118 @@ func TestEcdsaVerify(t *testing.T) {
 	}
 	fmt.Println(result)
 }
+
+func TestSignAlgorithms(t *testing.T) {
+	text := []byte("Test")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	cases := []struct {
+		signer *Signer
+		pub    crypto.PublicKey
+		alg    Algorithm
+	}{
+		{NewSigner(rsaKey), rsaKey.Public(), PS256},
+		{NewSignerWithAlgorithm(rsaKey, RS256), rsaKey.Public(), RS256},
+		{NewSigner(edKey), edKey.Public(), EdDSA},
+		{NewSigner(ecKey), ecKey.Public(), ES384},
+	}
+	for _, c := range cases {
+		sign, err := c.signer.Sign(text)
+		if err != nil {
+			t.Fatalf("%s: %v", c.alg, err)
+		}
+		if !strings.HasPrefix(sign, string(c.alg)+":") {
+			t.Errorf("%s: missing algorithm in %s", c.alg, sign)
+		}
+		v := NewVerifier(c.pub)
+		if ok, err := v.Verify(text, sign); !ok || err != nil {
+			t.Errorf("%s: verification failed: %v", c.alg, err)
+		}
+		if ok, _ := v.Verify([]byte("Other"), sign); ok {
+			t.Errorf("%s: tampered text verified", c.alg)
+		}
+	}
+
+	// An algorithm not matching the key is refused
+	sign, _ := NewSigner(edKey).Sign(text)
+	if ok, _ := NewVerifier(edKey.Public()).Verify(text, strings.Replace(sign, "EdDSA", "RS256", 1)); ok {
+		t.Error("signature verified with the wrong algorithm")
+	}
+
+	// Legacy hex encoded ECDSA signatures are still accepted
+	legacyKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	legacy, _ := EcdsaSign(legacyKey, text)
+	if ok, err := NewVerifier(legacyKey.Public()).Verify(text, legacy); !ok || err != nil {
+		t.Errorf("legacy signature failed: %v", err)
+	}
+}
+
+func TestJWS(t *testing.T) {
+	payload := []byte(`{"ceremony":"root-init"}`)
+	for _, priv := range []crypto.Signer{
+		func() crypto.Signer { k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader); return k }(),
+		func() crypto.Signer { k, _ := rsa.GenerateKey(rand.Reader, 2048); return k }(),
+		func() crypto.Signer { _, k, _ := ed25519.GenerateKey(rand.Reader); return k }(),
+	} {
+		token, err := NewSigner(priv).SignJWSWithKeyID(payload, "root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewVerifier(priv.Public()).VerifyJWS(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(payload) {
+			t.Errorf("unexpected payload %s", got)
+		}
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("{}")) + "." + parts[2]
+		if _, err := NewVerifier(priv.Public()).VerifyJWS(tampered); err == nil {
+			t.Error("tampered JWS verified")
+		}
+	}
+}
+
+// opaque Key only reachable through crypto.Signer, as PKCS#11 keys are
+type opaque struct {
+	crypto.Signer
+}
+
+func TestOpaqueSigner(t *testing.T) {
+	text := []byte("Test")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	for _, c := range []struct {
+		key crypto.Signer
+		alg Algorithm
+	}{
+		{rsaKey, PS256},
+		{rsaKey, RS256},
+		{edKey, EdDSA},
+		{ecKey, ES512},
+	} {
+		signer := NewSignerWithAlgorithm(opaque{c.key}, c.alg)
+		sign, err := signer.Sign(text)
+		if err != nil {
+			t.Fatalf("%s: %v", c.alg, err)
+		}
+		if ok, err := NewVerifier(c.key.Public()).Verify(text, sign); !ok || err != nil {
+			t.Errorf("%s: verification failed: %v", c.alg, err)
+		}
+		token, err := signer.SignJWS(text)
+		if err != nil {
+			t.Fatalf("%s: %v", c.alg, err)
+		}
+		if _, err := NewVerifier(c.key.Public()).VerifyJWS(token); err != nil {
+			t.Errorf("%s: JWS verification failed: %v", c.alg, err)
+		}
+	}
+	if NewSigner(opaque{ecKey}).Algorithm() != ES512 {
+		t.Error("default algorithm of an opaque key")
+	}
+	if _, err := NewSignerWithAlgorithm(opaque{ecKey}, ES256).Sign(text); err == nil {
+		t.Error("an algorithm not matching the key must be refused")
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+)
+
+type peerIdentityKey struct{}
+
+// WithPeerIdentity Store the verified identity of the peer in the context
+func WithPeerIdentity(ctx context.Context, id *IDGIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, id)
+}
+
+// PeerIdentityFromContext Identity of the peer verified during the TLS handshake
+func PeerIdentityFromContext(ctx context.Context) (*IDGIdentity, bool) {
+	id, ok := ctx.Value(peerIdentityKey{}).(*IDGIdentity)
+	return id, ok && id != nil
+}
+
+// PeerIdentityFromTLS Only verified chains are considered, unverified client certificates carry no identity
+func PeerIdentityFromTLS(state *tls.ConnectionState) (*IDGIdentity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	leaf := state.VerifiedChains[0][0]
+	if len(leaf.URIs) != 1 {
+		return nil, false
+	}
+	id, err := ParseIDGIdentity(leaf.URIs[0].String())
+	if err != nil {
+		return nil, false
+	}
+	return id, true
+}
+
+// PeerIdentityHandler Expose the verified peer SPIFFE identity to the handlers through the request context
+func PeerIdentityHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := PeerIdentityFromTLS(r.TLS); ok {
+			r = r.WithContext(WithPeerIdentity(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
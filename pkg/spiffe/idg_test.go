@@ -14,7 +14,11 @@ limitations under the License.
 package spiffe
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"testing"
 )
 
@@ -31,3 +35,23 @@ func TestParseIDGIdentity(t *testing.T) {
 		fmt.Println(id.String())
 	}
 }
+
+func TestPeerIdentityFromTLS(t *testing.T) {
+	u, _ := url.Parse("spiffe://siteid/clusterid/appid")
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{u}}},
+	}
+	if _, ok := PeerIdentityFromTLS(state); ok {
+		t.Error("unverified peer certificates should carry no identity")
+	}
+	state.VerifiedChains = [][]*x509.Certificate{state.PeerCertificates}
+	id, ok := PeerIdentityFromTLS(state)
+	if !ok || id.UniqueID != "appid" {
+		t.Errorf("unexpected identity %v", id)
+	}
+
+	ctx := WithPeerIdentity(context.Background(), id)
+	if got, ok := PeerIdentityFromContext(ctx); !ok || got.String() != u.String() {
+		t.Errorf("unexpected identity from context %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlspolicy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Pinning Verification of servers presenting CA certificates, which carry no host names.
+// The chain is verified against Roots, and any certificate of the chain must match one of the pins.
+// Without Roots, the server certificate must chain to a presented certificate matching one of the pins
+type Pinning struct {
+	Roots      *x509.CertPool
+	Pins       [][]byte // SHA-256 of the subject public key info
+	ServerName bool     // Also check the host name against the server certificate
+}
+
+// ParsePins Decode base64 SHA-256 SPKI pins, as printed by `openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`.
+// The "sha256/" prefix of HPKP is accepted
+func ParsePins(pins []string) ([][]byte, error) {
+	var out [][]byte
+	for _, pin := range pins {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q, want base64 of a SHA-256 digest", pin)
+		}
+		out = append(out, raw)
+	}
+	return out, nil
+}
+
+// SPKIPin base64 SHA-256 of the subject public key info of cert
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Enabled Whether anything is verified
+func (p *Pinning) Enabled() bool {
+	return p.Roots != nil || len(p.Pins) > 0
+}
+
+// VerifyConnection Check the peer chain of a handshake, for tls.Config.VerifyConnection
+func (p *Pinning) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server presented no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	roots := p.Roots
+	if roots == nil {
+		// Only the pinned certificates are trusted, the others presented are mere intermediates
+		roots = x509.NewCertPool()
+		pinned := 0
+		for _, cert := range cs.PeerCertificates {
+			if p.pinned(cert) {
+				roots.AddCert(cert)
+				pinned++
+			}
+		}
+		if pinned == 0 {
+			return p.pinError(leaf)
+		}
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if p.ServerName {
+		opts.DNSName = cs.ServerName
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return fmt.Errorf("tls: server certificate not trusted: %w", err)
+	}
+	if len(p.Pins) == 0 {
+		return nil
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if p.pinned(cert) {
+				return nil
+			}
+		}
+	}
+	return p.pinError(leaf)
+}
+
+func (p *Pinning) pinned(cert *x509.Certificate) bool {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	for _, pin := range p.Pins {
+		if bytes.Equal(sum[:], pin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pinning) pinError(leaf *x509.Certificate) error {
+	return fmt.Errorf("tls: no certificate of the server chain matches the SPKI pins, server key pin %s", SPKIPin(leaf))
+}
+
+// ClientConfig Client configuration verifying with the pinning instead of the system roots.
+// getClientCertificate, when set, presents a certificate for mutual TLS
+func (p *Pinning) ClientConfig(getClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Verified by VerifyConnection, the standard check requires host names CA certificates do not have
+		InsecureSkipVerify:   true, //nolint:gosec
+		VerifyConnection:     p.VerifyConnection,
+		GetClientCertificate: getClientCertificate,
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlspolicy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCA(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = tpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestPinning(t *testing.T) {
+	root, rootKey := newCA(t, "root", nil, nil)
+	upper, upperKey := newCA(t, "upper", root, rootKey)
+	server, _ := newCA(t, "server", upper, upperKey)
+	other, _ := newCA(t, "other", nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other)
+	rootPin, _ := ParsePins([]string{SPKIPin(root)})
+	otherPin, _ := ParsePins([]string{"sha256/" + SPKIPin(other)})
+
+	state := tls.ConnectionState{ServerName: "upper.example.com", PeerCertificates: []*x509.Certificate{server, upper}}
+	cases := []struct {
+		name string
+		p    *Pinning
+		ok   bool
+	}{
+		{"roots", &Pinning{Roots: roots}, true},
+		{"untrusted", &Pinning{Roots: otherRoots}, false},
+		{"roots and pin", &Pinning{Roots: roots, Pins: rootPin}, true},
+		{"roots and wrong pin", &Pinning{Roots: roots, Pins: otherPin}, false},
+		{"root pin without the root bundle", &Pinning{Pins: append(otherPin, rootPin[0])}, false},
+		{"host name", &Pinning{Roots: roots, ServerName: true}, false},
+	}
+	for _, c := range cases {
+		err := c.p.VerifyConnection(state)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+
+	// Without roots only the presented certificates are pinned, and the server certificate must chain to them
+	upperPin, _ := ParsePins([]string{SPKIPin(upper)})
+	if err := (&Pinning{Pins: upperPin}).VerifyConnection(state); err != nil {
+		t.Errorf("pin of the presented intermediate: %v", err)
+	}
+	forged := tls.ConnectionState{ServerName: "upper.example.com", PeerCertificates: []*x509.Certificate{other, upper}}
+	if err := (&Pinning{Pins: upperPin}).VerifyConnection(forged); err == nil {
+		t.Error("an unrelated server certificate followed by the pinned one should fail")
+	}
+	serverPin, _ := ParsePins([]string{SPKIPin(server)})
+	if err := (&Pinning{Pins: serverPin}).VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}}); err != nil {
+		t.Errorf("pin of the server certificate: %v", err)
+	}
+	if err := (&Pinning{Roots: roots}).VerifyConnection(tls.ConnectionState{}); err == nil {
+		t.Error("a server without certificate should fail")
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	if _, err := ParsePins([]string{"not base64"}); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := ParsePins([]string{base64.StdEncoding.EncodeToString([]byte("short"))}); err == nil {
+		t.Error("expected error for a short digest")
+	}
+	if (&Pinning{}).Enabled() {
+		t.Error("empty pinning should be disabled")
+	}
+}
+
+func TestPinningClientConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	pins, _ := ParsePins([]string{SPKIPin(srv.Certificate())})
+	other, _ := newCA(t, "other", nil, nil)
+	wrong, _ := ParsePins([]string{SPKIPin(other)})
+
+	for _, c := range []struct {
+		pins [][]byte
+		ok   bool
+	}{{pins, true}, {wrong, false}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: (&Pinning{Pins: c.pins}).ClientConfig(nil)}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != c.ok {
+			t.Errorf("pinned handshake: unexpected result %v", err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// Client certificate modes
+const (
+	ClientAuthNone     = "none"     // No client certificate is requested
+	ClientAuthRequest  = "request"  // Requested but not verified, left to the handlers
+	ClientAuthOptional = "optional" // Verified against the trust bundle if given
+	ClientAuthRequire  = "require"  // Required and verified against the trust bundle
+)
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+var clientAuths = map[string]tls.ClientAuthType{
+	"":                 tls.NoClientCert,
+	ClientAuthNone:     tls.NoClientCert,
+	ClientAuthRequest:  tls.RequestClientCert,
+	ClientAuthOptional: tls.VerifyClientCertIfGiven,
+	ClientAuthRequire:  tls.RequireAndVerifyClientCert,
+}
+
+// Policy Parsed TLS server settings
+type Policy struct {
+	MinVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	NextProtos       []string
+	ClientAuth       tls.ClientAuthType
+}
+
+// New Parse the TLS policy from configuration strings, empty values keep the defaults
+func New(minVersion string, cipherSuites, curvePreferences, alpn []string, clientAuth string) (*Policy, error) {
+	p := &Policy{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: alpn,
+	}
+	if minVersion != "" {
+		v, ok := versions[strings.TrimPrefix(strings.ToLower(minVersion), "tls")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min version %q", minVersion)
+		}
+		p.MinVersion = v
+	}
+
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	p.CipherSuites = suites
+
+	for _, name := range curvePreferences {
+		id, ok := curves[strings.ToUpper(strings.ReplaceAll(name, "-", ""))]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", name)
+		}
+		p.CurvePreferences = append(p.CurvePreferences, id)
+	}
+
+	ca, ok := clientAuths[strings.ToLower(clientAuth)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported client auth mode %q", clientAuth)
+	}
+	p.ClientAuth = ca
+	return p, nil
+}
+
+// ParseCipherSuites Map IANA cipher suite names, insecure suites are rejected.
+// TLS 1.3 suites are not configurable in crypto/tls and are skipped.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	var ids []uint16
+	for _, name := range names {
+		suite := findCipherSuite(name)
+		if suite == nil {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %q", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			continue
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+func findCipherSuite(name string) *tls.CipherSuite {
+	for _, suite := range tls.CipherSuites() {
+		if strings.EqualFold(suite.Name, name) {
+			return suite
+		}
+	}
+	return nil
+}
+
+// VerifiesClient Whether client certificates are verified during the handshake
+func (p *Policy) VerifiesClient() bool {
+	return p.ClientAuth == tls.VerifyClientCertIfGiven || p.ClientAuth == tls.RequireAndVerifyClientCert
+}
+
+// ServerConfig Build the listener configuration.
+// clientCAs is called per handshake so that rotated trust bundles are picked up without restarting.
+func (p *Policy) ServerConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+	clientCAs func() (*x509.CertPool, error)) *tls.Config {
+	cfg := &tls.Config{
+		GetCertificate:   getCertificate,
+		MinVersion:       p.MinVersion,
+		CipherSuites:     p.CipherSuites,
+		CurvePreferences: p.CurvePreferences,
+		NextProtos:       p.NextProtos,
+		ClientAuth:       p.ClientAuth,
+	}
+	if p.VerifiesClient() && clientCAs != nil {
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			pool, err := clientCAs()
+			if err != nil {
+				return nil, err
+			}
+			c := cfg.Clone()
+			c.GetConfigForClient = nil
+			c.ClientCAs = pool
+			return c, nil
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	p, err := New("1.3", []string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_AES_128_GCM_SHA256",
+	}, []string{"X25519", "P-256"}, []string{"h2"}, ClientAuthRequire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MinVersion != tls.VersionTLS13 {
+		t.Errorf("unexpected min version %x", p.MinVersion)
+	}
+	if len(p.CipherSuites) != 1 || p.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites %v", p.CipherSuites)
+	}
+	if len(p.CurvePreferences) != 2 || p.CurvePreferences[1] != tls.CurveP256 {
+		t.Errorf("unexpected curves %v", p.CurvePreferences)
+	}
+	if !p.VerifiesClient() {
+		t.Error("client certificates should be verified")
+	}
+
+	invalid := []func() error{
+		func() error { _, err := New("1.0", nil, nil, nil, ""); return err },
+		func() error { _, err := New("", []string{"TLS_RSA_WITH_RC4_128_SHA"}, nil, nil, ""); return err },
+		func() error { _, err := New("", nil, []string{"P224"}, nil, ""); return err },
+		func() error { _, err := New("", nil, nil, nil, "always"); return err },
+	}
+	for i, f := range invalid {
+		if f() == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	p, err := New("", nil, nil, nil, ClientAuthOptional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	cfg := p.ServerConfig(nil, func() (*x509.CertPool, error) {
+		return pool, nil
+	})
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	c, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientCAs != pool {
+		t.Error("client CAs should be loaded per handshake")
+	}
+
+	p, _ = New("", nil, nil, nil, ClientAuthRequest)
+	if cfg := p.ServerConfig(nil, nil); cfg.GetConfigForClient != nil {
+		t.Error("unverified client auth should not load client CAs")
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package topology Tree of the CAs, from the root to every subordinate
+package topology
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Node roles
+const (
+	RoleRoot         = "root"
+	RoleIntermediate = "intermediate"
+)
+
+// Node status
+const (
+	StatusHealthy     = "healthy"
+	StatusExpiring    = "expiring"    // The certificate expires within the warning period
+	StatusExpired     = "expired"     // No valid certificate
+	StatusRevoked     = "revoked"     // Revoked by the upper CA
+	StatusUnreachable = "unreachable" // The CA did not answer, its subtree is unknown
+	StatusUnknown     = "unknown"     // No address to ask, or an ancestor outside our reach
+)
+
+// Cert Certificate of a CA, as issued by its upper CA
+type Cert struct {
+	SN       string    `json:"sn"`
+	AKI      string    `json:"aki"`
+	NotAfter time.Time `json:"not_after"`
+	Status   string    `json:"status"` // good / revoked
+}
+
+// Node A CA and its subordinates
+type Node struct {
+	Name    string  `json:"name"`
+	Role    string  `json:"role"`
+	URL     string  `json:"url,omitempty"`
+	Current bool    `json:"current"` // The CA that answered the query
+	Certs   []*Cert `json:"certs,omitempty"`
+
+	Status   string     `json:"status"`
+	NotAfter *time.Time `json:"not_after,omitempty"` // Of the certificate the CA signs with
+	// LastHeartbeat Last heartbeat received by its upper CA
+	LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
+	ReportedAt    *time.Time `json:"reported_at,omitempty"` // When the CA built its own subtree
+	IssuedHour    int64      `json:"issued_hour"`           // Certificates issued in the last hour
+	IssuedDay     int64      `json:"issued_day"`            // Certificates issued in the last 24 hours
+	Error         string     `json:"error,omitempty"`
+
+	Children []*Node `json:"children"`
+}
+
+// CertStatus Status from the validity of a certificate, expiring within warn
+func CertStatus(notAfter, now time.Time, warn time.Duration) string {
+	switch {
+	case !now.Before(notAfter):
+		return StatusExpired
+	case notAfter.Sub(now) < warn:
+		return StatusExpiring
+	default:
+		return StatusHealthy
+	}
+}
+
+// Walk Visit n and its descendants depth first, children sorted by name
+func (n *Node) Walk(fn func(node *Node, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(node *Node, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Sort Order the children by name, recursively
+func (n *Node) Sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, child := range n.Children {
+		child.Sort()
+	}
+}
+
+// Count Nodes of the tree by status
+func (n *Node) Count() map[string]int {
+	counts := make(map[string]int)
+	n.Walk(func(node *Node, _ int) { counts[node.Status]++ })
+	return counts
+}
+
+var statusColors = map[string]string{
+	StatusHealthy:     "palegreen",
+	StatusExpiring:    "gold",
+	StatusExpired:     "tomato",
+	StatusRevoked:     "tomato",
+	StatusUnreachable: "lightgray",
+	StatusUnknown:     "white",
+}
+
+// WriteDOT Render the tree as a Graphviz digraph, nodes are colored by status
+func (n *Node) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph zaca {\n")
+	b.WriteString("\trankdir=TB;\n")
+	b.WriteString("\tnode [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	ids := make(map[*Node]string)
+	n.Walk(func(node *Node, _ int) {
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[node] = id
+		label := []string{node.Name, node.Role + ", " + node.Status}
+		if node.NotAfter != nil {
+			label = append(label, "expires "+node.NotAfter.UTC().Format("2006-01-02"))
+		}
+		if node.LastHeartbeat != nil {
+			label = append(label, "seen "+node.LastHeartbeat.UTC().Format(time.RFC3339))
+		}
+		label = append(label, fmt.Sprintf("issued %d/h, %d/24h", node.IssuedHour, node.IssuedDay))
+		color, ok := statusColors[node.Status]
+		if !ok {
+			color = "white"
+		}
+		penwidth := 1
+		if node.Current {
+			penwidth = 3
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s, fillcolor=%s, penwidth=%d];\n", id, quote(strings.Join(label, "\n")), color, penwidth)
+	})
+	n.Walk(func(node *Node, _ int) {
+		for _, child := range node.Children {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", ids[node], ids[child])
+		}
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote DOT string literal
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
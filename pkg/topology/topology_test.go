@@ -0,0 +1,73 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topology
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertStatus(t *testing.T) {
+	now := time.Now()
+	cases := map[time.Duration]string{
+		-time.Hour:          StatusExpired,
+		24 * time.Hour:      StatusExpiring,
+		90 * 24 * time.Hour: StatusHealthy,
+	}
+	for d, want := range cases {
+		if got := CertStatus(now.Add(d), now, 30*24*time.Hour); got != want {
+			t.Errorf("%v: got %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	root := &Node{Name: "root", Role: RoleRoot, Status: StatusHealthy, Current: true, NotAfter: &expiry, Children: []*Node{
+		{Name: "site-b", Role: RoleIntermediate, Status: StatusUnreachable},
+		{Name: "site-a", Role: RoleIntermediate, Status: StatusHealthy, IssuedHour: 3, Children: []*Node{
+			{Name: `edge "1"`, Role: RoleIntermediate, Status: StatusHealthy},
+		}},
+	}}
+	root.Sort()
+
+	var visited []string
+	root.Walk(func(node *Node, depth int) {
+		visited = append(visited, strings.Repeat("-", depth)+node.Name)
+	})
+	if got := strings.Join(visited, ","); got != `root,-site-a,--edge "1",-site-b` {
+		t.Errorf("unexpected walk %s", got)
+	}
+	if counts := root.Count(); counts[StatusHealthy] != 3 || counts[StatusUnreachable] != 1 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+
+	var b strings.Builder
+	if err := root.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, want := range []string{
+		"digraph zaca {",
+		`n0 [label="root\nroot, healthy\nexpires 2030-01-02\nissued 0/h, 0/24h", fillcolor=palegreen, penwidth=3];`,
+		`label="edge \"1\"\n`,
+		"n0 -> n1;", "n1 -> n2;", "n0 -> n3;",
+		"fillcolor=lightgray",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output misses %s:\n%s", want, dot)
+		}
+	}
+}
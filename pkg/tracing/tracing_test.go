@@ -0,0 +1,72 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStdoutExport(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Init(Config{Service: "zaca-test", Exporter: ExporterStdout, Writer: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := Start(context.Background(), "sign")
+	_, child := Start(ctx, "upperca")
+	End(child, errors.New("upstream down"))
+	End(span, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"Name": "sign"`, `"Name": "upperca"`, "upstream down", "zaca-test"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exported spans lack %s", want)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	// A subordinate injects, the upper CA extracts and continues the same trace
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if !strings.HasPrefix(header.Get("traceparent"), "00-01020300000000000000000000000000-0405060000000000-01") {
+		t.Fatalf("unexpected traceparent %q", header.Get("traceparent"))
+	}
+
+	remote := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	got := trace.SpanContextFromContext(remote)
+	if got.TraceID() != parent.TraceID() || !got.IsRemote() {
+		t.Errorf("trace not continued: %v", got.TraceID())
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package transcript Signed records of key ceremonies.
+// A record is a JWS signed by the key the ceremony operated, it names the operators and
+// the SHA-256 of every input and output, and links to the previous record by its hash.
+package transcript
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ztalab/ZACA/pkg/auditchain"
+	"github.com/ztalab/ZACA/pkg/signature"
+)
+
+// Genesis Previous hash of the first record
+const Genesis = auditchain.Genesis
+
+// Record One ceremony operation
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Operation string    `json:"operation"`
+	Time      time.Time `json:"time"`
+	Operators []string  `json:"operators"`
+	Host      string    `json:"host,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	// Hex SHA-256 of the files read and written, by name
+	Inputs  map[string]string `json:"inputs,omitempty"`
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Subject, serial numbers, profile and the like
+	Details map[string]interface{} `json:"details,omitempty"`
+	// Hash of the previous signed record, Genesis for the first
+	Previous string `json:"previous"`
+}
+
+// Digest hex SHA-256 of data
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Type "typ" header of the records
+const Type = "zaca-ceremony+jws"
+
+// Sign Compact JWS of the record
+func Sign(record *Record, key crypto.Signer) (string, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return signature.NewSigner(key).SignJWSWithType(payload, Type)
+}
+
+// Verify Record of a compact JWS signed by pub
+func Verify(signed string, pub crypto.PublicKey) (*Record, error) {
+	payload, err := signature.NewVerifier(pub).VerifyJWS(signed)
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// VerifyChain Verify records in order, each must be signed by pub and link to the one before
+func VerifyChain(signed []string, pub crypto.PublicKey) ([]*Record, error) {
+	records := make([]*Record, 0, len(signed))
+	prev := Genesis
+	for i, s := range signed {
+		record, err := Verify(s, pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "record %d", i+1)
+		}
+		if record.Seq != uint64(i+1) {
+			return nil, errors.Errorf("record %d has seq %d", i+1, record.Seq)
+		}
+		if record.Previous != prev {
+			return nil, errors.Errorf("record %d does not link to the record before it", i+1)
+		}
+		records = append(records, record)
+		prev = Digest([]byte(s))
+	}
+	return records, nil
+}
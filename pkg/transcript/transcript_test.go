@@ -0,0 +1,104 @@
+/*
+Copyright 2022-present The Ztalab Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transcript
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ztalab/ZACA/pkg/signature"
+)
+
+func TestSignVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &Record{
+		Seq:       1,
+		Operation: "init",
+		Time:      time.Now().UTC().Truncate(time.Second),
+		Operators: []string{"alice", "bob"},
+		Outputs:   map[string]string{"root.pem": Digest([]byte("cert"))},
+		Previous:  Genesis,
+	}
+
+	signed, err := Sign(record, rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Verify(signed, rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Operation != "init" || len(got.Operators) != 2 || got.Outputs["root.pem"] != record.Outputs["root.pem"] {
+		t.Fatalf("Verify() = %+v", got)
+	}
+	if _, err := Verify(signed, ecKey.Public()); err == nil {
+		t.Fatal("verified with another key")
+	}
+
+	signed, err = Sign(record, ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(signed, ecKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records of earlier releases are signed with RS256 by RSA keys
+	payload, _ := json.Marshal(record)
+	signed, err = signature.NewSignerWithAlgorithm(rsaKey, signature.RS256).SignJWSWithType(payload, Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(signed, rsaKey.Public()); err != nil {
+		t.Fatalf("RS256 record: %v", err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var chain []string
+	prev := Genesis
+	for i, op := range []string{"init", "sign-csr", "gencrl"} {
+		signed, err := Sign(&Record{Seq: uint64(i + 1), Operation: op, Previous: prev}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, signed)
+		prev = Digest([]byte(signed))
+	}
+	records, err := VerifyChain(chain, key.Public())
+	if err != nil || len(records) != 3 {
+		t.Fatalf("VerifyChain() = %d records, %v", len(records), err)
+	}
+
+	// A removed record breaks the link of the next one
+	if _, err := VerifyChain([]string{chain[0], chain[2]}, key.Public()); err == nil {
+		t.Fatal("gap not detected")
+	}
+	if _, err := VerifyChain([]string{chain[1], chain[0]}, key.Public()); err == nil {
+		t.Fatal("reordering not detected")
+	}
+}
@@ -14,7 +14,11 @@ limitations under the License.
 package vaultsecret
 
 import (
+	"context"
 	"github.com/ztalab/ZACA/pkg/logger"
+	"github.com/ztalab/ZACA/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"strings"
 
 	vaultAPI "github.com/hashicorp/vault/api"
@@ -25,24 +29,40 @@ const (
 	StorePEMPath = "pem"
 	StoreKeyPath = "key"
 
-	CALocalStoreKey = "local_store"
-	CATructCertsKey = "trust_certs"
+	CALocalStoreKey   = "local_store"
+	CAPendingStoreKey = "pending_store"
+	CATructCertsKey   = "trust_certs"
 )
 
 // VaultSecret ...
 type VaultSecret struct {
 	cli    *vaultAPI.Client
 	prefix string
+	ctx    context.Context
 }
 
 // NewVaultSecret ...
 func NewVaultSecret(cli *vaultAPI.Client, prefix string) *VaultSecret {
-	return &VaultSecret{cli: cli, prefix: strings.TrimSuffix(prefix, "/") + "/"}
+	return &VaultSecret{cli: cli, prefix: strings.TrimSuffix(prefix, "/") + "/", ctx: context.Background()}
+}
+
+// WithContext Copy whose calls are traced as children of the span in ctx
+func (v *VaultSecret) WithContext(ctx context.Context) *VaultSecret {
+	c := *v
+	c.ctx = ctx
+	return &c
+}
+
+func (v *VaultSecret) span(op, sn string) trace.Span {
+	_, span := tracing.Start(v.ctx, "vault."+op, attribute.String("vault.key", sn))
+	return span
 }
 
 // StoreCertPEM ...
-func (v *VaultSecret) StoreCertPEM(sn string, pem string) error {
-	_, err := v.cli.Logical().Write(v.prefix+"data/"+StorePEMPath+"/"+sn, map[string]interface{}{
+func (v *VaultSecret) StoreCertPEM(sn string, pem string) (err error) {
+	span := v.span("store", sn)
+	defer func() { tracing.End(span, err) }()
+	_, err = v.cli.Logical().Write(v.prefix+"data/"+StorePEMPath+"/"+sn, map[string]interface{}{
 		"data": map[string]interface{}{
 			"pem": pem,
 		},
@@ -54,8 +74,10 @@ func (v *VaultSecret) StoreCertPEM(sn string, pem string) error {
 }
 
 // StoreCertPEMKey ...
-func (v *VaultSecret) StoreCertPEMKey(sn string, pem string, key string) error {
-	_, err := v.cli.Logical().Write(v.prefix+"data/"+StorePEMPath+"/"+sn, map[string]interface{}{
+func (v *VaultSecret) StoreCertPEMKey(sn string, pem string, key string) (err error) {
+	span := v.span("store", sn)
+	defer func() { tracing.End(span, err) }()
+	_, err = v.cli.Logical().Write(v.prefix+"data/"+StorePEMPath+"/"+sn, map[string]interface{}{
 		"data": map[string]interface{}{
 			"pem": pem,
 			"key": key,
@@ -68,7 +90,9 @@ func (v *VaultSecret) StoreCertPEMKey(sn string, pem string, key string) error {
 }
 
 // GetCertPEM ...
-func (v *VaultSecret) GetCertPEM(sn string) (*string, error) {
+func (v *VaultSecret) GetCertPEM(sn string) (_ *string, err error) {
+	span := v.span("get", sn)
+	defer func() { tracing.End(span, err) }()
 	data, err := v.cli.Logical().Read(v.prefix + "data/" + StorePEMPath + "/" + sn)
 	if err != nil {
 		return nil, err
@@ -81,7 +105,9 @@ func (v *VaultSecret) GetCertPEM(sn string) (*string, error) {
 }
 
 // GetCertPEMKey ...
-func (v *VaultSecret) GetCertPEMKey(sn string) (*string, *string, error) {
+func (v *VaultSecret) GetCertPEMKey(sn string) (_ *string, _ *string, err error) {
+	span := v.span("get", sn)
+	defer func() { tracing.End(span, err) }()
 	data, err := v.cli.Logical().Read(v.prefix + "data/" + StorePEMPath + "/" + sn)
 	if err != nil {
 		return nil, nil, err
@@ -97,8 +123,10 @@ func (v *VaultSecret) GetCertPEMKey(sn string) (*string, *string, error) {
 }
 
 // DeleteCertPEM ...
-func (v *VaultSecret) DeleteCertPEM(sn string) error {
-	_, err := v.cli.Logical().Delete(v.prefix + "data/" + StorePEMPath + "/" + sn)
+func (v *VaultSecret) DeleteCertPEM(sn string) (err error) {
+	span := v.span("delete", sn)
+	defer func() { tracing.End(span, err) }()
+	_, err = v.cli.Logical().Delete(v.prefix + "data/" + StorePEMPath + "/" + sn)
 	if err != nil {
 		return err
 	}